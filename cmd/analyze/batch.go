@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/package-url/packageurl-go"
+
+	"github.com/ossf/package-analysis/internal/analysis"
+	"github.com/ossf/package-analysis/internal/log"
+	"github.com/ossf/package-analysis/internal/pkgmanager"
+	"github.com/ossf/package-analysis/internal/resultstore"
+	"github.com/ossf/package-analysis/internal/sandbox"
+	"github.com/ossf/package-analysis/internal/worker"
+)
+
+// imagesPulled is set once the sandbox images have been pulled for a batch of
+// packages, so that each analysis run does not pull them again.
+var imagesPulled bool
+
+// batchItem is a single package version to analyze from a list of purls,
+// along with the outcome of its analysis.
+type batchItem struct {
+	// purl is the line of the purl list that the package was resolved from.
+	purl string
+	pkg  *pkgmanager.Pkg
+	// err is set if the purl could not be parsed or resolved to a package.
+	err      error
+	statuses map[analysis.Mode]string
+}
+
+// readPurls returns the purls listed in the given file, or stdin if path is "-".
+// Blank lines and lines starting with '#' are ignored.
+func readPurls(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var purls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		purls = append(purls, line)
+	}
+
+	return purls, scanner.Err()
+}
+
+// resolveBatch resolves each purl to the package versions it refers to. A purl
+// which cannot be resolved results in a single item with a non-nil error.
+func resolveBatch(ctx context.Context, purls []string) []batchItem {
+	var items []batchItem
+	for _, line := range purls {
+		purl, err := packageurl.FromString(line)
+		if err != nil {
+			items = append(items, batchItem{purl: line, err: err})
+			continue
+		}
+
+		pkgs, err := worker.ResolvePurls(ctx, purl)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error resolving package", "purl", line, "error", err)
+			items = append(items, batchItem{purl: line, err: err})
+			continue
+		}
+
+		for _, pkg := range pkgs {
+			items = append(items, batchItem{purl: line, pkg: pkg})
+		}
+	}
+	return items
+}
+
+// pullImages pulls the sandbox images needed for the given analysis modes.
+func pullImages(ctx context.Context, runMode map[analysis.Mode]bool) error {
+	if runMode[analysis.Static] {
+		if err := sandbox.Pull(ctx, staticSandboxOptions()...); err != nil {
+			return err
+		}
+	}
+	if runMode[analysis.Dynamic] {
+		if err := sandbox.Pull(ctx, dynamicSandboxOptions()...); err != nil {
+			return err
+		}
+	}
+
+	imagesPulled = true
+	return nil
+}
+
+/*
+runBatch analyzes every package listed in the purl file given by the -purls flag,
+running up to -parallel analyses at the same time. Unless -force is used, analysis
+modes whose results were already saved to the result stores by the same
+-pipeline-version (or -image-tag) are skipped.
+
+A summary of the status of each package is printed once all packages are analyzed.
+An error is returned if any package could not be resolved or analyzed.
+*/
+func runBatch(ctx context.Context, runMode map[analysis.Mode]bool) error {
+	purls, err := readPurls(*purlsFile)
+	if err != nil {
+		return fmt.Errorf("failed to read purls: %w", err)
+	}
+
+	items := resolveBatch(ctx, purls)
+	slog.InfoContext(ctx, "Resolved purls", "purl_count", len(purls), "package_count", len(items))
+
+	if !*noPull && len(runMode) > 0 {
+		if err := pullImages(ctx, runMode); err != nil {
+			return err
+		}
+	}
+
+	version := *pipelineVersion
+	if version == "" {
+		version = *imageTag
+	}
+	if version == "" && !*force {
+		slog.WarnContext(ctx, "No -pipeline-version or -image-tag set, packages will be analyzed even if their results already exist")
+	}
+
+	// Results for different packages must not overwrite each other.
+	resultStores := makeResultStores(resultstore.ConstructPath(), resultstore.PipelineVersion(version))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < *parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each goroutine needs its own copy, as ResultStores records whether
+			// the analyzed package has been saved.
+			rs := resultStores
+			for i := range jobs {
+				item := &items[i]
+				itemCtx := log.ContextWithAttrs(ctx, slog.Any("ecosystem", item.pkg.Ecosystem()))
+				item.statuses = analyzePackage(itemCtx, item.pkg, runMode, &rs, !*force)
+			}
+		}()
+	}
+
+	for i := range items {
+		if items[i].err == nil {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := printSummary(os.Stdout, items, runMode)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packages failed", failed, len(items))
+	}
+	return nil
+}
+
+// statusFailed returns true if the status of an analysis mode indicates that the
+// package could not be analyzed or its results could not be saved.
+func statusFailed(status string) bool {
+	return status == statusRunError || status == statusUploadError
+}
+
+// printSummary prints a table of the status of each analysis mode for each package,
+// in the order the packages were listed. It returns the number of packages which
+// failed to be resolved or analyzed.
+func printSummary(w io.Writer, items []batchItem, runMode map[analysis.Mode]bool) int {
+	var modes []analysis.Mode
+	for _, mode := range analysis.AllModes() {
+		if runMode[mode] {
+			modes = append(modes, mode)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "PURL\tPACKAGE\tVERSION")
+	for _, mode := range modes {
+		fmt.Fprintf(tw, "\t%s", strings.ToUpper(string(mode)))
+	}
+	fmt.Fprintln(tw, "\tERROR")
+
+	failed := 0
+	for _, item := range items {
+		if item.err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\t-\t-", item.purl)
+			for range modes {
+				fmt.Fprint(tw, "\t-")
+			}
+			fmt.Fprintf(tw, "\t%v\n", item.err)
+			continue
+		}
+
+		itemFailed := false
+		fmt.Fprintf(tw, "%s\t%s\t%s", item.purl, item.pkg.Name(), item.pkg.Version())
+		for _, mode := range modes {
+			status := item.statuses[mode]
+			itemFailed = itemFailed || statusFailed(status)
+			fmt.Fprintf(tw, "\t%s", status)
+		}
+		fmt.Fprintln(tw)
+		if itemFailed {
+			failed++
+		}
+	}
+
+	tw.Flush()
+	return failed
+}
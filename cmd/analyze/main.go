@@ -14,7 +14,9 @@ import (
 	"golang.org/x/exp/slices"
 
 	"github.com/ossf/package-analysis/internal/analysis"
+	"github.com/ossf/package-analysis/internal/dynamicanalysis"
 	"github.com/ossf/package-analysis/internal/featureflags"
+	"github.com/ossf/package-analysis/internal/honeytoken"
 	"github.com/ossf/package-analysis/internal/log"
 	"github.com/ossf/package-analysis/internal/pkgmanager"
 	"github.com/ossf/package-analysis/internal/resultstore"
@@ -23,6 +25,7 @@ import (
 	"github.com/ossf/package-analysis/internal/useragent"
 	"github.com/ossf/package-analysis/internal/utils"
 	"github.com/ossf/package-analysis/internal/worker"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
@@ -30,7 +33,10 @@ var (
 	pkgName            = flag.String("package", "", "package name")
 	localPkg           = flag.String("local", "", "local package path")
 	ecosystem          pkgecosystem.Ecosystem
-	version            = flag.String("version", "", "version")
+	archiveType        pkgmanager.ArchiveType
+	clockShift         dynamicanalysis.ClockShift
+	honeytokenKinds    []honeytoken.Kind
+	version            = flag.String("version", "", "version, or a version spec (e.g. a range like ^1.2, a dist-tag, or last:30d for versions published in the last 30 days), in which case each matching version is analyzed")
 	noPull             = flag.Bool("nopull", false, "disables pulling down sandbox images")
 	imageTag           = flag.String("image-tag", "", "set image tag for analysis sandboxes")
 	dynamicBucket      = flag.String("dynamic-bucket", "", "bucket path for uploading dynamic analysis results")
@@ -38,15 +44,31 @@ var (
 	executionLogBucket = flag.String("execution-log-bucket", "", "bucket path for uploading execution log (dynamic analysis)")
 	fileWritesBucket   = flag.String("file-writes-bucket", "", "bucket path for uploading file writes data (dynamic analysis)")
 	analyzedPkgBucket  = flag.String("analyzed-pkg-bucket", "", "bucket path for uploading analyzed packages")
+	metadataBucket     = flag.String("metadata-bucket", "", "bucket path for uploading package metadata from the registry")
+	dependenciesBucket = flag.String("dependencies-bucket", "", "bucket path for uploading installed dependencies and their attributed behaviour (dynamic analysis)")
+	honeytokensBucket  = flag.String("honeytokens-bucket", "", "bucket path for uploading the honeytokens accessed during dynamic analysis")
+	comparisonBucket   = flag.String("profile-comparison-bucket", "", "bucket path for uploading the comparison of results from different -profile values (dynamic analysis)")
 	offline            = flag.Bool("offline", false, "disables sandbox network access")
 	customSandbox      = flag.String("sandbox-image", "", "override default dynamic analysis sandbox with custom image")
 	customAnalysisCmd  = flag.String("analysis-command", "", "override default dynamic analysis script path (use with custom sandbox image)")
 	listModes          = flag.Bool("list-modes", false, "prints out a list of available analysis modes")
 	features           = flag.String("features", "", "override features that are enabled/disabled by default")
+	registryURLs       = flag.String("registry-urls", os.Getenv(pkgmanager.RegistryURLsEnvVar), "comma-separated list of ecosystem=URL pairs, overriding the default registry URL for each ecosystem (default $"+pkgmanager.RegistryURLsEnvVar+")")
+	registryAuthFile   = flag.String("registry-auth-file", os.Getenv(pkgmanager.RegistryAuthFileEnvVar), "JSON file containing registry credentials for each ecosystem (default $"+pkgmanager.RegistryAuthFileEnvVar+")")
 	listFeatures       = flag.Bool("list-features", false, "list available features that can be toggled")
+	purlsFile          = flag.String("purls", "", "file containing a list of package URLs (purls) to analyze, one per line, or - to read them from stdin. Cannot be used with -ecosystem, -package, -version or -local")
+	parallel           = flag.Int("parallel", 1, "number of packages from -purls to analyze at the same time")
+	force              = flag.Bool("force", false, "analyze packages from -purls even if their results already exist in the result buckets")
+	pipelineVersion    = flag.String("pipeline-version", "", "version of the analysis pipeline recorded with results; packages from -purls whose results were saved by the same version are skipped (default -image-tag)")
+	isolatePhases      = flag.Bool("isolate-phases", false, "run each dynamic analysis phase after install in a fresh sandbox, started from a snapshot taken after install")
+	shiftClock         = flag.String("shift-clock", "", "run each dynamic analysis phase after install with the clock shifted by an offset (e.g. +30d, +1y) or to a date (e.g. 2030-01-01), optionally sped up (e.g. '+30d x10')")
+	honeytokens        = flag.String("honeytokens", "all", "comma-separated list of kinds of honeytokens (bait credentials) to plant for dynamic analysis, or all or none. Available: "+honeytokenKindNames())
 	help               = flag.Bool("help", false, "print help on available options")
 	analysisMode       = utils.CommaSeparatedFlags("mode", []string{"static", "dynamic"},
 		"list of analysis modes to run, separated by commas. Use -list-modes to see available options")
+	profiles = utils.CommaSeparatedFlags("profile", nil,
+		"list of environment profiles to run dynamic analysis in, separated by commas, each in a separate run. Available: "+
+			strings.Join(worker.EnvironmentProfileNames(), ", "))
 )
 
 // usageError wraps an error, to signal that the error arises from incorrect user input.
@@ -58,23 +80,35 @@ func usagef(format string, args ...any) error {
 	return usageError{fmt.Errorf(format, args...)}
 }
 
-func makeResultStores() worker.ResultStores {
+func makeResultStores(options ...resultstore.Option) worker.ResultStores {
 	rs := worker.ResultStores{}
 
 	if *analyzedPkgBucket != "" {
-		rs.AnalyzedPackage = resultstore.New(*analyzedPkgBucket)
+		rs.AnalyzedPackage = resultstore.New(*analyzedPkgBucket, options...)
+	}
+	if *dependenciesBucket != "" {
+		rs.Dependencies = resultstore.New(*dependenciesBucket, options...)
 	}
 	if *dynamicBucket != "" {
-		rs.DynamicAnalysis = resultstore.New(*dynamicBucket)
+		rs.DynamicAnalysis = resultstore.New(*dynamicBucket, options...)
 	}
 	if *executionLogBucket != "" {
-		rs.ExecutionLog = resultstore.New(*executionLogBucket)
+		rs.ExecutionLog = resultstore.New(*executionLogBucket, options...)
 	}
 	if *fileWritesBucket != "" {
-		rs.FileWrites = resultstore.New(*fileWritesBucket)
+		rs.FileWrites = resultstore.New(*fileWritesBucket, options...)
+	}
+	if *honeytokensBucket != "" {
+		rs.Honeytokens = resultstore.New(*honeytokensBucket, options...)
+	}
+	if *metadataBucket != "" {
+		rs.Metadata = resultstore.New(*metadataBucket, options...)
+	}
+	if *comparisonBucket != "" {
+		rs.ProfileComparison = resultstore.New(*comparisonBucket, options...)
 	}
 	if *staticBucket != "" {
-		rs.StaticAnalysis = resultstore.New(*staticBucket)
+		rs.StaticAnalysis = resultstore.New(*staticBucket, options...)
 	}
 
 	return rs
@@ -113,38 +147,135 @@ func printFeatureFlags() {
 //
 //  1. The image tag is always passed through. An empty tag is the same as "latest".
 //  2. A local package is mapped into the sandbox if applicable.
-//  3. Image pulling is disabled if the "-nopull" command-line flag was used.
+//  3. Image pulling is disabled if the "-nopull" command-line flag was used, or if
+//     the images have already been pulled for a batch of packages.
+//  4. Sandboxes may run concurrently if more than one package is analyzed at a time.
 func makeSandboxOptions() []sandbox.Option {
 	sbOpts := []sandbox.Option{sandbox.Tag(*imageTag)}
 
 	if *localPkg != "" {
 		sbOpts = append(sbOpts, sandbox.Copy(*localPkg, *localPkg))
 	}
-	if *noPull {
+	if *noPull || imagesPulled {
 		sbOpts = append(sbOpts, sandbox.NoPull())
 	}
 	if *offline {
 		sbOpts = append(sbOpts, sandbox.Offline())
 	}
+	if *parallel > 1 {
+		sbOpts = append(sbOpts, sandbox.Concurrent())
+	}
 
 	return sbOpts
 }
 
-func dynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, resultStores *worker.ResultStores) {
-	if !*offline {
-		sandbox.InitNetwork(ctx)
-	}
-
+func dynamicSandboxOptions() []sandbox.Option {
 	sbOpts := append(worker.DynamicSandboxOptions(), makeSandboxOptions()...)
 
 	if *customSandbox != "" {
 		sbOpts = append(sbOpts, sandbox.Image(*customSandbox))
 	}
 
-	result, err := worker.RunDynamicAnalysis(ctx, pkg, sbOpts, *customAnalysisCmd)
+	return sbOpts
+}
+
+func staticSandboxOptions() []sandbox.Option {
+	return append(worker.StaticSandboxOptions(), makeSandboxOptions()...)
+}
+
+// Statuses reported for an analysis mode, in addition to the analysis.Status of the run.
+const (
+	statusRunError    = "error_run"
+	statusUploadError = "error_upload"
+	statusSkipped     = "skipped"
+)
+
+func honeytokenKindNames() string {
+	var names []string
+	for _, kind := range honeytoken.AllKinds() {
+		names = append(names, string(kind))
+	}
+	return strings.Join(names, ", ")
+}
+
+// dynamicAnalysis runs dynamic analysis on the package and saves the results,
+// returning the status of the analysis. If environment profiles are given with
+// -profile, the analysis is run once in each profile, and the results of the
+// profiles are compared.
+func dynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, resultStores *worker.ResultStores) string {
+	var options []worker.DynamicAnalysisOption
+	if *isolatePhases {
+		options = append(options, worker.IsolatePhases())
+	}
+	if !clockShift.IsZero() {
+		options = append(options, worker.ShiftClock(clockShift))
+	}
+	options = append(options, worker.Honeytokens(honeytokenKinds...))
+
+	if len(profiles.Values) == 0 {
+		status, _ := runDynamicAnalysis(ctx, pkg, resultStores, "", options)
+		return status
+	}
+
+	// Each profile's results are saved as a variant named after the profile.
+
+	status := string(analysis.StatusCompleted)
+	results := make(map[string]analysisrun.DynamicAnalysisData)
+	for _, name := range profiles.Values {
+		// names have already been checked
+		profile, _ := worker.NewEnvironmentProfile(name)
+		profileCtx := log.ContextWithAttrs(ctx, slog.String("profile", name))
+		profileStatus, data := runDynamicAnalysis(profileCtx, pkg, resultStores, name, append(slices.Clip(options), worker.WithEnvironmentProfile(profile)))
+		if data != nil {
+			results[name] = *data
+		}
+		if status == string(analysis.StatusCompleted) {
+			status = profileStatus
+		}
+	}
+
+	if len(results) > 1 {
+		comparison := dynamicanalysis.CompareProfiles(results)
+		for _, diff := range comparison.Differences {
+			slog.InfoContext(ctx, "Behaviour not observed in every profile",
+				"profile", diff.Profile,
+				"phase", string(diff.Phase),
+				"files", diff.Files,
+				"sockets", diff.Sockets,
+				"commands", diff.Commands,
+				"hostnames", diff.Hostnames)
+		}
+		if err := worker.SaveProfileComparison(ctx, pkg, resultStores, comparison); err != nil {
+			slog.ErrorContext(ctx, "Upload error", "error", err)
+			return statusUploadError
+		}
+	}
+
+	return status
+}
+
+var (
+	// dynamicAnalysisSlot is held while a dynamic analysis sandbox runs. Packet capture
+	// records all traffic on the shared sandbox network, so when packages from -purls are
+	// analyzed in parallel, only one may be analyzed dynamically at a time, or the
+	// traffic of one package would be recorded against the others.
+	dynamicAnalysisSlot = make(chan struct{}, 1)
+
+	// runDynamicAnalysisInSandbox is replaced in tests.
+	runDynamicAnalysisInSandbox = worker.RunDynamicAnalysis
+)
+
+// runDynamicAnalysis runs dynamic analysis on the package and saves the results, as the
+// results of the given environment profile if it is not empty (see
+// worker.SaveDynamicAnalysisProfileData). It returns the status of the analysis, and the
+// analysis data if the analysis ran.
+func runDynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, resultStores *worker.ResultStores, profile string, options []worker.DynamicAnalysisOption) (string, *analysisrun.DynamicAnalysisData) {
+	dynamicAnalysisSlot <- struct{}{}
+	result, err := runDynamicAnalysisInSandbox(ctx, pkg, dynamicSandboxOptions(), *customAnalysisCmd, options...)
+	<-dynamicAnalysisSlot
 	if err != nil {
 		slog.ErrorContext(ctx, "Dynamic analysis aborted (run error)", "error", err)
-		return
+		return statusRunError, nil
 	}
 
 	// this is only valid if RunDynamicAnalysis() returns nil err
@@ -154,29 +285,36 @@ func dynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, resultStores *wor
 			"status", string(result.LastStatus))
 	}
 
-	if err := worker.SaveDynamicAnalysisData(ctx, pkg, resultStores, result.Data); err != nil {
-		slog.ErrorContext(ctx, "Upload error", "error", err)
+	if profile == "" {
+		err = worker.SaveDynamicAnalysisData(ctx, pkg, resultStores, nil, result.Data)
+	} else {
+		err = worker.SaveDynamicAnalysisProfileData(ctx, pkg, resultStores, profile, result.Data)
 	}
-}
-
-func staticAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, resultStores *worker.ResultStores) {
-	if !*offline {
-		sandbox.InitNetwork(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Upload error", "error", err)
+		return statusUploadError, &result.Data
 	}
 
-	sbOpts := append(worker.StaticSandboxOptions(), makeSandboxOptions()...)
+	return string(result.LastStatus), &result.Data
+}
 
-	data, status, err := worker.RunStaticAnalysis(ctx, pkg, sbOpts, staticanalysis.All)
+// staticAnalysis runs static analysis on the package and saves the results,
+// returning the status of the analysis.
+func staticAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, resultStores *worker.ResultStores) string {
+	data, status, err := worker.RunStaticAnalysis(ctx, pkg, staticSandboxOptions(), archiveType, staticanalysis.All)
 	if err != nil {
 		slog.ErrorContext(ctx, "Static analysis aborted", "error", err)
-		return
+		return statusRunError
 	}
 
 	slog.InfoContext(ctx, "Static analysis completed", "status", string(status))
 
-	if err := worker.SaveStaticAnalysisData(ctx, pkg, resultStores, data); err != nil {
+	if err := worker.SaveStaticAnalysisData(ctx, pkg, resultStores, nil, data); err != nil {
 		slog.ErrorContext(ctx, "Upload error", "error", err)
+		return statusUploadError
 	}
+
+	return string(status)
 }
 
 func run() error {
@@ -184,8 +322,10 @@ func run() error {
 
 	flag.TextVar(&ecosystem, "ecosystem", pkgecosystem.None, "package ecosystem. Available: "+
 		strings.Join(pkgecosystem.SupportedEcosystemsStrings, ", "))
+	flag.TextVar(&archiveType, "archive-type", pkgmanager.DefaultArchive, "type of package archive to use for static analysis, for ecosystems with more than one. Available: default, sdist, wheel, all")
 
 	analysisMode.InitFlag()
+	profiles.InitFlag()
 	flag.Parse()
 
 	http.DefaultTransport = useragent.DefaultRoundTripper(http.DefaultTransport, "")
@@ -194,6 +334,30 @@ func run() error {
 		return usageError{err}
 	}
 
+	if err := pkgmanager.ConfigureRegistries(*registryURLs, *registryAuthFile); err != nil {
+		return usageError{err}
+	}
+
+	for _, name := range profiles.Values {
+		if _, err := worker.NewEnvironmentProfile(name); err != nil {
+			return usageError{err}
+		}
+	}
+
+	if *shiftClock != "" {
+		shift, err := dynamicanalysis.ParseClockShift(*shiftClock)
+		if err != nil {
+			return usageError{err}
+		}
+		clockShift = shift
+	}
+
+	kinds, err := honeytoken.ParseKinds(*honeytokens)
+	if err != nil {
+		return usageError{err}
+	}
+	honeytokenKinds = kinds
+
 	if *help {
 		flag.Usage()
 		return nil
@@ -209,19 +373,29 @@ func run() error {
 		return nil
 	}
 
-	if ecosystem == pkgecosystem.None {
-		flag.Usage()
-		return usagef("missing ecosystem")
-	}
+	var manager *pkgmanager.PkgManager
+	if *purlsFile != "" {
+		if ecosystem != pkgecosystem.None || *pkgName != "" || *version != "" || *localPkg != "" {
+			return usagef("-purls cannot be used with -ecosystem, -package, -version or -local")
+		}
+		if *parallel < 1 {
+			return usagef("-parallel must be at least 1")
+		}
+	} else {
+		if ecosystem == pkgecosystem.None {
+			flag.Usage()
+			return usagef("missing ecosystem")
+		}
 
-	manager := pkgmanager.Manager(ecosystem)
-	if manager == nil {
-		return usagef("unsupported package ecosystem %q", ecosystem)
-	}
+		manager = pkgmanager.Manager(ecosystem)
+		if manager == nil {
+			return usagef("unsupported package ecosystem %q", ecosystem)
+		}
 
-	if *pkgName == "" {
-		flag.Usage()
-		return usagef("missing package name")
+		if *pkgName == "" {
+			flag.Usage()
+			return usagef("missing package name")
+		}
 	}
 
 	runMode := make(map[analysis.Mode]bool)
@@ -234,7 +408,16 @@ func run() error {
 		runMode[mode] = true
 	}
 
-	ctx := log.ContextWithAttrs(context.Background(),
+	ctx := context.Background()
+	if len(runMode) > 0 && !*offline {
+		sandbox.InitNetwork(ctx)
+	}
+
+	if *purlsFile != "" {
+		return runBatch(ctx, runMode)
+	}
+
+	ctx = log.ContextWithAttrs(ctx,
 		slog.Any("ecosystem", ecosystem),
 	)
 
@@ -243,32 +426,85 @@ func run() error {
 		slog.String("requested_version", *version),
 	)
 
-	pkg, err := worker.ResolvePkg(manager, *pkgName, *version, *localPkg)
+	pkgs, err := worker.ResolvePkgs(ctx, manager, *pkgName, *version, *localPkg)
 	if err != nil {
 		slog.ErrorContext(ctx, "Error resolving package", "error", err)
 		return err
 	}
 
+	if len(pkgs) > 1 {
+		slog.InfoContext(ctx, "Resolved version spec", "version_count", len(pkgs))
+	}
+
+	resultStores := makeResultStores()
+	for _, pkg := range pkgs {
+		analyzePackage(ctx, pkg, runMode, &resultStores, false)
+	}
+
+	return nil
+}
+
+/*
+analyzePackage runs the requested analysis modes on a single resolved package, and
+returns the status of each mode. Errors are logged, so that the remaining packages
+are analyzed anyway.
+
+If skipExisting is true, modes (and metadata collection) whose results already exist
+in the result stores are skipped.
+*/
+func analyzePackage(ctx context.Context, pkg *pkgmanager.Pkg, runMode map[analysis.Mode]bool, resultStores *worker.ResultStores, skipExisting bool) map[analysis.Mode]string {
 	ctx = log.ContextWithAttrs(ctx,
 		slog.String("name", pkg.Name()),
 		slog.String("version", pkg.Version()),
 	)
 
 	slog.InfoContext(ctx, "Processing resolved package", "package_path", *localPkg)
-	resultStores := makeResultStores()
+	resultStores.AnalyzedPackageSaved = false
+	statuses := map[analysis.Mode]string{}
+
+	if resultStores.Metadata != nil && !(skipExisting && worker.ResultsExist(ctx, resultStores.Metadata, pkg)) {
+		slog.InfoContext(ctx, "Collecting package metadata")
+		if err := worker.SaveMetadata(ctx, pkg, resultStores); err != nil {
+			slog.ErrorContext(ctx, "Package metadata collection failed", "error", err)
+		}
+	}
 
 	if runMode[analysis.Static] {
-		slog.InfoContext(ctx, "Starting static analysis")
-		staticAnalysis(ctx, pkg, &resultStores)
+		if skipExisting && worker.ResultsExist(ctx, resultStores.StaticAnalysis, pkg) {
+			slog.InfoContext(ctx, "Skipping static analysis, results already exist")
+			statuses[analysis.Static] = statusSkipped
+		} else {
+			slog.InfoContext(ctx, "Starting static analysis")
+			statuses[analysis.Static] = staticAnalysis(ctx, pkg, resultStores)
+		}
 	}
 
 	// dynamicAnalysis() currently panics on error, so it's last
 	if runMode[analysis.Dynamic] {
-		slog.InfoContext(ctx, "Starting dynamic analysis")
-		dynamicAnalysis(ctx, pkg, &resultStores)
+		if skipExisting && dynamicResultsExist(ctx, resultStores, pkg) {
+			slog.InfoContext(ctx, "Skipping dynamic analysis, results already exist")
+			statuses[analysis.Dynamic] = statusSkipped
+		} else {
+			slog.InfoContext(ctx, "Starting dynamic analysis")
+			statuses[analysis.Dynamic] = dynamicAnalysis(ctx, pkg, resultStores)
+		}
 	}
 
-	return nil
+	return statuses
+}
+
+// dynamicResultsExist returns true if the results of dynamic analysis of the package
+// have already been saved, in every profile given by -profile if there are any.
+func dynamicResultsExist(ctx context.Context, resultStores *worker.ResultStores, pkg *pkgmanager.Pkg) bool {
+	if len(profiles.Values) == 0 {
+		return worker.ResultsExist(ctx, resultStores.DynamicAnalysis, pkg)
+	}
+	for _, name := range profiles.Values {
+		if !worker.VariantResultsExist(ctx, resultStores.DynamicAnalysis, pkg, name) {
+			return false
+		}
+	}
+	return true
 }
 
 func main() {
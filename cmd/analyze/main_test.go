@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ossf/package-analysis/internal/analysis"
+	"github.com/ossf/package-analysis/internal/pkgmanager"
+	"github.com/ossf/package-analysis/internal/sandbox"
+	"github.com/ossf/package-analysis/internal/worker"
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+func TestRunDynamicAnalysisSerialized(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	orig := runDynamicAnalysisInSandbox
+	t.Cleanup(func() { runDynamicAnalysisInSandbox = orig })
+	runDynamicAnalysisInSandbox = func(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandbox.Option, analysisCmd string, options ...worker.DynamicAnalysisOption) (worker.DynamicAnalysisResult, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return worker.DynamicAnalysisResult{LastStatus: analysis.StatusCompleted}, nil
+	}
+
+	pkg := pkgmanager.Manager(pkgecosystem.NPM).Package("test", "1.0.0")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := runDynamicAnalysis(context.Background(), pkg, &worker.ResultStores{}, "", nil)
+			if status != string(analysis.StatusCompleted) {
+				t.Errorf("runDynamicAnalysis() status = %v; want %v", status, analysis.StatusCompleted)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := maxRunning.Load(); got != 1 {
+		t.Errorf("dynamic analysis sandboxes running at once = %d; want 1", got)
+	}
+}
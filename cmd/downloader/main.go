@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/package-url/packageurl-go"
 
+	"github.com/ossf/package-analysis/internal/pkgmanager"
 	"github.com/ossf/package-analysis/internal/useragent"
 	"github.com/ossf/package-analysis/internal/worker"
 )
@@ -18,8 +20,10 @@ import (
 // Command-line tool to download a list of package archives, specified by purl
 // See https://github.com/package-url/purl-spec
 var (
-	purlFilePath = flag.String("f", "", "file containing list of package URLs")
-	downloadDir  = flag.String("d", "", "directory to store downloaded tarballs")
+	purlFilePath     = flag.String("f", "", "file containing list of package URLs")
+	downloadDir      = flag.String("d", "", "directory to store downloaded tarballs")
+	registryURLs     = flag.String("registry-urls", os.Getenv(pkgmanager.RegistryURLsEnvVar), "comma-separated list of ecosystem=URL pairs, overriding the default registry URL for each ecosystem (default $"+pkgmanager.RegistryURLsEnvVar+")")
+	registryAuthFile = flag.String("registry-auth-file", os.Getenv(pkgmanager.RegistryAuthFileEnvVar), "JSON file containing registry credentials for each ecosystem (default $"+pkgmanager.RegistryAuthFileEnvVar+")")
 )
 
 // cmdError is a simple string error type, used when command usage
@@ -36,22 +40,27 @@ func newCmdError(message string) error {
 	return &cmdError{message}
 }
 
-func downloadPackage(purl packageurl.PackageURL, dir string) error {
-	pkg, err := worker.ResolvePurl(purl)
+// downloadPackage downloads the package given by the purl. If the purl version is
+// a version spec (e.g. a range), every matching version is downloaded.
+func downloadPackage(ctx context.Context, purl packageurl.PackageURL, dir string) error {
+	pkgs, err := worker.ResolvePurls(ctx, purl)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("[%s] %s@%s", pkg.EcosystemName(), pkg.Name(), pkg.Version())
+	var errs []error
+	for _, pkg := range pkgs {
+		fmt.Printf("[%s] %s@%s", pkg.EcosystemName(), pkg.Name(), pkg.Version())
 
-	if downloadPath, err := pkg.Manager().DownloadArchive(pkg.Name(), pkg.Version(), dir); err != nil {
-		fmt.Println()
-		return err
-	} else {
-		fmt.Printf(" -> %s\n", downloadPath)
+		if downloadPath, err := pkg.Manager().DownloadArchive(ctx, pkg.Name(), pkg.Version(), dir); err != nil {
+			fmt.Println()
+			errs = append(errs, fmt.Errorf("%s: %w", pkg.Version(), err))
+		} else {
+			fmt.Printf(" -> %s\n", downloadPath)
+		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func checkDirectoryExists(path string) error {
@@ -70,7 +79,7 @@ func checkDirectoryExists(path string) error {
 	return nil
 }
 
-func processFileLine(text string) error {
+func processFileLine(ctx context.Context, text string) error {
 	trimmed := strings.TrimSpace(text)
 	if len(trimmed) == 0 || trimmed[0] == '#' {
 		return nil
@@ -78,7 +87,7 @@ func processFileLine(text string) error {
 
 	if purl, err := packageurl.FromString(trimmed); err != nil {
 		return fmt.Errorf("invalid purl '%s': %w", text, err)
-	} else if err := downloadPackage(purl, *downloadDir); err != nil {
+	} else if err := downloadPackage(ctx, purl, *downloadDir); err != nil {
 		return fmt.Errorf("could not download %s: %w", text, err)
 	}
 
@@ -97,6 +106,10 @@ func run() error {
 		*downloadDir = "."
 	}
 
+	if err := pkgmanager.ConfigureRegistries(*registryURLs, *registryAuthFile); err != nil {
+		return newCmdError(err.Error())
+	}
+
 	if err := checkDirectoryExists(*downloadDir); err != nil {
 		return err
 	}
@@ -108,9 +121,10 @@ func run() error {
 
 	defer purlFile.Close()
 
+	ctx := context.Background()
 	scanner := bufio.NewScanner(purlFile)
 	for line := 1; scanner.Scan(); line += 1 {
-		if err := processFileLine(scanner.Text()); err != nil {
+		if err := processFileLine(ctx, scanner.Text()); err != nil {
 			fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
 		}
 	}
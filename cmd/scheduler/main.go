@@ -55,6 +55,7 @@ var supportedPkgManagers = map[string]*ManagerConfig{
 		ExcludeVersions: []*regexp.Regexp{regexp.MustCompile(`^dev-`), regexp.MustCompile(`\.x-dev$`)},
 	},
 	"crates": {Ecosystem: pkgecosystem.CratesIO},
+	"nuget":  {Ecosystem: pkgecosystem.NuGet},
 }
 
 func main() {
@@ -2,8 +2,13 @@ package main
 
 import (
 	"log/slog"
+	"net/url"
 	"os"
+	"strconv"
 
+	"github.com/ossf/package-analysis/internal/archivecache"
+	"github.com/ossf/package-analysis/internal/dynamicanalysis"
+	"github.com/ossf/package-analysis/internal/pkgmanager"
 	"github.com/ossf/package-analysis/internal/resultstore"
 	"github.com/ossf/package-analysis/internal/worker"
 )
@@ -17,6 +22,16 @@ type resultBucketPaths struct {
 	staticAnalysis  string
 }
 
+// defaultArchiveCacheMaxSize is the default maximum size of the archive cache, in bytes.
+const defaultArchiveCacheMaxSize = 10 << 30
+
+// archiveCacheSpec configures the local cache of downloaded package archives.
+type archiveCacheSpec struct {
+	// dir is the cache directory. The cache is disabled if it is empty.
+	dir     string
+	maxSize int64
+}
+
 type sandboxImageSpec struct {
 	tag    string
 	noPull bool
@@ -25,6 +40,12 @@ type sandboxImageSpec struct {
 type config struct {
 	imageSpec sandboxImageSpec
 
+	archiveCache archiveCacheSpec
+
+	// archives is the archive cache, created from archiveCache. It is nil if the
+	// cache is disabled.
+	archives *archivecache.Cache
+
 	resultStores *worker.ResultStores
 
 	subURL               string
@@ -32,6 +53,18 @@ type config struct {
 	notificationTopicURL string
 
 	userAgentExtra string
+
+	registryURLs     string
+	registryAuthFile string
+
+	// pipelineVersion identifies the version of the analysis pipeline which saves results.
+	// Analysis is skipped for packages whose results were saved by the same version. If it
+	// is empty, analysis is never skipped.
+	pipelineVersion string
+
+	// futureClockShift is the clock shift for the second, "future" run of dynamic analysis
+	// for packages which request it. Future runs are disabled if it is zero.
+	futureClockShift dynamicanalysis.ClockShift
 }
 
 func (c *config) LogValue() slog.Value {
@@ -43,38 +76,124 @@ func (c *config) LogValue() slog.Value {
 		slog.String("file_write_results_store", c.resultStores.FileWrites.String()),
 		slog.String("analyzed_packages_store", c.resultStores.AnalyzedPackage.String()),
 		slog.String("execution_log_store", c.resultStores.ExecutionLog.String()),
+		slog.String("dependencies_store", c.resultStores.Dependencies.String()),
+		slog.String("honeytokens_store", c.resultStores.Honeytokens.String()),
+		slog.String("metadata_store", c.resultStores.Metadata.String()),
+		slog.String("archive_cache_dir", c.archiveCache.dir),
+		slog.Int64("archive_cache_max_size", c.archiveCache.maxSize),
 		slog.String("image_tag", c.imageSpec.tag),
 		slog.Bool("image_nopull", c.imageSpec.noPull),
 		slog.String("topic_notification", c.notificationTopicURL),
 		slog.String("user_agent_extra", c.userAgentExtra),
+		slog.String("registry_urls", redactedRegistryURLs(c.registryURLs)),
+		slog.String("registry_auth_file", c.registryAuthFile),
+		slog.String("pipeline_version", c.pipelineVersion),
+		slog.String("future_clock_shift", futureClockShiftString(c.futureClockShift)),
 	)
 }
 
-func resultStoreForEnv(key string) *resultstore.ResultStore {
+func resultStoreForEnv(key, pipelineVersion string) *resultstore.ResultStore {
 	val := os.Getenv(key)
 	if val == "" {
 		return nil
 	}
-	return resultstore.New(val, resultstore.ConstructPath())
+	return resultstore.New(val, resultstore.ConstructPath(), resultstore.PipelineVersion(pipelineVersion))
+}
+
+// archiveCacheMaxSizeForEnv returns the maximum archive cache size in bytes set by the
+// given environment variable, or the default if it is unset or invalid.
+func archiveCacheMaxSizeForEnv(key string) int64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultArchiveCacheMaxSize
+	}
+	size, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		slog.Warn("Invalid archive cache size, using default", "env", key, "value", val, "default", defaultArchiveCacheMaxSize)
+		return defaultArchiveCacheMaxSize
+	}
+	return size
+}
+
+// redactedRegistryURLs returns the registry URLs in the given OSSF_REGISTRY_URLS value
+// with the passwords of any embedded credentials redacted, so that it can be logged.
+func redactedRegistryURLs(registryURLs string) string {
+	urls, err := pkgmanager.ParseRegistryURLs(registryURLs)
+	if err != nil {
+		return "invalid"
+	}
+	for e, registryURL := range urls {
+		u, err := url.Parse(registryURL)
+		if err != nil {
+			urls[e] = "invalid"
+			continue
+		}
+		urls[e] = u.Redacted()
+	}
+	return pkgmanager.FormatRegistryURLs(urls)
+}
+
+func futureClockShiftString(shift dynamicanalysis.ClockShift) string {
+	if shift.IsZero() {
+		return ""
+	}
+	return shift.String()
+}
+
+// clockShiftForEnv returns the clock shift set by the given environment variable, or a
+// zero shift if it is unset or invalid.
+func clockShiftForEnv(key string) dynamicanalysis.ClockShift {
+	val := os.Getenv(key)
+	if val == "" {
+		return dynamicanalysis.ClockShift{}
+	}
+	shift, err := dynamicanalysis.ParseClockShift(val)
+	if err != nil {
+		slog.Warn("Invalid clock shift, disabling future runs", "env", key, "value", val, "error", err)
+		return dynamicanalysis.ClockShift{}
+	}
+	return shift
 }
 
 func configFromEnv() *config {
+	imageTag := os.Getenv("OSSF_SANDBOX_IMAGE_TAG")
+
+	// the sandbox image tag identifies the version of the analysis if no version is given
+	pipelineVersion := os.Getenv("OSSF_MALWARE_ANALYSIS_PIPELINE_VERSION")
+	if pipelineVersion == "" {
+		pipelineVersion = imageTag
+	}
+
 	return &config{
 		imageSpec: sandboxImageSpec{
-			tag:    os.Getenv("OSSF_SANDBOX_IMAGE_TAG"),
+			tag:    imageTag,
 			noPull: os.Getenv("OSSF_SANDBOX_NOPULL") != "",
 		},
+		archiveCache: archiveCacheSpec{
+			dir:     os.Getenv("OSSF_MALWARE_ARCHIVE_CACHE_DIR"),
+			maxSize: archiveCacheMaxSizeForEnv("OSSF_MALWARE_ARCHIVE_CACHE_MAX_SIZE"),
+		},
 		resultStores: &worker.ResultStores{
-			AnalyzedPackage: resultStoreForEnv("OSSF_MALWARE_ANALYZED_PACKAGES"),
-			DynamicAnalysis: resultStoreForEnv("OSSF_MALWARE_ANALYSIS_RESULTS"),
-			ExecutionLog:    resultStoreForEnv("OSSF_MALWARE_ANALYSIS_EXECUTION_LOGS"),
-			FileWrites:      resultStoreForEnv("OSSF_MALWARE_ANALYSIS_FILE_WRITE_RESULTS"),
-			StaticAnalysis:  resultStoreForEnv("OSSF_MALWARE_STATIC_ANALYSIS_RESULTS"),
+			AnalyzedPackage: resultStoreForEnv("OSSF_MALWARE_ANALYZED_PACKAGES", pipelineVersion),
+			Dependencies:    resultStoreForEnv("OSSF_MALWARE_ANALYSIS_DEPENDENCIES_RESULTS", pipelineVersion),
+			DynamicAnalysis: resultStoreForEnv("OSSF_MALWARE_ANALYSIS_RESULTS", pipelineVersion),
+			ExecutionLog:    resultStoreForEnv("OSSF_MALWARE_ANALYSIS_EXECUTION_LOGS", pipelineVersion),
+			FileWrites:      resultStoreForEnv("OSSF_MALWARE_ANALYSIS_FILE_WRITE_RESULTS", pipelineVersion),
+			Honeytokens:     resultStoreForEnv("OSSF_MALWARE_ANALYSIS_HONEYTOKEN_RESULTS", pipelineVersion),
+			Metadata:        resultStoreForEnv("OSSF_MALWARE_ANALYSIS_METADATA_RESULTS", pipelineVersion),
+			StaticAnalysis:  resultStoreForEnv("OSSF_MALWARE_STATIC_ANALYSIS_RESULTS", pipelineVersion),
 		},
 		subURL:               os.Getenv("OSSMALWARE_WORKER_SUBSCRIPTION"),
 		packagesBucket:       os.Getenv("OSSF_MALWARE_ANALYSIS_PACKAGES"),
 		notificationTopicURL: os.Getenv("OSSF_MALWARE_NOTIFICATION_TOPIC"),
 
 		userAgentExtra: os.Getenv("OSSF_MALWARE_USER_AGENT_EXTRA"),
+
+		registryURLs:     os.Getenv(pkgmanager.RegistryURLsEnvVar),
+		registryAuthFile: os.Getenv(pkgmanager.RegistryAuthFileEnvVar),
+
+		pipelineVersion: pipelineVersion,
+
+		futureClockShift: clockShiftForEnv("OSSF_MALWARE_ANALYSIS_FUTURE_CLOCK_SHIFT"),
 	}
 }
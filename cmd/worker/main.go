@@ -10,6 +10,8 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 
 	"gocloud.dev/blob"
 	_ "gocloud.dev/blob/fileblob"
@@ -20,6 +22,7 @@ import (
 	_ "gocloud.dev/pubsub/kafkapubsub"
 
 	"github.com/ossf/package-analysis/cmd/worker/pubsubextender"
+	"github.com/ossf/package-analysis/internal/archivecache"
 	"github.com/ossf/package-analysis/internal/featureflags"
 	"github.com/ossf/package-analysis/internal/log"
 	"github.com/ossf/package-analysis/internal/notification"
@@ -114,12 +117,47 @@ func handleMessage(ctx context.Context, msg *pubsub.Message, cfg *config, packag
 		sandboxOpts = append(sandboxOpts, sandbox.NoPull())
 	}
 
-	pkg, err := worker.ResolvePkg(manager, name, version, localPkgPath)
+	pkg, err := worker.ResolvePkg(ctx, manager, name, version, localPkgPath)
 	if err != nil {
+		if !pkgmanager.IsRetryable(err) {
+			// e.g. the package doesn't exist, so there is no point retrying the message
+			slog.WarnContext(ctx, "Error resolving package, giving up", "error", err)
+			return nil
+		}
 		slog.ErrorContext(ctx, "Error resolving package", "error", err)
 		return err
 	}
 
+	// Unless forced, skip analysis whose results have already been saved by this version
+	// of the pipeline, e.g. when a message is redelivered. Local packages are always
+	// analyzed, since their contents may differ from a previous package with the same version.
+	runStatic, runDynamic := true, true
+	if !pkg.IsLocal() && !boolAttribute(ctx, msg, "force") {
+		runStatic = !worker.ResultsExist(ctx, cfg.resultStores.StaticAnalysis, pkg)
+		runDynamic = !worker.ResultsExist(ctx, cfg.resultStores.DynamicAnalysis, pkg)
+	}
+	// Packages flagged as interesting can request a second run of dynamic analysis with
+	// the clock shifted into the future, to trigger code that only runs after a delay.
+	runFuture := !cfg.futureClockShift.IsZero() && boolAttribute(ctx, msg, "future")
+	if !runStatic && !runDynamic && !runFuture {
+		slog.InfoContext(ctx, "Analysis results already exist, skipping")
+		return nil
+	}
+
+	// analysisPkg is the package passed to the sandboxes, which may use a cached archive
+	analysisPkg := pkg
+	if cfg.archives != nil && !pkg.IsLocal() {
+		entry, err := cfg.archives.Get(ctx, pkg)
+		if err != nil {
+			// the sandboxes download the archive themselves instead
+			slog.WarnContext(ctx, "Failed to get package archive from cache", "error", err)
+		} else {
+			sandboxPath := fmt.Sprintf(localPkgPathFmt, filepath.Base(entry.Path))
+			sandboxOpts = append(sandboxOpts, sandbox.Volume(entry.Path, sandboxPath))
+			analysisPkg = pkg.WithArchive(sandboxPath, entry.Archive.Digest)
+		}
+	}
+
 	staticSandboxOpts := append(worker.StaticSandboxOptions(), sandboxOpts...)
 	dynamicSandboxOpts := append(worker.DynamicSandboxOptions(), sandboxOpts...)
 
@@ -130,20 +168,47 @@ func handleMessage(ctx context.Context, msg *pubsub.Message, cfg *config, packag
 
 	// run both dynamic and static analysis regardless of error status of either
 	// and return combined error(s) afterwards, if applicable
-	staticResults, _, staticAnalysisErr := worker.RunStaticAnalysis(ctx, pkg, staticSandboxOpts, staticanalysis.All)
-	if staticAnalysisErr == nil {
-		staticAnalysisErr = worker.SaveStaticAnalysisData(ctx, pkg, cfg.resultStores, staticResults)
+	var staticAnalysisErr, dynamicAnalysisErr, futureAnalysisErr error
+	if runStatic {
+		staticResults, _, err := worker.RunStaticAnalysis(ctx, analysisPkg, staticSandboxOpts, pkgmanager.DefaultArchive, staticanalysis.All)
+		if err == nil {
+			err = worker.SaveStaticAnalysisData(ctx, pkg, cfg.resultStores, cfg.archives, staticResults)
+		}
+		staticAnalysisErr = err
+	} else {
+		slog.InfoContext(ctx, "Static analysis results already exist, skipping")
+	}
+
+	if runDynamic {
+		result, err := worker.RunDynamicAnalysis(ctx, analysisPkg, dynamicSandboxOpts, "")
+		if err == nil {
+			err = worker.SaveDynamicAnalysisData(ctx, pkg, cfg.resultStores, cfg.archives, result.Data)
+		}
+		dynamicAnalysisErr = err
+	} else {
+		slog.InfoContext(ctx, "Dynamic analysis results already exist, skipping")
 	}
 
-	result, dynamicAnalysisErr := worker.RunDynamicAnalysis(ctx, pkg, dynamicSandboxOpts, "")
-	if dynamicAnalysisErr == nil {
-		dynamicAnalysisErr = worker.SaveDynamicAnalysisData(ctx, pkg, cfg.resultStores, result.Data)
+	if runFuture {
+		futureCtx := log.ContextWithAttrs(ctx, slog.String("clock_shift", cfg.futureClockShift.String()))
+		result, err := worker.RunDynamicAnalysis(futureCtx, analysisPkg, dynamicSandboxOpts, "", worker.ShiftClock(cfg.futureClockShift))
+		if err == nil {
+			err = worker.SaveDynamicAnalysisVariantData(futureCtx, pkg, cfg.resultStores, "future", result.Data)
+		}
+		futureAnalysisErr = err
 	}
 
 	cfg.resultStores.AnalyzedPackageSaved = false
 
+	metadataErr := worker.SaveMetadata(ctx, pkg, cfg.resultStores)
+	if errors.Is(metadataErr, pkgmanager.ErrPackageNotFound) || errors.Is(metadataErr, pkgmanager.ErrVersionNotFound) {
+		// retrying won't help, so don't fail the whole analysis because of missing metadata
+		slog.WarnContext(ctx, "Package metadata not found", "error", metadataErr)
+		metadataErr = nil
+	}
+
 	// combine errors
-	if analysisErr := errors.Join(dynamicAnalysisErr, staticAnalysisErr); analysisErr != nil {
+	if analysisErr := errors.Join(dynamicAnalysisErr, futureAnalysisErr, staticAnalysisErr, metadataErr); analysisErr != nil {
 		return analysisErr
 	}
 
@@ -156,6 +221,25 @@ func handleMessage(ctx context.Context, msg *pubsub.Message, cfg *config, packag
 	return nil
 }
 
+// boolAttribute returns true if the message has the given attribute set to true. The
+// "force" attribute requests that the package is analyzed even if its results already
+// exist, and the "future" attribute requests a second run of dynamic analysis with the
+// clock shifted into the future.
+func boolAttribute(ctx context.Context, msg *pubsub.Message, name string) bool {
+	val, ok := msg.Metadata[name]
+	if !ok {
+		return false
+	}
+
+	set, err := strconv.ParseBool(val)
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid boolean attribute, ignoring", "attribute", name, "value", val)
+		return false
+	}
+
+	return set
+}
+
 func messageLoop(ctx context.Context, cfg *config) error {
 	sub, err := pubsub.OpenSubscription(ctx, cfg.subURL)
 	if err != nil {
@@ -246,6 +330,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := pkgmanager.ConfigureRegistries(cfg.registryURLs, cfg.registryAuthFile); err != nil {
+		slog.Error("Failed to configure registries", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.archiveCache.dir != "" {
+		cache, err := archivecache.New(cfg.archiveCache.dir, cfg.archiveCache.maxSize)
+		if err != nil {
+			slog.Error("Failed to create archive cache", "error", err)
+			os.Exit(1)
+		}
+		cfg.archives = cache
+	}
+
 	sandbox.InitNetwork(ctx)
 
 	// If configured, start a webserver so that Go's pprof can be accessed for
@@ -262,6 +360,9 @@ func main() {
 		"config", cfg,
 		"feature_flags", featureflags.State(),
 	)
+	if cfg.pipelineVersion == "" {
+		slog.WarnContext(ctx, "No pipeline version or sandbox image tag set, packages will be analyzed even if their results already exist")
+	}
 
 	err := messageLoop(ctx, cfg)
 	if err != nil {
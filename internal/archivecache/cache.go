@@ -0,0 +1,415 @@
+/*
+Package archivecache implements a local cache of package archives downloaded from
+package registries, so that each archive only needs to be downloaded once even if
+it is used by several analyses.
+
+Archives are stored in a directory tree keyed by ecosystem, package name, version
+and the SHA-256 hash of the archive contents:
+
+	<dir>/<ecosystem>/<name>/<version>/<sha256>/<archive filename>
+
+The total size of the cache is bounded by evicting the least recently used archives.
+*/
+package archivecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/internal/pkgmanager"
+	"github.com/ossf/package-analysis/internal/utils"
+)
+
+// entryInfoFile is the name of the file in each entry directory which
+// describes the cached archive.
+const entryInfoFile = "archive.json"
+
+// tmpDirName is the directory in the cache where archives are downloaded,
+// before they are moved into place.
+const tmpDirName = ".tmp"
+
+// Cache stores package archives on the local filesystem. A Cache is safe to use
+// from multiple goroutines, but the cache directory must not be shared between
+// processes.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	// mu protects locks, and is held while directories are created or removed
+	// outside of a locked version directory.
+	mu sync.Mutex
+	// locks holds the lock for each version directory that is in use.
+	locks map[string]*versionLock
+	// evictMu is held while evicting archives.
+	evictMu sync.Mutex
+}
+
+// versionLock is held while a version directory is looked up or downloaded to,
+// so that different package versions can be downloaded at the same time.
+type versionLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// Entry is a package archive stored in the cache.
+type Entry struct {
+	// Path is the location of the archive file in the cache.
+	Path string `json:"-"`
+
+	// SHA256 is the hex encoded SHA-256 hash of the archive file.
+	SHA256 string
+
+	// Archive describes the archive as it was listed by the registry,
+	// including the digest that it was verified against.
+	Archive pkgmanager.Archive
+}
+
+/*
+New returns a Cache which stores archives in the given directory, creating it if it
+doesn't exist. Archives are evicted when the total size of the cached archives exceeds
+maxSize bytes, except for the archive that was most recently used. If maxSize is zero
+or negative, archives are never evicted.
+*/
+func New(dir string, maxSize int64) (*Cache, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// remove any downloads left over from a previous process
+	if err := os.RemoveAll(filepath.Join(dir, tmpDirName)); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, tmpDirName), 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Cache{dir: dir, maxSize: maxSize, locks: map[string]*versionLock{}}, nil
+}
+
+// String implements fmt.Stringer.
+func (c *Cache) String() string {
+	return fmt.Sprintf("%s (max size %d bytes)", c.dir, c.maxSize)
+}
+
+/*
+Get returns the cached default archive for the given package, downloading it from
+the registry if it is not already in the cache. Downloaded archives are verified
+against the digest published by the registry (see pkgmanager.PkgManager.Download).
+
+Local packages are not cached, and an error is returned if pkg is local.
+*/
+func (c *Cache) Get(ctx context.Context, pkg *pkgmanager.Pkg) (*Entry, error) {
+	if pkg.IsLocal() {
+		return nil, errors.New("local packages cannot be cached")
+	}
+	if pkg.Name() == "" || pkg.Version() == "" {
+		return nil, errors.New("packages without a name and version cannot be cached")
+	}
+
+	versionDir := c.versionDir(pkg)
+	unlock := c.lockVersion(versionDir)
+	defer unlock()
+
+	entry, err := c.lookup(versionDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		slog.DebugContext(ctx, "Archive cache hit", "path", entry.Path)
+		if err := touch(filepath.Dir(entry.Path)); err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+
+	entry, err = c.download(ctx, pkg, versionDir)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Added archive to cache", "path", entry.Path)
+
+	if err := c.evict(ctx, filepath.Dir(entry.Path)); err != nil {
+		slog.WarnContext(ctx, "Failed to evict archives from cache", "error", err)
+	}
+
+	return entry, nil
+}
+
+// versionDir returns the directory containing the cache entries for the package version.
+// The name and version are escaped (see escapePathSegment), so that they are always a
+// single directory below their parent.
+func (c *Cache) versionDir(pkg *pkgmanager.Pkg) string {
+	return filepath.Join(c.dir, pkg.EcosystemName(), escapePathSegment(pkg.Name()), escapePathSegment(pkg.Version()))
+}
+
+// escapePathSegment escapes s for use as a single path segment. Slashes are escaped,
+// since they may be part of package names (e.g. npm scopes), as are the dots of "."
+// and "..", which would otherwise refer to the current or parent directory.
+func escapePathSegment(s string) string {
+	if s == "." || s == ".." {
+		return strings.ReplaceAll(s, ".", "%2E")
+	}
+	return url.PathEscape(s)
+}
+
+// lockVersion locks the given version directory, and returns a function which unlocks it.
+func (c *Cache) lockVersion(versionDir string) func() {
+	c.mu.Lock()
+	l := c.locks[versionDir]
+	if l == nil {
+		l = &versionLock{}
+		c.locks[versionDir] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		c.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(c.locks, versionDir)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// lookup returns an entry in the given version directory, or nil if there are none.
+// Incomplete entries are removed.
+func (c *Cache) lookup(versionDir string) (*Entry, error) {
+	dirEntries, err := os.ReadDir(versionDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, d := range dirEntries {
+		entryDir := filepath.Join(versionDir, d.Name())
+		entry, err := readEntry(entryDir)
+		if err != nil {
+			if err := os.RemoveAll(entryDir); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return entry, nil
+	}
+
+	return nil, nil
+}
+
+// readEntry reads the cache entry in the given directory, and checks that the archive exists.
+func readEntry(entryDir string) (*Entry, error) {
+	data, err := os.ReadFile(filepath.Join(entryDir, entryInfoFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+
+	if err := pkgmanager.CheckArchiveFilename(entry.Archive.Filename); err != nil {
+		return nil, err
+	}
+	entry.Path = filepath.Join(entryDir, entry.Archive.Filename)
+	if _, err := os.Stat(entry.Path); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// download downloads the default archive of the package and adds it to the cache.
+func (c *Cache) download(ctx context.Context, pkg *pkgmanager.Pkg, versionDir string) (*Entry, error) {
+	manager := pkg.Manager()
+	archives, err := manager.Archives(ctx, pkg.Name(), pkg.Version(), pkgmanager.DefaultArchive)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Join(c.dir, tmpDirName), "download")
+	if err != nil {
+		return nil, err
+	}
+	// does nothing once the directory has been moved into place
+	defer os.RemoveAll(tmpDir)
+
+	path, err := manager.Download(ctx, archives[0], tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := utils.SHA256Hash(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := Entry{SHA256: hash, Archive: archives[0]}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, entryInfoFile), data, 0o644); err != nil {
+		return nil, err
+	}
+
+	// The parents of versionDir may be removed by eviction of another version.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.MkdirAll(versionDir, 0o755); err != nil {
+		return nil, err
+	}
+	entryDir := filepath.Join(versionDir, hash)
+	if err := os.Rename(tmpDir, entryDir); err != nil {
+		return nil, err
+	}
+
+	entry.Path = filepath.Join(entryDir, archives[0].Filename)
+	return &entry, nil
+}
+
+// cachedEntry is an entry directory found when scanning the cache for eviction.
+type cachedEntry struct {
+	dir      string
+	size     int64
+	lastUsed time.Time
+}
+
+// evict removes the least recently used entries until the total size of the
+// cache is no more than the maximum size. The entry in keepDir is never removed,
+// nor are entries of versions which are being looked up or downloaded.
+func (c *Cache) evict(ctx context.Context, keepDir string) error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+
+	entries, err := c.scan()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	slices.SortFunc(entries, func(a, b cachedEntry) int {
+		return a.lastUsed.Compare(b.lastUsed)
+	})
+
+	for _, e := range entries {
+		if total <= c.maxSize {
+			break
+		}
+		if e.dir == keepDir {
+			continue
+		}
+		removed, err := c.removeEntry(e.dir)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			continue
+		}
+		total -= e.size
+		slog.InfoContext(ctx, "Evicted archive from cache", "path", e.dir, "size", e.size)
+	}
+
+	return nil
+}
+
+// scan lists every entry directory in the cache, along with its size.
+func (c *Cache) scan() ([]cachedEntry, error) {
+	// entry directories are at <ecosystem>/<name>/<version>/<sha256>, which is
+	// deeper than anything in the temporary download directory
+	dirs, err := filepath.Glob(filepath.Join(c.dir, "*", "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []cachedEntry
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			continue
+		}
+
+		e := cachedEntry{dir: dir, lastUsed: info.ModTime()}
+		err = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			fileInfo, err := d.Info()
+			if err != nil {
+				return err
+			}
+			e.size += fileInfo.Size()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// removeEntry removes the entry in the given directory, and its empty parent directories,
+// unless its version directory is locked, in which case false is returned.
+func (c *Cache) removeEntry(entryDir string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.locks[filepath.Dir(entryDir)] != nil {
+		return false, nil
+	}
+	if err := os.RemoveAll(entryDir); err != nil {
+		return false, err
+	}
+	c.removeEmptyParents(entryDir)
+	return true, nil
+}
+
+// removeEmptyParents removes the version, name and ecosystem directories
+// above an entry directory if they are empty.
+func (c *Cache) removeEmptyParents(entryDir string) {
+	dir := filepath.Dir(entryDir)
+	for i := 0; i < 3 && dir != c.dir; i++ {
+		// fails if the directory is not empty
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// touch records that the entry in the given directory was used, for eviction.
+func touch(dir string) error {
+	now := time.Now()
+	return os.Chtimes(dir, now, now)
+}
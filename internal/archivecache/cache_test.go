@@ -0,0 +1,282 @@
+package archivecache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ossf/package-analysis/internal/pkgmanager"
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+// testArchiveSHA256 is the SHA-256 hash of the test archive contents, "archive".
+const testArchiveSHA256 = "0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3"
+
+// useTestRegistry starts a local npm registry which serves the archive for any version of
+// any package, and returns a map counting the number of times each archive was downloaded.
+func useTestRegistry(t *testing.T) map[string]int {
+	t.Helper()
+
+	downloads := map[string]int{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".tgz") {
+			downloads[r.URL.Path]++
+			fmt.Fprint(w, "archive")
+			return
+		}
+		// version document: /<name>/<version>
+		fmt.Fprintf(w, `{"dist": {"tarball": "%s%s.tgz", "shasum": "ebfb55f4432b592119a10592e4f26272cc72359e"}}`, server.URL, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+
+	if err := pkgmanager.SetRegistryURL(pkgecosystem.NPM, server.URL); err != nil {
+		t.Fatalf("SetRegistryURL() = %v", err)
+	}
+	t.Cleanup(func() { _ = pkgmanager.SetRegistryURL(pkgecosystem.NPM, "") })
+
+	return downloads
+}
+
+func TestGet(t *testing.T) {
+	downloads := useTestRegistry(t)
+	dir := t.TempDir()
+
+	cache, err := New(dir, 0)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	pkg := pkgmanager.Manager(pkgecosystem.NPM).Package("@scope/test", "1.0.0")
+	for i := 0; i < 2; i++ {
+		entry, err := cache.Get(context.Background(), pkg)
+		if err != nil {
+			t.Fatalf("Get() = %v", err)
+		}
+
+		wantPath := filepath.Join(dir, "npm", "@scope%2Ftest", "1.0.0", testArchiveSHA256, "@scope-test-1.0.0.tgz")
+		if entry.Path != wantPath {
+			t.Errorf("Get() path = %q; want %q", entry.Path, wantPath)
+		}
+		if entry.SHA256 != testArchiveSHA256 {
+			t.Errorf("Get() SHA256 = %q; want %q", entry.SHA256, testArchiveSHA256)
+		}
+		if entry.Archive.Digest.IsZero() {
+			t.Errorf("Get() archive digest is not set")
+		}
+		if data, err := os.ReadFile(entry.Path); err != nil || string(data) != "archive" {
+			t.Errorf("cached archive = %q, %v; want %q", data, err, "archive")
+		}
+	}
+
+	if n := downloads["/@scope/test/1.0.0.tgz"]; n != 1 {
+		t.Errorf("archive downloaded %d times; want 1", n)
+	}
+}
+
+func TestGetIncompleteEntry(t *testing.T) {
+	downloads := useTestRegistry(t)
+	dir := t.TempDir()
+
+	cache, err := New(dir, 0)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	// an entry without the archive file is ignored and replaced
+	incomplete := filepath.Join(dir, "npm", "test", "1.0.0", "abcdef")
+	if err := os.MkdirAll(incomplete, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := cache.Get(context.Background(), pkgmanager.Manager(pkgecosystem.NPM).Package("test", "1.0.0"))
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if filepath.Base(filepath.Dir(entry.Path)) != testArchiveSHA256 {
+		t.Errorf("Get() path = %q; want entry for %s", entry.Path, testArchiveSHA256)
+	}
+	if _, err := os.Stat(incomplete); !os.IsNotExist(err) {
+		t.Errorf("incomplete entry was not removed")
+	}
+	if n := downloads["/test/1.0.0.tgz"]; n != 1 {
+		t.Errorf("archive downloaded %d times; want 1", n)
+	}
+}
+
+func TestGetInvalidEntryFilename(t *testing.T) {
+	downloads := useTestRegistry(t)
+	dir := t.TempDir()
+
+	cache, err := New(dir, 0)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	// an entry whose archive filename is outside its directory is ignored and replaced
+	hostile := filepath.Join(dir, "npm", "test", "1.0.0", "abcdef")
+	if err := os.MkdirAll(hostile, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hostile, entryInfoFile), []byte(`{"Archive": {"Filename": "../../x"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "npm", "test", "x"), []byte("archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := cache.Get(context.Background(), pkgmanager.Manager(pkgecosystem.NPM).Package("test", "1.0.0"))
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	if filepath.Dir(entry.Path) != filepath.Join(dir, "npm", "test", "1.0.0", testArchiveSHA256) {
+		t.Errorf("Get() path = %q; want entry for %s", entry.Path, testArchiveSHA256)
+	}
+	if _, err := os.Stat(hostile); !os.IsNotExist(err) {
+		t.Errorf("entry with invalid filename was not removed")
+	}
+	if n := downloads["/test/1.0.0.tgz"]; n != 1 {
+		t.Errorf("archive downloaded %d times; want 1", n)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	useTestRegistry(t)
+	dir := t.TempDir()
+
+	// large enough for two archives (and their archive.json files), but not three
+	cache, err := New(dir, 600)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	ctx := context.Background()
+	manager := pkgmanager.Manager(pkgecosystem.NPM)
+	get := func(name string) *Entry {
+		t.Helper()
+		entry, err := cache.Get(ctx, manager.Package(name, "1.0.0"))
+		if err != nil {
+			t.Fatalf("Get(%s) = %v", name, err)
+		}
+		return entry
+	}
+
+	a := get("a")
+	b := get("b")
+	// use a again, so that b is the least recently used
+	get("a")
+	c := get("c")
+
+	for _, tt := range []struct {
+		entry *Entry
+		want  bool
+	}{{a, true}, {b, false}, {c, true}} {
+		_, err := os.Stat(tt.entry.Path)
+		if exists := err == nil; exists != tt.want {
+			t.Errorf("%s exists = %v; want %v", tt.entry.Path, exists, tt.want)
+		}
+	}
+
+	// empty parent directories of evicted entries are removed
+	if _, err := os.Stat(filepath.Join(dir, "npm", "b")); !os.IsNotExist(err) {
+		t.Errorf("directory of evicted package was not removed")
+	}
+}
+
+func TestGetLocalPackage(t *testing.T) {
+	cache, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	pkg := pkgmanager.Manager(pkgecosystem.NPM).Local("test", "1.0.0", "/local/test.tgz")
+	if _, err := cache.Get(context.Background(), pkg); err == nil {
+		t.Errorf("Get() for local package should return an error")
+	}
+}
+
+func TestVersionDirEscapesDots(t *testing.T) {
+	cache, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	ecosystemDir := filepath.Join(cache.dir, "npm")
+	for _, tt := range []struct{ name, version string }{
+		{"..", "1.0.0"},
+		{"test", ".."},
+		{"..", ".."},
+		{".", "."},
+	} {
+		dir := cache.versionDir(pkgmanager.Manager(pkgecosystem.NPM).Package(tt.name, tt.version))
+		rel, err := filepath.Rel(ecosystemDir, dir)
+		if err != nil || len(strings.Split(rel, string(filepath.Separator))) != 2 || strings.HasPrefix(rel, "..") {
+			t.Errorf("versionDir(%q, %q) = %q; want a directory two levels below %q", tt.name, tt.version, dir, ecosystemDir)
+		}
+	}
+}
+
+func TestGetConcurrentMisses(t *testing.T) {
+	// The archive of a is only served once the archive of b has been requested, so
+	// the downloads only finish if they are not serialized.
+	bRequested := make(chan struct{})
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a/1.0.0.tgz":
+			select {
+			case <-bRequested:
+			case <-time.After(10 * time.Second):
+				http.Error(w, "timed out", http.StatusServiceUnavailable)
+				return
+			}
+		case "/b/1.0.0.tgz":
+			close(bRequested)
+		default:
+			fmt.Fprintf(w, `{"dist": {"tarball": "%s%s.tgz", "shasum": "ebfb55f4432b592119a10592e4f26272cc72359e"}}`, server.URL, r.URL.Path)
+			return
+		}
+		fmt.Fprint(w, "archive")
+	}))
+	t.Cleanup(server.Close)
+
+	if err := pkgmanager.SetRegistryURL(pkgecosystem.NPM, server.URL); err != nil {
+		t.Fatalf("SetRegistryURL() = %v", err)
+	}
+	t.Cleanup(func() { _ = pkgmanager.SetRegistryURL(pkgecosystem.NPM, "") })
+
+	cache, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+
+	manager := pkgmanager.Manager(pkgecosystem.NPM)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(context.Background(), manager.Package("a", "1.0.0"))
+		errs <- err
+	}()
+	// wait until a is being downloaded
+	for {
+		cache.mu.Lock()
+		n := len(cache.locks)
+		cache.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := cache.Get(context.Background(), manager.Package("b", "1.0.0")); err != nil {
+		t.Errorf("Get(b) = %v", err)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Get(a) = %v", err)
+	}
+}
@@ -24,6 +24,9 @@ type Result struct {
 	// IDs that correlate to the name of the file that saves the actual write buffer contents.
 	// We save this separately so that we don't need to dig through the FileWritesSummary later on.
 	FileWriteBufferIds []string
+	// Processes holds the activity of each process, which is used to attribute
+	// behaviour to individual packages (see AttributeActivity).
+	Processes []Process
 }
 
 var resultError = &Result{
@@ -32,7 +35,10 @@ var resultError = &Result{
 	},
 }
 
-func Run(ctx context.Context, sb sandbox.Sandbox, command string, args []string, straceLogger *slog.Logger) (*Result, error) {
+// Run runs the given command in the sandbox, and returns the behaviour observed by
+// strace and packet capture. Any receivers given are registered with the packet
+// capture, in addition to the DNS analyzer used for the results.
+func Run(ctx context.Context, sb sandbox.Sandbox, command string, args []string, straceLogger *slog.Logger, receivers ...packetcapture.PacketReceiver) (*Result, error) {
 	slog.InfoContext(ctx, "Running dynamic analysis", "args", args)
 
 	slog.DebugContext(ctx, "Preparing packet capture")
@@ -40,6 +46,9 @@ func Run(ctx context.Context, sb sandbox.Sandbox, command string, args []string,
 
 	dns := dnsanalyzer.New()
 	pcap.RegisterReceiver(dns)
+	for _, r := range receivers {
+		pcap.RegisterReceiver(r)
+	}
 	if err := pcap.Start(); err != nil {
 		return resultError, fmt.Errorf("failed to start packet capture (%w)", err)
 	}
@@ -117,6 +126,24 @@ func (d *Result) setData(straceResult *strace.Result, dns *dnsanalyzer.DNSAnalyz
 		})
 	}
 
+	for _, p := range straceResult.Processes() {
+		proc := Process{
+			PID:       p.PID,
+			ParentPID: p.ParentPID,
+			Dirs:      p.Dirs,
+			Files:     p.Files,
+			Commands:  p.Commands,
+		}
+		for _, s := range p.Sockets {
+			proc.Sockets = append(proc.Sockets, analysisrun.SocketResult{
+				Address:   s.Address,
+				Port:      s.Port,
+				Hostnames: dns.Hostnames(s.Address),
+			})
+		}
+		d.Processes = append(d.Processes, proc)
+	}
+
 	for dnsClass, queries := range dns.Questions() {
 		c := analysisrun.DNSResult{Class: dnsClass}
 		for host, types := range queries {
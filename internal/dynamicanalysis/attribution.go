@@ -0,0 +1,196 @@
+package dynamicanalysis
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+// Process holds the activity of a single process that ran during an analysis phase.
+type Process struct {
+	PID int
+	// ParentPID is the process which created this process, or 0 if it is not known.
+	ParentPID int
+	// Dirs lists the working directories of the process, in the order they were seen.
+	Dirs     []string
+	Files    []string
+	Sockets  []analysisrun.SocketResult
+	Commands [][]string
+}
+
+var (
+	// pip builds each source distribution in its own directory, named after the
+	// (canonical) project name and a random UUID, e.g.
+	// /tmp/pip-install-3k2j5f0e/evil-pkg_1f0e2a46a5d14d7b8c1b2e4f1c3a7d9e
+	pipBuildDirPattern = regexp.MustCompile(`^(.+)_[0-9a-f]{32}$`)
+
+	canonicalNameSeparators = regexp.MustCompile(`[-_.]+`)
+)
+
+// canonicalName normalizes a package name so that names which differ only in case or
+// the use of separators (as in PEP 503) compare equal.
+func canonicalName(name string) string {
+	return strings.ToLower(canonicalNameSeparators.ReplaceAllString(name, "-"))
+}
+
+// attributor maps processes to the installed packages whose behaviour they represent.
+type attributor struct {
+	packages  []analysisrun.InstalledPackage
+	processes map[int]*Process
+	// attributed caches the package of each process. A nil package means the
+	// process could not be attributed.
+	attributed map[int]*analysisrun.InstalledPackage
+}
+
+/*
+packageForDir returns the package which owns the given directory, if any. This is
+either the installed package with the longest path containing the directory (e.g.
+node_modules/<name> for npm), or the package being built in a pip build directory.
+
+A package built by pip may not be in the list of installed packages (e.g. if the
+build failed), in which case a package with only its name is returned.
+*/
+func (a *attributor) packageForDir(dir string) *analysisrun.InstalledPackage {
+	var best *analysisrun.InstalledPackage
+	for i := range a.packages {
+		p := &a.packages[i]
+		if p.Path == "" || (dir != p.Path && !strings.HasPrefix(dir, p.Path+"/")) {
+			continue
+		}
+		if best == nil || len(p.Path) > len(best.Path) {
+			best = p
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	parts := strings.Split(filepath.Clean(dir), "/")
+	for i := 1; i < len(parts); i++ {
+		if !strings.HasPrefix(parts[i-1], "pip-install-") {
+			continue
+		}
+		match := pipBuildDirPattern.FindStringSubmatch(parts[i])
+		if match == nil {
+			continue
+		}
+		name := canonicalName(match[1])
+		for j := range a.packages {
+			if canonicalName(a.packages[j].Name) == name {
+				return &a.packages[j]
+			}
+		}
+		return &analysisrun.InstalledPackage{Name: name}
+	}
+
+	return nil
+}
+
+// packageForProcess returns the package that the process is attributed to. This is
+// the package owning the first of its working directories which belongs to a package,
+// or else the package of its parent process.
+func (a *attributor) packageForProcess(pid int) *analysisrun.InstalledPackage {
+	if pkg, done := a.attributed[pid]; done {
+		return pkg
+	}
+	// guard against cycles in the process tree
+	a.attributed[pid] = nil
+
+	proc, ok := a.processes[pid]
+	if !ok {
+		return nil
+	}
+
+	var pkg *analysisrun.InstalledPackage
+	for _, dir := range proc.Dirs {
+		if pkg = a.packageForDir(dir); pkg != nil {
+			break
+		}
+	}
+	if pkg == nil && proc.ParentPID != 0 {
+		pkg = a.packageForProcess(proc.ParentPID)
+	}
+
+	a.attributed[pid] = pkg
+	return pkg
+}
+
+/*
+AttributeActivity attributes the activity of each process to one of the installed
+packages, using the process tree and the working directories of each process. A process
+belongs to a package if it ran in the directory of the package (e.g. an npm install
+script runs in node_modules/<name>, and pip builds a package in its own directory),
+or if its parent process belongs to the package.
+
+The activity of processes which could not be attributed (e.g. the package manager
+itself) is not included. Packages are listed in the same order as packages, followed by
+packages which were built but not installed.
+*/
+func AttributeActivity(processes []Process, packages []analysisrun.InstalledPackage) []analysisrun.PackageActivity {
+	a := &attributor{
+		packages:   packages,
+		processes:  make(map[int]*Process),
+		attributed: make(map[int]*analysisrun.InstalledPackage),
+	}
+	for i := range processes {
+		a.processes[processes[i].PID] = &processes[i]
+	}
+
+	var order []string
+	activity := map[string]*analysisrun.PackageActivity{}
+	seen := map[string]map[string]bool{}
+
+	for _, proc := range processes {
+		pkg := a.packageForProcess(proc.PID)
+		if pkg == nil {
+			continue
+		}
+
+		key := pkg.Name + "@" + pkg.Version
+		act, ok := activity[key]
+		if !ok {
+			act = &analysisrun.PackageActivity{Name: pkg.Name, Version: pkg.Version}
+			activity[key] = act
+			seen[key] = map[string]bool{}
+			order = append(order, key)
+		}
+
+		// add each item once, even if multiple processes of the package used it
+		for _, f := range proc.Files {
+			if !seen[key]["file:"+f] {
+				seen[key]["file:"+f] = true
+				act.Files = append(act.Files, f)
+			}
+		}
+		for _, s := range proc.Sockets {
+			if id := "socket:" + s.Address + "-" + strconv.Itoa(s.Port); !seen[key][id] {
+				seen[key][id] = true
+				act.Sockets = append(act.Sockets, s)
+			}
+		}
+		for _, c := range proc.Commands {
+			if id := "command:" + strings.Join(c, "\x00"); !seen[key][id] {
+				seen[key][id] = true
+				act.Commands = append(act.Commands, c)
+			}
+		}
+	}
+
+	var result []analysisrun.PackageActivity
+	for _, p := range packages {
+		if act, ok := activity[p.Name+"@"+p.Version]; ok {
+			result = append(result, *act)
+			delete(activity, p.Name+"@"+p.Version)
+		}
+	}
+	for _, key := range order {
+		if act, ok := activity[key]; ok {
+			result = append(result, *act)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,62 @@
+package dynamicanalysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+func TestAttributeActivityNPM(t *testing.T) {
+	packages := []analysisrun.InstalledPackage{
+		{Name: "parent", Version: "1.0.0", Path: "/app/node_modules/parent", Dependencies: []string{"evil"}},
+		{Name: "evil", Version: "2.0.0", Path: "/app/node_modules/evil"},
+	}
+	processes := []Process{
+		// npm itself
+		{PID: 2, Dirs: []string{"/app"}, Files: []string{"/app/package.json"}},
+		// install script of evil, and a process it started
+		{PID: 7, ParentPID: 2, Dirs: []string{"/app/node_modules/evil"}, Commands: [][]string{{"sh", "-c", "node install.js"}}},
+		{PID: 8, ParentPID: 7, Sockets: []analysisrun.SocketResult{{Address: "1.2.3.4", Port: 80}}},
+		// install script of parent
+		{PID: 9, ParentPID: 2, Dirs: []string{"/app/node_modules/parent/lib"}, Files: []string{"/app/node_modules/parent/lib/a.js"}},
+	}
+
+	want := []analysisrun.PackageActivity{
+		{
+			Name:    "parent",
+			Version: "1.0.0",
+			Files:   []string{"/app/node_modules/parent/lib/a.js"},
+		},
+		{
+			Name:     "evil",
+			Version:  "2.0.0",
+			Sockets:  []analysisrun.SocketResult{{Address: "1.2.3.4", Port: 80}},
+			Commands: [][]string{{"sh", "-c", "node install.js"}},
+		},
+	}
+	if got := AttributeActivity(processes, packages); !reflect.DeepEqual(got, want) {
+		t.Errorf("AttributeActivity() = %+v; want %+v", got, want)
+	}
+}
+
+func TestAttributeActivityPip(t *testing.T) {
+	packages := []analysisrun.InstalledPackage{
+		{Name: "Evil_Pkg", Version: "0.1"},
+	}
+	processes := []Process{
+		// pip itself
+		{PID: 1, Dirs: []string{"/app"}},
+		{PID: 3, ParentPID: 1, Dirs: []string{"/tmp/pip-install-3k2j5f0e/evil-pkg_1f0e2a46a5d14d7b8c1b2e4f1c3a7d9e"}, Files: []string{"/root/.ssh/id_rsa"}},
+		// a dependency which failed to build, so was not installed
+		{PID: 4, ParentPID: 1, Dirs: []string{"/tmp/pip-install-3k2j5f0e/broken_00000000000000000000000000000000/build"}, Commands: [][]string{{"gcc"}}},
+	}
+
+	want := []analysisrun.PackageActivity{
+		{Name: "Evil_Pkg", Version: "0.1", Files: []string{"/root/.ssh/id_rsa"}},
+		{Name: "broken", Commands: [][]string{{"gcc"}}},
+	}
+	if got := AttributeActivity(processes, packages); !reflect.DeepEqual(got, want) {
+		t.Errorf("AttributeActivity() = %+v; want %+v", got, want)
+	}
+}
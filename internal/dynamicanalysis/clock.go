@@ -0,0 +1,178 @@
+package dynamicanalysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ossf/package-analysis/internal/sandbox"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+// faketimeLibraryPattern matches the path of libfaketime in the dynamic analysis sandbox
+// image, which depends on the architecture of the image (e.g. x86_64-linux-gnu or
+// aarch64-linux-gnu).
+const faketimeLibraryPattern = "/usr/lib/*/faketime/libfaketime.so.1"
+
+// clockOffsetPattern matches a clock offset such as +30d, -12h or +1y.
+var clockOffsetPattern = regexp.MustCompile(`^([+-])(\d+)([smhdy])$`)
+
+var clockOffsetUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+/*
+ClockShift changes the time seen by the analysis command and the processes it
+starts, in order to trigger code which only runs after a certain date or delay
+(i.e. time bombs).
+
+The clock is shifted using libfaketime, which is preloaded into each dynamically
+linked process started by the analysis command (see FindFaketimeLibrary). Statically
+linked programs (such as most Go binaries) still see the real time.
+*/
+type ClockShift struct {
+	// Offset moves the clock forward (or backward, if negative) from the real time.
+	// It is ignored if Date is set.
+	Offset time.Duration
+
+	// Date, if not zero, is the time the clock starts at.
+	Date time.Time
+
+	// SpeedUp, if greater than 1, makes the clock run faster than real time by
+	// this factor. Sleeps are shortened by the same factor.
+	SpeedUp float64
+}
+
+/*
+ParseClockShift parses a ClockShift from a string of the form
+
+	WHEN [xSPEEDUP]
+
+WHEN is either an offset from the real time, as a sign, a number and one of the
+units s, m, h, d or y (e.g. +30d or +1y, where a year is 365 days), or a date in
+the format 2006-01-02 or RFC 3339. The optional SPEEDUP is the factor by which the
+clock runs faster than real time (e.g. x10).
+*/
+func ParseClockShift(s string) (ClockShift, error) {
+	var shift ClockShift
+
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return shift, fmt.Errorf("invalid clock shift %q", s)
+	}
+
+	if len(fields) == 2 {
+		speedUp, ok := strings.CutPrefix(fields[1], "x")
+		if !ok {
+			return shift, fmt.Errorf("invalid clock speed-up %q: must start with x", fields[1])
+		}
+		factor, err := strconv.ParseFloat(speedUp, 64)
+		if err != nil || factor < 1 || math.IsInf(factor, 0) {
+			return shift, fmt.Errorf("invalid clock speed-up %q: must be a number not less than 1", fields[1])
+		}
+		shift.SpeedUp = factor
+	}
+
+	if m := clockOffsetPattern.FindStringSubmatch(fields[0]); m != nil {
+		n, err := strconv.ParseInt(m[2], 10, 64)
+		unit := clockOffsetUnits[m[3]]
+		if err != nil || n > math.MaxInt64/int64(unit) {
+			return shift, fmt.Errorf("invalid clock offset %q: out of range", fields[0])
+		}
+		shift.Offset = time.Duration(n) * unit
+		if m[1] == "-" {
+			shift.Offset = -shift.Offset
+		}
+		return shift, nil
+	}
+
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if date, err := time.Parse(layout, fields[0]); err == nil {
+			shift.Date = date
+			return shift, nil
+		}
+	}
+
+	return shift, fmt.Errorf("invalid clock shift %q: expected an offset such as +30d or a date", fields[0])
+}
+
+// IsZero returns true if the clock is not shifted.
+func (c ClockShift) IsZero() bool {
+	return c.Offset == 0 && c.Date.IsZero() && c.SpeedUp <= 1
+}
+
+// String returns the clock shift in the format accepted by ParseClockShift.
+func (c ClockShift) String() string {
+	var when string
+	if !c.Date.IsZero() {
+		when = c.Date.Format(time.RFC3339)
+	} else {
+		when = fmt.Sprintf("%+ds", int64(c.Offset/time.Second))
+	}
+	if c.SpeedUp > 1 {
+		return fmt.Sprintf("%s x%g", when, c.SpeedUp)
+	}
+	return when
+}
+
+// offsetAt returns how far the clock is moved from the real time, if now is the real time.
+func (c ClockShift) offsetAt(now time.Time) time.Duration {
+	if !c.Date.IsZero() {
+		return c.Date.Sub(now)
+	}
+	return c.Offset
+}
+
+/*
+FindFaketimeLibrary returns the path of libfaketime in the sandbox, which must be
+initialised. An error is returned if the library is not installed, since the clock
+could not be shifted.
+*/
+func FindFaketimeLibrary(ctx context.Context, sb sandbox.Sandbox) (string, error) {
+	script := fmt.Sprintf(`for lib in %s; do if [ -f "$lib" ]; then echo "$lib"; exit 0; fi; done; exit 1`, faketimeLibraryPattern)
+	r, err := sb.Run(ctx, "/bin/sh", "-c", script)
+	if err != nil {
+		return "", fmt.Errorf("could not look for libfaketime: %w", err)
+	}
+	path := strings.TrimSpace(string(r.Stdout()))
+	if r.Status() != sandbox.RunStatusSuccess || path == "" {
+		return "", fmt.Errorf("libfaketime not found in sandbox at %s", faketimeLibraryPattern)
+	}
+	return path, nil
+}
+
+/*
+Apply returns the command and args which run the given command and args in the
+sandbox with the clock shifted by preloading the libfaketime library at the given
+path (see FindFaketimeLibrary), along with a record of the shift for the analysis
+results. now is the real time at which the command is run, and is used to turn a
+Date into an offset.
+*/
+func (c ClockShift) Apply(now time.Time, faketimeLibrary, command string, args []string) (string, []string, analysisrun.ClockShift) {
+	offset := int64(c.offsetAt(now) / time.Second)
+	record := analysisrun.ClockShift{OffsetSeconds: offset}
+
+	faketime := fmt.Sprintf("%+d", offset)
+	if c.SpeedUp > 1 {
+		faketime += fmt.Sprintf(" x%g", c.SpeedUp)
+		record.SpeedUp = c.SpeedUp
+	}
+
+	envArgs := []string{
+		"LD_PRELOAD=" + faketimeLibrary,
+		"FAKETIME=" + faketime,
+		// child processes continue from the faked time of their parent,
+		// rather than starting again from the offset
+		"FAKETIME_DONT_RESET=1",
+		command,
+	}
+	return "env", append(envArgs, args...), record
+}
@@ -0,0 +1,87 @@
+package dynamicanalysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+func TestParseClockShift(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ClockShift
+		wantErr bool
+	}{
+		{input: "+30d", want: ClockShift{Offset: 30 * 24 * time.Hour}},
+		{input: "+1y", want: ClockShift{Offset: 365 * 24 * time.Hour}},
+		{input: "-12h", want: ClockShift{Offset: -12 * time.Hour}},
+		{input: "+30d x10", want: ClockShift{Offset: 30 * 24 * time.Hour, SpeedUp: 10}},
+		{input: "2030-01-01", want: ClockShift{Date: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{input: "2030-01-01T12:00:00Z x1.5", want: ClockShift{Date: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC), SpeedUp: 1.5}},
+		{input: "", wantErr: true},
+		{input: "30d", wantErr: true},
+		{input: "+30w", wantErr: true},
+		{input: "+30d 10", wantErr: true},
+		{input: "+30d x0.5", wantErr: true},
+		{input: "+30d x10 extra", wantErr: true},
+		{input: "+99999999999y", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			got, err := ParseClockShift(test.input)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseClockShift() error = %v; wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseClockShift() = %#v; want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+const testFaketimeLibrary = "/usr/lib/aarch64-linux-gnu/faketime/libfaketime.so.1"
+
+func TestClockShiftApply(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		shift      ClockShift
+		wantArgs   []string
+		wantRecord analysisrun.ClockShift
+	}{
+		{
+			name:  "offset",
+			shift: ClockShift{Offset: 30 * 24 * time.Hour},
+			wantArgs: []string{
+				"LD_PRELOAD=" + testFaketimeLibrary, "FAKETIME=+2592000", "FAKETIME_DONT_RESET=1",
+				"/usr/local/bin/analyze.py", "import", "foo",
+			},
+			wantRecord: analysisrun.ClockShift{OffsetSeconds: 2592000},
+		},
+		{
+			name:  "date with speed-up",
+			shift: ClockShift{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), SpeedUp: 10},
+			wantArgs: []string{
+				"LD_PRELOAD=" + testFaketimeLibrary, "FAKETIME=+86400 x10", "FAKETIME_DONT_RESET=1",
+				"/usr/local/bin/analyze.py", "import", "foo",
+			},
+			wantRecord: analysisrun.ClockShift{OffsetSeconds: 86400, SpeedUp: 10},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			command, args, record := test.shift.Apply(now, testFaketimeLibrary, "/usr/local/bin/analyze.py", []string{"import", "foo"})
+			if command != "env" {
+				t.Errorf("Apply() command = %q; want %q", command, "env")
+			}
+			if !reflect.DeepEqual(args, test.wantArgs) {
+				t.Errorf("Apply() args = %q; want %q", args, test.wantArgs)
+			}
+			if record != test.wantRecord {
+				t.Errorf("Apply() record = %+v; want %+v", record, test.wantRecord)
+			}
+		})
+	}
+}
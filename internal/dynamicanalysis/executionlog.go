@@ -0,0 +1,150 @@
+package dynamicanalysis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+// maxParseErrorTextBytes limits how much of a malformed line is kept in a parse error.
+const maxParseErrorTextBytes = 256
+
+// executionLogRecord is the format of each line of the execution log.
+// See "Execution log" in sandboxes/README.md.
+type executionLogRecord struct {
+	Version          *int     `json:"version"`
+	Kind             string   `json:"kind"`
+	Symbol           string   `json:"symbol"`
+	Args             string   `json:"args"`
+	Outcome          string   `json:"outcome"`
+	ReturnValue      string   `json:"return_value"`
+	ExceptionType    string   `json:"exception_type"`
+	ExceptionMessage string   `json:"exception_message"`
+	DurationMs       *float64 `json:"duration_ms"`
+	Text             *string  `json:"text"`
+}
+
+var knownKinds = map[analysisrun.ExecutionEventKind]bool{
+	analysisrun.ExecutionModule:   true,
+	analysisrun.ExecutionFunction: true,
+	analysisrun.ExecutionClass:    true,
+	analysisrun.ExecutionInstance: true,
+	analysisrun.ExecutionMethod:   true,
+	analysisrun.ExecutionSkipped:  true,
+	analysisrun.ExecutionOutput:   true,
+	analysisrun.ExecutionError:    true,
+}
+
+var knownOutcomes = map[analysisrun.ExecutionOutcome]bool{
+	analysisrun.ExecutionReturned: true,
+	analysisrun.ExecutionRaised:   true,
+	analysisrun.ExecutionTimeout:  true,
+}
+
+// validate checks that the record has the fields required for its kind, and returns
+// the event it represents.
+func (r *executionLogRecord) validate() (analysisrun.ExecutionEvent, error) {
+	if r.Version == nil {
+		return analysisrun.ExecutionEvent{}, errors.New("missing version")
+	}
+	if *r.Version != analysisrun.ExecutionLogVersion {
+		return analysisrun.ExecutionEvent{}, fmt.Errorf("unsupported version %d", *r.Version)
+	}
+
+	kind := analysisrun.ExecutionEventKind(r.Kind)
+	if !knownKinds[kind] {
+		return analysisrun.ExecutionEvent{}, fmt.Errorf("unknown kind %q", r.Kind)
+	}
+
+	event := analysisrun.ExecutionEvent{Kind: kind, Symbol: r.Symbol}
+	switch kind {
+	case analysisrun.ExecutionModule, analysisrun.ExecutionInstance:
+		if r.Symbol == "" {
+			return event, fmt.Errorf("missing symbol for %s", kind)
+		}
+	case analysisrun.ExecutionOutput, analysisrun.ExecutionError:
+		if r.Text == nil {
+			return event, fmt.Errorf("missing text for %s", kind)
+		}
+	}
+
+	if r.Text != nil {
+		event.Text = *r.Text
+	}
+
+	if !kind.IsCall() {
+		if r.Outcome != "" {
+			return event, fmt.Errorf("outcome is only valid for calls, not %s", kind)
+		}
+		return event, nil
+	}
+
+	if r.Symbol == "" {
+		return event, fmt.Errorf("missing symbol for %s", kind)
+	}
+	outcome := analysisrun.ExecutionOutcome(r.Outcome)
+	if !knownOutcomes[outcome] {
+		return event, fmt.Errorf("unknown outcome %q", r.Outcome)
+	}
+	if outcome == analysisrun.ExecutionRaised && r.ExceptionType == "" {
+		return event, errors.New("missing exception_type for raised outcome")
+	}
+	if r.DurationMs != nil {
+		if *r.DurationMs < 0 {
+			return event, fmt.Errorf("negative duration_ms %v", *r.DurationMs)
+		}
+		event.DurationMs = *r.DurationMs
+	}
+
+	event.Args = r.Args
+	event.Outcome = outcome
+	event.ReturnValue = r.ReturnValue
+	event.ExceptionType = r.ExceptionType
+	event.ExceptionMessage = r.ExceptionMessage
+	return event, nil
+}
+
+/*
+ParseExecutionLog parses the execution log written by the analysis command during the
+execute phase. Each non-empty line of the log must be a JSON object in the format
+described in "Execution log" in sandboxes/README.md.
+
+Lines which are not valid JSON, or do not have the fields required by the format, are
+recorded as parse errors, and parsing continues with the next line.
+*/
+func ParseExecutionLog(data []byte) analysisrun.DynamicAnalysisExecutionLog {
+	var log analysisrun.DynamicAnalysisExecutionLog
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var record executionLogRecord
+		err := json.Unmarshal([]byte(line), &record)
+		var event analysisrun.ExecutionEvent
+		if err == nil {
+			event, err = record.validate()
+		}
+		if err != nil {
+			text := line
+			if len(text) > maxParseErrorTextBytes {
+				text = text[:maxParseErrorTextBytes]
+			}
+			log.ParseErrors = append(log.ParseErrors, analysisrun.ExecutionLogParseError{
+				Line:  i + 1,
+				Error: err.Error(),
+				Text:  text,
+			})
+			continue
+		}
+
+		log.Events = append(log.Events, event)
+	}
+
+	return log
+}
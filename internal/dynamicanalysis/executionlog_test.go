@@ -0,0 +1,94 @@
+package dynamicanalysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+func TestParseExecutionLog(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want analysisrun.DynamicAnalysisExecutionLog
+	}{
+		{
+			name: "empty",
+			log:  "\n\n",
+			want: analysisrun.DynamicAnalysisExecutionLog{},
+		},
+		{
+			name: "events",
+			log: `{"version": 1, "kind": "module", "symbol": "evil"}
+{"version": 1, "kind": "function", "symbol": "run", "args": "url=<mock>", "outcome": "returned", "return_value": "'ok'", "duration_ms": 1.5}
+{"version": 1, "kind": "output", "text": "hello from package"}
+{"version": 1, "kind": "class", "symbol": "Client", "outcome": "raised", "exception_type": "TypeError", "exception_message": "bad host"}
+{"version": 1, "kind": "method", "symbol": "Client.wait", "outcome": "timeout"}
+{"version": 1, "kind": "skipped", "text": ""}
+`,
+			want: analysisrun.DynamicAnalysisExecutionLog{
+				Events: []analysisrun.ExecutionEvent{
+					{Kind: analysisrun.ExecutionModule, Symbol: "evil"},
+					{
+						Kind:        analysisrun.ExecutionFunction,
+						Symbol:      "run",
+						Args:        "url=<mock>",
+						Outcome:     analysisrun.ExecutionReturned,
+						ReturnValue: "'ok'",
+						DurationMs:  1.5,
+					},
+					{Kind: analysisrun.ExecutionOutput, Text: "hello from package"},
+					{
+						Kind:             analysisrun.ExecutionClass,
+						Symbol:           "Client",
+						Outcome:          analysisrun.ExecutionRaised,
+						ExceptionType:    "TypeError",
+						ExceptionMessage: "bad host",
+					},
+					{Kind: analysisrun.ExecutionMethod, Symbol: "Client.wait", Outcome: analysisrun.ExecutionTimeout},
+					{Kind: analysisrun.ExecutionSkipped},
+				},
+			},
+		},
+		{
+			name: "malformed",
+			log: "[function] run\r\n" +
+				`{"kind": "module", "symbol": "evil"}` + "\n" +
+				`{"version": 2, "kind": "module", "symbol": "evil"}` + "\n" +
+				`{"version": 1, "kind": "print", "text": "x"}` + "\n" +
+				`{"version": 1, "kind": "function", "outcome": "returned"}` + "\n" +
+				`{"version": 1, "kind": "function", "symbol": "f", "outcome": "exploded"}` + "\n" +
+				`{"version": 1, "kind": "function", "symbol": "f", "outcome": "raised"}` + "\n" +
+				`{"version": 1, "kind": "function", "symbol": "f", "outcome": "returned", "duration_ms": -1}` + "\n" +
+				`{"version": 1, "kind": "output"}` + "\n" +
+				`{"version": 1, "kind": "module", "symbol": "evil", "outcome": "returned"}` + "\n" +
+				`{"version": 1, "kind": "module", "symbol": "ok"}` + "\n",
+			want: analysisrun.DynamicAnalysisExecutionLog{
+				Events: []analysisrun.ExecutionEvent{
+					{Kind: analysisrun.ExecutionModule, Symbol: "ok"},
+				},
+				ParseErrors: []analysisrun.ExecutionLogParseError{
+					{Line: 1, Error: "invalid character 'u' in literal false (expecting 'a')", Text: "[function] run"},
+					{Line: 2, Error: "missing version", Text: `{"kind": "module", "symbol": "evil"}`},
+					{Line: 3, Error: "unsupported version 2", Text: `{"version": 2, "kind": "module", "symbol": "evil"}`},
+					{Line: 4, Error: `unknown kind "print"`, Text: `{"version": 1, "kind": "print", "text": "x"}`},
+					{Line: 5, Error: "missing symbol for function", Text: `{"version": 1, "kind": "function", "outcome": "returned"}`},
+					{Line: 6, Error: `unknown outcome "exploded"`, Text: `{"version": 1, "kind": "function", "symbol": "f", "outcome": "exploded"}`},
+					{Line: 7, Error: "missing exception_type for raised outcome", Text: `{"version": 1, "kind": "function", "symbol": "f", "outcome": "raised"}`},
+					{Line: 8, Error: "negative duration_ms -1", Text: `{"version": 1, "kind": "function", "symbol": "f", "outcome": "returned", "duration_ms": -1}`},
+					{Line: 9, Error: "missing text for output", Text: `{"version": 1, "kind": "output"}`},
+					{Line: 10, Error: "outcome is only valid for calls, not module", Text: `{"version": 1, "kind": "module", "symbol": "evil", "outcome": "returned"}`},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ParseExecutionLog([]byte(test.log))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseExecutionLog() = %#v; want %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package dynamicanalysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+var (
+	// homeDirPattern matches the home directory, which differs between profiles
+	// (e.g. /home/runner or /root).
+	homeDirPattern = regexp.MustCompile(`^(/home/[^/]+|/root)(/|$)`)
+
+	// tempNamePattern matches names of temporary files and directories which end
+	// in random characters, such as pip's /tmp/pip-install-3k2j5f0e and Python's
+	// /tmp/tmpa1b2c3d4.
+	tempNamePattern = regexp.MustCompile(`^(pip-[a-z-]+-|tmp)[a-z0-9_]{8}$`)
+
+	// randomHexPattern matches names ending in a random hex string, such as pip's
+	// build directories (<name>_<uuid>) and the files of the github-actions profile
+	// (set_env_<hex>).
+	randomHexPattern = regexp.MustCompile(`_[0-9a-f]{32}$`)
+)
+
+// normalizePath rewrites the parts of a path which differ between profiles, even
+// for the same behaviour: the home directory is replaced by ~, and the random part
+// of temporary names is replaced by *.
+func normalizePath(path string) string {
+	path = homeDirPattern.ReplaceAllString(path, "~$2")
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		part = tempNamePattern.ReplaceAllString(part, "${1}*")
+		parts[i] = randomHexPattern.ReplaceAllString(part, "_*")
+	}
+	return strings.Join(parts, "/")
+}
+
+// behaviour holds the sets of each kind of behaviour observed during a phase.
+type behaviour struct {
+	files, sockets, commands, hostnames map[string]bool
+}
+
+func phaseBehaviour(s *analysisrun.StraceSummary) behaviour {
+	b := behaviour{
+		files:     make(map[string]bool),
+		sockets:   make(map[string]bool),
+		commands:  make(map[string]bool),
+		hostnames: make(map[string]bool),
+	}
+	if s == nil {
+		return b
+	}
+	for _, f := range s.Files {
+		b.files[normalizePath(f.Path)] = true
+	}
+	for _, sock := range s.Sockets {
+		b.sockets[fmt.Sprintf("%s:%d", sock.Address, sock.Port)] = true
+	}
+	for _, c := range s.Commands {
+		args := make([]string, len(c.Command))
+		for i, arg := range c.Command {
+			args[i] = normalizePath(arg)
+		}
+		b.commands[strings.Join(args, " ")] = true
+	}
+	for _, d := range s.DNS {
+		for _, q := range d.Queries {
+			b.hostnames[q.Hostname] = true
+		}
+	}
+	return b
+}
+
+// notInAll returns the sorted items of set which are missing from at least one of others.
+func notInAll(set map[string]bool, others []map[string]bool) []string {
+	var items []string
+	for item := range set {
+		for _, other := range others {
+			if !other[item] {
+				items = append(items, item)
+				break
+			}
+		}
+	}
+	slices.Sort(items)
+	return items
+}
+
+/*
+CompareProfiles compares the results of dynamic analysis of the same package in
+each of the given environment profiles, keyed by profile name. For each profile and
+phase, it lists the behaviour (files, sockets, commands and DNS lookups) that was
+not observed during the same phase in every other profile.
+
+Paths in files and commands are compared after normalizing the parts which differ
+between profiles (see normalizePath), so they are listed in their normalized form,
+e.g. ~/.gitconfig rather than /home/runner/.gitconfig.
+*/
+func CompareProfiles(results map[string]analysisrun.DynamicAnalysisData) analysisrun.ProfileComparison {
+	profiles := maps.Keys(results)
+	slices.Sort(profiles)
+	comparison := analysisrun.ProfileComparison{Profiles: profiles}
+
+	phases := map[analysisrun.DynamicPhase]bool{}
+	for _, data := range results {
+		for phase := range data.StraceSummary {
+			phases[phase] = true
+		}
+	}
+
+	for _, phase := range analysisrun.AllDynamicPhases() {
+		if !phases[phase] {
+			continue
+		}
+
+		behaviours := make(map[string]behaviour, len(profiles))
+		for _, profile := range profiles {
+			behaviours[profile] = phaseBehaviour(results[profile].StraceSummary[phase])
+		}
+
+		for _, profile := range profiles {
+			var others []behaviour
+			for _, other := range profiles {
+				if other != profile {
+					others = append(others, behaviours[other])
+				}
+			}
+			b := behaviours[profile]
+			diff := analysisrun.ProfileDifference{
+				Profile:   profile,
+				Phase:     phase,
+				Files:     notInAll(b.files, fieldOf(others, func(b behaviour) map[string]bool { return b.files })),
+				Sockets:   notInAll(b.sockets, fieldOf(others, func(b behaviour) map[string]bool { return b.sockets })),
+				Commands:  notInAll(b.commands, fieldOf(others, func(b behaviour) map[string]bool { return b.commands })),
+				Hostnames: notInAll(b.hostnames, fieldOf(others, func(b behaviour) map[string]bool { return b.hostnames })),
+			}
+			if s := results[profile].StraceSummary[phase]; s != nil {
+				diff.Status = s.Status
+			}
+			if len(diff.Files) > 0 || len(diff.Sockets) > 0 || len(diff.Commands) > 0 || len(diff.Hostnames) > 0 {
+				comparison.Differences = append(comparison.Differences, diff)
+			}
+		}
+	}
+
+	return comparison
+}
+
+func fieldOf(behaviours []behaviour, field func(behaviour) map[string]bool) []map[string]bool {
+	sets := make([]map[string]bool, len(behaviours))
+	for i, b := range behaviours {
+		sets[i] = field(b)
+	}
+	return sets
+}
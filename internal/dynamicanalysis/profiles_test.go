@@ -0,0 +1,113 @@
+package dynamicanalysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ossf/package-analysis/internal/analysis"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+func TestCompareProfiles(t *testing.T) {
+	common := analysisrun.StraceSummary{
+		Status:   analysis.StatusCompleted,
+		Files:    []analysisrun.FileResult{{Path: "/usr/lib/python3/os.py", Read: true}},
+		Commands: []analysisrun.CommandResult{{Command: []string{"python3", "analyze.py"}}},
+	}
+	ci := common
+	ci.Files = append(ci.Files, analysisrun.FileResult{Path: "/home/runner/.gitconfig", Read: true})
+	ci.Sockets = []analysisrun.SocketResult{{Address: "203.0.113.1", Port: 443}}
+	ci.DNS = []analysisrun.DNSResult{{Class: "IN", Queries: []analysisrun.DNSQueries{{Hostname: "evil.example.com"}}}}
+	laptop := common
+	laptop.Commands = append(laptop.Commands, analysisrun.CommandResult{Command: []string{"sh", "-c", "id"}})
+
+	results := map[string]analysisrun.DynamicAnalysisData{
+		"github-actions": {StraceSummary: analysisrun.DynamicAnalysisStraceSummary{
+			analysisrun.DynamicPhaseInstall: &common,
+			analysisrun.DynamicPhaseImport:  &ci,
+		}},
+		"developer-laptop": {StraceSummary: analysisrun.DynamicAnalysisStraceSummary{
+			analysisrun.DynamicPhaseInstall: &common,
+			analysisrun.DynamicPhaseImport:  &laptop,
+		}},
+		"gcp-vm": {StraceSummary: analysisrun.DynamicAnalysisStraceSummary{
+			analysisrun.DynamicPhaseInstall: &common,
+		}},
+	}
+
+	want := analysisrun.ProfileComparison{
+		Profiles: []string{"developer-laptop", "gcp-vm", "github-actions"},
+		Differences: []analysisrun.ProfileDifference{
+			{
+				Profile:  "developer-laptop",
+				Phase:    analysisrun.DynamicPhaseImport,
+				Status:   analysis.StatusCompleted,
+				Files:    []string{"/usr/lib/python3/os.py"},
+				Commands: []string{"python3 analyze.py", "sh -c id"},
+			},
+			{
+				Profile:   "github-actions",
+				Phase:     analysisrun.DynamicPhaseImport,
+				Status:    analysis.StatusCompleted,
+				Files:     []string{"/usr/lib/python3/os.py", "~/.gitconfig"},
+				Sockets:   []string{"203.0.113.1:443"},
+				Commands:  []string{"python3 analyze.py"},
+				Hostnames: []string{"evil.example.com"},
+			},
+		},
+	}
+
+	got := CompareProfiles(results)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CompareProfiles() = %+v; want %+v", got, want)
+	}
+}
+
+func TestCompareProfilesNormalizesPaths(t *testing.T) {
+	summary := func(home, tmp, buildDir string) *analysisrun.StraceSummary {
+		return &analysisrun.StraceSummary{
+			Status: analysis.StatusCompleted,
+			Files: []analysisrun.FileResult{
+				{Path: home + "/.aws/credentials", Read: true},
+				{Path: home, Read: true},
+				{Path: "/tmp/" + tmp + "/" + buildDir + "/setup.py", Read: true},
+			},
+			Commands: []analysisrun.CommandResult{{Command: []string{"python3", "/tmp/" + tmp + "/" + buildDir + "/setup.py"}}},
+		}
+	}
+
+	results := map[string]analysisrun.DynamicAnalysisData{
+		"developer-laptop": {StraceSummary: analysisrun.DynamicAnalysisStraceSummary{
+			analysisrun.DynamicPhaseInstall: summary("/home/dev", "pip-install-3k2j5f0e", "evil-pkg_1f0e2a46a5d14d7b8c1b2e4f1c3a7d9e"),
+		}},
+		"gcp-vm": {StraceSummary: analysisrun.DynamicAnalysisStraceSummary{
+			analysisrun.DynamicPhaseInstall: summary("/root", "pip-install-a8_x0q2z", "evil-pkg_0a9b8c7d6e5f40312233445566778899"),
+		}},
+	}
+
+	got := CompareProfiles(results)
+	if len(got.Differences) != 0 {
+		t.Errorf("CompareProfiles() differences = %+v; want none", got.Differences)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/home/runner/.gitconfig", want: "~/.gitconfig"},
+		{path: "/root/.npmrc", want: "~/.npmrc"},
+		{path: "/home/admin", want: "~"},
+		{path: "/rootfs/file", want: "/rootfs/file"},
+		{path: "/tmp/tmpa1b2c3d4/out", want: "/tmp/tmp*/out"},
+		{path: "/tmp/pip-build-env-x1y2z3w4/overlay", want: "/tmp/pip-build-env-*/overlay"},
+		{path: "/home/runner/work/_temp/_runner_file_commands/set_env_0123456789abcdef0123456789abcdef", want: "~/work/_temp/_runner_file_commands/set_env_*"},
+		{path: "/usr/lib/python3/os.py", want: "/usr/lib/python3/os.py"},
+	}
+	for _, tt := range tests {
+		if got := normalizePath(tt.path); got != tt.want {
+			t.Errorf("normalizePath(%q) = %q; want %q", tt.path, got, tt.want)
+		}
+	}
+}
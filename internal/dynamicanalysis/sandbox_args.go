@@ -6,11 +6,17 @@ import (
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
+// RegistryURLEnvVar is the name of the environment variable which is set in the
+// sandbox when the package should be installed from a registry other than the default
+// public registry for its ecosystem. Its value is the registry URL (see pkgmanager.RegistryURL).
+const RegistryURLEnvVar = "OSSF_REGISTRY_URL"
+
 // defaultCommand returns the path (in the default sandbox image)
 // of the default dynamic analysis command for the ecosystem
 var defaultCommand = map[pkgecosystem.Ecosystem]string{
 	pkgecosystem.CratesIO:  "/usr/local/bin/analyze-rust.py",
 	pkgecosystem.NPM:       "/usr/local/bin/analyze-node.js",
+	pkgecosystem.NuGet:     "/usr/local/bin/analyze-dotnet.py",
 	pkgecosystem.Packagist: "/usr/local/bin/analyze-php.php",
 	pkgecosystem.PyPI:      "/usr/local/bin/analyze-python.py",
 	pkgecosystem.RubyGems:  "/usr/local/bin/analyze-ruby.rb",
@@ -32,6 +38,9 @@ func MakeAnalysisArgs(p *pkgmanager.Pkg, phase analysisrun.DynamicPhase) []strin
 
 	if p.IsLocal() {
 		args = append(args, "--local", p.LocalPath())
+	} else if p.ArchivePath() != "" {
+		// install from the archive that was already downloaded from the registry
+		args = append(args, "--local", p.ArchivePath())
 	} else if p.Version() != "" {
 		args = append(args, "--version", p.Version())
 	}
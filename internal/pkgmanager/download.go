@@ -1,10 +1,12 @@
 package pkgmanager
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
+
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
 /*
@@ -16,7 +18,7 @@ If any error occurs, the created file is removed.
 
 Callers must ensure that path and url are nonempty, otherwise the function will panic.
 */
-func downloadToPath(path, url string) error {
+func downloadToPath(ctx context.Context, e pkgecosystem.Ecosystem, path, url string) error {
 	if path == "" {
 		panic("path is empty")
 	}
@@ -29,7 +31,7 @@ func downloadToPath(path, url string) error {
 		return err
 	}
 
-	if downloadErr := downloadToFile(file, url); downloadErr != nil {
+	if downloadErr := downloadToFile(ctx, e, file, url); downloadErr != nil {
 		// cleanup file
 		if removeErr := os.Remove(path); removeErr != nil {
 			return fmt.Errorf("%w\n%v", downloadErr, removeErr)
@@ -50,27 +52,25 @@ func downloadToPath(path, url string) error {
 
 /*
 downloadToFile writes the contents of whatever is at the given URL to the
-given file, without opening or closing the file. If any errors occur while
-making the network request, then no file operations will be performed.
+given file, without opening or closing the file. Registry credentials for the
+ecosystem are used if the URL is on the registry host. If any errors occur while
+making the network request, then no file operations will be performed. If there
+is nothing at the URL, ErrVersionNotFound is returned.
 
 Callers must ensure that url is nonempty, otherwise the function will panic.
 */
-func downloadToFile(dest *os.File, url string) error {
+func downloadToFile(ctx context.Context, e pkgecosystem.Ecosystem, dest *os.File, url string) error {
 	if url == "" {
 		panic("url is empty")
 	}
 
-	resp, err := http.Get(url)
+	resp, err := registryGet(ctx, e, url, ErrVersionNotFound)
 	if err != nil {
 		return err
 	}
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http status %s", resp.Status)
-	}
-
 	if _, err := io.Copy(dest, resp.Body); err != nil {
 		return err
 	}
@@ -1,6 +1,10 @@
 package pkgmanager
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ossf/package-analysis/internal/utils"
@@ -87,6 +91,20 @@ var downloadTestCases = []downloadTestCase{
 		pkgVersion: "123",
 		wantErr:    true,
 	},
+	{
+		name:       "NuGet Newtonsoft.Json valid version",
+		ecosystem:  pkgecosystem.NuGet,
+		pkgName:    "Newtonsoft.Json",
+		pkgVersion: "13.0.3",
+		wantErr:    false,
+	},
+	{
+		name:       "NuGet Newtonsoft.Json invalid version",
+		ecosystem:  pkgecosystem.NuGet,
+		pkgName:    "Newtonsoft.Json",
+		pkgVersion: "13.0.3333",
+		wantErr:    true,
+	},
 	{
 		name:        "pypi black 23.3.0",
 		ecosystem:   pkgecosystem.PyPI,
@@ -117,7 +135,7 @@ func TestDownload(t *testing.T) {
 	for _, tt := range downloadTestCases {
 		t.Run(tt.name, func(t *testing.T) {
 			downloadDir := t.TempDir()
-			downloadPath, err := Manager(tt.ecosystem).DownloadArchive(tt.pkgName, tt.pkgVersion, downloadDir)
+			downloadPath, err := Manager(tt.ecosystem).DownloadArchive(context.Background(), tt.pkgName, tt.pkgVersion, downloadDir)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Want error: %v; got error: %v", tt.wantErr, err)
 				return
@@ -143,3 +161,80 @@ func TestDownload(t *testing.T) {
 		})
 	}
 }
+
+// downloadTestRegistries holds the responses served by the local test registry for
+// each ecosystem in downloadTestCases (see useTestRegistry).
+var downloadTestRegistries = map[pkgecosystem.Ecosystem]map[string]string{
+	pkgecosystem.NPM: {
+		"/async/latest":            `{"dist": {"tarball": "{{registry}}/async/-/async-3.2.4.tgz", "integrity": "` + testArchiveIntegrity + `"}}`,
+		"/async/3.2.4":             `{"dist": {"tarball": "{{registry}}/async/-/async-3.2.4.tgz", "integrity": "` + testArchiveIntegrity + `"}}`,
+		"/async/-/async-3.2.4.tgz": "archive",
+	},
+	pkgecosystem.PyPI: {
+		"/pypi/urllib3/1.26.11/json":    `{"urls": [{"packagetype": "sdist", "filename": "urllib3-1.26.11.tar.gz", "url": "{{registry}}/files/urllib3-1.26.11.tar.gz"}]}`,
+		"/files/urllib3-1.26.11.tar.gz": "archive",
+		"/pypi/black/23.3.0/json": `{"urls": [
+			{"packagetype": "bdist_wheel", "filename": "black-23.3.0-py3-none-any.whl", "url": "{{registry}}/files/black-23.3.0-py3-none-any.whl"},
+			{"packagetype": "sdist", "filename": "black-23.3.0.tar.gz", "url": "{{registry}}/files/black-23.3.0.tar.gz", "digests": {"sha256": "` + testArchiveSHA256 + `"}}
+		]}`,
+		"/files/black-23.3.0.tar.gz": "archive",
+	},
+	pkgecosystem.CratesIO: {
+		"/api/v1/crates/rand/0.8.5":          `{"version": {"checksum": "` + testArchiveSHA256 + `"}}`,
+		"/api/v1/crates/rand/0.8.5/download": "archive",
+	},
+	pkgecosystem.NuGet: {
+		"/": `{"resources": [{"@id": "{{registry}}/flat/", "@type": "PackageBaseAddress/3.0.0"}]}`,
+		"/flat/newtonsoft.json/13.0.3/newtonsoft.json.13.0.3.nupkg": "archive",
+	},
+}
+
+// TestDownloadLocalRegistry runs the cases of TestDownload against local test registries,
+// which serve the test archive for each package version that exists.
+func TestDownloadLocalRegistry(t *testing.T) {
+	for _, tt := range downloadTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestRegistry(t, tt.ecosystem, downloadTestRegistries[tt.ecosystem])
+			downloadPath, err := Manager(tt.ecosystem).DownloadArchive(context.Background(), tt.pkgName, tt.pkgVersion, t.TempDir())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Want error: %v; got error: %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			gotHash, err := utils.SHA256Hash(downloadPath)
+			if err != nil {
+				t.Fatalf("hashing failed: %v", err)
+			}
+			if gotHash != testArchiveSHA256 {
+				t.Errorf("Expected hash %s, got %s", testArchiveSHA256, gotHash)
+			}
+		})
+	}
+}
+
+func TestDownloadInvalidFilename(t *testing.T) {
+	useTestRegistry(t, pkgecosystem.PyPI, map[string]string{
+		"/pypi/evil/1.0/json":    `{"urls": [{"packagetype": "sdist", "filename": "../../evil-1.0.tar.gz", "url": "{{registry}}/files/evil-1.0.tar.gz"}]}`,
+		"/files/evil-1.0.tar.gz": "archive",
+	})
+
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Manager(pkgecosystem.PyPI).DownloadArchive(context.Background(), "evil", "1.0", dir)
+	if !errors.Is(err, ErrInvalidArchiveFilename) {
+		t.Errorf("DownloadArchive() error = %v; want %v", err, ErrInvalidArchiveFilename)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "..", "evil-1.0.tar.gz")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("archive written outside download directory (stat error = %v)", err)
+	}
+
+	_, err = Manager(pkgecosystem.PyPI).Download(context.Background(), Archive{URL: "http://example.com/x", Filename: "../x"}, dir)
+	if !errors.Is(err, ErrInvalidArchiveFilename) {
+		t.Errorf("Download() error = %v; want %v", err, ErrInvalidArchiveFilename)
+	}
+}
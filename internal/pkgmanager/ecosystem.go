@@ -1,29 +1,138 @@
 package pkgmanager
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
-var ErrNoArchiveURL = errors.New("archive URL not found")
+var (
+	ErrNoArchiveURL = errors.New("archive URL not found")
+
+	// ErrInvalidArchiveFilename is returned if the filename of an archive is not a
+	// single path element, and so cannot be used as the name of the downloaded file.
+	ErrInvalidArchiveFilename = errors.New("invalid archive filename")
+)
+
+// ArchiveType selects which kind of distribution archive should be used, for
+// ecosystems that publish more than one kind of archive for each package version
+// (e.g. source distributions and wheels on PyPI).
+type ArchiveType string
+
+const (
+	// DefaultArchive selects the single archive that is used when no preference
+	// is given. This is the only type supported by every ecosystem.
+	DefaultArchive ArchiveType = ""
+
+	// SourceArchive selects a source distribution (e.g. a PyPI sdist).
+	SourceArchive ArchiveType = "sdist"
+
+	// WheelArchive selects a built (binary) distribution in wheel format.
+	WheelArchive ArchiveType = "wheel"
+
+	// AllArchives selects every archive published for the package version.
+	AllArchives ArchiveType = "all"
+)
+
+// ParseArchiveType returns the ArchiveType corresponding to the given string,
+// or an error if there is no such ArchiveType. The string "default" may be used
+// as an alias of the empty string.
+func ParseArchiveType(s string) (ArchiveType, error) {
+	switch t := ArchiveType(s); t {
+	case DefaultArchive, SourceArchive, WheelArchive, AllArchives:
+		return t, nil
+	case "default":
+		return DefaultArchive, nil
+	default:
+		return "", fmt.Errorf("unknown archive type %q", s)
+	}
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (t *ArchiveType) UnmarshalText(text []byte) error {
+	archiveType, err := ParseArchiveType(string(text))
+	if err != nil {
+		return err
+	}
+
+	*t = archiveType
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t ArchiveType) MarshalText() ([]byte, error) {
+	return []byte(t), nil
+}
+
+// String implements the fmt.Stringer interface.
+func (t ArchiveType) String() string {
+	if t == DefaultArchive {
+		return "default"
+	}
+	return string(t)
+}
+
+// Archive describes a single downloadable distribution archive for a package version.
+type Archive struct {
+	// URL is the location that the archive can be downloaded from.
+	URL string
+
+	// Filename is the name used for the archive when it is downloaded.
+	Filename string
+
+	// Type records the kind of the archive. It is DefaultArchive for
+	// ecosystems that only publish one kind of archive.
+	Type ArchiveType
+
+	// Digest is the checksum of the archive published by the registry, which
+	// is used to verify the archive when it is downloaded. It is zero if the
+	// registry does not publish a checksum for the archive.
+	Digest Digest
+}
+
+// CheckArchiveFilename returns ErrInvalidArchiveFilename if filename is not a single
+// path element. Filenames may be given by the registry, so they must be checked before
+// they are joined to a directory path.
+func CheckArchiveFilename(filename string) error {
+	if filename == "" || filename == "." || filename == ".." ||
+		filepath.Base(filename) != filename || strings.ContainsAny(filename, `/\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidArchiveFilename, filename)
+	}
+	return nil
+}
 
 // PkgManager represents how packages from a common ecosystem are accessed.
 type PkgManager struct {
 	ecosystem       pkgecosystem.Ecosystem
-	latestVersion   func(name string) (string, error)
-	archiveURL      func(name, version string) (string, error)
+	latestVersion   func(ctx context.Context, name string) (string, error)
 	archiveFilename func(name, version, downloadURL string) string
 	extractArchive  func(path, outputDir string) error
+	// archives lists every archive available for a package version, along with
+	// their digests. The first archive listed is the default archive.
+	archives func(ctx context.Context, name, version string) ([]Archive, error)
+	// archiveURL is only used if archives is nil, in which case the only archive
+	// available is the one given by archiveURL, and it cannot be verified.
+	archiveURL func(ctx context.Context, name, version string) (string, error)
+	metadata   func(ctx context.Context, name, version string) (*analysisrun.PackageMetadata, error)
+	// versionScheme and releases are used to resolve version specs to versions.
+	versionScheme *versionScheme
+	releases      func(ctx context.Context, name string) ([]Release, error)
+	// distTags maps each dist-tag of a package to a version. It is nil for
+	// ecosystems without dist-tags.
+	distTags func(ctx context.Context, name string) (map[string]string, error)
 }
 
 var (
 	supportedPkgManagers = map[pkgecosystem.Ecosystem]*PkgManager{
 		npmPkgManager.ecosystem:       &npmPkgManager,
+		nugetPkgManager.ecosystem:     &nugetPkgManager,
 		pypiPkgManager.ecosystem:      &pypiPkgManager,
 		rubygemsPkgManager.ecosystem:  &rubygemsPkgManager,
 		packagistPkgManager.ecosystem: &packagistPkgManager,
@@ -54,9 +163,11 @@ func (p *PkgManager) Ecosystem() pkgecosystem.Ecosystem {
 	return p.ecosystem
 }
 
-func (p *PkgManager) Latest(name string) (*Pkg, error) {
+// Latest returns the latest version of the named package. ErrPackageNotFound is
+// returned if the package does not exist in the registry.
+func (p *PkgManager) Latest(ctx context.Context, name string) (*Pkg, error) {
 	name = normalizePkgName(name)
-	version, err := p.latestVersion(name)
+	version, err := p.latestVersion(ctx, name)
 	if err != nil {
 		return nil, err
 	}
@@ -93,28 +204,110 @@ directory specifies the destination directory for the archive.
 If an empty string is passed, the current directory is used.
 
 If an error occurs during download of the file, it is returned along with
-an empty path value.
+an empty path value. ErrInvalidArchiveFilename is returned if the filename of
+the archive is not a single path element. If the archive does not match the checksum published by
+the registry, a *ChecksumMismatchError is returned.
 */
-func (p *PkgManager) DownloadArchive(name, version, directory string) (string, error) {
-	if directory == "" {
-		directory = "."
+func (p *PkgManager) DownloadArchive(ctx context.Context, name, version, directory string) (string, error) {
+	archives, err := p.Archives(ctx, name, version, DefaultArchive)
+	if err != nil {
+		return "", err
 	}
 
-	downloadURL, err := p.archiveURL(name, version)
+	return p.Download(ctx, archives[0], directory)
+}
+
+/*
+Archives returns the distribution archives of the given package name and version
+which match the given ArchiveType.
+
+For ecosystems which only publish a single archive per version, the archive
+type is ignored and the single archive is returned. Otherwise, DefaultArchive
+selects the same archive that DownloadArchive would download, and AllArchives
+selects every archive. If no archive matches, ErrNoArchiveURL is returned.
+If the package version does not exist, ErrVersionNotFound (or ErrPackageNotFound)
+is returned.
+*/
+func (p *PkgManager) Archives(ctx context.Context, name, version string, archiveType ArchiveType) ([]Archive, error) {
+	if p.archives == nil {
+		downloadURL, err := p.archiveURL(ctx, name, version)
+		if err != nil {
+			return nil, err
+		}
+		if downloadURL == "" {
+			return nil, fmt.Errorf("%w: package %s @ %s", ErrNoArchiveURL, name, version)
+		}
+		filename := p.archiveFilename(name, version, downloadURL)
+		if err := CheckArchiveFilename(filename); err != nil {
+			return nil, err
+		}
+		return []Archive{{
+			URL:      downloadURL,
+			Filename: filename,
+		}}, nil
+	}
+
+	all, err := p.archives(ctx, name, version)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	for _, a := range all {
+		if err := CheckArchiveFilename(a.Filename); err != nil {
+			return nil, err
+		}
+	}
+
+	var matching []Archive
+	for _, a := range all {
+		if archiveType == AllArchives || a.Type == archiveType {
+			matching = append(matching, a)
+		}
 	}
-	if downloadURL == "" {
-		return "", fmt.Errorf("%w: package %s @ %s", ErrNoArchiveURL, name, version)
+	// the archive type is ignored for ecosystems that only publish one kind of archive
+	if len(all) > 0 && (archiveType == DefaultArchive || all[0].Type == DefaultArchive) {
+		matching = all[:1]
 	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("%w: package %s @ %s (type %s)", ErrNoArchiveURL, name, version, archiveType)
+	}
+
+	return matching, nil
+}
 
-	baseFilename := p.archiveFilename(name, version, downloadURL)
-	if baseFilename == "" {
+/*
+Download downloads the given archive to the specified directory, and returns
+the path to the downloaded archive.
+
+directory specifies the destination directory for the archive.
+If an empty string is passed, the current directory is used.
+
+If an error occurs during download of the file, it is returned along with
+an empty path value. ErrInvalidArchiveFilename is returned if the filename of
+the archive is not a single path element. If the archive has a Digest and the downloaded file does
+not match it, the file is removed and a *ChecksumMismatchError is returned.
+*/
+func (p *PkgManager) Download(ctx context.Context, archive Archive, directory string) (string, error) {
+	if directory == "" {
+		directory = "."
+	}
+
+	if archive.Filename == "" {
 		panic("base filename for archive is empty")
 	}
 
-	destPath := filepath.Join(directory, baseFilename)
-	if err := downloadToPath(destPath, downloadURL); err != nil {
+	if err := CheckArchiveFilename(archive.Filename); err != nil {
+		return "", err
+	}
+
+	destPath := filepath.Join(directory, archive.Filename)
+	if err := downloadToPath(ctx, p.ecosystem, destPath, archive.URL); err != nil {
+		return "", err
+	}
+
+	if err := VerifyDigest(archive, destPath); err != nil {
+		if removeErr := os.Remove(destPath); removeErr != nil {
+			return "", fmt.Errorf("%w\n%v", err, removeErr)
+		}
 		return "", err
 	}
 
@@ -0,0 +1,149 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseArchiveType(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ArchiveType
+		wantErr bool
+	}{
+		{input: "", want: DefaultArchive},
+		{input: "default", want: DefaultArchive},
+		{input: "sdist", want: SourceArchive},
+		{input: "wheel", want: WheelArchive},
+		{input: "all", want: AllArchives},
+		{input: "egg", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseArchiveType(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseArchiveType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseArchiveType() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchives(t *testing.T) {
+	sdist := Archive{URL: "https://example.com/pkg-1.0.tar.gz", Filename: "pkg-1.0.tar.gz", Type: SourceArchive}
+	wheel := Archive{URL: "https://example.com/pkg-1.0-py3-none-any.whl", Filename: "pkg-1.0-py3-none-any.whl", Type: WheelArchive}
+
+	multi := &PkgManager{
+		archives: func(_ context.Context, _, _ string) ([]Archive, error) {
+			return []Archive{sdist, wheel}, nil
+		},
+	}
+	wheelOnly := &PkgManager{
+		archives: func(_ context.Context, _, _ string) ([]Archive, error) {
+			return []Archive{wheel}, nil
+		},
+	}
+	hostile := &PkgManager{
+		archives: func(_ context.Context, _, _ string) ([]Archive, error) {
+			return []Archive{sdist, {URL: "https://example.com/x", Filename: "../../x", Type: WheelArchive}}, nil
+		},
+	}
+	single := &PkgManager{
+		archiveURL:      func(_ context.Context, _, _ string) (string, error) { return "https://example.com/pkg-1.0.tgz", nil },
+		archiveFilename: defaultArchiveFilename,
+	}
+
+	tests := []struct {
+		name        string
+		manager     *PkgManager
+		archiveType ArchiveType
+		want        []Archive
+		wantErr     error
+	}{
+		{
+			name:        "default",
+			manager:     multi,
+			archiveType: DefaultArchive,
+			want:        []Archive{sdist},
+		},
+		{
+			name:        "wheel",
+			manager:     multi,
+			archiveType: WheelArchive,
+			want:        []Archive{wheel},
+		},
+		{
+			name:        "all",
+			manager:     multi,
+			archiveType: AllArchives,
+			want:        []Archive{sdist, wheel},
+		},
+		{
+			name:        "default wheel only",
+			manager:     wheelOnly,
+			archiveType: DefaultArchive,
+			want:        []Archive{wheel},
+		},
+		{
+			name:        "sdist missing",
+			manager:     wheelOnly,
+			archiveType: SourceArchive,
+			wantErr:     ErrNoArchiveURL,
+		},
+		{
+			name:        "filename outside directory",
+			manager:     hostile,
+			archiveType: DefaultArchive,
+			wantErr:     ErrInvalidArchiveFilename,
+		},
+		{
+			name:        "single archive ecosystem ignores type",
+			manager:     single,
+			archiveType: WheelArchive,
+			want:        []Archive{{URL: "https://example.com/pkg-1.0.tgz", Filename: "pkg-1.0.tgz"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.manager.Archives(context.Background(), "pkg", "1.0", tt.archiveType)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Archives() error = %v; want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Archives() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckArchiveFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		wantErr  bool
+	}{
+		{filename: "pkg-1.0.tar.gz"},
+		{filename: "@scope-pkg-1.0.tgz"},
+		{filename: "", wantErr: true},
+		{filename: ".", wantErr: true},
+		{filename: "..", wantErr: true},
+		{filename: "../../x", wantErr: true},
+		{filename: "dir/pkg-1.0.tar.gz", wantErr: true},
+		{filename: "/pkg-1.0.tar.gz", wantErr: true},
+		{filename: `..\pkg-1.0.tar.gz`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			err := CheckArchiveFilename(tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckArchiveFilename(%q) = %v; want error: %v", tt.filename, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidArchiveFilename) {
+				t.Errorf("CheckArchiveFilename(%q) = %v; want %v", tt.filename, err, ErrInvalidArchiveFilename)
+			}
+		})
+	}
+}
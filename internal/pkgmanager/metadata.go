@@ -0,0 +1,96 @@
+package pkgmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+/*
+Metadata fetches information about the given package version from the registry,
+such as when it was published, its maintainers and its declared license.
+See analysisrun.PackageMetadata for the information that is collected.
+*/
+func (p *PkgManager) Metadata(ctx context.Context, name, version string) (*analysisrun.PackageMetadata, error) {
+	if p.metadata == nil {
+		return nil, fmt.Errorf("metadata collection not implemented for %s", p.Ecosystem())
+	}
+	return p.metadata(ctx, name, version)
+}
+
+// getRegistryJSON fetches the given URL from the registry for the ecosystem, and
+// decodes the JSON response into v. If there is nothing at the URL, notFound is
+// returned (see registryClient.get).
+func getRegistryJSON(ctx context.Context, e pkgecosystem.Ecosystem, url string, notFound error, v any) error {
+	resp, err := registryGet(ctx, e, url, notFound)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+
+	return nil
+}
+
+/*
+setReleaseHistory fills in the publish time, version count and previous release fields
+of md, given the publish time of every version of the package. Versions with an unknown
+(zero) publish time are counted, but are not considered as previous releases.
+*/
+func setReleaseHistory(md *analysisrun.PackageMetadata, version string, releases map[string]time.Time) {
+	md.VersionCount = len(releases)
+
+	published, ok := releases[version]
+	if !ok || published.IsZero() {
+		return
+	}
+	if md.PublishedTime == nil {
+		md.PublishedTime = timePtr(published)
+	}
+
+	var previousVersion string
+	var previousTime time.Time
+	for v, t := range releases {
+		if v == version || t.IsZero() || !t.Before(published) {
+			continue
+		}
+		if t.After(previousTime) {
+			previousVersion, previousTime = v, t
+		}
+	}
+
+	if previousVersion != "" {
+		md.PreviousVersion = previousVersion
+		md.PreviousPublishedTime = timePtr(previousTime)
+		md.SecondsSincePreviousRelease = int64(published.Sub(previousTime).Seconds())
+	}
+}
+
+// repositoryHosts lists hosts of source code repositories, which are used to recognise
+// repository URLs when a registry only publishes a project (homepage) URL.
+var repositoryHosts = []string{"github.com", "gitlab.com", "bitbucket.org"}
+
+// isRepositoryURL returns true if u is on the host of a source code repository.
+func isRepositoryURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && slices.Contains(repositoryHosts, parsed.Host)
+}
+
+// timePtr returns a pointer to t in UTC, or nil if t is zero.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
@@ -0,0 +1,201 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+func TestMetadata(t *testing.T) {
+	previous := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	published := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	const secondsSincePrevious = 3 * 365 * 24 * 60 * 60
+
+	tests := []struct {
+		name      string
+		ecosystem pkgecosystem.Ecosystem
+		pkgName   string
+		responses map[string]string
+		want      analysisrun.PackageMetadata
+	}{
+		{
+			name:      "npm",
+			ecosystem: pkgecosystem.NPM,
+			pkgName:   "test",
+			responses: map[string]string{
+				"/test": `{
+					"time": {"created": "2019-01-01T00:00:00.000Z", "0.9.0": "2020-01-01T00:00:00.000Z", "1.0.0": "2023-01-01T00:00:00.000Z"},
+					"maintainers": [{"name": "alice", "email": "alice@example.com"}],
+					"repository": {"type": "git", "url": "git+https://github.com/example/old.git"},
+					"versions": {
+						"0.9.0": {},
+						"1.0.0": {
+							"license": "MIT",
+							"repository": {"type": "git", "url": "git+https://github.com/example/test.git"},
+							"deprecated": "use something else",
+							"scripts": {"test": "jest", "postinstall": "node install.js"}
+						}
+					}
+				}`,
+			},
+			want: analysisrun.PackageMetadata{
+				Maintainers:        []string{"alice"},
+				RepositoryURL:      "git+https://github.com/example/test.git",
+				License:            "MIT",
+				Deprecated:         true,
+				DeprecationMessage: "use something else",
+				InstallScripts:     map[string]string{"postinstall": "node install.js"},
+			},
+		},
+		{
+			name:      "pypi",
+			ecosystem: pkgecosystem.PyPI,
+			pkgName:   "test",
+			responses: map[string]string{
+				"/pypi/test/1.0.0/json": `{"info": {
+					"version": "1.0.0", "author": "Alice", "maintainer": "Bob", "license": "MIT",
+					"project_urls": {"Homepage": "https://example.com", "Tracker": "https://github.com/example/test/issues"},
+					"yanked": true
+				}}`,
+				"/pypi/test/json": `{"releases": {
+					"0.9.0": [{"upload_time_iso_8601": "2020-01-01T00:00:00.000000Z"}],
+					"1.0.0": [{"upload_time_iso_8601": "2023-01-01T00:00:00.000000Z"}, {"upload_time_iso_8601": "2023-01-02T00:00:00.000000Z"}]
+				}}`,
+			},
+			want: analysisrun.PackageMetadata{
+				Maintainers:   []string{"Bob", "Alice"},
+				RepositoryURL: "https://github.com/example/test/issues",
+				License:       "MIT",
+				Yanked:        true,
+			},
+		},
+		{
+			name:      "rubygems",
+			ecosystem: pkgecosystem.RubyGems,
+			pkgName:   "test",
+			responses: map[string]string{
+				"/api/v2/rubygems/test/versions/1.0.0.json": `{
+					"version_created_at": "2023-01-01T00:00:00.000Z", "licenses": ["MIT", "Apache-2.0"],
+					"metadata": {"source_code_uri": "https://github.com/example/test"}
+				}`,
+				"/api/v1/versions/test.json": `[
+					{"number": "1.0.0", "created_at": "2023-01-01T00:00:00.000Z", "platform": "ruby"},
+					{"number": "1.0.0", "created_at": "2023-01-01T01:00:00.000Z", "platform": "java"},
+					{"number": "0.9.0", "created_at": "2020-01-01T00:00:00.000Z", "platform": "ruby"}
+				]`,
+				"/api/v1/gems/test/owners.json": `[{"handle": "alice"}]`,
+			},
+			want: analysisrun.PackageMetadata{
+				Maintainers:   []string{"alice"},
+				RepositoryURL: "https://github.com/example/test",
+				License:       "MIT OR Apache-2.0",
+			},
+		},
+		{
+			name:      "crates.io",
+			ecosystem: pkgecosystem.CratesIO,
+			pkgName:   "test",
+			responses: map[string]string{
+				"/api/v1/crates/test": `{
+					"crate": {"repository": "https://github.com/example/test"},
+					"versions": [
+						{"num": "1.0.0", "created_at": "2023-01-01T00:00:00+00:00", "license": "MIT"},
+						{"num": "0.9.0", "created_at": "2020-01-01T00:00:00+00:00", "yanked": true}
+					]
+				}`,
+				"/api/v1/crates/test/owners": `{"users": [{"login": "alice"}]}`,
+			},
+			want: analysisrun.PackageMetadata{
+				Maintainers:   []string{"alice"},
+				RepositoryURL: "https://github.com/example/test",
+				License:       "MIT",
+			},
+		},
+		{
+			name:      "packagist",
+			ecosystem: pkgecosystem.Packagist,
+			pkgName:   "vendor/test",
+			responses: map[string]string{
+				"/p2/vendor/test.json": `{"minified": "composer/2.0", "packages": {"vendor/test": [
+					{
+						"version": "1.0.0", "time": "2023-01-01T00:00:00+00:00", "license": ["MIT"],
+						"authors": [{"name": "Alice"}], "source": {"url": "https://github.com/example/test.git"},
+						"type": "composer-plugin", "extra": {"class": "Vendor\\Plugin"}, "abandoned": "vendor/other"
+					},
+					{"version": "0.9.0", "time": "2020-01-01T00:00:00+00:00", "type": "library", "extra": "__unset"}
+				]}}`,
+			},
+			want: analysisrun.PackageMetadata{
+				Maintainers:        []string{"Alice"},
+				RepositoryURL:      "https://github.com/example/test.git",
+				License:            "MIT",
+				Deprecated:         true,
+				DeprecationMessage: "use vendor/other instead",
+				InstallScripts:     map[string]string{"composer-plugin": `"Vendor\\Plugin"`},
+			},
+		},
+		{
+			name:      "nuget",
+			ecosystem: pkgecosystem.NuGet,
+			pkgName:   "Test",
+			responses: map[string]string{
+				"/": `{"resources": [
+					{"@id": "{{registry}}/registration/", "@type": "RegistrationsBaseUrl/3.6.0"}
+				]}`,
+				"/registration/test/index.json": `{"items": [{"@id": "{{registry}}/registration/test/page.json"}]}`,
+				"/registration/test/page.json": `{"items": [
+					{"catalogEntry": {"version": "0.9.0", "published": "2020-01-01T00:00:00+00:00"}},
+					{"catalogEntry": {
+						"version": "1.0.0", "published": "2023-01-01T00:00:00+00:00", "authors": "Alice, Bob",
+						"licenseExpression": "MIT", "projectUrl": "https://github.com/example/test", "listed": true,
+						"deprecation": {"reasons": ["Legacy"]}
+					}}
+				]}`,
+			},
+			want: analysisrun.PackageMetadata{
+				Maintainers:        []string{"Alice", "Bob"},
+				RepositoryURL:      "https://github.com/example/test",
+				License:            "MIT",
+				Deprecated:         true,
+				DeprecationMessage: "Legacy",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestRegistry(t, tt.ecosystem, tt.responses)
+
+			got, err := Manager(tt.ecosystem).Metadata(context.Background(), tt.pkgName, "1.0.0")
+			if err != nil {
+				t.Fatalf("Metadata() = %v", err)
+			}
+
+			want := tt.want
+			want.PublishedTime = &published
+			want.VersionCount = 2
+			want.PreviousVersion = "0.9.0"
+			want.PreviousPublishedTime = &previous
+			want.SecondsSincePreviousRelease = secondsSincePrevious + 24*60*60 // 2020 is a leap year
+
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("Metadata() = %+v; want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestMetadataVersionNotFound(t *testing.T) {
+	useTestRegistry(t, pkgecosystem.CratesIO, map[string]string{
+		"/api/v1/crates/test":        `{"versions": [{"num": "0.9.0"}]}`,
+		"/api/v1/crates/test/owners": `{"users": []}`,
+	})
+
+	if _, err := Manager(pkgecosystem.CratesIO).Metadata(context.Background(), "test", "1.0.0"); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Metadata() error = %v; want %v", err, ErrVersionNotFound)
+	}
+}
@@ -1,13 +1,14 @@
 package pkgmanager
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ossf/package-analysis/internal/utils"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
@@ -15,9 +16,7 @@ import (
 // when package information is requested.
 // See https://github.com/npm/registry/blob/master/docs/responses/package-metadata.md
 type npmPackageJSON struct {
-	DistTags struct {
-		Latest string `json:"latest"`
-	} `json:"dist-tags"`
+	DistTags map[string]string `json:"dist-tags"`
 }
 
 // npmVersionJSON represents relevant JSON data from the NPM registry response
@@ -25,25 +24,30 @@ type npmPackageJSON struct {
 // See https://github.com/npm/registry/blob/master/docs/responses/package-metadata.md
 type npmVersionJSON struct {
 	Dist struct {
-		Tarball string `json:"tarball"`
+		Tarball   string `json:"tarball"`
+		Integrity string `json:"integrity"`
+		Shasum    string `json:"shasum"`
 	} `json:"dist"`
 }
 
-func getNPMLatest(pkg string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://registry.npmjs.org/%s", pkg))
+func getNPMLatest(ctx context.Context, pkg string) (string, error) {
+	tags, err := getNPMDistTags(ctx, pkg)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	decoder := json.NewDecoder(resp.Body)
+	return tags["latest"], nil
+}
+
+// getNPMDistTags returns the dist-tags of the package, such as "latest" and "next".
+// See https://docs.npmjs.com/cli/v9/commands/npm-dist-tag
+func getNPMDistTags(ctx context.Context, pkg string) (map[string]string, error) {
 	var details npmPackageJSON
-	err = decoder.Decode(&details)
-	if err != nil {
-		return "", err
+	if err := getRegistryJSON(ctx, pkgecosystem.NPM, fmt.Sprintf("%s/%s", RegistryURL(pkgecosystem.NPM), pkg), ErrPackageNotFound, &details); err != nil {
+		return nil, err
 	}
 
-	return details.DistTags.Latest, nil
+	return details.DistTags, nil
 }
 
 /*
@@ -59,34 +63,158 @@ func getNPMArchiveFilename(pkgName, version, _ string) string {
 	return fmt.Sprintf("%s-%s.tgz", cleanedName, version)
 }
 
-func getNPMArchiveURL(pkgName, version string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://registry.npmjs.org/%s/%s", pkgName, version))
-	if err != nil {
-		return "", err
+/*
+getNPMArchives returns the tarball for the given package version. Its digest is taken
+from the dist.integrity field, or from the (SHA-1) dist.shasum field for packages
+published before integrity was supported.
+*/
+func getNPMArchives(ctx context.Context, pkgName, version string) ([]Archive, error) {
+	var packageInfo npmVersionJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.NPM, fmt.Sprintf("%s/%s/%s", RegistryURL(pkgecosystem.NPM), pkgName, version), ErrVersionNotFound, &packageInfo); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	responseBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading HTTP response: %w", err)
+	dist := packageInfo.Dist
+	if dist.Tarball == "" {
+		return nil, nil
 	}
 
-	responseString := string(responseBytes)
+	digest := parseSRIDigest(dist.Integrity)
+	if digest.IsZero() {
+		digest = hexDigest(SHA1, dist.Shasum)
+	}
 
-	decoder := json.NewDecoder(strings.NewReader(responseString))
-	var packageInfo npmVersionJSON
-	if err := decoder.Decode(&packageInfo); err != nil {
-		// invalid version, non-existent package, etc. Details in responseString
-		return "", fmt.Errorf("%w. NPM response: %s", err, responseString)
+	return []Archive{{
+		URL:      dist.Tarball,
+		Filename: getNPMArchiveFilename(pkgName, version, dist.Tarball),
+		Digest:   digest,
+	}}, nil
+}
+
+// npmPackumentJSON represents the relevant JSON data from the full package document
+// (packument) returned by the NPM registry for a package.
+// See https://github.com/npm/registry/blob/master/docs/responses/package-metadata.md
+type npmPackumentJSON struct {
+	// Time maps each version to when it was published. It also contains the
+	// "created" and "modified" keys, and an object if the package is unpublished.
+	Time        map[string]json.RawMessage `json:"time"`
+	Maintainers []struct {
+		Name string `json:"name"`
+	} `json:"maintainers"`
+	Repository json.RawMessage            `json:"repository"`
+	Versions   map[string]npmManifestJSON `json:"versions"`
+}
+
+// npmManifestJSON represents the relevant fields of the package.json manifest of a package version.
+type npmManifestJSON struct {
+	License    json.RawMessage   `json:"license"`
+	Repository json.RawMessage   `json:"repository"`
+	Deprecated json.RawMessage   `json:"deprecated"`
+	Scripts    map[string]string `json:"scripts"`
+}
+
+// npmInstallScripts lists the lifecycle scripts which npm runs when a package is installed.
+// See https://docs.npmjs.com/cli/v9/using-npm/scripts#npm-install
+var npmInstallScripts = []string{"preinstall", "install", "postinstall"}
+
+/*
+npmStringOrField returns the value of a package.json field which is either a string or
+an object (e.g. "repository": {"type": "git", "url": "..."}). If it is an object, the
+value of the given key is returned.
+*/
+func npmStringOrField(raw json.RawMessage, key string) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		if s, ok := obj[key].(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+func getNPMMetadata(ctx context.Context, pkgName, version string) (*analysisrun.PackageMetadata, error) {
+	var packument npmPackumentJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.NPM, fmt.Sprintf("%s/%s", RegistryURL(pkgecosystem.NPM), pkgName), ErrPackageNotFound, &packument); err != nil {
+		return nil, err
+	}
+
+	manifest, ok := packument.Versions[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s @ %s", ErrVersionNotFound, pkgName, version)
+	}
+
+	md := &analysisrun.PackageMetadata{
+		License: npmStringOrField(manifest.License, "type"),
+	}
+
+	for _, m := range packument.Maintainers {
+		md.Maintainers = append(md.Maintainers, m.Name)
+	}
+
+	md.RepositoryURL = npmStringOrField(manifest.Repository, "url")
+	if md.RepositoryURL == "" {
+		md.RepositoryURL = npmStringOrField(packument.Repository, "url")
+	}
+
+	// deprecated is a message, but some packages set it to a boolean
+	if message := npmStringOrField(manifest.Deprecated, ""); message != "" {
+		md.Deprecated = true
+		md.DeprecationMessage = message
+	} else if string(manifest.Deprecated) == "true" {
+		md.Deprecated = true
+	}
+
+	for _, script := range npmInstallScripts {
+		if cmd, ok := manifest.Scripts[script]; ok {
+			if md.InstallScripts == nil {
+				md.InstallScripts = map[string]string{}
+			}
+			md.InstallScripts[script] = cmd
+		}
+	}
+
+	setReleaseHistory(md, version, npmPublishTimes(&packument))
+
+	return md, nil
+}
+
+// npmPublishTimes returns the publish time of every version in the packument.
+func npmPublishTimes(packument *npmPackumentJSON) map[string]time.Time {
+	releases := map[string]time.Time{}
+	for v := range packument.Versions {
+		var published time.Time
+		// unparseable times are left as zero
+		_ = json.Unmarshal(packument.Time[v], &published)
+		releases[v] = published
+	}
+	return releases
+}
+
+// getNPMReleases lists every version of the package. Unpublished versions are
+// not listed, and npm has no concept of yanked versions.
+func getNPMReleases(ctx context.Context, pkgName string) ([]Release, error) {
+	var packument npmPackumentJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.NPM, fmt.Sprintf("%s/%s", RegistryURL(pkgecosystem.NPM), pkgName), ErrPackageNotFound, &packument); err != nil {
+		return nil, err
 	}
 
-	return packageInfo.Dist.Tarball, nil
+	return releaseList(npmPublishTimes(&packument)), nil
 }
 
 var npmPkgManager = PkgManager{
 	ecosystem:       pkgecosystem.NPM,
 	latestVersion:   getNPMLatest,
-	archiveURL:      getNPMArchiveURL,
 	archiveFilename: getNPMArchiveFilename,
 	extractArchive:  utils.ExtractArchiveFile,
+	archives:        getNPMArchives,
+	metadata:        getNPMMetadata,
+	versionScheme:   semverScheme,
+	releases:        getNPMReleases,
+	distTags:        getNPMDistTags,
 }
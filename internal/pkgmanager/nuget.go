@@ -0,0 +1,280 @@
+package pkgmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ossf/package-analysis/internal/utils"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+// nugetServiceIndexURL is the URL of the NuGet V3 service index for nuget.org,
+// which is the registry URL for NuGet. Mirrors are identified by the URL of
+// their own service index.
+// See https://learn.microsoft.com/en-us/nuget/api/service-index
+const nugetServiceIndexURL = "https://api.nuget.org/v3/index.json"
+
+// nugetFlatContainerURL is the base URL of the NuGet V3 package content
+// (flat container) resource on nuget.org, which is used to enumerate package
+// versions and download .nupkg files.
+// See https://learn.microsoft.com/en-us/nuget/api/package-base-address-resource
+const nugetFlatContainerURL = "https://api.nuget.org/v3-flatcontainer"
+
+// nugetPackageBaseAddressType is the service index resource type of the
+// package content resource.
+const nugetPackageBaseAddressType = "PackageBaseAddress/3.0.0"
+
+// nugetRegistrationsURL is the base URL of the NuGet V3 package metadata
+// (registration) resource on nuget.org, including SemVer 2.0.0 packages.
+// See https://learn.microsoft.com/en-us/nuget/api/registration-base-url-resource
+const nugetRegistrationsURL = "https://api.nuget.org/v3/registration5-gz-semver2"
+
+// nugetRegistrationsType is the service index resource type of the package
+// metadata resource, including SemVer 2.0.0 packages.
+const nugetRegistrationsType = "RegistrationsBaseUrl/3.6.0"
+
+// nugetUnlistedTime is the publish time given to unlisted packages by nuget.org.
+var nugetUnlistedTime = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// nugetServiceIndexJSON represents the relevant JSON data from a NuGet V3 service index.
+type nugetServiceIndexJSON struct {
+	Resources []struct {
+		ID   string `json:"@id"`
+		Type string `json:"@type"`
+	} `json:"resources"`
+}
+
+// nugetVersionsJSON represents the JSON data returned by the NuGet flat container
+// resource when the versions of a package are requested. Versions are listed in
+// ascending order and are normalized to lowercase.
+type nugetVersionsJSON struct {
+	Versions []string `json:"versions"`
+}
+
+// nugetRegistrationPageJSON represents the relevant JSON data from a page of a NuGet
+// registration index. Pages of large registrations must be fetched separately, in which
+// case Items is empty.
+type nugetRegistrationPageJSON struct {
+	ID    string `json:"@id"`
+	Items []struct {
+		CatalogEntry nugetCatalogEntryJSON `json:"catalogEntry"`
+	} `json:"items"`
+}
+
+// nugetCatalogEntryJSON represents the relevant JSON data describing a single package
+// version in a NuGet registration page.
+type nugetCatalogEntryJSON struct {
+	Version           string    `json:"version"`
+	Published         time.Time `json:"published"`
+	Authors           string    `json:"authors"`
+	LicenseExpression string    `json:"licenseExpression"`
+	ProjectURL        string    `json:"projectUrl"`
+	Listed            *bool     `json:"listed"`
+	Deprecation       *struct {
+		Message string   `json:"message"`
+		Reasons []string `json:"reasons"`
+	} `json:"deprecation"`
+}
+
+// published returns when the version was published, or zero if it is unlisted.
+func (e *nugetCatalogEntryJSON) published() time.Time {
+	if !e.Published.After(nugetUnlistedTime) {
+		return time.Time{}
+	}
+	return e.Published
+}
+
+// unlisted returns true if the version was unlisted, which is the NuGet equivalent of yanking.
+func (e *nugetCatalogEntryJSON) unlisted() bool {
+	return e.Listed != nil && !*e.Listed
+}
+
+// nugetRegistrationIndexJSON represents the relevant JSON data from a NuGet registration index.
+type nugetRegistrationIndexJSON struct {
+	Items []nugetRegistrationPageJSON `json:"items"`
+}
+
+// getNuGetPackageBaseURL returns the base URL of the package content resource
+// of the configured NuGet registry.
+func getNuGetPackageBaseURL(ctx context.Context) (string, error) {
+	return getNuGetResourceURL(ctx, nugetPackageBaseAddressType, nugetFlatContainerURL)
+}
+
+/*
+getNuGetResourceURL returns the URL of the resource with the given type, for the configured
+NuGet registry. For nuget.org the given well-known URL is used, otherwise it is looked up in
+the registry's service index.
+*/
+func getNuGetResourceURL(ctx context.Context, resourceType, nugetOrgURL string) (string, error) {
+	indexURL := RegistryURL(pkgecosystem.NuGet)
+	if indexURL == nugetServiceIndexURL {
+		return nugetOrgURL, nil
+	}
+
+	var index nugetServiceIndexJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.NuGet, indexURL, nil, &index); err != nil {
+		return "", fmt.Errorf("service index: %w", err)
+	}
+
+	for _, r := range index.Resources {
+		if r.Type == resourceType {
+			return strings.TrimSuffix(r.ID, "/"), nil
+		}
+	}
+
+	return "", fmt.Errorf("service index %s has no %s resource", indexURL, resourceType)
+}
+
+func getNuGetLatest(ctx context.Context, pkg string) (string, error) {
+	baseURL, err := getNuGetPackageBaseURL(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var details nugetVersionsJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.NuGet, fmt.Sprintf("%s/%s/index.json", baseURL, strings.ToLower(pkg)), ErrPackageNotFound, &details); err != nil {
+		return "", err
+	}
+
+	if len(details.Versions) == 0 {
+		return "", fmt.Errorf("%w: %s has no versions", ErrPackageNotFound, pkg)
+	}
+
+	// Prefer the most recent stable release. SemVer 2.0 prerelease versions
+	// contain a '-' after the version number.
+	for i := len(details.Versions) - 1; i >= 0; i-- {
+		if !strings.Contains(details.Versions[i], "-") {
+			return details.Versions[i], nil
+		}
+	}
+
+	return details.Versions[len(details.Versions)-1], nil
+}
+
+/*
+getNuGetArchiveURL returns the URL of the .nupkg file for the given package version.
+The flat container resource requires both the package ID and version to be lowercase.
+*/
+func getNuGetArchiveURL(ctx context.Context, pkgName, version string) (string, error) {
+	baseURL, err := getNuGetPackageBaseURL(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	id := strings.ToLower(pkgName)
+	v := strings.ToLower(version)
+	return fmt.Sprintf("%s/%s/%s/%s.%s.nupkg", baseURL, id, v, id, v), nil
+}
+
+func getNuGetArchiveFilename(pkgName, version, _ string) string {
+	return fmt.Sprintf("%s.%s.nupkg", strings.ToLower(pkgName), strings.ToLower(version))
+}
+
+/*
+getNuGetMetadata collects metadata about a package version from the registration resource
+of the NuGet registry. NuGet does not publish the owners of a package in this resource, so
+the authors of the version are listed instead, and the project URL is only used as the
+repository URL if it is on a known source code host.
+*/
+func getNuGetMetadata(ctx context.Context, pkgName, version string) (*analysisrun.PackageMetadata, error) {
+	entries, err := getNuGetCatalogEntries(ctx, pkgName)
+	if err != nil {
+		return nil, err
+	}
+
+	var md *analysisrun.PackageMetadata
+	releases := map[string]time.Time{}
+	for _, entry := range entries {
+		v := strings.ToLower(entry.Version)
+		releases[v] = entry.published()
+
+		if v != strings.ToLower(version) {
+			continue
+		}
+
+		md = &analysisrun.PackageMetadata{
+			PublishedTime: timePtr(entry.published()),
+			License:       entry.LicenseExpression,
+			Yanked:        entry.unlisted(),
+		}
+		if isRepositoryURL(entry.ProjectURL) {
+			md.RepositoryURL = entry.ProjectURL
+		}
+		for _, author := range strings.Split(entry.Authors, ",") {
+			if author = strings.TrimSpace(author); author != "" {
+				md.Maintainers = append(md.Maintainers, author)
+			}
+		}
+		if entry.Deprecation != nil {
+			md.Deprecated = true
+			md.DeprecationMessage = entry.Deprecation.Message
+			if md.DeprecationMessage == "" {
+				md.DeprecationMessage = strings.Join(entry.Deprecation.Reasons, ", ")
+			}
+		}
+	}
+	if md == nil {
+		return nil, fmt.Errorf("%w: %s @ %s", ErrVersionNotFound, pkgName, version)
+	}
+	setReleaseHistory(md, strings.ToLower(version), releases)
+
+	return md, nil
+}
+
+// getNuGetCatalogEntries returns the catalog entry of every version of the package from
+// the registration resource of the NuGet registry, fetching pages separately if needed.
+func getNuGetCatalogEntries(ctx context.Context, pkgName string) ([]nugetCatalogEntryJSON, error) {
+	baseURL, err := getNuGetResourceURL(ctx, nugetRegistrationsType, nugetRegistrationsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var index nugetRegistrationIndexJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.NuGet, fmt.Sprintf("%s/%s/index.json", baseURL, strings.ToLower(pkgName)), ErrPackageNotFound, &index); err != nil {
+		return nil, err
+	}
+
+	var entries []nugetCatalogEntryJSON
+	for _, page := range index.Items {
+		if len(page.Items) == 0 {
+			if err := getRegistryJSON(ctx, pkgecosystem.NuGet, page.ID, nil, &page); err != nil {
+				return nil, err
+			}
+		}
+		for _, item := range page.Items {
+			entries = append(entries, item.CatalogEntry)
+		}
+	}
+	return entries, nil
+}
+
+func getNuGetReleases(ctx context.Context, pkgName string) ([]Release, error) {
+	entries, err := getNuGetCatalogEntries(ctx, pkgName)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	for _, entry := range entries {
+		releases = append(releases, Release{
+			Version:   strings.ToLower(entry.Version),
+			Published: entry.published(),
+			Yanked:    entry.unlisted(),
+		})
+	}
+	return releases, nil
+}
+
+var nugetPkgManager = PkgManager{
+	ecosystem:       pkgecosystem.NuGet,
+	latestVersion:   getNuGetLatest,
+	archiveURL:      getNuGetArchiveURL,
+	archiveFilename: getNuGetArchiveFilename,
+	extractArchive:  utils.ExtractZipFile,
+	metadata:        getNuGetMetadata,
+	versionScheme:   nugetScheme,
+	releases:        getNuGetReleases,
+}
@@ -9,6 +9,10 @@ type Pkg struct {
 	version string
 	manager *PkgManager
 	local   string
+
+	// archive is set if the registry archive has already been downloaded.
+	archive       string
+	archiveDigest Digest
 }
 
 func (p *Pkg) Name() string {
@@ -38,3 +42,28 @@ func (p *Pkg) Manager() *PkgManager {
 func (p *Pkg) LocalPath() string {
 	return p.local
 }
+
+/*
+WithArchive returns a copy of the package with the path of a copy of its default
+registry archive that has already been downloaded (e.g. to a cache), along with the
+digest that it was verified against, which may be zero. Unlike a local package, the
+package is still treated as coming from the registry, but the archive at the given
+path is used instead of downloading it again.
+*/
+func (p *Pkg) WithArchive(path string, digest Digest) *Pkg {
+	pkg := *p
+	pkg.archive = path
+	pkg.archiveDigest = digest
+	return &pkg
+}
+
+// ArchivePath returns the path of the downloaded archive set by WithArchive,
+// or an empty string if there is none.
+func (p *Pkg) ArchivePath() string {
+	return p.archive
+}
+
+// ArchiveDigest returns the digest of the downloaded archive set by WithArchive.
+func (p *Pkg) ArchiveDigest() Digest {
+	return p.archiveDigest
+}
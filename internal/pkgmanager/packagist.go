@@ -1,12 +1,16 @@
 package pkgmanager
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"strings"
 	"time"
 
+	"golang.org/x/exp/maps"
+
+	"github.com/ossf/package-analysis/internal/utils"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
@@ -39,17 +43,63 @@ type packagistJSON struct {
 	} `json:"packages"`
 }
 
-func getPackagistLatest(pkg string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://repo.packagist.org/p2/%s.json", pkg))
-	if err != nil {
-		return "", err
+// packagistMetadataJSON represents the JSON data from the Packagist p2 API, with the
+// version entries left in their minified form (see expandPackagistVersions).
+type packagistMetadataJSON struct {
+	Packages map[string][]map[string]json.RawMessage `json:"packages"`
+}
+
+// packagistVersionJSON represents the relevant JSON data for a single package
+// version, after it has been expanded.
+type packagistVersionJSON struct {
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+	License []string  `json:"license"`
+	Authors []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Source *struct {
+		URL string `json:"url"`
+	} `json:"source"`
+	Type  string `json:"type"`
+	Extra *struct {
+		Class json.RawMessage `json:"class"`
+	} `json:"extra"`
+	// Abandoned is either true, or the name of a replacement package.
+	Abandoned json.RawMessage `json:"abandoned"`
+}
+
+// packagistPluginType is the package type of Composer plugins, which are
+// loaded and run by Composer when they are installed.
+const packagistPluginType = "composer-plugin"
+
+/*
+expandPackagistVersions expands the minified version list returned by the Packagist p2 API,
+in which each entry only contains the fields that differ from the previous entry, and fields
+that are removed have the value "__unset".
+See https://github.com/composer/metadata-minifier
+*/
+func expandPackagistVersions(minified []map[string]json.RawMessage) []map[string]json.RawMessage {
+	var expanded []map[string]json.RawMessage
+	current := map[string]json.RawMessage{}
+	for _, entry := range minified {
+		next := maps.Clone(current)
+		for key, value := range entry {
+			if string(value) == `"__unset"` {
+				delete(next, key)
+			} else {
+				next[key] = value
+			}
+		}
+		expanded = append(expanded, next)
+		current = next
 	}
-	defer resp.Body.Close()
+	return expanded
+}
 
-	decoder := json.NewDecoder(resp.Body)
+func getPackagistLatest(ctx context.Context, pkg string) (string, error) {
 	var details packagistJSON
-	err = decoder.Decode(&details)
-	if err != nil {
+	if err := getRegistryJSON(ctx, pkgecosystem.Packagist, fmt.Sprintf("%s/p2/%s.json", RegistryURL(pkgecosystem.Packagist), pkg), ErrPackageNotFound, &details); err != nil {
 		return "", err
 	}
 
@@ -68,29 +118,36 @@ func getPackagistLatest(pkg string) (string, error) {
 	return latestVersion, nil
 }
 
-func getPackagistArchiveURL(pkgName, version string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://repo.packagist.org/p2/%s.json", pkgName))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	decoder := json.NewDecoder(resp.Body)
+// getPackagistArchives returns the dist archive for the given package version.
+// Packagist only publishes a (SHA-1) checksum for some archives. No archives are
+// returned if the version exists but has no dist archive.
+func getPackagistArchives(ctx context.Context, pkgName, version string) ([]Archive, error) {
 	var details packagistJSON
-	err = decoder.Decode(&details)
-	if err != nil {
-		return "", err
+	if err := getRegistryJSON(ctx, pkgecosystem.Packagist, fmt.Sprintf("%s/p2/%s.json", RegistryURL(pkgecosystem.Packagist), pkgName), ErrPackageNotFound, &details); err != nil {
+		return nil, err
 	}
 
+	found := false
 	for _, versions := range details.Packages {
 		for _, v := range versions {
-			if v.Version == version {
-				return v.Dist.URL, nil
+			if v.Version != version {
+				continue
+			}
+			found = true
+			if v.Dist.URL != "" {
+				return []Archive{{
+					URL:      v.Dist.URL,
+					Filename: getPackagistArchiveFilename(pkgName, version, v.Dist.URL),
+					Digest:   hexDigest(SHA1, v.Dist.Shasum),
+				}}, nil
 			}
 		}
 	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s @ %s", ErrVersionNotFound, pkgName, version)
+	}
 
-	return "", nil
+	return nil, nil
 }
 
 func getPackagistArchiveFilename(pkgName, version, _ string) string {
@@ -98,9 +155,105 @@ func getPackagistArchiveFilename(pkgName, version, _ string) string {
 	return strings.Join([]string{pkg[0], "-", pkg[1], "-", version, ".zip"}, "")
 }
 
+/*
+getPackagistMetadata collects metadata about a package version from the Packagist p2 API.
+The p2 API does not list the maintainers of a package, so the authors of the version are
+listed instead. Composer does not run scripts from dependencies, but Composer plugins are
+run when they are installed, so the plugin class is reported as an install script.
+*/
+func getPackagistMetadata(ctx context.Context, pkgName, version string) (*analysisrun.PackageMetadata, error) {
+	versions, err := getPackagistVersions(ctx, pkgName)
+	if err != nil {
+		return nil, err
+	}
+
+	var md *analysisrun.PackageMetadata
+	releases := map[string]time.Time{}
+	for _, v := range versions {
+		releases[v.Version] = v.Time
+		if v.Version == version {
+			md = packagistVersionMetadata(v)
+		}
+	}
+	if md == nil {
+		return nil, fmt.Errorf("%w: %s @ %s", ErrVersionNotFound, pkgName, version)
+	}
+	setReleaseHistory(md, version, releases)
+
+	return md, nil
+}
+
+// getPackagistVersions returns every tagged version of the package from the Packagist p2 API.
+func getPackagistVersions(ctx context.Context, pkgName string) ([]packagistVersionJSON, error) {
+	var details packagistMetadataJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.Packagist, fmt.Sprintf("%s/p2/%s.json", RegistryURL(pkgecosystem.Packagist), pkgName), ErrPackageNotFound, &details); err != nil {
+		return nil, err
+	}
+
+	var versions []packagistVersionJSON
+	for _, entry := range expandPackagistVersions(details.Packages[pkgName]) {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		var v packagistVersionJSON
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("version %s: %w", v.Version, err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+func getPackagistReleases(ctx context.Context, pkgName string) ([]Release, error) {
+	versions, err := getPackagistVersions(ctx, pkgName)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	for _, v := range versions {
+		releases = append(releases, Release{Version: v.Version, Published: v.Time})
+	}
+	return releases, nil
+}
+
+// packagistVersionMetadata returns the metadata which is specific to a single package version.
+func packagistVersionMetadata(v packagistVersionJSON) *analysisrun.PackageMetadata {
+	md := &analysisrun.PackageMetadata{
+		License: strings.Join(v.License, " OR "),
+	}
+
+	for _, a := range v.Authors {
+		md.Maintainers = append(md.Maintainers, a.Name)
+	}
+
+	if v.Source != nil {
+		md.RepositoryURL = v.Source.URL
+	}
+
+	if len(v.Abandoned) > 0 && string(v.Abandoned) != "false" && string(v.Abandoned) != "null" {
+		md.Deprecated = true
+		var replacement string
+		if err := json.Unmarshal(v.Abandoned, &replacement); err == nil && replacement != "" {
+			md.DeprecationMessage = "use " + replacement + " instead"
+		}
+	}
+
+	if v.Type == packagistPluginType && v.Extra != nil && len(v.Extra.Class) > 0 {
+		md.InstallScripts = map[string]string{packagistPluginType: string(v.Extra.Class)}
+	}
+
+	return md
+}
+
 var packagistPkgManager = PkgManager{
 	ecosystem:       pkgecosystem.Packagist,
 	latestVersion:   getPackagistLatest,
-	archiveURL:      getPackagistArchiveURL,
 	archiveFilename: getPackagistArchiveFilename,
+	extractArchive:  utils.ExtractZipFile,
+	archives:        getPackagistArchives,
+	metadata:        getPackagistMetadata,
+	versionScheme:   composerScheme,
+	releases:        getPackagistReleases,
 }
@@ -1,13 +1,17 @@
 package pkgmanager
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 
 	"github.com/ossf/package-analysis/internal/utils"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
@@ -21,67 +25,220 @@ import (
 // See https://warehouse.pypa.io/api-reference/json.html and https://peps.python.org/pep-0691
 type pypiPackageInfoJSON struct {
 	Info struct {
-		Version string `json:"version"`
+		Version     string            `json:"version"`
+		Author      string            `json:"author"`
+		Maintainer  string            `json:"maintainer"`
+		License     string            `json:"license"`
+		Classifiers []string          `json:"classifiers"`
+		ProjectURLs map[string]string `json:"project_urls"`
+		Yanked      bool              `json:"yanked"`
 	} `json:"info"`
-	URLs []struct {
-		PackageType string `json:"packagetype"`
-		URL         string `json:"url"`
-	} `json:"urls"`
+	URLs     []pypiFileJSON            `json:"urls"`
+	Releases map[string][]pypiFileJSON `json:"releases"`
 }
 
-func getPyPILatest(pkg string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://pypi.org/pypi/%s/json", pkg))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+// pypiFileJSON represents a distribution file of a package version.
+type pypiFileJSON struct {
+	PackageType string `json:"packagetype"`
+	Filename    string `json:"filename"`
+	URL         string `json:"url"`
+	Digests     struct {
+		SHA256 string `json:"sha256"`
+	} `json:"digests"`
+	UploadTime time.Time `json:"upload_time_iso_8601"`
+	Yanked     bool      `json:"yanked"`
+}
+
+// pypiPackageTypes maps the PyPI 'packagetype' of a distribution file
+// to the corresponding ArchiveType. Other package types (e.g. bdist_egg,
+// bdist_wininst) are not supported.
+var pypiPackageTypes = map[string]ArchiveType{
+	"sdist":       SourceArchive,
+	"bdist_wheel": WheelArchive,
+}
 
-	decoder := json.NewDecoder(resp.Body)
+// pypiPureWheelSuffix is the filename suffix of wheels which contain
+// only Python code and can be installed on any platform.
+const pypiPureWheelSuffix = "-none-any.whl"
+
+func getPyPILatest(ctx context.Context, pkg string) (string, error) {
 	var details pypiPackageInfoJSON
-	err = decoder.Decode(&details)
-	if err != nil {
+	if err := getRegistryJSON(ctx, pkgecosystem.PyPI, fmt.Sprintf("%s/pypi/%s/json", RegistryURL(pkgecosystem.PyPI), pkg), ErrPackageNotFound, &details); err != nil {
 		return "", err
 	}
 
 	return details.Info.Version, nil
 }
 
-func getPyPIArchiveURL(pkgName, version string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://pypi.org/pypi/%s/%s/json", pkgName, version))
-	if err != nil {
-		return "", err
+/*
+getPyPIArchives returns all sdist and wheel distribution files for the given
+package version. The first archive in the list is the default archive for the
+package version, which is the sdist if one exists, otherwise a wheel, preferring
+pure Python wheels over platform-specific ones.
+*/
+func getPyPIArchives(ctx context.Context, pkgName, version string) ([]Archive, error) {
+	var packageInfo pypiPackageInfoJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.PyPI, fmt.Sprintf("%s/pypi/%s/%s/json", RegistryURL(pkgecosystem.PyPI), pkgName, version), ErrVersionNotFound, &packageInfo); err != nil {
+		return nil, err
+	}
+
+	var archives []Archive
+	for _, file := range packageInfo.URLs {
+		archiveType, supported := pypiPackageTypes[file.PackageType]
+		if !supported {
+			continue
+		}
+		filename := file.Filename
+		if filename == "" {
+			filename = defaultArchiveFilename(pkgName, version, file.URL)
+		}
+		archives = append(archives, Archive{
+			URL:      file.URL,
+			Filename: filename,
+			Type:     archiveType,
+			Digest:   hexDigest(SHA256, file.Digests.SHA256),
+		})
+	}
+
+	// stable sort to move the default archive to the front of the list
+	slices.SortStableFunc(archives, func(a, b Archive) int {
+		return pypiArchiveRank(a) - pypiArchiveRank(b)
+	})
+
+	return archives, nil
+}
+
+// pypiArchiveRank orders PyPI archives by preference for use as the default archive.
+func pypiArchiveRank(a Archive) int {
+	switch {
+	case a.Type == SourceArchive:
+		return 0
+	case strings.HasSuffix(a.Filename, pypiPureWheelSuffix):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// pypiInactiveClassifier is the trove classifier used to mark a project as no longer maintained.
+const pypiInactiveClassifier = "Development Status :: 7 - Inactive"
+
+// pypiRepositoryURLKeys lists project URL labels (in lowercase) which commonly identify
+// the source code repository of a project.
+var pypiRepositoryURLKeys = []string{"source", "source code", "repository", "code", "github"}
+
+// getPyPIRepositoryURL returns the project URL that is most likely to be the source
+// code repository of the project.
+func getPyPIRepositoryURL(projectURLs map[string]string) string {
+	for _, key := range pypiRepositoryURLKeys {
+		for label, u := range projectURLs {
+			if strings.ToLower(label) == key {
+				return u
+			}
+		}
 	}
-	defer resp.Body.Close()
 
-	responseBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading HTTP response: %w", err)
+	// sort labels so that the result is deterministic
+	labels := maps.Keys(projectURLs)
+	slices.Sort(labels)
+	for _, label := range labels {
+		if isRepositoryURL(projectURLs[label]) {
+			return projectURLs[label]
+		}
+	}
+
+	return ""
+}
+
+/*
+getPyPIMetadata collects metadata about a package version from the PyPI JSON API.
+PyPI does not publish the list of project maintainers, so the author and maintainer
+of the version are listed instead. A version is considered to be released when its
+first distribution file was uploaded.
+*/
+func getPyPIMetadata(ctx context.Context, pkgName, version string) (*analysisrun.PackageMetadata, error) {
+	registryURL := RegistryURL(pkgecosystem.PyPI)
+
+	var versionInfo pypiPackageInfoJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.PyPI, fmt.Sprintf("%s/pypi/%s/%s/json", registryURL, pkgName, version), ErrVersionNotFound, &versionInfo); err != nil {
+		return nil, err
 	}
 
-	responseString := string(responseBytes)
-	decoder := json.NewDecoder(strings.NewReader(responseString))
 	var packageInfo pypiPackageInfoJSON
-	err = decoder.Decode(&packageInfo)
-	if err != nil {
-		// invalid version, non-existent package, etc. Details in responseString
-		return "", fmt.Errorf("%w. PyPI response: %s", err, responseString)
+	if err := getRegistryJSON(ctx, pkgecosystem.PyPI, fmt.Sprintf("%s/pypi/%s/json", registryURL, pkgName), ErrPackageNotFound, &packageInfo); err != nil {
+		return nil, err
+	}
+
+	info := versionInfo.Info
+	md := &analysisrun.PackageMetadata{
+		License:       info.License,
+		RepositoryURL: getPyPIRepositoryURL(info.ProjectURLs),
+		Yanked:        info.Yanked,
+	}
+
+	for _, person := range []string{info.Maintainer, info.Author} {
+		if person != "" && !slices.Contains(md.Maintainers, person) {
+			md.Maintainers = append(md.Maintainers, person)
+		}
+	}
+
+	if slices.Contains(info.Classifiers, pypiInactiveClassifier) {
+		md.Deprecated = true
+	}
+
+	releases := map[string]time.Time{}
+	for _, r := range pypiReleases(&packageInfo) {
+		releases[r.Version] = r.Published
 	}
+	setReleaseHistory(md, version, releases)
 
-	// Need to find the archive with PackageType == "sdist"
-	for _, url := range packageInfo.URLs {
-		if url.PackageType == "sdist" {
-			return url.URL, nil
+	return md, nil
+}
+
+// pypiReleases lists the versions of the package. A version is published when its first
+// distribution file was uploaded, and is yanked if all of its files were yanked.
+func pypiReleases(packageInfo *pypiPackageInfoJSON) []Release {
+	var releases []Release
+	for v, files := range packageInfo.Releases {
+		r := Release{Version: v, Yanked: len(files) > 0}
+		for _, f := range files {
+			if r.Published.IsZero() || f.UploadTime.Before(r.Published) {
+				r.Published = f.UploadTime
+			}
+			r.Yanked = r.Yanked && f.Yanked
 		}
+		releases = append(releases, r)
+	}
+	return releases
+}
+
+func getPyPIReleases(ctx context.Context, pkgName string) ([]Release, error) {
+	var packageInfo pypiPackageInfoJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.PyPI, fmt.Sprintf("%s/pypi/%s/json", RegistryURL(pkgecosystem.PyPI), pkgName), ErrPackageNotFound, &packageInfo); err != nil {
+		return nil, err
 	}
 
-	// Return an empty string and no error if we can't find an archive URL.
-	return "", nil
+	return pypiReleases(&packageInfo), nil
+}
+
+// extractPyPIArchive extracts either a wheel (zip format) or a source distribution
+// (.tar.gz or, for some older packages, .zip) based on the archive file extension.
+func extractPyPIArchive(archivePath, outputDir string) error {
+	switch strings.ToLower(filepath.Ext(archivePath)) {
+	case ".whl", ".zip":
+		return utils.ExtractZipFile(archivePath, outputDir)
+	default:
+		return utils.ExtractArchiveFile(archivePath, outputDir)
+	}
 }
 
 var pypiPkgManager = PkgManager{
 	ecosystem:       pkgecosystem.PyPI,
 	latestVersion:   getPyPILatest,
-	archiveURL:      getPyPIArchiveURL,
 	archiveFilename: defaultArchiveFilename,
-	extractArchive:  utils.ExtractArchiveFile,
+	extractArchive:  extractPyPIArchive,
+	archives:        getPyPIArchives,
+	metadata:        getPyPIMetadata,
+	versionScheme:   pep440Scheme,
+	releases:        getPyPIReleases,
 }
@@ -0,0 +1,228 @@
+package pkgmanager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+// useTestRegistry starts a local registry serving the given responses (keyed by URL path)
+// and configures it as the registry for the given ecosystem for the duration of the test.
+// Occurrences of "{{registry}}" in a response are replaced by the registry URL.
+func useTestRegistry(t *testing.T, e pkgecosystem.Ecosystem, responses map[string]string) {
+	t.Helper()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, strings.ReplaceAll(body, "{{registry}}", server.URL))
+	}))
+	t.Cleanup(server.Close)
+
+	if err := SetRegistryURL(e, server.URL+"/"); err != nil {
+		t.Fatalf("SetRegistryURL() = %v", err)
+	}
+	t.Cleanup(func() { _ = SetRegistryURL(e, "") })
+}
+
+// Digests of the test archive contents, "archive".
+const (
+	testArchiveSHA1      = "ebfb55f4432b592119a10592e4f26272cc72359e"
+	testArchiveSHA256    = "0eb3e36bfb24dcd9bb1d1bece1531216b59539a8fde17ee80224af0653c92aa3"
+	testArchiveIntegrity = "sha512-sRU36Ok1DOcSWqYvA3z8E7szGJ0jPd3ewR+Oo3NRdlDSb053ZXua6gAZX/g3UdaiFCZ0yyF+PzssiRO+IXhDRA=="
+)
+
+func TestParseRegistryURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[pkgecosystem.Ecosystem]string
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  map[pkgecosystem.Ecosystem]string{},
+		},
+		{
+			name:  "multiple",
+			input: "npm=https://npm.example.com, pypi=https://pypi.example.com/",
+			want: map[pkgecosystem.Ecosystem]string{
+				pkgecosystem.NPM:  "https://npm.example.com",
+				pkgecosystem.PyPI: "https://pypi.example.com/",
+			},
+		},
+		{
+			name:    "missing URL",
+			input:   "npm=",
+			wantErr: true,
+		},
+		{
+			name:    "unknown ecosystem",
+			input:   "maven=https://maven.example.com",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRegistryURLs(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRegistryURLs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRegistryURLs() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetRegistryURL(t *testing.T) {
+	t.Cleanup(func() { _ = SetRegistryURL(pkgecosystem.NPM, "") })
+
+	if err := SetRegistryURL(pkgecosystem.NPM, "npm.example.com"); err == nil {
+		t.Errorf("SetRegistryURL() with relative URL should return an error")
+	}
+	if err := SetRegistryURL(pkgecosystem.NPM, "https://npm.example.com/"); err != nil {
+		t.Fatalf("SetRegistryURL() = %v", err)
+	}
+	if got := Manager(pkgecosystem.NPM).RegistryURL(); got != "https://npm.example.com" {
+		t.Errorf("RegistryURL() = %q; want %q", got, "https://npm.example.com")
+	}
+	if got, want := FormatRegistryURLs(CustomRegistryURLs()), "npm=https://npm.example.com"; got != want {
+		t.Errorf("FormatRegistryURLs() = %q; want %q", got, want)
+	}
+	if err := SetRegistryURL(pkgecosystem.NPM, ""); err != nil {
+		t.Fatalf("SetRegistryURL() = %v", err)
+	}
+	if Manager(pkgecosystem.NPM).HasCustomRegistry() {
+		t.Errorf("HasCustomRegistry() = true after reset")
+	}
+}
+
+func TestRegistryDownload(t *testing.T) {
+	tests := []struct {
+		name       string
+		ecosystem  pkgecosystem.Ecosystem
+		pkgName    string
+		responses  map[string]string
+		wantLatest string
+		wantFile   string
+	}{
+		{
+			name:      "npm",
+			ecosystem: pkgecosystem.NPM,
+			pkgName:   "test",
+			responses: map[string]string{
+				"/test":                  `{"dist-tags": {"latest": "1.0.0"}}`,
+				"/test/1.0.0":            `{"dist": {"tarball": "{{registry}}/test/-/test-1.0.0.tgz", "integrity": "` + testArchiveIntegrity + `"}}`,
+				"/test/-/test-1.0.0.tgz": "archive",
+			},
+			wantLatest: "1.0.0",
+			wantFile:   "test-1.0.0.tgz",
+		},
+		{
+			name:      "pypi",
+			ecosystem: pkgecosystem.PyPI,
+			pkgName:   "test",
+			responses: map[string]string{
+				"/pypi/test/json": `{"info": {"version": "1.0.0"}}`,
+				"/pypi/test/1.0.0/json": `{"urls": [
+					{"packagetype": "bdist_wheel", "filename": "test-1.0.0-py3-none-any.whl", "url": "{{registry}}/files/test-1.0.0-py3-none-any.whl"},
+					{"packagetype": "sdist", "filename": "test-1.0.0.tar.gz", "url": "{{registry}}/files/test-1.0.0.tar.gz", "digests": {"sha256": "` + testArchiveSHA256 + `"}}
+				]}`,
+				"/files/test-1.0.0.tar.gz": "archive",
+			},
+			wantLatest: "1.0.0",
+			wantFile:   "test-1.0.0.tar.gz",
+		},
+		{
+			name:      "rubygems",
+			ecosystem: pkgecosystem.RubyGems,
+			pkgName:   "test",
+			responses: map[string]string{
+				"/api/v1/gems/test.json":                    `{"version": "1.0.0"}`,
+				"/api/v2/rubygems/test/versions/1.0.0.json": `{"sha": "` + testArchiveSHA256 + `"}`,
+				"/gems/test-1.0.0.gem":                      "archive",
+			},
+			wantLatest: "1.0.0",
+			wantFile:   "test-1.0.0.gem",
+		},
+		{
+			name:      "crates.io",
+			ecosystem: pkgecosystem.CratesIO,
+			pkgName:   "test",
+			responses: map[string]string{
+				"/api/v1/crates/test/versions":       `{"versions": [{"num": "1.0.0"}]}`,
+				"/api/v1/crates/test/1.0.0":          `{"version": {"checksum": "` + testArchiveSHA256 + `"}}`,
+				"/api/v1/crates/test/1.0.0/download": "archive",
+			},
+			wantLatest: "1.0.0",
+			wantFile:   "test-1.0.0.tar.gz",
+		},
+		{
+			name:      "packagist",
+			ecosystem: pkgecosystem.Packagist,
+			pkgName:   "vendor/test",
+			responses: map[string]string{
+				"/p2/vendor/test.json": `{"packages": {"vendor/test": [
+					{"version": "1.0.0", "time": "2023-01-01T00:00:00+00:00", "dist": {"url": "{{registry}}/dist/test-1.0.0.zip", "type": "zip", "shasum": "` + testArchiveSHA1 + `"}}
+				]}}`,
+				"/dist/test-1.0.0.zip": "archive",
+			},
+			wantLatest: "1.0.0",
+			wantFile:   "vendor-test-1.0.0.zip",
+		},
+		{
+			name:      "nuget",
+			ecosystem: pkgecosystem.NuGet,
+			pkgName:   "Test",
+			responses: map[string]string{
+				"/": `{"resources": [
+					{"@id": "{{registry}}/search", "@type": "SearchQueryService"},
+					{"@id": "{{registry}}/flat/", "@type": "PackageBaseAddress/3.0.0"}
+				]}`,
+				"/flat/test/index.json":             `{"versions": ["0.9.0", "1.0.0", "1.1.0-beta"]}`,
+				"/flat/test/1.0.0/test.1.0.0.nupkg": "archive",
+			},
+			wantLatest: "1.0.0",
+			wantFile:   "test.1.0.0.nupkg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestRegistry(t, tt.ecosystem, tt.responses)
+			manager := Manager(tt.ecosystem)
+
+			pkg, err := manager.Latest(context.Background(), tt.pkgName)
+			if err != nil {
+				t.Fatalf("Latest() = %v", err)
+			}
+			if pkg.Version() != tt.wantLatest {
+				t.Errorf("Latest() version = %q; want %q", pkg.Version(), tt.wantLatest)
+			}
+
+			downloadPath, err := manager.DownloadArchive(context.Background(), pkg.Name(), pkg.Version(), t.TempDir())
+			if err != nil {
+				t.Fatalf("DownloadArchive() = %v", err)
+			}
+			if filepath.Base(downloadPath) != tt.wantFile {
+				t.Errorf("DownloadArchive() filename = %q; want %q", filepath.Base(downloadPath), tt.wantFile)
+			}
+			if contents, err := os.ReadFile(downloadPath); err != nil || string(contents) != "archive" {
+				t.Errorf("downloaded archive contents = %q (error %v); want %q", contents, err, "archive")
+			}
+		})
+	}
+}
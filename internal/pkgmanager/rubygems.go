@@ -1,10 +1,13 @@
 package pkgmanager
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"strings"
+	"time"
 
+	"github.com/ossf/package-analysis/internal/utils"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
@@ -12,37 +15,128 @@ type rubygemsJSON struct {
 	Version string `json:"version"`
 }
 
-func getRubyGemsLatest(pkg string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://rubygems.org/api/v1/gems/%s.json", pkg))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+// rubygemsVersionJSON represents the relevant JSON data from the RubyGems API
+// response when a single gem version is requested.
+// See https://guides.rubygems.org/rubygems-org-api-v2/
+type rubygemsVersionJSON struct {
+	SHA              string            `json:"sha"`
+	VersionCreatedAt time.Time         `json:"version_created_at"`
+	Authors          string            `json:"authors"`
+	Licenses         []string          `json:"licenses"`
+	SourceCodeURI    string            `json:"source_code_uri"`
+	HomepageURI      string            `json:"homepage_uri"`
+	Metadata         map[string]string `json:"metadata"`
+	Yanked           bool              `json:"yanked"`
+}
+
+// rubygemsVersionsJSON represents the JSON data returned by the RubyGems API when
+// the versions of a gem are requested. Yanked versions are not included.
+type rubygemsVersionsJSON []struct {
+	Number    string    `json:"number"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// rubygemsOwnersJSON represents the JSON data returned by the RubyGems API
+// when the owners of a gem are requested.
+type rubygemsOwnersJSON []struct {
+	Handle string `json:"handle"`
+}
 
-	decoder := json.NewDecoder(resp.Body)
+func getRubyGemsLatest(ctx context.Context, pkg string) (string, error) {
 	var details rubygemsJSON
-	err = decoder.Decode(&details)
-	if err != nil {
+	if err := getRegistryJSON(ctx, pkgecosystem.RubyGems, fmt.Sprintf("%s/api/v1/gems/%s.json", RegistryURL(pkgecosystem.RubyGems), pkg), ErrPackageNotFound, &details); err != nil {
 		return "", err
 	}
 
 	return details.Version, nil
 }
 
-func getRubyGemsArchiveURL(pkgName, version string) (string, error) {
-	pkgURL := fmt.Sprintf("https://rubygems.org/gems/%v-%v.gem", pkgName, version)
-	resp, err := http.Get(pkgURL)
-	if err != nil {
-		return "", err
+// getRubyGemsArchives returns the .gem file for the given gem version, along with
+// its (SHA-256) checksum from the RubyGems API.
+func getRubyGemsArchives(ctx context.Context, pkgName, version string) ([]Archive, error) {
+	var details rubygemsVersionJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.RubyGems, fmt.Sprintf("%s/api/v2/rubygems/%s/versions/%s.json", RegistryURL(pkgecosystem.RubyGems), pkgName, version), ErrVersionNotFound, &details); err != nil {
+		return nil, err
+	}
+
+	pkgURL := fmt.Sprintf("%s/gems/%v-%v.gem", RegistryURL(pkgecosystem.RubyGems), pkgName, version)
+	return []Archive{{
+		URL:      pkgURL,
+		Filename: defaultArchiveFilename(pkgName, version, pkgURL),
+		Digest:   hexDigest(SHA256, details.SHA),
+	}}, nil
+}
+
+/*
+getRubyGemsMetadata collects metadata about a gem version from the RubyGems API.
+Since gems with native extensions are built by running code from the gem, and the
+API does not say which gems have them, no install scripts are reported.
+*/
+func getRubyGemsMetadata(ctx context.Context, pkgName, version string) (*analysisrun.PackageMetadata, error) {
+	registryURL := RegistryURL(pkgecosystem.RubyGems)
+
+	var details rubygemsVersionJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.RubyGems, fmt.Sprintf("%s/api/v2/rubygems/%s/versions/%s.json", registryURL, pkgName, version), ErrVersionNotFound, &details); err != nil {
+		return nil, err
+	}
+
+	var versions rubygemsVersionsJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.RubyGems, fmt.Sprintf("%s/api/v1/versions/%s.json", registryURL, pkgName), ErrPackageNotFound, &versions); err != nil {
+		return nil, err
+	}
+
+	var owners rubygemsOwnersJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.RubyGems, fmt.Sprintf("%s/api/v1/gems/%s/owners.json", registryURL, pkgName), ErrPackageNotFound, &owners); err != nil {
+		return nil, err
+	}
+
+	md := &analysisrun.PackageMetadata{
+		PublishedTime: timePtr(details.VersionCreatedAt),
+		License:       strings.Join(details.Licenses, " OR "),
+		RepositoryURL: details.SourceCodeURI,
+		Yanked:        details.Yanked,
+	}
+	if md.RepositoryURL == "" {
+		md.RepositoryURL = details.Metadata["source_code_uri"]
+	}
+
+	for _, o := range owners {
+		md.Maintainers = append(md.Maintainers, o.Handle)
+	}
+
+	setReleaseHistory(md, version, rubygemsPublishTimes(versions))
+
+	return md, nil
+}
+
+// rubygemsPublishTimes returns the publish time of every version of a gem.
+func rubygemsPublishTimes(versions rubygemsVersionsJSON) map[string]time.Time {
+	// versions are listed once for each platform that they are published for
+	releases := map[string]time.Time{}
+	for _, v := range versions {
+		if t, ok := releases[v.Number]; !ok || v.CreatedAt.Before(t) {
+			releases[v.Number] = v.CreatedAt
+		}
+	}
+	return releases
+}
+
+func getRubyGemsReleases(ctx context.Context, pkgName string) ([]Release, error) {
+	var versions rubygemsVersionsJSON
+	if err := getRegistryJSON(ctx, pkgecosystem.RubyGems, fmt.Sprintf("%s/api/v1/versions/%s.json", RegistryURL(pkgecosystem.RubyGems), pkgName), ErrPackageNotFound, &versions); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	return pkgURL, nil
+	return releaseList(rubygemsPublishTimes(versions)), nil
 }
 
 var rubygemsPkgManager = PkgManager{
 	ecosystem:       pkgecosystem.RubyGems,
 	latestVersion:   getRubyGemsLatest,
-	archiveURL:      getRubyGemsArchiveURL,
 	archiveFilename: defaultArchiveFilename,
+	extractArchive:  utils.ExtractGemFile,
+	archives:        getRubyGemsArchives,
+	metadata:        getRubyGemsMetadata,
+	versionScheme:   gemScheme,
+	releases:        getRubyGemsReleases,
 }
@@ -0,0 +1,364 @@
+package pkgmanager
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// versionPart is a single segment of the part of a version which follows
+// the numeric release segments, such as a prerelease identifier.
+type versionPart struct {
+	num   int
+	str   string
+	isStr bool
+}
+
+// version is a package version parsed according to the versioning scheme of an
+// ecosystem, so that it can be compared with other versions of the same scheme.
+type version struct {
+	raw   string
+	epoch int
+	// release holds the numeric release segments, e.g. [1 2 3] for 1.2.3.
+	release []int
+	// suffix holds the segments which order versions with the same release,
+	// such as prerelease identifiers. How they compare depends on the scheme.
+	suffix     []versionPart
+	prerelease bool
+}
+
+// versionScheme describes how the versions of an ecosystem are parsed and compared,
+// and how version specs are interpreted.
+type versionScheme struct {
+	name  string
+	parse func(s string) (*version, error)
+	// compareSuffix compares the suffixes of two versions with the same release.
+	compareSuffix func(a, b []versionPart) int
+	// pessimisticTilde is set if "~1.2" means ">=1.2, <2" (as for Composer) rather than
+	// ">=1.2, <1.3" (as for npm and Cargo).
+	pessimisticTilde bool
+}
+
+// compare returns -1, 0 or 1 if a is less than, equal to or greater than b.
+func (s *versionScheme) compare(a, b *version) int {
+	if c := compareInt(a.epoch, b.epoch); c != 0 {
+		return c
+	}
+	if c := compareRelease(a.release, b.release); c != 0 {
+		return c
+	}
+	return s.compareSuffix(a.suffix, b.suffix)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareRelease compares release segments, treating missing segments as zero.
+func compareRelease(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := compareInt(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// comparePart orders version parts, with strings ordered before numbers.
+func comparePart(a, b versionPart) int {
+	switch {
+	case a.isStr && b.isStr:
+		return strings.Compare(a.str, b.str)
+	case a.isStr:
+		return -1
+	case b.isStr:
+		return 1
+	default:
+		return compareInt(a.num, b.num)
+	}
+}
+
+/*
+compareSemverSuffix compares SemVer prerelease identifiers. A version without any
+prerelease identifiers is greater than one with them, numeric identifiers are less
+than alphanumeric ones, and a shorter list of identifiers is less than a longer one
+that it is a prefix of.
+See https://semver.org/#spec-item-11
+*/
+func compareSemverSuffix(a, b []versionPart) int {
+	if len(a) == 0 || len(b) == 0 {
+		return -compareInt(len(a), len(b))
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		// numeric identifiers have lower precedence, unlike comparePart
+		if a[i].isStr != b[i].isStr {
+			if a[i].isStr {
+				return 1
+			}
+			return -1
+		}
+		if c := comparePart(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(a), len(b))
+}
+
+// comparePaddedSuffix compares suffixes segment by segment, treating missing
+// segments as zero, so that strings are ordered before a missing segment.
+func comparePaddedSuffix(a, b []versionPart) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y versionPart
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if c := comparePart(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// parseRelease parses dot separated numeric release segments.
+func parseRelease(s string) ([]int, error) {
+	var release []int
+	for _, seg := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(seg)
+		if err != nil {
+			return nil, err
+		}
+		release = append(release, n)
+	}
+	return release, nil
+}
+
+var semverPattern = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+/*
+parseSemver parses a SemVer version, as used by npm and crates.io. Build metadata
+is ignored. Versions with fewer or more than three release segments are accepted,
+since they appear in version specs (e.g. "^1.2") and in NuGet versions.
+See https://semver.org
+*/
+func parseSemver(s string) (*version, error) {
+	m := semverPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	release, err := parseRelease(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q: %w", s, err)
+	}
+
+	v := &version{raw: s, release: release}
+	if m[2] != "" {
+		v.prerelease = true
+		for _, id := range strings.Split(m[2], ".") {
+			if n, err := strconv.Atoi(id); err == nil {
+				v.suffix = append(v.suffix, versionPart{num: n})
+			} else {
+				v.suffix = append(v.suffix, versionPart{str: id, isStr: true})
+			}
+		}
+	}
+	return v, nil
+}
+
+// parseNuGetVersion parses a NuGet version, which is SemVer with up to four release
+// segments, and with case-insensitive prerelease labels.
+// See https://learn.microsoft.com/en-us/nuget/concepts/package-versioning
+func parseNuGetVersion(s string) (*version, error) {
+	v, err := parseSemver(strings.ToLower(s))
+	if err != nil {
+		return nil, err
+	}
+	v.raw = s
+	return v, nil
+}
+
+var pep440Pattern = regexp.MustCompile(`^v?(?:(\d+)!)?(\d+(?:\.\d+)*)` +
+	`(?:[-_.]?(a|b|c|rc|alpha|beta|pre|preview)[-_.]?(\d+)?)?` +
+	`(?:-(\d+)|[-_.]?(post|rev|r)[-_.]?(\d+)?)?` +
+	`(?:[-_.]?(dev)[-_.]?(\d+)?)?` +
+	`(?:\+[a-z0-9]+(?:[-_.][a-z0-9]+)*)?$`)
+
+// pep440Phases orders the prerelease phases of PEP 440 versions. Final releases are
+// greater than every prerelease, and a developmental release of a final release
+// (e.g. 1.0.dev1) is less than every prerelease.
+var pep440Phases = map[string]int{
+	"a": 1, "alpha": 1,
+	"b": 2, "beta": 2,
+	"c": 3, "rc": 3, "pre": 3, "preview": 3,
+}
+
+const (
+	pep440DevPhase   = 0
+	pep440FinalPhase = 4
+)
+
+/*
+parsePEP440 parses a Python package version. The suffix of the version holds the
+prerelease phase and number, the post-release number and the developmental release
+number, in that order, so that suffixes can be compared segment by segment. Local
+version labels are ignored.
+See https://peps.python.org/pep-0440
+*/
+func parsePEP440(s string) (*version, error) {
+	m := pep440Pattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	release, err := parseRelease(m[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q: %w", s, err)
+	}
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	v := &version{raw: s, epoch: atoi(m[1]), release: release}
+
+	phase, preNum := pep440FinalPhase, 0
+	if m[3] != "" {
+		phase, preNum = pep440Phases[m[3]], atoi(m[4])
+	}
+
+	post := -1
+	if m[5] != "" {
+		post = atoi(m[5])
+	} else if m[6] != "" {
+		post = atoi(m[7])
+	}
+
+	dev := math.MaxInt
+	if m[8] != "" {
+		dev = atoi(m[9])
+		if m[3] == "" && post < 0 {
+			phase = pep440DevPhase
+		}
+	}
+
+	v.prerelease = m[3] != "" || m[8] != ""
+	v.suffix = []versionPart{{num: phase}, {num: preNum}, {num: post}, {num: dev}}
+	return v, nil
+}
+
+var (
+	gemVersionPattern = regexp.MustCompile(`^[0-9]+(?:\.[0-9a-zA-Z]+)*(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?$`)
+	gemSegmentPattern = regexp.MustCompile(`[0-9]+|[a-zA-Z]+`)
+)
+
+/*
+parseGemVersion parses a RubyGems version. Versions are split into numeric and alphabetic
+segments, and any alphabetic segment makes the version a prerelease. The release holds the
+leading numeric segments and the suffix holds the rest, so that "1.0.a" is less than "1.0".
+See https://guides.rubygems.org/patterns/#semantic-versioning
+*/
+func parseGemVersion(s string) (*version, error) {
+	s = strings.TrimSpace(s)
+	if !gemVersionPattern.MatchString(s) {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+
+	v := &version{raw: s}
+	// as in Gem::Version, "1.0-x" is the same as "1.0.pre.x"
+	for _, seg := range gemSegmentPattern.FindAllString(strings.ReplaceAll(s, "-", ".pre."), -1) {
+		n, err := strconv.Atoi(seg)
+		switch {
+		case err != nil:
+			v.prerelease = true
+			v.suffix = append(v.suffix, versionPart{str: seg, isStr: true})
+		case v.prerelease:
+			v.suffix = append(v.suffix, versionPart{num: n})
+		default:
+			v.release = append(v.release, n)
+		}
+	}
+	return v, nil
+}
+
+var composerVersionPattern = regexp.MustCompile(`^v?(\d+(?:\.\d+){0,3})(?:[._-]?(stable|beta|b|rc|alpha|a|patch|pl|p)(?:[.-]?(\d+))?)?$`)
+
+// composerStabilities orders the stability flags of Composer versions.
+var composerStabilities = map[string]int{
+	"alpha": 1, "a": 1,
+	"beta": 2, "b": 2,
+	"rc":     3,
+	"stable": 4, "": 4,
+	"patch": 5, "pl": 5, "p": 5,
+}
+
+/*
+parseComposerVersion parses a Composer package version. The suffix of the version holds
+the stability flag and its number. Development versions of branches (e.g. "dev-main"
+and "1.x-dev") are not supported.
+See https://getcomposer.org/doc/articles/versions.md
+*/
+func parseComposerVersion(s string) (*version, error) {
+	m := composerVersionPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	release, err := parseRelease(m[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q: %w", s, err)
+	}
+	num, _ := strconv.Atoi(m[3])
+	stability := composerStabilities[m[2]]
+
+	return &version{
+		raw:        s,
+		release:    release,
+		suffix:     []versionPart{{num: stability}, {num: num}},
+		prerelease: stability < composerStabilities["stable"],
+	}, nil
+}
+
+var (
+	semverScheme = &versionScheme{
+		name:          "semver",
+		parse:         parseSemver,
+		compareSuffix: compareSemverSuffix,
+	}
+	nugetScheme = &versionScheme{
+		name:          "nuget",
+		parse:         parseNuGetVersion,
+		compareSuffix: compareSemverSuffix,
+	}
+	pep440Scheme = &versionScheme{
+		name:          "pep440",
+		parse:         parsePEP440,
+		compareSuffix: comparePaddedSuffix,
+	}
+	gemScheme = &versionScheme{
+		name:          "rubygems",
+		parse:         parseGemVersion,
+		compareSuffix: comparePaddedSuffix,
+	}
+	composerScheme = &versionScheme{
+		name:             "composer",
+		parse:            parseComposerVersion,
+		compareSuffix:    comparePaddedSuffix,
+		pessimisticTilde: true,
+	}
+)
@@ -0,0 +1,88 @@
+package pkgmanager
+
+import (
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		scheme *versionScheme
+		a, b   string
+		want   int
+	}{
+		// semver
+		{semverScheme, "1.2.3", "1.2.3", 0},
+		{semverScheme, "1.2.3", "1.10.0", -1},
+		{semverScheme, "1.2", "1.2.0", 0},
+		{semverScheme, "1.0.0-alpha", "1.0.0", -1},
+		{semverScheme, "1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{semverScheme, "1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{semverScheme, "1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{semverScheme, "1.0.0-rc.1", "1.0.0-beta.11", 1},
+		{semverScheme, "1.0.0+build.1", "1.0.0", 0},
+		// NuGet
+		{nugetScheme, "1.0.0.1", "1.0.0", 1},
+		{nugetScheme, "1.0.0-Beta", "1.0.0-beta", 0},
+		// PEP 440
+		{pep440Scheme, "1.0", "1.0.0", 0},
+		{pep440Scheme, "1.0.dev1", "1.0a1", -1},
+		{pep440Scheme, "1.0a1", "1.0b1", -1},
+		{pep440Scheme, "1.0b2", "1.0rc1", -1},
+		{pep440Scheme, "1.0rc1", "1.0", -1},
+		{pep440Scheme, "1.0", "1.0.post1", -1},
+		{pep440Scheme, "1.0.post1.dev1", "1.0.post1", -1},
+		{pep440Scheme, "1.0.post1.dev1", "1.0", 1},
+		{pep440Scheme, "1.0-1", "1.0.post1", 0},
+		{pep440Scheme, "1.0.0-Alpha.1", "1.0a1", 0},
+		{pep440Scheme, "1!0.1", "2.0", 1},
+		{pep440Scheme, "1.0+local", "1.0", 0},
+		// RubyGems
+		{gemScheme, "1.0", "1.0.0", 0},
+		{gemScheme, "1.0.a", "1.0", -1},
+		{gemScheme, "1.0.a.1", "1.0.b", -1},
+		{gemScheme, "1.0.0.pre1", "1.0.0", -1},
+		{gemScheme, "1.0.0-rc1", "1.0.0.pre.rc1", 0},
+		{gemScheme, "1.10", "1.9", 1},
+		// Composer
+		{composerScheme, "v1.2.3", "1.2.3", 0},
+		{composerScheme, "1.0.0-alpha2", "1.0.0-beta1", -1},
+		{composerScheme, "1.0.0-RC1", "1.0.0", -1},
+		{composerScheme, "1.0.0", "1.0.0-p1", -1},
+		{composerScheme, "1.0.0.1", "1.0.0", 1},
+	}
+	for _, tt := range tests {
+		a, err := tt.scheme.parse(tt.a)
+		if err != nil {
+			t.Fatalf("%s: parse(%q) = %v", tt.scheme.name, tt.a, err)
+		}
+		b, err := tt.scheme.parse(tt.b)
+		if err != nil {
+			t.Fatalf("%s: parse(%q) = %v", tt.scheme.name, tt.b, err)
+		}
+		if got := tt.scheme.compare(a, b); got != tt.want {
+			t.Errorf("%s: compare(%q, %q) = %d; want %d", tt.scheme.name, tt.a, tt.b, got, tt.want)
+		}
+		if got := tt.scheme.compare(b, a); got != -tt.want {
+			t.Errorf("%s: compare(%q, %q) = %d; want %d", tt.scheme.name, tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestParseVersionInvalid(t *testing.T) {
+	tests := []struct {
+		scheme *versionScheme
+		s      string
+	}{
+		{semverScheme, "next"},
+		{semverScheme, "1.2.3.beta"},
+		{pep440Scheme, "1.0-foo"},
+		{gemScheme, "a.1"},
+		{composerScheme, "dev-main"},
+		{composerScheme, "1.x-dev"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.scheme.parse(tt.s); err == nil {
+			t.Errorf("%s: parse(%q) succeeded; want error", tt.scheme.name, tt.s)
+		}
+	}
+}
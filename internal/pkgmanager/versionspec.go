@@ -0,0 +1,476 @@
+package pkgmanager
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Release is a published version of a package.
+type Release struct {
+	Version string
+
+	// Published is when the version was published, or zero if it is unknown.
+	Published time.Time
+
+	// Yanked is set if the version was yanked (or unlisted) from the registry,
+	// so that it is not installed unless it is asked for exactly.
+	Yanked bool
+}
+
+// releaseList returns a Release for each version in the map of publish times.
+func releaseList(published map[string]time.Time) []Release {
+	releases := make([]Release, 0, len(published))
+	for v, t := range published {
+		releases = append(releases, Release{Version: v, Published: t})
+	}
+	return releases
+}
+
+// LastDaysPrefix is the prefix of version specs which select every version
+// published in the given number of days, e.g. "last:30d".
+const LastDaysPrefix = "last:"
+
+var (
+	lastDaysPattern = regexp.MustCompile(`^` + LastDaysPrefix + `(\d+)d?$`)
+	distTagPattern  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+)
+
+// timeNow returns the current time, and is replaced in tests.
+var timeNow = time.Now
+
+/*
+IsVersionSpec returns true if s selects versions of a package, rather than being
+an exact version. Version specs are:
+
+  - ranges, such as "^1.2", "~=3.1", ">=2,<3", "~> 1.0", "1.2.*" or "[1.0,2.0)",
+    using the syntax and version semantics of the ecosystem;
+  - dist-tags, such as "next" or "beta", for ecosystems which support them (npm);
+  - LastDaysPrefix followed by a number of days, such as "last:30d", which selects
+    every version published in that many days.
+
+A bare version (e.g. "1.2.3") is always treated as an exact version.
+*/
+func (p *PkgManager) IsVersionSpec(s string) bool {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "":
+		return false
+	case strings.HasPrefix(s, LastDaysPrefix):
+		return true
+	case strings.ContainsAny(s, " ,<>=^~*|![]()"):
+		return true
+	case p.distTags != nil && isDistTag(s):
+		return true
+	}
+	return hasWildcard(s)
+}
+
+// isDistTag returns true if s looks like a dist-tag rather than a version.
+// Versions prefixed with "v" (e.g. "v1.2.3") are not dist-tags.
+func isDistTag(s string) bool {
+	return distTagPattern.MatchString(s) && !(s[0] == 'v' && len(s) > 1 && s[1] >= '0' && s[1] <= '9')
+}
+
+// hasWildcard returns true if s has a wildcard segment, e.g. "1.x" or "1.2.*".
+func hasWildcard(s string) bool {
+	for _, seg := range strings.Split(s, ".") {
+		if seg == "*" || seg == "x" || seg == "X" {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ResolveVersions returns the versions of the named package which match the given
+version spec (see IsVersionSpec), in ascending order. An exact version is returned
+as is, without checking that it exists.
+
+Prerelease versions only match a range if a version in the range with the same
+release numbers is also a prerelease (e.g. ">=1.2.0-beta" matches "1.2.0-rc.1",
+but not "1.3.0-beta"), and yanked versions never match a range. Both are included
+in the versions published in the last number of days, since recently published
+versions are interesting whatever their status.
+
+If no version matches, ErrVersionNotFound is returned.
+*/
+func (p *PkgManager) ResolveVersions(ctx context.Context, name, spec string) ([]string, error) {
+	name = normalizePkgName(name)
+	spec = strings.TrimSpace(spec)
+	if !p.IsVersionSpec(spec) {
+		return []string{spec}, nil
+	}
+
+	if p.distTags != nil && isDistTag(spec) {
+		tags, err := p.distTags(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := tags[spec]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s has no dist-tag %q", ErrVersionNotFound, name, spec)
+		}
+		return []string{v}, nil
+	}
+
+	if p.releases == nil || p.versionScheme == nil {
+		return nil, fmt.Errorf("version specs are not supported for %s", p.Ecosystem())
+	}
+
+	var match func(r Release, v *version) bool
+	if m := lastDaysPattern.FindStringSubmatch(spec); m != nil {
+		days, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid version spec %q: %w", spec, err)
+		}
+		since := timeNow().AddDate(0, 0, -days)
+		match = func(r Release, _ *version) bool {
+			return r.Published.After(since)
+		}
+	} else {
+		vs, err := parseVersionSpec(p.versionScheme, spec)
+		if err != nil {
+			return nil, err
+		}
+		match = func(r Release, v *version) bool {
+			return !r.Yanked && vs.matches(v)
+		}
+	}
+
+	releases, err := p.releases(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []*version
+	for _, r := range releases {
+		v, err := p.versionScheme.parse(r.Version)
+		if err != nil {
+			// e.g. branches of Composer packages, which can't be ordered
+			continue
+		}
+		if match(r, v) {
+			matching = append(matching, v)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("%w: no version of %s matches %q", ErrVersionNotFound, name, spec)
+	}
+
+	slices.SortFunc(matching, p.versionScheme.compare)
+	versions := make([]string, len(matching))
+	for i, v := range matching {
+		versions[i] = v.raw
+	}
+	return versions, nil
+}
+
+// comparator is a single condition on a version, e.g. ">=1.2".
+type comparator struct {
+	op string
+	v  *version
+	// prefix is set for wildcard versions (e.g. "==1.2.*"), in which case only
+	// the release segments of v are compared, and only the "=" and "!=" ops are used.
+	prefix bool
+}
+
+// versionSpec is a parsed version range. A version matches if it satisfies every
+// comparator of any of the alternatives.
+type versionSpec struct {
+	scheme       *versionScheme
+	alternatives [][]comparator
+}
+
+// specOps lists the operators of version ranges, longest first so that they
+// are matched correctly as prefixes.
+var specOps = []string{"===", "==", "!=", ">=", "<=", "~>", "~=", ">", "<", "^", "~", "="}
+
+/*
+parseVersionSpec parses a version range. Alternatives are separated by "||", and
+the comparators of each alternative by commas or spaces. The operators of every
+supported ecosystem are accepted:
+
+  - "=", "==" and "===" match an exact version, or a prefix if it has a wildcard;
+  - "!=", ">", ">=", "<" and "<=" compare versions;
+  - "^1.2" matches versions up to the next change of the left-most non-zero segment;
+  - "~1.2" matches patch releases (npm, Cargo), or minor releases for Composer;
+  - "~>" (RubyGems) and "~=" (PEP 440) match versions up to the next change of the
+    second to last segment, e.g. "~> 1.2" matches ">= 1.2, < 2";
+  - "a - b" (npm) matches versions from a to b inclusive;
+  - "[a,b)" (NuGet) interval notation matches versions between a and b.
+
+A version without an operator is exact, except that a partial version is treated as
+a wildcard (e.g. "1.2" is "1.2.*").
+*/
+func parseVersionSpec(scheme *versionScheme, spec string) (*versionSpec, error) {
+	vs := &versionSpec{scheme: scheme}
+	for _, alt := range strings.Split(spec, "||") {
+		var comparators []comparator
+		var err error
+		if alt = strings.TrimSpace(alt); strings.HasPrefix(alt, "[") || strings.HasPrefix(alt, "(") {
+			comparators, err = parseInterval(scheme, alt)
+		} else {
+			comparators, err = parseComparators(scheme, alt)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid version spec %q: %w", spec, err)
+		}
+		vs.alternatives = append(vs.alternatives, comparators)
+	}
+	return vs, nil
+}
+
+// parseComparators parses the comparators of a single alternative of a version range.
+func parseComparators(scheme *versionScheme, alt string) ([]comparator, error) {
+	// join operators which are separated from their version by spaces, e.g. "~> 1.2"
+	var terms []string
+	for _, f := range strings.Fields(strings.ReplaceAll(alt, ",", " ")) {
+		if n := len(terms); n > 0 && slices.Contains(specOps, terms[n-1]) {
+			terms[n-1] += f
+		} else {
+			terms = append(terms, f)
+		}
+	}
+
+	var comparators []comparator
+	for i := 0; i < len(terms); i++ {
+		// hyphen range: "a - b"
+		if i+2 < len(terms) && terms[i+1] == "-" {
+			lower, err := parseComparator(scheme, ">="+terms[i])
+			if err != nil {
+				return nil, err
+			}
+			upper, err := hyphenUpperBound(scheme, terms[i+2])
+			if err != nil {
+				return nil, err
+			}
+			comparators = append(comparators, lower...)
+			comparators = append(comparators, upper...)
+			i += 2
+			continue
+		}
+
+		c, err := parseComparator(scheme, terms[i])
+		if err != nil {
+			return nil, err
+		}
+		comparators = append(comparators, c...)
+	}
+	return comparators, nil
+}
+
+// hyphenUpperBound returns the comparator for the upper bound of a hyphen range. As for
+// npm, a partial version includes every version it is a prefix of, e.g. "1.0 - 2.3"
+// matches "<2.4".
+func hyphenUpperBound(scheme *versionScheme, s string) ([]comparator, error) {
+	v, err := scheme.parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(v.release) >= 3 || v.prerelease {
+		return []comparator{{op: "<=", v: v}}, nil
+	}
+	bounds, err := boundedRange(scheme, v, len(v.release)-1)
+	if err != nil {
+		return nil, err
+	}
+	return bounds[1:], nil
+}
+
+// parseComparator parses a single term of a version range, which may expand into
+// more than one comparator (e.g. "^1.2" is ">=1.2, <2").
+func parseComparator(scheme *versionScheme, term string) ([]comparator, error) {
+	op := ""
+	for _, o := range specOps {
+		if strings.HasPrefix(term, o) {
+			op = o
+			break
+		}
+	}
+	s := strings.TrimSpace(strings.TrimPrefix(term, op))
+
+	// remove wildcard segments, e.g. "1.2.*" -> "1.2"
+	segments := strings.Split(s, ".")
+	wildcard := false
+	for len(segments) > 0 && (segments[len(segments)-1] == "*" || strings.EqualFold(segments[len(segments)-1], "x")) {
+		segments = segments[:len(segments)-1]
+		wildcard = true
+	}
+	if len(segments) == 0 {
+		// matches any version, unless it's negated
+		if op == "!=" {
+			return nil, fmt.Errorf("%q matches no versions", term)
+		}
+		return nil, nil
+	}
+	s = strings.Join(segments, ".")
+
+	v, err := scheme.parse(s)
+	if err != nil {
+		return nil, err
+	}
+	// a bare partial version is a wildcard, e.g. "1.2" is "1.2.*"
+	if op == "" && len(v.release) < 3 && !v.prerelease && v.epoch == 0 {
+		wildcard = true
+	}
+
+	switch op {
+	case "", "=", "==", "===":
+		if wildcard {
+			return []comparator{{op: "=", v: v, prefix: true}}, nil
+		}
+		return []comparator{{op: "=", v: v}}, nil
+	case "!=":
+		return []comparator{{op: "!=", v: v, prefix: wildcard}}, nil
+	case ">", ">=", "<", "<=":
+		return []comparator{{op: op, v: v}}, nil
+	case "^":
+		return boundedRange(scheme, v, caretIndex(v.release))
+	case "~":
+		if scheme.pessimisticTilde {
+			return boundedRange(scheme, v, pessimisticIndex(v.release))
+		}
+		return boundedRange(scheme, v, min(1, len(v.release)-1))
+	case "~>", "~=":
+		return boundedRange(scheme, v, pessimisticIndex(v.release))
+	}
+	return nil, fmt.Errorf("unknown operator in %q", term)
+}
+
+// caretIndex returns the index of the release segment which is incremented for the
+// upper bound of a caret range, which is the left-most non-zero segment.
+func caretIndex(release []int) int {
+	for i, n := range release {
+		if n != 0 {
+			return i
+		}
+	}
+	return len(release) - 1
+}
+
+// pessimisticIndex returns the index of the release segment which is incremented for
+// the upper bound of a pessimistic range, which is the second to last segment.
+func pessimisticIndex(release []int) int {
+	return max(len(release)-2, 0)
+}
+
+// boundedRange returns comparators matching versions from v (inclusive) up to the
+// version made by incrementing the release segment of v at index i (exclusive).
+func boundedRange(scheme *versionScheme, v *version, i int) ([]comparator, error) {
+	upper := slices.Clone(v.release[:i+1])
+	upper[i]++
+	segments := make([]string, len(upper))
+	for j, n := range upper {
+		segments[j] = strconv.Itoa(n)
+	}
+	if v.epoch != 0 {
+		segments[0] = fmt.Sprintf("%d!%s", v.epoch, segments[0])
+	}
+
+	u, err := scheme.parse(strings.Join(segments, "."))
+	if err != nil {
+		return nil, err
+	}
+	return []comparator{{op: ">=", v: v}, {op: "<", v: u}}, nil
+}
+
+// parseInterval parses NuGet interval notation, e.g. "[1.0,2.0)" or "(,1.0]".
+// See https://learn.microsoft.com/en-us/nuget/concepts/package-versioning#version-ranges
+func parseInterval(scheme *versionScheme, s string) ([]comparator, error) {
+	if len(s) < 2 || !strings.ContainsAny(s[len(s)-1:], "])") {
+		return nil, fmt.Errorf("unterminated interval %q", s)
+	}
+	inclusiveLower, inclusiveUpper := s[0] == '[', s[len(s)-1] == ']'
+	lowerStr, upperStr, isRange := strings.Cut(s[1:len(s)-1], ",")
+
+	if !isRange {
+		if !inclusiveLower || !inclusiveUpper {
+			return nil, fmt.Errorf("exact version must be inclusive in %q", s)
+		}
+		v, err := scheme.parse(lowerStr)
+		if err != nil {
+			return nil, err
+		}
+		return []comparator{{op: "=", v: v}}, nil
+	}
+
+	var comparators []comparator
+	bounds := []struct {
+		s         string
+		inclusive bool
+		op        string
+	}{
+		{lowerStr, inclusiveLower, ">"},
+		{upperStr, inclusiveUpper, "<"},
+	}
+	for _, b := range bounds {
+		if strings.TrimSpace(b.s) == "" {
+			continue
+		}
+		v, err := scheme.parse(b.s)
+		if err != nil {
+			return nil, err
+		}
+		op := b.op
+		if b.inclusive {
+			op += "="
+		}
+		comparators = append(comparators, comparator{op: op, v: v})
+	}
+	return comparators, nil
+}
+
+// matches returns true if v satisfies the comparator.
+func (c comparator) matches(scheme *versionScheme, v *version) bool {
+	if c.prefix {
+		equal := v.epoch == c.v.epoch && compareRelease(v.release[:min(len(v.release), len(c.v.release))], c.v.release) == 0
+		return equal == (c.op == "=")
+	}
+
+	cmp := scheme.compare(v, c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// matches returns true if v is in the range. See ResolveVersions for how
+// prerelease versions are matched.
+func (vs *versionSpec) matches(v *version) bool {
+	for _, alt := range vs.alternatives {
+		if vs.matchesAll(alt, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (vs *versionSpec) matchesAll(comparators []comparator, v *version) bool {
+	allowPrerelease := !v.prerelease
+	for _, c := range comparators {
+		if !c.matches(vs.scheme, v) {
+			return false
+		}
+		if c.v.prerelease && c.v.epoch == v.epoch && compareRelease(c.v.release, v.release) == 0 {
+			allowPrerelease = true
+		}
+	}
+	return allowPrerelease
+}
@@ -0,0 +1,233 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+func TestIsVersionSpec(t *testing.T) {
+	tests := []struct {
+		ecosystem pkgecosystem.Ecosystem
+		s         string
+		want      bool
+	}{
+		{pkgecosystem.NPM, "", false},
+		{pkgecosystem.NPM, "1.2.3", false},
+		{pkgecosystem.NPM, "1.2.3-beta.1", false},
+		{pkgecosystem.NPM, "^1.2", true},
+		{pkgecosystem.NPM, "1.x", true},
+		{pkgecosystem.NPM, "next", true},
+		{pkgecosystem.NPM, "last:30d", true},
+		{pkgecosystem.PyPI, "~=3.1", true},
+		{pkgecosystem.PyPI, ">=2,<3", true},
+		{pkgecosystem.PyPI, "1.0rc1", false},
+		{pkgecosystem.RubyGems, "~> 1.0", true},
+		{pkgecosystem.Packagist, "dev-main", false},
+		{pkgecosystem.Packagist, "v1.2.3", false},
+		{pkgecosystem.NuGet, "[1.0,2.0)", true},
+	}
+	for _, tt := range tests {
+		if got := Manager(tt.ecosystem).IsVersionSpec(tt.s); got != tt.want {
+			t.Errorf("%s: IsVersionSpec(%q) = %v; want %v", tt.ecosystem, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestVersionSpecMatches(t *testing.T) {
+	tests := []struct {
+		scheme   *versionScheme
+		spec     string
+		versions []string
+		want     []string
+	}{
+		{
+			scheme:   semverScheme,
+			spec:     "^1.2",
+			versions: []string{"1.1.0", "1.2.0", "1.9.9", "2.0.0", "1.3.0-beta.1"},
+			want:     []string{"1.2.0", "1.9.9"},
+		},
+		{
+			scheme:   semverScheme,
+			spec:     "^0.2.3",
+			versions: []string{"0.2.2", "0.2.3", "0.2.9", "0.3.0"},
+			want:     []string{"0.2.3", "0.2.9"},
+		},
+		{
+			scheme:   semverScheme,
+			spec:     "~1.2.3",
+			versions: []string{"1.2.2", "1.2.3", "1.2.9", "1.3.0"},
+			want:     []string{"1.2.3", "1.2.9"},
+		},
+		{
+			scheme:   semverScheme,
+			spec:     ">=1.0.0-beta.1 <2",
+			versions: []string{"1.0.0-alpha", "1.0.0-beta.2", "1.0.0", "1.1.0-beta.1", "2.0.0-rc.1"},
+			want:     []string{"1.0.0-beta.2", "1.0.0"},
+		},
+		{
+			scheme:   semverScheme,
+			spec:     "1.x || >=3.1.0",
+			versions: []string{"1.0.0", "1.5.0", "2.0.0", "3.0.0", "3.1.0"},
+			want:     []string{"1.0.0", "1.5.0", "3.1.0"},
+		},
+		{
+			scheme:   semverScheme,
+			spec:     "1.2.3 - 2.3",
+			versions: []string{"1.2.2", "1.2.3", "2.3.0", "2.3.9", "2.4.0"},
+			want:     []string{"1.2.3", "2.3.0", "2.3.9"},
+		},
+		{
+			scheme:   pep440Scheme,
+			spec:     "~=3.1",
+			versions: []string{"3.0", "3.1", "3.9.1", "3.10rc1", "4.0"},
+			want:     []string{"3.1", "3.9.1"},
+		},
+		{
+			scheme:   pep440Scheme,
+			spec:     ">=2,<3,!=2.1.*",
+			versions: []string{"1.9", "2.0", "2.1", "2.1.5", "2.2.post1", "3.0"},
+			want:     []string{"2.0", "2.2.post1"},
+		},
+		{
+			scheme:   pep440Scheme,
+			spec:     "==1.0rc1",
+			versions: []string{"1.0rc1", "1.0"},
+			want:     []string{"1.0rc1"},
+		},
+		{
+			scheme:   gemScheme,
+			spec:     "~> 1.2",
+			versions: []string{"1.1", "1.2.0", "1.9", "2.0", "1.5.0.pre"},
+			want:     []string{"1.2.0", "1.9"},
+		},
+		{
+			scheme:   gemScheme,
+			spec:     "~> 1.2.3, != 1.2.4",
+			versions: []string{"1.2.3", "1.2.4", "1.2.5", "1.3.0"},
+			want:     []string{"1.2.3", "1.2.5"},
+		},
+		{
+			scheme:   composerScheme,
+			spec:     "~1.2",
+			versions: []string{"1.1.0", "1.2.0", "1.9.0", "2.0.0", "1.5.0-beta1"},
+			want:     []string{"1.2.0", "1.9.0"},
+		},
+		{
+			scheme:   composerScheme,
+			spec:     "^1.2 || ^2.0",
+			versions: []string{"v1.1.0", "v1.2.0", "v2.4.0", "v3.0.0"},
+			want:     []string{"v1.2.0", "v2.4.0"},
+		},
+		{
+			scheme:   nugetScheme,
+			spec:     "[1.0,2.0)",
+			versions: []string{"0.9.0", "1.0.0", "1.5.0", "2.0.0"},
+			want:     []string{"1.0.0", "1.5.0"},
+		},
+		{
+			scheme:   nugetScheme,
+			spec:     "(,1.5]",
+			versions: []string{"0.9.0", "1.5.0", "1.5.1"},
+			want:     []string{"0.9.0", "1.5.0"},
+		},
+	}
+	for _, tt := range tests {
+		vs, err := parseVersionSpec(tt.scheme, tt.spec)
+		if err != nil {
+			t.Fatalf("%s: parseVersionSpec(%q) = %v", tt.scheme.name, tt.spec, err)
+		}
+
+		var got []string
+		for _, s := range tt.versions {
+			v, err := tt.scheme.parse(s)
+			if err != nil {
+				t.Fatalf("%s: parse(%q) = %v", tt.scheme.name, s, err)
+			}
+			if vs.matches(v) {
+				got = append(got, s)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %q matches %v; want %v", tt.scheme.name, tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionSpecInvalid(t *testing.T) {
+	for _, spec := range []string{">=abc", "[1.0,2.0", "(1.0)", "!=*"} {
+		if _, err := parseVersionSpec(semverScheme, spec); err == nil {
+			t.Errorf("parseVersionSpec(%q) succeeded; want error", spec)
+		}
+	}
+}
+
+func TestResolveVersions(t *testing.T) {
+	now := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	useTestRegistry(t, pkgecosystem.NPM, map[string]string{
+		"/test": `{
+			"dist-tags": {"latest": "1.2.0", "next": "2.0.0-beta.1"},
+			"versions": {"1.0.0": {}, "1.2.0": {}, "1.10.0": {}, "2.0.0-beta.1": {}},
+			"time": {
+				"created": "2023-01-01T00:00:00Z",
+				"1.0.0": "2023-01-01T00:00:00Z",
+				"1.2.0": "2023-05-01T00:00:00Z",
+				"1.10.0": "2023-06-20T00:00:00Z",
+				"2.0.0-beta.1": "2023-06-25T00:00:00Z"
+			}
+		}`,
+	})
+
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr error
+	}{
+		{spec: "1.0.0", want: []string{"1.0.0"}},
+		{spec: "^1.0.0", want: []string{"1.0.0", "1.2.0", "1.10.0"}},
+		{spec: "next", want: []string{"2.0.0-beta.1"}},
+		{spec: "last:30d", want: []string{"1.10.0", "2.0.0-beta.1"}},
+		{spec: "^3", wantErr: ErrVersionNotFound},
+		{spec: "beta", wantErr: ErrVersionNotFound},
+	}
+	for _, tt := range tests {
+		got, err := Manager(pkgecosystem.NPM).ResolveVersions(context.Background(), "test", tt.spec)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ResolveVersions(%q) error = %v; want %v", tt.spec, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveVersions(%q) = %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveVersions(%q) = %v; want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestResolveVersionsYanked(t *testing.T) {
+	useTestRegistry(t, pkgecosystem.CratesIO, map[string]string{
+		"/api/v1/crates/test": `{"versions": [
+			{"num": "1.1.0", "created_at": "2023-02-01T00:00:00Z", "yanked": true},
+			{"num": "1.0.0", "created_at": "2023-01-01T00:00:00Z", "yanked": false}
+		]}`,
+	})
+
+	got, err := Manager(pkgecosystem.CratesIO).ResolveVersions(context.Background(), "test", "^1")
+	if err != nil {
+		t.Fatalf("ResolveVersions() = %v", err)
+	}
+	if want := []string{"1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveVersions() = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,16 @@
+package registryproxy
+
+import "syscall"
+
+// freeBind sets IP_FREEBIND on the socket, which allows it to be bound to an
+// address that is not (yet) assigned to the host.
+func freeBind(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_FREEBIND, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
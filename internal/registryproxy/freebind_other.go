@@ -0,0 +1,10 @@
+//go:build !linux
+
+package registryproxy
+
+import "syscall"
+
+// freeBind does nothing, since IP_FREEBIND is only supported on Linux.
+func freeBind(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,245 @@
+/*
+Package registryproxy implements an HTTP proxy which gives package managers in the
+dynamic analysis sandbox access to private registries, without giving them the
+registries' credentials.
+
+Each registry is served under its own path prefix. The proxy forwards each request
+to the registry, replacing any Authorization header with the registry's credentials.
+Only GET and HEAD requests for paths under the registry URL are forwarded, so that
+the proxy cannot be used to publish or delete packages. Absolute URLs of the registry
+in responses (such as npm tarball URLs or NuGet service index resources) are rewritten
+to go through the proxy. The credentials only exist in the worker process, so they can
+never be read by the package under analysis.
+*/
+package registryproxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ossf/package-analysis/internal/pkgmanager"
+)
+
+const (
+	// SandboxGateway is the address of the host on the sandbox network. It must
+	// match the gateway of the subnet in tools/network/podman-analysis.conflist.
+	SandboxGateway = "172.16.16.1"
+
+	// SandboxPort is the port the proxy listens on for the sandbox. It must match
+	// the rule in tools/network/iptables.rules which allows the sandbox network to
+	// connect to the proxy.
+	SandboxPort = 3129
+)
+
+// SandboxNetwork is the subnet of the sandbox network, from which connections
+// to the proxy are accepted.
+var SandboxNetwork = netip.MustParsePrefix("172.16.16.0/24")
+
+// Proxy is a running registry proxy.
+type Proxy struct {
+	listener net.Listener
+	server   *http.Server
+
+	mu         sync.RWMutex
+	registries map[string]*registry
+}
+
+// registry is a registry served by the proxy.
+type registry struct {
+	upstream *url.URL
+	auth     pkgmanager.RegistryAuth
+}
+
+/*
+Start starts a proxy which listens on listenAddr, and only accepts connections from
+addresses in clients. The address does not need to be assigned to the host yet, so
+that the proxy can be started before the sandbox network is created. Registries are
+made available through the proxy with AddRegistry. Close must be called to stop the proxy.
+*/
+func Start(listenAddr string, clients netip.Prefix) (*Proxy, error) {
+	listenConfig := net.ListenConfig{Control: freeBind}
+	listener, err := listenConfig.Listen(context.Background(), "tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{listener: listener, registries: map[string]*registry{}}
+	p.server = &http.Server{Handler: p.handler(clients)}
+	go func() {
+		if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Registry proxy stopped", "error", err)
+		}
+	}()
+
+	return p, nil
+}
+
+// AddRegistry makes the registry at registryURL available through the proxy under
+// the given name, adding the given credentials to each request. The name must be a
+// single path segment. If a registry was already added with the name, it is replaced.
+func (p *Proxy) AddRegistry(name, registryURL string, auth pkgmanager.RegistryAuth) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid registry name %q", name)
+	}
+	upstream, err := url.Parse(registryURL)
+	if err != nil || upstream.Host == "" {
+		return fmt.Errorf("invalid registry URL %q", registryURL)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.registries[name] = &registry{upstream: upstream, auth: auth}
+	return nil
+}
+
+// Addr returns the address the proxy is listening on.
+func (p *Proxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// RegistryURL returns the URL of the registry added with the given name, when
+// accessed through the proxy at the given host (and port), or an empty string if
+// there is no such registry.
+func (p *Proxy) RegistryURL(name, host string) string {
+	p.mu.RLock()
+	reg := p.registries[name]
+	p.mu.RUnlock()
+	if reg == nil {
+		return ""
+	}
+
+	u := *reg.upstream
+	u.Scheme = "http"
+	u.Host = host
+	u.Path = "/" + name + reg.upstream.Path
+	u.RawPath = ""
+	return u.String()
+}
+
+// Close stops the proxy.
+func (p *Proxy) Close() error {
+	return p.server.Close()
+}
+
+func (p *Proxy) handler(clients netip.Prefix) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isClient(r.RemoteAddr, clients) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		// The first path segment names the registry.
+		name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		p.mu.RLock()
+		reg := p.registries[name]
+		p.mu.RUnlock()
+		if reg == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		namePrefix := "/" + name
+		upstreamPath := strings.TrimPrefix(r.URL.Path, namePrefix)
+		if !isAllowed(r.Method, upstreamPath, reg.upstream.Path) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		// URLs in the response are rewritten to the host the client used to connect.
+		upstreamOrigin := reg.upstream.Scheme + "://" + reg.upstream.Host
+		proxyOrigin := "http://" + r.Host + namePrefix
+		proxy := &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.Out.URL.Scheme = reg.upstream.Scheme
+				pr.Out.URL.Host = reg.upstream.Host
+				pr.Out.URL.Path = upstreamPath
+				pr.Out.URL.RawPath = strings.TrimPrefix(pr.In.URL.RawPath, namePrefix)
+				pr.Out.Host = reg.upstream.Host
+				pr.Out.Header.Set("Authorization", reg.auth.AuthorizationHeader())
+				// Let the transport negotiate compression, so that responses are
+				// decompressed before their URLs are rewritten.
+				pr.Out.Header.Del("Accept-Encoding")
+			},
+			ModifyResponse: func(resp *http.Response) error {
+				return rewriteResponse(resp, upstreamOrigin, proxyOrigin)
+			},
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// isAllowed returns true if a request with the given method and path only reads
+// from the registry whose URL has the path registryPath, i.e. it is a GET or HEAD
+// request for a path under registryPath that has no "." or ".." segments.
+func isAllowed(method, path, registryPath string) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+	registryPath = strings.TrimSuffix(registryPath, "/")
+	return path == registryPath || strings.HasPrefix(path, registryPath+"/")
+}
+
+// isClient returns true if remoteAddr (in host:port form) is in clients.
+func isClient(remoteAddr string, clients netip.Prefix) bool {
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	return clients.Contains(addrPort.Addr().Unmap())
+}
+
+// isTextContent returns true if the given Content-Type is one that may contain
+// registry URLs, such as JSON, XML or HTML.
+func isTextContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml")
+}
+
+// rewriteResponse replaces upstreamOrigin by proxyOrigin in the redirect location
+// and textual body of resp.
+func rewriteResponse(resp *http.Response, upstreamOrigin, proxyOrigin string) error {
+	if location := resp.Header.Get("Location"); strings.HasPrefix(location, upstreamOrigin) {
+		resp.Header.Set("Location", proxyOrigin+strings.TrimPrefix(location, upstreamOrigin))
+	}
+
+	if !isTextContent(resp.Header.Get("Content-Type")) || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := resp.Body.Close(); err != nil {
+		return err
+	}
+
+	body = bytes.ReplaceAll(body, []byte(upstreamOrigin), []byte(proxyOrigin))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
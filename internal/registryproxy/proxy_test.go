@@ -0,0 +1,167 @@
+package registryproxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/ossf/package-analysis/internal/pkgmanager"
+)
+
+var loopback = netip.MustParsePrefix("127.0.0.0/8")
+
+// startTestProxy starts a proxy for a test registry which serves a JSON document
+// containing its own URL at /pkg, and returns the proxy's registry URL. The test
+// registry accepts any request for /other, which the proxy must not forward.
+func startTestProxy(t *testing.T, auth pkgmanager.RegistryAuth, clients netip.Prefix) string {
+	t.Helper()
+
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != auth.AuthorizationHeader() {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/registry/pkg":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"tarball": "%s/registry/pkg.tgz"}`, registry.URL)
+		case "/registry/pkg.tgz":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, "archive")
+		case "/other", "/registry-other":
+			fmt.Fprint(w, "other")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(registry.Close)
+
+	proxy, err := Start("127.0.0.1:0", clients)
+	if err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	t.Cleanup(func() { _ = proxy.Close() })
+
+	if err := proxy.AddRegistry("test", registry.URL+"/registry", auth); err != nil {
+		t.Fatalf("AddRegistry() = %v", err)
+	}
+
+	return proxy.RegistryURL("test", proxy.Addr().String())
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	return do(t, http.MethodGet, url)
+}
+
+func do(t *testing.T, method, url string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() = %v", err)
+	}
+	// Credentials sent by the client must be replaced by the proxy.
+	req.Header.Set("Authorization", "Bearer client-token")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s = %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response body = %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestProxy(t *testing.T) {
+	tests := []struct {
+		name string
+		auth pkgmanager.RegistryAuth
+	}{
+		{
+			name: "token",
+			auth: pkgmanager.RegistryAuth{Token: "secret"},
+		},
+		{
+			name: "basic",
+			auth: pkgmanager.RegistryAuth{Username: "user", Password: "secret"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registryURL := startTestProxy(t, tt.auth, loopback)
+
+			status, body := get(t, registryURL+"/pkg")
+			if status != http.StatusOK {
+				t.Fatalf("GET /pkg status = %d; want %d", status, http.StatusOK)
+			}
+			if want := fmt.Sprintf(`{"tarball": "%s/pkg.tgz"}`, registryURL); body != want {
+				t.Errorf("GET /pkg body = %s; want %s", body, want)
+			}
+
+			status, body = get(t, registryURL+"/pkg.tgz")
+			if status != http.StatusOK || body != "archive" {
+				t.Errorf("GET /pkg.tgz = %d %q; want %d %q", status, body, http.StatusOK, "archive")
+			}
+		})
+	}
+}
+
+func TestProxyRejectsOtherClients(t *testing.T) {
+	registryURL := startTestProxy(t, pkgmanager.RegistryAuth{Token: "secret"}, SandboxNetwork)
+
+	if status, _ := get(t, registryURL+"/pkg"); status != http.StatusForbidden {
+		t.Errorf("GET /pkg status = %d; want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestProxyRejectsRequests(t *testing.T) {
+	registryURL := startTestProxy(t, pkgmanager.RegistryAuth{Token: "secret"}, loopback)
+	proxyURL := strings.TrimSuffix(registryURL, "/test/registry")
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+	}{
+		{"put", http.MethodPut, registryURL + "/pkg", http.StatusForbidden},
+		{"post", http.MethodPost, registryURL + "/pkg", http.StatusForbidden},
+		{"delete", http.MethodDelete, registryURL + "/pkg", http.StatusForbidden},
+		{"outside registry path", http.MethodGet, proxyURL + "/test/other", http.StatusForbidden},
+		{"registry path prefix", http.MethodGet, proxyURL + "/test/registry-other", http.StatusForbidden},
+		{"dot dot", http.MethodGet, registryURL + "/../other", http.StatusForbidden},
+		{"escaped dot dot", http.MethodGet, registryURL + "/%2e%2e/other", http.StatusForbidden},
+		{"unknown registry", http.MethodGet, proxyURL + "/unknown/registry/pkg", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := do(t, tt.method, tt.url); status != tt.wantStatus {
+				t.Errorf("%s %s = %d %q; want %d", tt.method, tt.url, status, body, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAddRegistryInvalidName(t *testing.T) {
+	proxy, err := Start("127.0.0.1:0", loopback)
+	if err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	defer proxy.Close()
+
+	for _, name := range []string{"", ".", "..", "a/b"} {
+		if err := proxy.AddRegistry(name, "https://registry.example.com", pkgmanager.RegistryAuth{Token: "secret"}); err == nil {
+			t.Errorf("AddRegistry(%q) = nil; want error", name)
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	_ "gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/gcsblob"
 	_ "gocloud.dev/blob/s3blob"
+	"gocloud.dev/gcerrors"
 
 	"github.com/ossf/package-analysis/internal/pkgmanager"
 	"github.com/ossf/package-analysis/internal/utils"
@@ -23,10 +24,15 @@ import (
 	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
 )
 
+// pipelineVersionKey is the blob metadata key used to record the version of the
+// analysis pipeline that saved a result.
+const pipelineVersionKey = "pipeline-version"
+
 type ResultStore struct {
-	bucket        *url.URL
-	keyPrefix     string
-	constructPath bool
+	bucket          *url.URL
+	keyPrefix       string
+	constructPath   bool
+	pipelineVersion string
 }
 
 type (
@@ -42,6 +48,19 @@ func ConstructPath() Option {
 	return option(func(rs *ResultStore) { rs.constructPath = true })
 }
 
+// PipelineVersion records the given version of the analysis pipeline (e.g. the sandbox
+// image tag) with each result saved, and causes Exists() to ignore results saved by a
+// different version of the pipeline.
+func PipelineVersion(version string) Option {
+	return option(func(rs *ResultStore) { rs.pipelineVersion = version })
+}
+
+// PipelineVersion returns the version of the analysis pipeline given by the
+// PipelineVersion() option, or an empty string if there is none.
+func (rs *ResultStore) PipelineVersion() string {
+	return rs.pipelineVersion
+}
+
 // New creates a new ResultStore instance with the given bucket URL and options.
 // If the bucket URL is invalid, a nil pointer is returned.
 func New(bucket string, options ...Option) *ResultStore {
@@ -101,6 +120,16 @@ func (rs *ResultStore) openBucket(ctx context.Context) (*blob.Bucket, error) {
 	return blob.OpenBucket(ctx, rs.bucket.String())
 }
 
+// writerOptions returns the options used to write results to the bucket.
+func (rs *ResultStore) writerOptions() *blob.WriterOptions {
+	if rs.pipelineVersion == "" {
+		return nil
+	}
+	return &blob.WriterOptions{
+		Metadata: map[string]string{pipelineVersionKey: rs.pipelineVersion},
+	}
+}
+
 func (rs *ResultStore) SaveTempFilesToZip(ctx context.Context, p Pkg, zipName string, tempFileNames []string) error {
 	bkt, err := rs.openBucket(ctx)
 	if err != nil {
@@ -111,7 +140,7 @@ func (rs *ResultStore) SaveTempFilesToZip(ctx context.Context, p Pkg, zipName st
 	uploadPath := rs.generateKey(p, zipName+".zip")
 	slog.InfoContext(ctx, "Uploading results", "bucket", rs.bucket.String(), "path", uploadPath)
 
-	bucketWriter, err := bkt.NewWriter(ctx, uploadPath, nil)
+	bucketWriter, err := bkt.NewWriter(ctx, uploadPath, rs.writerOptions())
 	if err != nil {
 		return err
 	}
@@ -143,7 +172,7 @@ func (rs *ResultStore) SaveTempFilesToZip(ctx context.Context, p Pkg, zipName st
 }
 
 func (rs *ResultStore) SaveAnalyzedPackage(ctx context.Context, manager *pkgmanager.PkgManager, pkg Pkg) error {
-	archivePath, err := manager.DownloadArchive(pkg.Name(), pkg.Version(), "")
+	archivePath, err := manager.DownloadArchive(ctx, pkg.Name(), pkg.Version(), "")
 	if errors.Is(err, pkgmanager.ErrNoArchiveURL) {
 		slog.WarnContext(ctx, "unable to download archive", "error", err)
 		return nil
@@ -162,13 +191,19 @@ func (rs *ResultStore) SaveAnalyzedPackage(ctx context.Context, manager *pkgmana
 		return err
 	}
 
+	return rs.SaveArchive(ctx, pkg, archivePath, hash)
+}
+
+// SaveArchive uploads the package archive at the given path, which has already been
+// downloaded, to the bucket. The key includes the (hex encoded) SHA-256 hash of the archive.
+func (rs *ResultStore) SaveArchive(ctx context.Context, pkg Pkg, archivePath, sha256 string) error {
 	bkt, err := rs.openBucket(ctx)
 	if err != nil {
 		return err
 	}
 	defer bkt.Close()
 
-	uploadPath := rs.generateKey(pkg, pkg.Version()+"-"+hash)
+	uploadPath := rs.generateKey(pkg, pkg.Version()+"-"+sha256)
 	slog.InfoContext(ctx, "Uploading analyzed package", "bucket", rs.bucket.String(), "path", uploadPath)
 
 	f, err := os.Open(archivePath)
@@ -177,7 +212,7 @@ func (rs *ResultStore) SaveAnalyzedPackage(ctx context.Context, manager *pkgmana
 	}
 	defer f.Close()
 
-	w, err := bkt.NewWriter(ctx, uploadPath, nil)
+	w, err := bkt.NewWriter(ctx, uploadPath, rs.writerOptions())
 	if err != nil {
 		return err
 	}
@@ -217,7 +252,7 @@ func (rs *ResultStore) saveWithFilename(ctx context.Context, p Pkg, data any, fi
 	uploadPath := rs.generateKey(p, filename)
 	slog.InfoContext(ctx, "Uploading results", "bucket", rs.bucket.String(), "path", uploadPath)
 
-	w, err := bkt.NewWriter(ctx, uploadPath, nil)
+	w, err := bkt.NewWriter(ctx, uploadPath, rs.writerOptions())
 	if err != nil {
 		return err
 	}
@@ -242,9 +277,50 @@ func DefaultFilename(p Pkg) string {
 	return "results.json"
 }
 
+// Exists returns true if an object with the given filename (key) has already been saved for
+// the package. If filename is empty, a default filename (chosen using DefaultFilename) is used,
+// as when saving results.
+//
+// Only objects saved by the version of the analysis pipeline given by the PipelineVersion()
+// option are treated as existing. If the ResultStore has no pipeline version, it cannot be
+// known which version saved an object, so false is always returned.
+func (rs *ResultStore) Exists(ctx context.Context, p Pkg, filename string) (bool, error) {
+	if rs.pipelineVersion == "" {
+		return false, nil
+	}
+	if filename == "" {
+		filename = DefaultFilename(p)
+	}
+
+	bkt, err := rs.openBucket(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer bkt.Close()
+
+	attrs, err := bkt.Attributes(ctx, rs.generateKey(p, filename))
+	if gcerrors.Code(err) == gcerrors.NotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if attrs.Metadata[pipelineVersionKey] != rs.pipelineVersion {
+		return false, nil
+	}
+
+	return true, nil
+}
+
 // SaveDynamicAnalysis wraps the analysis object with the DynamicAnalysisRecord struct and saves it to the bucket
 // using saveWithFilename. If filename is empty, a default filename (chosen using DefaultFilename) is used.
 func (rs *ResultStore) SaveDynamicAnalysis(ctx context.Context, p Pkg, analysis any, filename string) error {
+	return rs.SaveProfileDynamicAnalysis(ctx, p, "", analysis, filename)
+}
+
+// SaveProfileDynamicAnalysis is like SaveDynamicAnalysis, but also records the name of the
+// environment profile the analysis ran in.
+func (rs *ResultStore) SaveProfileDynamicAnalysis(ctx context.Context, p Pkg, profile string, analysis any, filename string) error {
 	if filename == "" {
 		filename = DefaultFilename(p)
 	}
@@ -256,12 +332,33 @@ func (rs *ResultStore) SaveDynamicAnalysis(ctx context.Context, p Pkg, analysis
 			Version:   p.Version(),
 		},
 		CreatedTimestamp: time.Now().UTC().Unix(),
+		Profile:          profile,
 		Analysis:         analysis,
 	}
 
 	return rs.saveWithFilename(ctx, p, data, filename)
 }
 
+// SaveMetadata wraps the package metadata with the MetadataRecord struct and saves it to the bucket
+// using saveWithFilename. If filename is empty, a default filename (chosen using DefaultFilename) is used.
+func (rs *ResultStore) SaveMetadata(ctx context.Context, p Pkg, metadata *analysisrun.PackageMetadata, filename string) error {
+	if filename == "" {
+		filename = DefaultFilename(p)
+	}
+
+	data := &analysisrun.MetadataRecord{
+		Package: analysisrun.Key{
+			Ecosystem: p.Ecosystem(),
+			Name:      p.Name(),
+			Version:   p.Version(),
+		},
+		CreatedTimestamp: time.Now().UTC().Unix(),
+		Metadata:         *metadata,
+	}
+
+	return rs.saveWithFilename(ctx, p, data, filename)
+}
+
 // SaveStaticAnalysis wraps the results object with the Record struct and saves it to the bucket
 // using saveWithFilename. If filename is empty, a default filename (chosen using DefaultFilename) is used.
 func (rs *ResultStore) SaveStaticAnalysis(ctx context.Context, p Pkg, data *staticanalysis.Record, filename string) error {
@@ -2,11 +2,16 @@ package resultstore
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
 )
 
 func TestFileBucket(t *testing.T) {
@@ -58,3 +63,123 @@ func TestFileBucket(t *testing.T) {
 		t.Errorf("failed to close bucket: %v", err)
 	}
 }
+
+type testPkg struct {
+	name, version string
+}
+
+func (p testPkg) Ecosystem() pkgecosystem.Ecosystem { return pkgecosystem.NPM }
+func (p testPkg) EcosystemName() string             { return string(pkgecosystem.NPM) }
+func (p testPkg) Name() string                      { return p.name }
+func (p testPkg) Version() string                   { return p.version }
+
+func TestExists(t *testing.T) {
+	ctx := context.Background()
+	rs := New("file://"+t.TempDir(), ConstructPath(), PipelineVersion("v1"))
+
+	saved := testPkg{name: "test", version: "1.0.0"}
+	if err := rs.SaveStaticAnalysis(ctx, saved, &staticanalysis.Record{}, ""); err != nil {
+		t.Fatalf("SaveStaticAnalysis() = %v", err)
+	}
+
+	tests := []struct {
+		pkg      testPkg
+		filename string
+		want     bool
+	}{
+		{pkg: saved, want: true},
+		{pkg: saved, filename: "1.0.0.json", want: true},
+		{pkg: saved, filename: "other.json", want: false},
+		{pkg: testPkg{name: "test", version: "2.0.0"}, want: false},
+		{pkg: testPkg{name: "other", version: "1.0.0"}, want: false},
+	}
+	for _, tt := range tests {
+		got, err := rs.Exists(ctx, tt.pkg, tt.filename)
+		if err != nil {
+			t.Fatalf("Exists(%v, %q) = %v", tt.pkg, tt.filename, err)
+		}
+		if got != tt.want {
+			t.Errorf("Exists(%v, %q) = %v; want %v", tt.pkg, tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestExistsPipelineVersion(t *testing.T) {
+	ctx := context.Background()
+	dir := "file://" + t.TempDir()
+	pkg := testPkg{name: "test", version: "1.0.0"}
+
+	if err := New(dir, PipelineVersion("v1")).SaveStaticAnalysis(ctx, pkg, &staticanalysis.Record{}, ""); err != nil {
+		t.Fatalf("SaveStaticAnalysis() = %v", err)
+	}
+
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "", want: false},
+		{version: "v1", want: true},
+		{version: "v2", want: false},
+	}
+	for _, tt := range tests {
+		got, err := New(dir, PipelineVersion(tt.version)).Exists(ctx, pkg, "")
+		if err != nil {
+			t.Fatalf("Exists() with version %q = %v", tt.version, err)
+		}
+		if got != tt.want {
+			t.Errorf("Exists() with version %q = %v; want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestExistsNoPipelineVersion(t *testing.T) {
+	ctx := context.Background()
+	rs := New("file://" + t.TempDir())
+	pkg := testPkg{name: "test", version: "1.0.0"}
+
+	// Without a pipeline version, it is unknown which version saved the results,
+	// so they must be analyzed again.
+	if err := rs.SaveStaticAnalysis(ctx, pkg, &staticanalysis.Record{}, ""); err != nil {
+		t.Fatalf("SaveStaticAnalysis() = %v", err)
+	}
+	got, err := rs.Exists(ctx, pkg, "")
+	if err != nil {
+		t.Fatalf("Exists() = %v", err)
+	}
+	if got {
+		t.Errorf("Exists() without pipeline version = true; want false")
+	}
+}
+
+func TestSaveProfileDynamicAnalysis(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	rs := New("file://"+dir, ConstructPath(), PipelineVersion("v1"))
+	pkg := testPkg{name: "test", version: "1.0.0"}
+
+	if err := rs.SaveProfileDynamicAnalysis(ctx, pkg, "ci", map[string]string{}, "ci-1.0.0.json"); err != nil {
+		t.Fatalf("SaveProfileDynamicAnalysis() = %v", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, pkg.EcosystemName(), pkg.Name(), "ci-1.0.0.json"))
+	if err != nil {
+		t.Fatalf("reading saved record = %v", err)
+	}
+	var record analysisrun.DynamicAnalysisRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		t.Fatalf("json.Unmarshal() = %v", err)
+	}
+	if record.Profile != "ci" {
+		t.Errorf("Profile = %q; want %q", record.Profile, "ci")
+	}
+
+	for filename, want := range map[string]bool{"ci-1.0.0.json": true, "": false} {
+		got, err := rs.Exists(ctx, pkg, filename)
+		if err != nil {
+			t.Fatalf("Exists(%q) = %v", filename, err)
+		}
+		if got != want {
+			t.Errorf("Exists(%q) = %v; want %v", filename, got, want)
+		}
+	}
+}
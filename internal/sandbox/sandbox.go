@@ -29,6 +29,9 @@ const (
 	// used by the sandbox during analysis to separate the sandbox traffic
 	// from the host.
 	networkName = "analysis-net"
+
+	// snapshotImage is the name of the images created by Snapshot.
+	snapshotImage = "localhost/sandbox-snapshot"
 )
 
 type RunStatus uint8
@@ -110,6 +113,29 @@ type Sandbox interface {
 	// should be performed on the file before use.
 	// The sandbox must be initialised using Init() before calling this function.
 	CopyBackToHost(ctx context.Context, hostPath, sandboxPath string) error
+
+	// Snapshot saves the current state of the sandbox filesystem, so that new
+	// sandboxes can be started from it. The sandbox must not be running a command.
+	// The sandbox must be initialised using Init() before calling this function.
+	Snapshot(ctx context.Context) (*Snapshot, error)
+}
+
+// Snapshot is a saved state of a sandbox, stored as a local image.
+type Snapshot struct {
+	image string
+	tag   string
+}
+
+// Options returns the options needed to create a sandbox that starts from the
+// snapshot. They must be applied after any other Image, Tag or NoPull options.
+func (s *Snapshot) Options() []Option {
+	return []Option{Image(s.image), Tag(s.tag), NoPull()}
+}
+
+// Remove deletes the image holding the snapshot. Sandboxes created from the
+// snapshot must be cleaned up first.
+func (s *Snapshot) Remove(ctx context.Context) error {
+	return podmanRun(ctx, "rmi", "--force", fmt.Sprintf("%s:%s", s.image, s.tag))
 }
 
 // volume represents a volume mapping between a host src and a container dest.
@@ -132,6 +158,7 @@ type podmanSandbox struct {
 	id          string
 	container   string
 	noPull      bool
+	concurrent  bool
 	rawSockets  bool
 	strace      bool
 	offline     bool
@@ -144,6 +171,8 @@ type podmanSandbox struct {
 	volumes     []volume
 	copies      []copySpec
 	environment map[string]string
+	hostname    string
+	hosts       []string
 	logger      *slog.Logger
 }
 
@@ -222,6 +251,13 @@ func NoPull() Option {
 	return option(func(sb *podmanSandbox) { sb.noPull = true })
 }
 
+// Concurrent allows other sandboxes to run at the same time as this one. Init
+// won't remove the logs of other sandboxes or prune images, and Clean only
+// removes the container of this sandbox, instead of every container.
+func Concurrent() Option {
+	return option(func(sb *podmanSandbox) { sb.concurrent = true })
+}
+
 // Volume can be used to specify an additional volume map into the container.
 // src is the path in the host that will be mapped to the dest path.
 func Volume(src, dest string) Option {
@@ -253,6 +289,16 @@ func SetEnv(key, value string) Option {
 	return option(func(sb *podmanSandbox) { sb.environment[key] = value })
 }
 
+// Hostname sets the hostname of the sandbox.
+func Hostname(hostname string) Option {
+	return option(func(sb *podmanSandbox) { sb.hostname = hostname })
+}
+
+// AddHost adds an entry to /etc/hosts in the sandbox, which resolves host to ip.
+func AddHost(host, ip string) Option {
+	return option(func(sb *podmanSandbox) { sb.hosts = append(sb.hosts, host+":"+ip) })
+}
+
 func removeAllLogs() error {
 	matches, err := filepath.Glob(filepath.Join(os.TempDir(), logDirPattern+"*"))
 	if err != nil {
@@ -292,6 +338,16 @@ func (s *podmanSandbox) pullImage(ctx context.Context) error {
 	return podmanRun(ctx, "pull", s.imageWithTag())
 }
 
+// Pull pulls the image of a sandbox created with the given options, so that many
+// sandboxes using the same image can be created afterwards with NoPull().
+func Pull(ctx context.Context, options ...Option) error {
+	s := New(options...).(*podmanSandbox)
+	if err := s.pullImage(ctx); err != nil {
+		return fmt.Errorf("error pulling image %s: %w", s.imageWithTag(), err)
+	}
+	return nil
+}
+
 func (s *podmanSandbox) createContainer(ctx context.Context) (string, error) {
 	args := []string{
 		"create",
@@ -316,6 +372,13 @@ func (s *podmanSandbox) createContainer(ctx context.Context) (string, error) {
 		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
 	}
 
+	if s.hostname != "" {
+		args = append(args, "--hostname="+s.hostname)
+	}
+	for _, h := range s.hosts {
+		args = append(args, "--add-host="+h)
+	}
+
 	args = append(args, s.extraArgs()...)
 	args = append(args, s.imageWithTag())
 	cmd := podman(ctx, args...)
@@ -394,12 +457,14 @@ func (s *podmanSandbox) Init(ctx context.Context) error {
 	if s.container != "" {
 		return nil
 	}
-	// Delete existing logs (if any).
-	if err := removeAllLogs(); err != nil {
-		return fmt.Errorf("failed removing all logs: %w", err)
-	}
-	if err := podmanPrune(ctx); err != nil {
-		return fmt.Errorf("error pruning images: %w", err)
+	if !s.concurrent {
+		// Delete existing logs (if any).
+		if err := removeAllLogs(); err != nil {
+			return fmt.Errorf("failed removing all logs: %w", err)
+		}
+		if err := podmanPrune(ctx); err != nil {
+			return fmt.Errorf("error pruning images: %w", err)
+		}
 	}
 	if !s.noPull {
 		if err := s.pullImage(ctx); err != nil {
@@ -536,6 +601,9 @@ func (s *podmanSandbox) Clean(ctx context.Context) error {
 	if err := s.forceStopContainer(ctx); err != nil {
 		return err
 	}
+	if s.concurrent {
+		return podmanRun(ctx, "rm", "--force", s.container)
+	}
 	return podmanCleanContainers(ctx)
 }
 
@@ -568,3 +636,21 @@ func (s *podmanSandbox) CopyBackToHost(ctx context.Context, hostPath, sandboxPat
 	s.logger.InfoContext(ctx, "podman "+copyCmd.String())
 	return podmanRun(ctx, copyCmd.Args()...)
 }
+
+// Snapshot implements the Sandbox interface by committing the container to a new image.
+func (s *podmanSandbox) Snapshot(ctx context.Context) (*Snapshot, error) {
+	if !s.initialised {
+		return nil, errors.New("sandbox not initialised")
+	}
+	if s.container == "" {
+		return nil, errors.New("container ID is empty")
+	}
+
+	snapshot := &Snapshot{image: snapshotImage, tag: s.container}
+	ref := fmt.Sprintf("%s:%s", snapshot.image, snapshot.tag)
+	s.logger.InfoContext(ctx, "podman commit", "container", s.container, "image", ref)
+	if err := podmanRun(ctx, "commit", s.container, ref); err != nil {
+		return nil, fmt.Errorf("error committing container: %w", err)
+	}
+	return snapshot, nil
+}
@@ -13,8 +13,10 @@ import (
 	"github.com/ossf/package-analysis/internal/log"
 	"github.com/ossf/package-analysis/internal/staticanalysis/basicdata"
 	"github.com/ossf/package-analysis/internal/staticanalysis/externalcmd"
+	"github.com/ossf/package-analysis/internal/staticanalysis/manifest"
 	"github.com/ossf/package-analysis/internal/staticanalysis/parsing"
 	"github.com/ossf/package-analysis/internal/staticanalysis/signals"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
 )
 
 // enumeratePackageFiles returns a list of absolute paths to all (regular) files
@@ -62,6 +64,8 @@ func AnalyzePackageFiles(ctx context.Context, extractDir string, jsParserConfig
 			}
 			runTask[Parsing] = true
 			runTask[Signals] = true
+		case Manifest:
+			// package-level task, performed by AnalyzeManifests
 		case All:
 			return nil, errors.New("staticanalysis.All should not be passed in directly, use staticanalysis.AllTasks() instead")
 		default:
@@ -135,3 +139,22 @@ func AnalyzePackageFiles(ctx context.Context, extractDir string, jsParserConfig
 
 	return fileResults, nil
 }
+
+/*
+AnalyzeManifests walks a tree of extracted package files and performs the Manifest
+task, extracting information from each package manifest that is found.
+
+If an error occurs while traversing the extracted package directory tree, a nil
+result is returned along with the corresponding error object.
+*/
+func AnalyzeManifests(ctx context.Context, extractDir string) ([]staticanalysis.ManifestResult, error) {
+	paths, err := enumeratePackageFiles(extractDir)
+	if err != nil {
+		return nil, fmt.Errorf("error enumerating package files: %w", err)
+	}
+
+	slog.InfoContext(ctx, "run manifest analysis")
+	return manifest.Analyze(ctx, paths, func(absolutePath string) string {
+		return strings.TrimPrefix(absolutePath, extractDir+string(os.PathSeparator))
+	}), nil
+}
@@ -54,6 +54,9 @@ func makeDesiredResult(files ...testFile) []SingleResult {
 				IntLiterals:   []token.Int{},
 				FloatLiterals: []token.Float{},
 				Comments:      []token.Comment{},
+				Calls: []token.Call{
+					{Callee: "console.log", Arguments: []token.ArgumentKind{token.LiteralArgument}, Pos: token.Position{1, 0}},
+				},
 			},
 			Signals: &signals.FileSignals{
 				IdentifierLengths:     valuecounts.New(),
@@ -64,6 +67,8 @@ func makeDesiredResult(files ...testFile) []SingleResult {
 				HexStrings:            []string{},
 				IPAddresses:           []string{},
 				URLs:                  []string{},
+				DecodedStrings:        []staticanalysis.DecodedString{},
+				SuspiciousCalls:       []staticanalysis.SuspiciousCall{},
 			},
 		}
 	}
@@ -117,3 +122,31 @@ func TestAnalyzePackageFiles(t *testing.T) {
 		})
 	}
 }
+
+func TestAnalyzeManifests(t *testing.T) {
+	extractDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(extractDir, "package"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	manifest := []byte(`{"name": "hi", "main": "hi.js", "scripts": {"postinstall": "node hi.js"}}`)
+	if err := os.WriteFile(filepath.Join(extractDir, "package", "package.json"), manifest, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(extractDir, "package", helloWorldJs.filename), helloWorldJs.contents, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := AnalyzeManifests(context.Background(), extractDir)
+	if err != nil {
+		t.Fatalf("AnalyzeManifests() error = %v", err)
+	}
+	want := []staticanalysis.ManifestResult{{
+		Filename:    filepath.Join("package", "package.json"),
+		Type:        "package.json",
+		Hooks:       []staticanalysis.InstallHook{{Name: "postinstall", Command: "node hi.js"}},
+		EntryPoints: []staticanalysis.EntryPoint{{Kind: "main", Name: "hi", Target: "hi.js"}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeManifests() = %+v; want %+v", got, want)
+	}
+}
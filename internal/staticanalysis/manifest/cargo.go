@@ -0,0 +1,132 @@
+package manifest
+
+import (
+	"path/filepath"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+// cargoDependencies holds the dependency tables of Cargo.toml, or of a
+// target.<platform> table for platform specific dependencies.
+type cargoDependencies struct {
+	Dependencies      map[string]any `toml:"dependencies"`
+	DevDependencies   map[string]any `toml:"dev-dependencies"`
+	BuildDependencies map[string]any `toml:"build-dependencies"`
+}
+
+// cargoManifest holds the parts of Cargo.toml which are read by parseCargoToml.
+type cargoManifest struct {
+	cargoDependencies
+	Package struct {
+		Name string `toml:"name"`
+		// Build is either the path to the build script, or false to disable it.
+		Build any `toml:"build"`
+	} `toml:"package"`
+	Target map[string]cargoDependencies `toml:"target"`
+	Bin    []struct {
+		Name string `toml:"name"`
+		Path string `toml:"path"`
+	} `toml:"bin"`
+}
+
+// appendCargoDependencies appends the dependencies in deps to result, in order of
+// scope and then name.
+func appendCargoDependencies(result *staticanalysis.ManifestResult, deps cargoDependencies) {
+	for _, table := range []struct {
+		deps  map[string]any
+		scope string
+	}{
+		{deps.Dependencies, ""},
+		{deps.DevDependencies, "dev"},
+		{deps.BuildDependencies, "build"},
+	} {
+		names := maps.Keys(table.deps)
+		slices.Sort(names)
+		for _, name := range names {
+			result.Dependencies = append(result.Dependencies, cargoDependency(name, table.deps[name], table.scope))
+		}
+	}
+}
+
+// cargoDependency converts the specification of a dependency in Cargo.toml, which
+// is either a version requirement or a table with keys such as version and git.
+func cargoDependency(name string, spec any, scope string) staticanalysis.Dependency {
+	dep := staticanalysis.Dependency{Name: name, Source: staticanalysis.RegistrySource, Scope: scope}
+	switch spec := spec.(type) {
+	case string:
+		dep.Version = spec
+	case map[string]any:
+		// a dependency may be renamed, in which case its key is not the name of the crate
+		if pkg, ok := spec["package"].(string); ok {
+			dep.Name = pkg
+		}
+		if version, ok := spec["version"].(string); ok {
+			dep.Version = version
+		}
+		if git, ok := spec["git"].(string); ok {
+			dep.Source = staticanalysis.GitSource
+			dep.Version = git
+		} else if path, ok := spec["path"].(string); ok {
+			dep.Source = staticanalysis.PathSource
+			dep.Version = path
+		}
+	}
+	return dep
+}
+
+/*
+parseCargoToml extracts information from a Rust Cargo.toml file. The install hook
+of a crate is its build script, which Cargo compiles and runs before building the
+crate. The build script is build.rs, next to Cargo.toml, unless another path is
+given by the build key of the package table.
+*/
+func parseCargoToml(manifestPath string, contents []byte) (staticanalysis.ManifestResult, error) {
+	var manifest cargoManifest
+	if err := toml.Unmarshal(contents, &manifest); err != nil {
+		return staticanalysis.ManifestResult{}, err
+	}
+	dir := filepath.Dir(manifestPath)
+	pkg := manifest.Package
+	result := staticanalysis.ManifestResult{}
+
+	buildScript, explicit := pkg.Build.(string)
+	if !explicit {
+		buildScript = "build.rs"
+	}
+	if build, ok := pkg.Build.(bool); !ok || build {
+		code, found := readRelativeFile(dir, buildScript)
+		if found || explicit {
+			result.Hooks = append(result.Hooks, staticanalysis.InstallHook{
+				Name:    "build",
+				Command: buildScript,
+				Flags:   hookFlags(code),
+			})
+		}
+	}
+
+	appendCargoDependencies(&result, manifest.cargoDependencies)
+	targets := maps.Keys(manifest.Target)
+	slices.Sort(targets)
+	for _, target := range targets {
+		appendCargoDependencies(&result, manifest.Target[target])
+	}
+
+	for _, bin := range manifest.Bin {
+		target := bin.Path
+		if target == "" {
+			target = "src/bin/" + bin.Name + ".rs"
+		}
+		result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{Kind: "bin", Name: bin.Name, Target: target})
+	}
+	if len(manifest.Bin) == 0 {
+		if _, ok := readRelativeFile(dir, "src/main.rs"); ok {
+			result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{Kind: "bin", Name: pkg.Name, Target: "src/main.rs"})
+		}
+	}
+
+	return result, nil
+}
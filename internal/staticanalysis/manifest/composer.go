@@ -0,0 +1,137 @@
+package manifest
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+type composerJSON struct {
+	Require      map[string]string `json:"require"`
+	RequireDev   map[string]string `json:"require-dev"`
+	Scripts      map[string]any    `json:"scripts"`
+	Bin          []string          `json:"bin"`
+	Repositories json.RawMessage   `json:"repositories"`
+}
+
+type composerRepository struct {
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	Package struct {
+		Dist struct {
+			URL string `json:"url"`
+		} `json:"dist"`
+		Source struct {
+			URL string `json:"url"`
+		} `json:"source"`
+	} `json:"package"`
+}
+
+// isComposerPlatformPackage returns true for requirements on the PHP platform,
+// such as the PHP version or extensions, rather than on packages.
+func isComposerPlatformPackage(name string) bool {
+	return name == "php" || name == "hhvm" || hasAnyPrefix(name, "php-", "ext-", "lib-", "composer-")
+}
+
+// composerRepositories returns the URLs of the repositories in composer.json,
+// which are given as either a list or an object.
+func composerRepositories(raw json.RawMessage) []string {
+	var repos []composerRepository
+	if json.Unmarshal(raw, &repos) != nil {
+		var named map[string]composerRepository
+		if json.Unmarshal(raw, &named) != nil {
+			return nil
+		}
+		keys := maps.Keys(named)
+		slices.Sort(keys)
+		for _, key := range keys {
+			repos = append(repos, named[key])
+		}
+	}
+
+	var urls []string
+	for _, r := range repos {
+		for _, url := range []string{r.URL, r.Package.Dist.URL, r.Package.Source.URL} {
+			if url != "" {
+				urls = append(urls, url)
+			}
+		}
+	}
+	return urls
+}
+
+/*
+parseComposerJSON extracts information from a PHP Composer composer.json file.
+Install hooks are the scripts for Composer events, such as post-install-cmd.
+Other scripts are commands that are only run on request, so are not included.
+A script is either a command, or a list of commands which are each a hook.
+*/
+func parseComposerJSON(manifestPath string, contents []byte) (staticanalysis.ManifestResult, error) {
+	var pkg composerJSON
+	if err := json.Unmarshal(contents, &pkg); err != nil {
+		return staticanalysis.ManifestResult{}, err
+	}
+	dir := filepath.Dir(manifestPath)
+	result := staticanalysis.ManifestResult{}
+
+	events := maps.Keys(pkg.Scripts)
+	slices.Sort(events)
+	for _, event := range events {
+		if !hasAnyPrefix(event, "pre-", "post-") {
+			continue
+		}
+		var commands []string
+		switch script := pkg.Scripts[event].(type) {
+		case string:
+			commands = []string{script}
+		case []any:
+			for _, c := range script {
+				if command, ok := c.(string); ok {
+					commands = append(commands, command)
+				}
+			}
+		}
+		for _, command := range commands {
+			result.Hooks = append(result.Hooks, staticanalysis.InstallHook{
+				Name:    event,
+				Command: command,
+				Flags:   hookFlags(append([]string{command}, commandFiles(dir, command)...)...),
+			})
+		}
+	}
+
+	for _, deps := range []struct {
+		requirements map[string]string
+		scope        string
+	}{{pkg.Require, ""}, {pkg.RequireDev, "dev"}} {
+		names := maps.Keys(deps.requirements)
+		slices.Sort(names)
+		for _, name := range names {
+			if isComposerPlatformPackage(name) {
+				continue
+			}
+			result.Dependencies = append(result.Dependencies, staticanalysis.Dependency{
+				Name:    name,
+				Version: deps.requirements[name],
+				Source:  staticanalysis.RegistrySource,
+				Scope:   deps.scope,
+			})
+		}
+	}
+	result.Repositories = composerRepositories(pkg.Repositories)
+
+	for _, bin := range pkg.Bin {
+		result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{
+			Kind:   "bin",
+			Name:   path.Base(bin),
+			Target: bin,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,25 @@
+package manifest
+
+import (
+	"github.com/ossf/package-analysis/internal/staticanalysis/signals/detections"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+// hookFlags returns the flags for an install hook, given its command and the
+// code that it runs. See detections.DownloadPattern and detections.ExecPattern.
+func hookFlags(sources ...string) []staticanalysis.HookFlag {
+	var download, exec bool
+	for _, s := range sources {
+		download = download || detections.DownloadPattern.MatchString(s)
+		exec = exec || detections.ExecPattern.MatchString(s)
+	}
+
+	var flags []staticanalysis.HookFlag
+	if download {
+		flags = append(flags, staticanalysis.HookDownloads)
+	}
+	if exec {
+		flags = append(flags, staticanalysis.HookExecutes)
+	}
+	return flags
+}
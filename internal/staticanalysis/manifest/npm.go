@@ -0,0 +1,126 @@
+package manifest
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"regexp"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+// npmLifecycleScripts lists the scripts in package.json which npm runs when a
+// package is installed, in the order that they are run.
+var npmLifecycleScripts = []string{"preinstall", "install", "postinstall", "prepublish", "preprepare", "prepare", "postprepare"}
+
+// npmDependencyScopes maps the dependency fields in package.json to the scope of their dependencies.
+var npmDependencyScopes = []struct{ field, scope string }{
+	{"dependencies", ""},
+	{"devDependencies", "dev"},
+	{"optionalDependencies", "optional"},
+	{"peerDependencies", "peer"},
+}
+
+// githubShorthand matches a dependency version of the form user/repo, optionally
+// followed by a ref, which npm fetches from GitHub.
+var githubShorthand = regexp.MustCompile(`^[\w.-]+/[\w.-]+(#.*)?$`)
+
+type packageJSON struct {
+	Name    string          `json:"name"`
+	Main    string          `json:"main"`
+	Bin     json.RawMessage `json:"bin"`
+	Scripts map[string]any  `json:"scripts"`
+}
+
+// npmDependencySource returns where npm fetches a dependency with the given version from.
+func npmDependencySource(version string) staticanalysis.DependencySource {
+	switch {
+	case hasAnyPrefix(version, "git+", "git://", "git@", "github:", "gitlab:", "bitbucket:", "gist:"):
+		return staticanalysis.GitSource
+	case hasAnyPrefix(version, "http://", "https://"):
+		return staticanalysis.URLSource
+	case hasAnyPrefix(version, "file:", "link:", ".", "/", "~/"):
+		return staticanalysis.PathSource
+	case githubShorthand.MatchString(version):
+		return staticanalysis.GitSource
+	default:
+		return staticanalysis.RegistrySource
+	}
+}
+
+/*
+parsePackageJSON extracts information from an npm package.json file. Install
+hooks are the lifecycle scripts run by npm install. A package with a binding.gyp
+file and no install or preinstall script has an implicit install script, which
+runs node-gyp to build a native addon.
+*/
+func parsePackageJSON(manifestPath string, contents []byte) (staticanalysis.ManifestResult, error) {
+	var pkg packageJSON
+	if err := json.Unmarshal(contents, &pkg); err != nil {
+		return staticanalysis.ManifestResult{}, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &fields); err != nil {
+		return staticanalysis.ManifestResult{}, err
+	}
+
+	dir := filepath.Dir(manifestPath)
+	result := staticanalysis.ManifestResult{}
+
+	for _, name := range npmLifecycleScripts {
+		command, ok := pkg.Scripts[name].(string)
+		if !ok {
+			continue
+		}
+		result.Hooks = append(result.Hooks, staticanalysis.InstallHook{
+			Name:    name,
+			Command: command,
+			Flags:   hookFlags(append([]string{command}, commandFiles(dir, command)...)...),
+		})
+	}
+	if _, hasInstall := pkg.Scripts["install"]; !hasInstall {
+		if _, hasPreinstall := pkg.Scripts["preinstall"]; !hasPreinstall {
+			if _, ok := readRelativeFile(dir, "binding.gyp"); ok {
+				result.Hooks = append(result.Hooks, staticanalysis.InstallHook{Name: "install", Command: "node-gyp rebuild"})
+			}
+		}
+	}
+
+	for _, s := range npmDependencyScopes {
+		var deps map[string]string
+		if raw, ok := fields[s.field]; !ok || json.Unmarshal(raw, &deps) != nil {
+			continue
+		}
+		names := maps.Keys(deps)
+		slices.Sort(names)
+		for _, name := range names {
+			result.Dependencies = append(result.Dependencies, staticanalysis.Dependency{
+				Name:    name,
+				Version: deps[name],
+				Source:  npmDependencySource(deps[name]),
+				Scope:   s.scope,
+			})
+		}
+	}
+
+	if pkg.Main != "" {
+		result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{Kind: "main", Name: pkg.Name, Target: pkg.Main})
+	}
+	var bin string
+	var bins map[string]string
+	if json.Unmarshal(pkg.Bin, &bin) == nil && bin != "" {
+		// a single bin script is named after the package, without its scope
+		result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{Kind: "bin", Name: path.Base(pkg.Name), Target: bin})
+	} else if json.Unmarshal(pkg.Bin, &bins) == nil {
+		names := maps.Keys(bins)
+		slices.Sort(names)
+		for _, name := range names {
+			result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{Kind: "bin", Name: name, Target: bins[name]})
+		}
+	}
+
+	return result, nil
+}
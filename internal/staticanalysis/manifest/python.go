@@ -0,0 +1,183 @@
+package manifest
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+var (
+	// cmdclassPattern matches the cmdclass argument to setup(), given as a dict literal or dict() call.
+	cmdclassPattern = regexp.MustCompile(`\bcmdclass\s*=\s*(\{[^}]*\}|dict\([^)]*\))`)
+	// cmdclassEntryPattern matches a command and the class implementing it, e.g. "install": CustomInstall.
+	cmdclassEntryPattern = regexp.MustCompile(`['"]?([\w-]+)['"]?\s*[:=]\s*([\w.]+)`)
+
+	requirementsPattern    = regexp.MustCompile(`\b(install_requires|setup_requires|tests_require)\s*=\s*\[([^\]]*)\]`)
+	extrasRequirePattern   = regexp.MustCompile(`\bextras_require\s*=\s*\{([^}]*)\}`)
+	dependencyLinksPattern = regexp.MustCompile(`\bdependency_links\s*=\s*\[([^\]]*)\]`)
+	entryPointsPattern     = regexp.MustCompile(`['"](console_scripts|gui_scripts)['"]\s*:\s*\[([^\]]*)\]`)
+	scriptsPattern         = regexp.MustCompile(`\bscripts\s*=\s*\[([^\]]*)\]`)
+
+	// pythonStringPattern matches a single-line string literal, and whether it is
+	// followed by a colon, i.e. is a key in a dict literal.
+	pythonStringPattern = regexp.MustCompile(`(?:"([^"\n]*)"|'([^'\n]*)')(\s*:)?`)
+
+	// requirementPattern splits a requirement specifier into its name, extras and version or URL.
+	requirementPattern = regexp.MustCompile(`^\s*([A-Za-z0-9][\w.-]*)\s*(\[[^\]]*\])?\s*(.*)$`)
+)
+
+// setupPyScopes maps the requirement arguments to setup() to the scope of their dependencies.
+var setupPyScopes = map[string]string{
+	"install_requires": "",
+	"setup_requires":   "build",
+	"tests_require":    "test",
+}
+
+// pythonStrings returns the values of the string literals in source which are
+// not keys of a dict literal.
+func pythonStrings(source string) []string {
+	var values []string
+	for _, m := range pythonStringPattern.FindAllStringSubmatch(source, -1) {
+		if m[3] == "" {
+			values = append(values, m[1]+m[2])
+		}
+	}
+	return values
+}
+
+// pythonDependency parses a requirement specifier (PEP 508), such as "requests>=2.0"
+// or "pkg @ git+https://example.com/pkg.git".
+func pythonDependency(requirement, scope string) (staticanalysis.Dependency, bool) {
+	m := requirementPattern.FindStringSubmatch(requirement)
+	if m == nil {
+		return staticanalysis.Dependency{}, false
+	}
+	// drop environment markers, e.g. "; python_version < '3.8'"
+	spec, _, _ := strings.Cut(m[3], ";")
+	spec = strings.TrimSpace(spec)
+
+	dep := staticanalysis.Dependency{Name: m[1], Source: staticanalysis.RegistrySource, Scope: scope}
+	if url, ok := strings.CutPrefix(spec, "@"); ok {
+		dep.Version = strings.TrimSpace(url)
+		switch {
+		case strings.HasPrefix(dep.Version, "git+"):
+			dep.Source = staticanalysis.GitSource
+		case strings.HasPrefix(dep.Version, "file:"):
+			dep.Source = staticanalysis.PathSource
+		default:
+			dep.Source = staticanalysis.URLSource
+		}
+	} else {
+		dep.Version = spec
+	}
+	return dep, true
+}
+
+// classSource returns the source code of the top-level class with the given
+// name in source, or an empty string if there is none. If name is qualified by
+// a module, only the name of the class itself is used.
+func classSource(source, name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	classDef := regexp.MustCompile(`(?m)^class[ \t]+` + regexp.QuoteMeta(name) + `\b.*$`)
+	loc := classDef.FindStringIndex(source)
+	if loc == nil {
+		return ""
+	}
+	lines := []string{source[loc[0]:loc[1]]}
+	for _, line := range strings.Split(source[loc[1]:], "\n")[1:] {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// moduleLevelSource returns the lines of source which are not in the body of a
+// top-level class or function, i.e. the code run when the module is loaded.
+func moduleLevelSource(source string) string {
+	var lines []string
+	inDefinition := false
+	for _, line := range strings.Split(source, "\n") {
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		if inDefinition && (indented || strings.TrimSpace(line) == "") {
+			continue
+		}
+		inDefinition = hasAnyPrefix(line, "class ", "def ", "async def ")
+		if !inDefinition {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+/*
+parseSetupPy extracts information from a setuptools setup.py script. Since
+setup.py is a Python program, only arguments to setup() that are given literally
+are found. Running setup.py is itself an install hook for source distributions,
+and the commands overridden using cmdclass are further hooks, which run the code
+of the given classes.
+*/
+func parseSetupPy(_ string, contents []byte) (staticanalysis.ManifestResult, error) {
+	source := string(contents)
+	result := staticanalysis.ManifestResult{}
+
+	result.Hooks = append(result.Hooks, staticanalysis.InstallHook{
+		Name:    "setup.py",
+		Command: "python setup.py",
+		Flags:   hookFlags(moduleLevelSource(source)),
+	})
+	for _, cmdclass := range cmdclassPattern.FindAllStringSubmatch(source, -1) {
+		for _, entry := range cmdclassEntryPattern.FindAllStringSubmatch(cmdclass[1], -1) {
+			command, class := entry[1], entry[2]
+			result.Hooks = append(result.Hooks, staticanalysis.InstallHook{
+				Name:    "cmdclass:" + command,
+				Command: class,
+				Flags:   hookFlags(classSource(source, class)),
+			})
+		}
+	}
+
+	for _, m := range requirementsPattern.FindAllStringSubmatch(source, -1) {
+		for _, requirement := range pythonStrings(m[2]) {
+			if dep, ok := pythonDependency(requirement, setupPyScopes[m[1]]); ok {
+				result.Dependencies = append(result.Dependencies, dep)
+			}
+		}
+	}
+	for _, m := range extrasRequirePattern.FindAllStringSubmatch(source, -1) {
+		for _, requirement := range pythonStrings(m[1]) {
+			if dep, ok := pythonDependency(requirement, "optional"); ok {
+				result.Dependencies = append(result.Dependencies, dep)
+			}
+		}
+	}
+	for _, m := range dependencyLinksPattern.FindAllStringSubmatch(source, -1) {
+		result.Repositories = append(result.Repositories, pythonStrings(m[1])...)
+	}
+
+	for _, m := range entryPointsPattern.FindAllStringSubmatch(source, -1) {
+		kind := strings.TrimSuffix(m[1], "s")
+		for _, entryPoint := range pythonStrings(m[2]) {
+			name, target, ok := strings.Cut(entryPoint, "=")
+			if ok {
+				result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{
+					Kind:   kind,
+					Name:   strings.TrimSpace(name),
+					Target: strings.TrimSpace(target),
+				})
+			}
+		}
+	}
+	for _, m := range scriptsPattern.FindAllStringSubmatch(source, -1) {
+		for _, script := range pythonStrings(m[1]) {
+			result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{Kind: "script", Name: path.Base(script), Target: script})
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+var (
+	// rubyStringPattern matches single-line string literals, including the %q<...>
+	// form used by generated gemspecs, and word lists such as %w[a b].
+	rubyStringPattern = regexp.MustCompile(`"([^"\n]*)"|'([^'\n]*)'|%q<([^>\n]*)>|%[wW][\[({<]([^\])}>]*)[\])}>]`)
+
+	// rubyValuePattern matches the value assigned or appended to an attribute,
+	// which is an array or word list, or else the rest of the line.
+	rubyValuePattern = `\s*(\[[^\]]*\]|%[wW][\[({<][^\])}>]*[\])}>]|[^\n]*)`
+
+	extensionsPattern  = regexp.MustCompile(`\.extensions\s*(?:=|<<|\+=|\.concat\(?|\.push\(?)` + rubyValuePattern)
+	executablesPattern = regexp.MustCompile(`\.executables\s*(?:=|<<|\+=)` + rubyValuePattern)
+	bindirPattern      = regexp.MustCompile(`\.bindir\s*=\s*(?:"([^"\n]*)"|'([^'\n]*)')`)
+	gemDependency      = regexp.MustCompile(`\.add_(runtime_|development_)?dependency\b\s*\(?([^\n]*)`)
+)
+
+// rubyStrings returns the values of the string literals in source, with each
+// word of a word list as a separate value.
+func rubyStrings(source string) []string {
+	var values []string
+	for _, m := range rubyStringPattern.FindAllStringSubmatch(source, -1) {
+		if strings.HasPrefix(m[0], "%w") || strings.HasPrefix(m[0], "%W") {
+			values = append(values, strings.Fields(m[4])...)
+		} else {
+			values = append(values, m[1]+m[2]+m[3])
+		}
+	}
+	return values
+}
+
+/*
+parseGemspec extracts information from a RubyGems gemspec. Since a gemspec is a
+Ruby program, only attributes that are given literally are found. The install
+hooks of a gem are its native extensions, whose extconf.rb (or Rakefile) is run
+when the gem is installed.
+*/
+func parseGemspec(manifestPath string, contents []byte) (staticanalysis.ManifestResult, error) {
+	source := string(contents)
+	dir := filepath.Dir(manifestPath)
+	result := staticanalysis.ManifestResult{}
+
+	for _, m := range extensionsPattern.FindAllStringSubmatch(source, -1) {
+		for _, extension := range rubyStrings(m[1]) {
+			hook := staticanalysis.InstallHook{Name: "extension", Command: extension}
+			if code, ok := readRelativeFile(dir, extension); ok {
+				hook.Flags = hookFlags(code)
+			}
+			result.Hooks = append(result.Hooks, hook)
+		}
+	}
+
+	for _, m := range gemDependency.FindAllStringSubmatch(source, -1) {
+		values := rubyStrings(m[2])
+		if len(values) == 0 {
+			continue
+		}
+		dep := staticanalysis.Dependency{
+			Name:    values[0],
+			Version: strings.Join(values[1:], ", "),
+			Source:  staticanalysis.RegistrySource,
+		}
+		if m[1] == "development_" {
+			dep.Scope = "dev"
+		}
+		result.Dependencies = append(result.Dependencies, dep)
+	}
+
+	bindir := "bin"
+	if m := bindirPattern.FindStringSubmatch(source); m != nil {
+		bindir = m[1] + m[2]
+	}
+	for _, m := range executablesPattern.FindAllStringSubmatch(source, -1) {
+		for _, executable := range rubyStrings(m[1]) {
+			result.EntryPoints = append(result.EntryPoints, staticanalysis.EntryPoint{
+				Kind:   "executable",
+				Name:   executable,
+				Target: path.Join(bindir, executable),
+			})
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,248 @@
+package staticanalysis
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/internal/staticanalysis/parsing"
+	"github.com/ossf/package-analysis/internal/staticanalysis/signals/stats"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis/token"
+)
+
+const (
+	// minifiedMinSize is the size in bytes of the smallest source file which is
+	// detected as minified by its line lengths, since a few long lines are not
+	// unusual in small files.
+	minifiedMinSize = 1024
+
+	// minifiedMeanLineLength is the mean line length of source files above which
+	// they are detected as minified.
+	minifiedMeanLineLength = 200
+)
+
+// bundlerIdentifiers are names defined by the runtime code that bundlers add
+// to their output, such as webpack's module loader.
+var bundlerIdentifiers = []string{"__webpack_require__", "__webpack_modules__", "parcelRequire", "__commonJS", "__toESM"}
+
+// obfuscatedIdentifierRules are the rules in detections.SuspiciousIdentifierPatterns
+// that match names generated by obfuscators. Single character names are excluded,
+// since minifiers generate them too.
+var obfuscatedIdentifierRules = []string{"hex", "numeric"}
+
+// isMinified returns true if a source file appears to be minified, because
+// it has a .min. filename or a large mean line length.
+func isMinified(f SingleResult) bool {
+	if f.Parsing == nil || f.Parsing.Language == parsing.NoLanguage {
+		return false
+	}
+	if strings.Contains(filepath.Base(f.Filename), ".min.") {
+		return true
+	}
+	if f.Basic == nil || f.Basic.Size < minifiedMinSize {
+		return false
+	}
+	lines, totalLength := 0, 0
+	for _, p := range f.Basic.LineLengths.ToPairs() {
+		lines += p.Count
+		totalLength += p.Value * p.Count
+	}
+	return lines > 0 && totalLength/lines >= minifiedMeanLineLength
+}
+
+// isBundled returns true if a source file appears to be the output of a
+// bundler, because it defines one of bundlerIdentifiers.
+func isBundled(f SingleResult) bool {
+	if f.Parsing == nil {
+		return false
+	}
+	return slices.ContainsFunc(f.Parsing.Identifiers, func(i token.Identifier) bool {
+		return slices.Contains(bundlerIdentifiers, i.Name)
+	})
+}
+
+// definedOrNil returns a pointer to x, or nil if x is NaN or infinite,
+// which are not valid JSON values.
+func definedOrNil(x float64) *float64 {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return nil
+	}
+	return &x
+}
+
+func summariseSample[T stats.RealNumber](sample []T) *staticanalysis.SampleSummary {
+	s := stats.Summarise(sample)
+	summary := &staticanalysis.SampleSummary{
+		Size:     s.Size,
+		Mean:     definedOrNil(s.Mean),
+		Variance: definedOrNil(s.Variance),
+		Skewness: definedOrNil(s.Skewness),
+	}
+	if s.Size > 0 {
+		summary.Quartiles = s.Quartiles[:]
+	}
+	return summary
+}
+
+// ratio returns n / total, capped at 1, or 0 if total is 0.
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Min(1, float64(n)/float64(total))
+}
+
+/*
+obfuscationScore combines features of a package's code into a score between 0
+and 1 for how likely it is to contain obfuscated code. Each feature has a value
+between 0 and 1, and a weight reflecting how strongly it indicates obfuscation:
+
+  - obfuscated_identifiers: the fraction of identifiers which look generated by an
+    obfuscator, such as _0x3f2a (weight 0.35)
+  - escaped_strings: the fraction of string literals which are mostly escape
+    sequences (weight 0.2)
+  - encoded_strings: the number of base64 and long hex strings, per string literal
+    (weight 0.2)
+  - dynamic_code_execution: 1 if code is run from a string computed at runtime,
+    e.g. eval(x), otherwise 0 (weight 0.15)
+  - minified_code: the fraction of source files which are minified (weight 0.1)
+
+Minification gets a low weight since it is common in benign packages.
+*/
+func obfuscationScore(values map[string]float64) *staticanalysis.ObfuscationScore {
+	features := []struct {
+		name   string
+		weight float64
+	}{
+		{"obfuscated_identifiers", 0.35},
+		{"escaped_strings", 0.2},
+		{"encoded_strings", 0.2},
+		{"dynamic_code_execution", 0.15},
+		{"minified_code", 0.1},
+	}
+
+	score := &staticanalysis.ObfuscationScore{}
+	for _, f := range features {
+		component := staticanalysis.ScoreComponent{
+			Feature:      f.name,
+			Value:        values[f.name],
+			Weight:       f.weight,
+			Contribution: values[f.name] * f.weight,
+		}
+		score.Score += component.Contribution
+		score.Components = append(score.Components, component)
+	}
+	return score
+}
+
+// summarisePackage aggregates the results for each file of a package into a
+// PackageSummary (see staticanalysis.PackageSummary).
+func summarisePackage(files []SingleResult) *staticanalysis.PackageSummary {
+	summary := &staticanalysis.PackageSummary{
+		FileCount: len(files),
+		FileTypes: map[string]int{},
+		Languages: map[string]int{},
+	}
+
+	var identifierLengths, stringLengths []int
+	var identifierEntropy, stringEntropy []float64
+	var parsed bool
+	var sourceFiles int
+
+	var signalCounts *staticanalysis.SignalCounts
+	var obfuscatedIdentifiers int
+	var dynamicCodeExecution bool
+
+	for _, f := range files {
+		if f.Basic != nil {
+			summary.TotalSize += f.Basic.Size
+			// the detected type is followed by details, e.g. "ASCII text, with very long lines"
+			if fileType, _, _ := strings.Cut(f.Basic.DetectedType, ","); fileType != "" {
+				summary.FileTypes[fileType]++
+			}
+		}
+
+		if f.Parsing != nil {
+			parsed = true
+			if f.Parsing.Language != parsing.NoLanguage {
+				summary.Languages[string(f.Parsing.Language)]++
+				sourceFiles++
+			}
+			for _, i := range f.Parsing.Identifiers {
+				identifierLengths = append(identifierLengths, utf8.RuneCountInString(i.Name))
+				identifierEntropy = append(identifierEntropy, i.Entropy)
+			}
+			for _, s := range f.Parsing.StringLiterals {
+				stringLengths = append(stringLengths, utf8.RuneCountInString(s.Value))
+				stringEntropy = append(stringEntropy, s.Entropy)
+			}
+			if isMinified(f) {
+				summary.MinifiedFiles = append(summary.MinifiedFiles, f.Filename)
+			}
+			if isBundled(f) {
+				summary.BundledFiles = append(summary.BundledFiles, f.Filename)
+			}
+		}
+
+		if f.Signals != nil {
+			if signalCounts == nil {
+				signalCounts = &staticanalysis.SignalCounts{SuspiciousCalls: map[staticanalysis.SuspiciousCallCategory]int{}}
+			}
+			signalCounts.SuspiciousIdentifiers += len(f.Signals.SuspiciousIdentifiers)
+			signalCounts.EscapedStrings += len(f.Signals.EscapedStrings)
+			signalCounts.Base64Strings += len(f.Signals.Base64Strings)
+			signalCounts.HexStrings += len(f.Signals.HexStrings)
+			signalCounts.IPAddresses += len(f.Signals.IPAddresses)
+			signalCounts.URLs += len(f.Signals.URLs)
+			signalCounts.DecodedStrings += len(f.Signals.DecodedStrings)
+			for _, i := range f.Signals.SuspiciousIdentifiers {
+				if slices.Contains(obfuscatedIdentifierRules, i.Rule) {
+					obfuscatedIdentifiers++
+				}
+			}
+			for _, c := range f.Signals.SuspiciousCalls {
+				signalCounts.SuspiciousCalls[c.Category]++
+				if c.Category == staticanalysis.CodeExecution && slices.Contains(c.Arguments, token.DynamicArgument) {
+					dynamicCodeExecution = true
+				}
+			}
+		}
+	}
+
+	if parsed {
+		summary.IdentifierLengths = summariseSample(identifierLengths)
+		summary.StringLengths = summariseSample(stringLengths)
+		summary.IdentifierEntropy = summariseSample(identifierEntropy)
+		summary.StringEntropy = summariseSample(stringEntropy)
+	}
+
+	if signalCounts != nil {
+		if len(signalCounts.SuspiciousCalls) == 0 {
+			signalCounts.SuspiciousCalls = nil
+		}
+		summary.Signals = signalCounts
+
+		values := map[string]float64{
+			"obfuscated_identifiers": ratio(obfuscatedIdentifiers, len(identifierLengths)),
+			"escaped_strings":        ratio(signalCounts.EscapedStrings, len(stringLengths)),
+			"encoded_strings":        ratio(signalCounts.Base64Strings+signalCounts.HexStrings, len(stringLengths)),
+			"minified_code":          ratio(len(summary.MinifiedFiles), sourceFiles),
+		}
+		if dynamicCodeExecution {
+			values["dynamic_code_execution"] = 1
+		}
+		summary.ObfuscationScore = obfuscationScore(values)
+	}
+
+	if len(summary.FileTypes) == 0 {
+		summary.FileTypes = nil
+	}
+	if len(summary.Languages) == 0 {
+		summary.Languages = nil
+	}
+	return summary
+}
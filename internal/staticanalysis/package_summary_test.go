@@ -0,0 +1,176 @@
+package staticanalysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ossf/package-analysis/internal/staticanalysis/basicdata"
+	"github.com/ossf/package-analysis/internal/staticanalysis/parsing"
+	"github.com/ossf/package-analysis/internal/staticanalysis/signals"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis/token"
+	"github.com/ossf/package-analysis/pkg/valuecounts"
+)
+
+func TestIsMinified(t *testing.T) {
+	tests := []struct {
+		name        string
+		filename    string
+		language    parsing.Language
+		size        int64
+		lineLengths []int
+		want        bool
+	}{
+		{"min filename", "dist/lib.min.js", parsing.JavaScript, 100, []int{20, 30}, true},
+		{"long lines", "index.js", parsing.JavaScript, 2000, []int{1500, 500}, true},
+		{"short lines", "index.js", parsing.JavaScript, 2000, []int{40, 20, 80}, false},
+		{"small file with long lines", "index.js", parsing.JavaScript, 500, []int{500}, false},
+		{"not source code", "data.min.json", parsing.NoLanguage, 2000, []int{2000}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := SingleResult{
+				Filename: tt.filename,
+				Basic:    &basicdata.FileData{Size: tt.size, LineLengths: valuecounts.Count(tt.lineLengths)},
+				Parsing:  &parsing.SingleResult{Language: tt.language},
+			}
+			if got := isMinified(f); got != tt.want {
+				t.Errorf("isMinified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarisePackage(t *testing.T) {
+	scoreComponents := []staticanalysis.ScoreComponent{
+		{Feature: "obfuscated_identifiers", Value: 0.5, Weight: 0.35, Contribution: 0.5 * 0.35},
+		{Feature: "escaped_strings", Value: 0.5, Weight: 0.2, Contribution: 0.5 * 0.2},
+		{Feature: "encoded_strings", Value: 0, Weight: 0.2, Contribution: 0},
+		{Feature: "dynamic_code_execution", Value: 1, Weight: 0.15, Contribution: 0.15},
+		{Feature: "minified_code", Value: 1, Weight: 0.1, Contribution: 0.1},
+	}
+	score := 0.0
+	for _, c := range scoreComponents {
+		score += c.Contribution
+	}
+
+	tests := []struct {
+		name  string
+		files []SingleResult
+		want  *staticanalysis.PackageSummary
+	}{
+		{
+			name: "basic data only",
+			files: []SingleResult{
+				{Filename: "README.md", Basic: &basicdata.FileData{DetectedType: "ASCII text", Size: 100}},
+				{Filename: "LICENSE", Basic: &basicdata.FileData{DetectedType: "ASCII text, with CRLF line terminators", Size: 50}},
+				{Filename: "logo.png", Basic: &basicdata.FileData{DetectedType: "PNG image data, 64 x 64", Size: 1000}},
+			},
+			want: &staticanalysis.PackageSummary{
+				FileCount: 3,
+				TotalSize: 1150,
+				FileTypes: map[string]int{"ASCII text": 2, "PNG image data": 1},
+			},
+		},
+		{
+			name: "obfuscated bundle",
+			files: []SingleResult{
+				{
+					Filename: "dist/index.js",
+					Basic: &basicdata.FileData{
+						DetectedType: "JavaScript source, ASCII text, with very long lines",
+						Size:         2048,
+						LineLengths:  valuecounts.Count([]int{1024, 1024}),
+					},
+					Parsing: &parsing.SingleResult{
+						Language: parsing.JavaScript,
+						Identifiers: []token.Identifier{
+							{Name: "_0x1a2b", Type: token.Variable, Entropy: 2},
+							{Name: "__webpack_require__", Type: token.Function, Entropy: 4},
+						},
+						StringLiterals: []token.String{
+							{Value: "abc", Raw: `"abc"`, Entropy: 1},
+							{Value: "A", Raw: `"\x41"`, Entropy: 0},
+						},
+					},
+					Signals: &signals.FileSignals{
+						SuspiciousIdentifiers: []staticanalysis.SuspiciousIdentifier{{Name: "_0x1a2b", Rule: "hex"}},
+						EscapedStrings:        []staticanalysis.EscapedString{{Value: "A", Raw: `"\x41"`, LevenshteinDist: 4}},
+						SuspiciousCalls: []staticanalysis.SuspiciousCall{{
+							Callee:    "eval",
+							Category:  staticanalysis.CodeExecution,
+							Arguments: []token.ArgumentKind{token.DynamicArgument},
+						}},
+					},
+				},
+				{
+					Filename: "README.md",
+					Basic:    &basicdata.FileData{DetectedType: "ASCII text", Size: 100},
+					Parsing:  &parsing.SingleResult{},
+					Signals:  &signals.FileSignals{},
+				},
+			},
+			want: &staticanalysis.PackageSummary{
+				FileCount: 2,
+				TotalSize: 2148,
+				FileTypes: map[string]int{"JavaScript source": 1, "ASCII text": 1},
+				Languages: map[string]int{"JavaScript": 1},
+				IdentifierLengths: &staticanalysis.SampleSummary{
+					Size: 2, Mean: ptr(13.0), Variance: ptr(72.0), Quartiles: []float64{7, 7, 13, 19, 19},
+				},
+				StringLengths: &staticanalysis.SampleSummary{
+					Size: 2, Mean: ptr(2.0), Variance: ptr(2.0), Quartiles: []float64{1, 1, 2, 3, 3},
+				},
+				IdentifierEntropy: &staticanalysis.SampleSummary{
+					Size: 2, Mean: ptr(3.0), Variance: ptr(2.0), Quartiles: []float64{2, 2, 3, 4, 4},
+				},
+				StringEntropy: &staticanalysis.SampleSummary{
+					Size: 2, Mean: ptr(0.5), Variance: ptr(0.5), Quartiles: []float64{0, 0, 0.5, 1, 1},
+				},
+				Signals: &staticanalysis.SignalCounts{
+					SuspiciousIdentifiers: 1,
+					EscapedStrings:        1,
+					SuspiciousCalls:       map[staticanalysis.SuspiciousCallCategory]int{staticanalysis.CodeExecution: 1},
+				},
+				MinifiedFiles:    []string{"dist/index.js"},
+				BundledFiles:     []string{"dist/index.js"},
+				ObfuscationScore: &staticanalysis.ObfuscationScore{Score: score, Components: scoreComponents},
+			},
+		},
+		{
+			name: "no identifiers or strings",
+			files: []SingleResult{
+				{
+					Filename: "empty.py",
+					Basic:    &basicdata.FileData{},
+					Parsing:  &parsing.SingleResult{Language: parsing.Python},
+					Signals:  &signals.FileSignals{},
+				},
+			},
+			want: &staticanalysis.PackageSummary{
+				FileCount:         1,
+				Languages:         map[string]int{"Python": 1},
+				IdentifierLengths: &staticanalysis.SampleSummary{},
+				StringLengths:     &staticanalysis.SampleSummary{},
+				IdentifierEntropy: &staticanalysis.SampleSummary{},
+				StringEntropy:     &staticanalysis.SampleSummary{},
+				Signals:           &staticanalysis.SignalCounts{},
+				ObfuscationScore: &staticanalysis.ObfuscationScore{Components: []staticanalysis.ScoreComponent{
+					{Feature: "obfuscated_identifiers", Weight: 0.35},
+					{Feature: "escaped_strings", Weight: 0.2},
+					{Feature: "encoded_strings", Weight: 0.2},
+					{Feature: "dynamic_code_execution", Weight: 0.15},
+					{Feature: "minified_code", Weight: 0.1},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarisePackage(tt.files)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("summarisePackage() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package parsing
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 
@@ -11,7 +12,8 @@ import (
 	"github.com/ossf/package-analysis/pkg/api/staticanalysis/token"
 )
 
-func processJsData(fileData singleParseData) SingleResult {
+// processParseData converts the data from the parser for the given language into a SingleResult.
+func processParseData(fileData singleParseData, language Language) SingleResult {
 	result := SingleResult{
 		Language: NoLanguage,
 		// Initialise with empty slices to avoid null values in JSON
@@ -20,14 +22,14 @@ func processJsData(fileData singleParseData) SingleResult {
 		IntLiterals:    []token.Int{},
 		FloatLiterals:  []token.Float{},
 		Comments:       []token.Comment{},
+		Calls:          []token.Call{},
 	}
 
 	if !fileData.ValidInput {
 		return result
 	}
 
-	// JavaScript is the only currently supported / valid language
-	result.Language = JavaScript
+	result.Language = language
 
 	for _, d := range fileData.Literals {
 		if d.GoType == "string" {
@@ -52,42 +54,105 @@ func processJsData(fileData singleParseData) SingleResult {
 	for _, c := range fileData.Comments {
 		result.Comments = append(result.Comments, token.Comment{Text: c.Data})
 	}
+
+	for _, c := range fileData.Calls {
+		result.Calls = append(result.Calls, token.Call{Callee: c.Callee, Arguments: c.Arguments, Pos: c.Pos})
+	}
 	return result
 }
 
+// characterDistributions holds the estimated probabilities for characters in
+// identifiers and string literals respectively.
+type characterDistributions struct {
+	identifiers map[rune]float64
+	strings     map[rune]float64
+}
+
 // computeCharacterDistributions estimates the probabilities for characters in
 // identifiers and string literals respectively, by aggregating character counts
-// across all symbols of each type in the package.
-func computeCharacterDistributions(parseResults map[string]SingleResult) (map[rune]float64, map[rune]float64) {
-	var identifiers []string
-	var strings []string
+// across all symbols of each type in the package. A separate distribution is
+// computed for each language, since their naming conventions and keywords differ.
+func computeCharacterDistributions(parseResults map[string]SingleResult) map[Language]characterDistributions {
+	identifiers := make(map[Language][]string)
+	strings := make(map[Language][]string)
 
 	for _, r := range parseResults {
 		for _, str := range r.StringLiterals {
-			strings = append(strings, str.Value)
+			strings[r.Language] = append(strings[r.Language], str.Value)
 		}
 		for _, ident := range r.Identifiers {
-			identifiers = append(identifiers, ident.Name)
+			identifiers[r.Language] = append(identifiers[r.Language], ident.Name)
+		}
+	}
+
+	distributions := make(map[Language]characterDistributions)
+	for _, language := range allLanguages {
+		if len(identifiers[language]) == 0 && len(strings[language]) == 0 {
+			continue
+		}
+		distributions[language] = characterDistributions{
+			identifiers: stringentropy.CharacterProbabilities(identifiers[language]),
+			strings:     stringentropy.CharacterProbabilities(strings[language]),
 		}
 	}
+	return distributions
+}
 
-	return stringentropy.CharacterProbabilities(identifiers), stringentropy.CharacterProbabilities(strings)
+/*
+chooseParseData returns the parse data to use for a file, out of the data produced
+by the parser for each language (which is absent if the parser did not parse the
+file), along with its language.
+
+Files detected as one of the languages other than JavaScript (see detectLanguage)
+use the data for that language, if it is valid. Otherwise, valid JavaScript data is
+preferred, since some short snippets of JavaScript are also valid code in other
+languages, followed by valid data for the languages whose parsers allow it (see
+languageParser.undetectedFallback). If no data is used, the JavaScript data is
+returned if present, and otherwise the data for the detected language.
+*/
+func chooseParseData(filename string, data map[Language]*singleParseData) (singleParseData, Language) {
+	isValid := func(language Language) bool {
+		d := data[language]
+		return d != nil && d.ValidInput
+	}
+
+	detected := detectLanguage(filename)
+	if detected != NoLanguage && isValid(detected) {
+		return *data[detected], detected
+	}
+	if isValid(JavaScript) {
+		return *data[JavaScript], JavaScript
+	}
+	for _, p := range languageParsers {
+		if p.undetectedFallback && isValid(p.language) {
+			return *data[p.language], p.language
+		}
+	}
+	for _, language := range []Language{JavaScript, detected} {
+		if d := data[language]; d != nil {
+			return *d, language
+		}
+	}
+	return singleParseData{}, NoLanguage
 }
 
 /*
 Analyze (parsing.Analyze) parses the specified list of files using all supported parsers
-and returns a map of filename to slice of parsing.SingleResult. Each slice holds information
-about source code tokens found for that file for each supported langauge parser.
+and returns a map of filename to parsing.SingleResult. Each result holds information
+about source code tokens found for that file by the parser for its language (see
+chooseParseData), which is recorded in the result's Language field.
 
-Currently, the only supported language is JavaScript, however more language parsers will
-be added in the future.
+The supported languages are JavaScript, Python, Ruby, PHP and Rust. Each file is parsed
+as JavaScript, and as any other language which it looks like (e.g. by its extension).
+Parsers for languages other than JavaScript are only run if they are available (see
+InitParser), and if one fails to run, the results for that language are omitted.
 
 Input can be specified either by file path or by passing the source code string directly.
 To parse a file, specify its path using sourceFile; the value of sourceString is ignored.
 If sourceFile is empty, then sourceString is parsed directly as code.
 
-If parsing is attempted in a given langauge and fails due to syntax errors, the value
-for that language in the returned map is nil, with no other error.
+If parsing fails due to syntax errors in every language, the result for the file has
+no language (NoLanguage), with no other error.
 
 If an internal error occurs during parsing, parsing is interrupted and the error returned.
 
@@ -106,23 +171,48 @@ func Analyze(ctx context.Context, parserConfig ParserConfig, input externalcmd.I
 		return nil, err
 	}
 
+	results := map[Language]map[string]singleParseData{JavaScript: jsResults}
+	for _, p := range languageParsers {
+		if p.parserPath(parserConfig) == "" {
+			continue
+		}
+		languageResults, rawOutput, err := parseLanguage(ctx, parserConfig, p.language, input)
+		if printDebug {
+			fmt.Fprintf(os.Stderr, "\nRaw %s parser JSON:\n%s\n", p.language, rawOutput)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "parsing failed", "language", p.language, "error", err)
+			continue
+		}
+		results[p.language] = languageResults
+	}
+
+	dataByFile := make(map[string]map[Language]*singleParseData)
+	for language, languageResults := range results {
+		for filename, d := range languageResults {
+			if dataByFile[filename] == nil {
+				dataByFile[filename] = make(map[Language]*singleParseData)
+			}
+			dataByFile[filename][language] = &d
+		}
+	}
+
 	resultsByFile := make(map[string]SingleResult)
-	for filename, jsData := range jsResults {
-		resultsByFile[filename] = processJsData(jsData)
+	for filename, data := range dataByFile {
+		resultsByFile[filename] = processParseData(chooseParseData(filename, data))
 	}
 
 	// TODO replace this with a global count across many packages from an ecosystem.
-	//  If more languages are added before this is done, the function below should be
-	//  modified to compute a separate distribution for identifiers each language.
-	identifierProbs, stringProbs := computeCharacterDistributions(resultsByFile)
+	distributions := computeCharacterDistributions(resultsByFile)
 
 	// populate entropy values for identifiers and string literals.
 	for _, r := range resultsByFile {
+		probs := distributions[r.Language]
 		for i := range r.Identifiers {
-			r.Identifiers[i].ComputeEntropy(identifierProbs)
+			r.Identifiers[i].ComputeEntropy(probs.identifiers)
 		}
 		for i := range r.StringLiterals {
-			r.StringLiterals[i].ComputeEntropy(stringProbs)
+			r.StringLiterals[i].ComputeEntropy(probs.strings)
 		}
 	}
 
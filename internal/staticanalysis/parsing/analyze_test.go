@@ -42,6 +42,9 @@ var analyzeTestcases = []analyzeTestcase{
 			IntLiterals:   []token.Int{},
 			FloatLiterals: []token.Float{},
 			Comments:      []token.Comment{},
+			Calls: []token.Call{
+				{Callee: "console.log", Arguments: []token.ArgumentKind{token.LiteralArgument}, Pos: token.Position{1, 0}},
+			},
 		},
 	},
 	{
@@ -60,6 +63,7 @@ var a = "hello"
 			IntLiterals:   []token.Int{},
 			FloatLiterals: []token.Float{},
 			Comments:      []token.Comment{},
+			Calls:         []token.Call{},
 		},
 	},
 	{
@@ -94,6 +98,9 @@ function test(a, b = 2) {
 			},
 			FloatLiterals: []token.Float{},
 			Comments:      []token.Comment{},
+			Calls: []token.Call{
+				{Callee: "console.log", Arguments: []token.ArgumentKind{token.LiteralArgument}, Pos: token.Position{3, 1}},
+			},
 		},
 	},
 	{
@@ -106,6 +113,7 @@ function test(a, b = 2) {
 			IntLiterals:    []token.Int{},
 			FloatLiterals:  []token.Float{},
 			Comments:       []token.Comment{},
+			Calls:          []token.Call{},
 		},
 	},
 }
@@ -144,6 +152,9 @@ func TestAnalyze(t *testing.T) {
 			if !reflect.DeepEqual(got.Comments, tt.expectedData.Comments) {
 				t.Errorf("Comments mismatch: got %#v, want %v", got.Comments, tt.expectedData.Comments)
 			}
+			if !reflect.DeepEqual(got.Calls, tt.expectedData.Calls) {
+				t.Errorf("Calls mismatch: got %#v, want %v", got.Calls, tt.expectedData.Calls)
+			}
 		})
 	}
 }
@@ -20,6 +20,120 @@ function position(node) {
     return (node.loc !== null) ? [node.loc.start.line,node.loc.start.column] : [];
 }
 
+// global objects through which global functions can be called, e.g. globalThis.eval
+const globalObjects = new Set(["globalThis", "global", "window", "self"]);
+
+// types of argument nodes whose values are known without running the code
+const literalTypes = new Set(["StringLiteral", "NumericLiteral", "BigIntLiteral", "BooleanLiteral",
+    "NullLiteral", "RegExpLiteral"]);
+
+// objects whose properties give access to the environment, so that reading them is
+// logged like a call (see ParseData.logAccess), e.g. process.env.HOME
+const accessedObjects = new Set(["process.env"]);
+
+// module names may have a node: prefix, e.g. require("node:fs")
+function moduleName(name) {
+    return name.startsWith("node:") ? name.slice("node:".length) : name;
+}
+
+function isRequireCall(node) {
+    return node.type === "CallExpression" && node.callee.type === "Identifier" && node.callee.name === "require" &&
+        node.arguments.length === 1 && node.arguments[0].type === "StringLiteral";
+}
+
+function argumentKind(node) {
+    if (literalTypes.has(node.type) || (node.type === "TemplateLiteral" && node.expressions.length === 0)) {
+        return "literal";
+    }
+    return "dynamic";
+}
+
+/*
+ calleePath returns a dotted path naming the function called by a call expression,
+ e.g. "child_process.exec". A module loaded with require() or import is named by the
+ module, and names bound to modules or their members are replaced by what they
+ refer to (see collectAliases). Parts which are not known without running the code
+ are written as "?".
+ */
+function calleePath(node, aliases) {
+    switch (node.type) {
+        case "Identifier":
+            return aliases.has(node.name) ? aliases.get(node.name) : node.name;
+        case "ThisExpression":
+            return "this";
+        case "Super":
+            return "super";
+        case "Import":
+            // dynamic import, i.e. import("module")
+            return "import";
+        case "MemberExpression":
+        case "OptionalMemberExpression": {
+            const object = calleePath(node.object, aliases);
+            let property = "?";
+            if (!node.computed && node.property.type === "Identifier") {
+                property = node.property.name;
+            } else if (node.computed && node.property.type === "StringLiteral") {
+                property = node.property.value;
+            }
+            return (globalObjects.has(object) && property !== "?") ? property : object + "." + property;
+        }
+        case "CallExpression":
+            return isRequireCall(node) ? moduleName(node.arguments[0].value) : "?";
+        case "SequenceExpression":
+            // e.g. the indirect eval (0, eval)(code)
+            return calleePath(node.expressions[node.expressions.length - 1], aliases);
+        default:
+            return "?";
+    }
+}
+
+/*
+ collectAliases returns a map from names bound to modules or their members, to the
+ callee path they refer to. For example, after const cp = require("child_process")
+ or import * as cp from "child_process", cp refers to child_process, and after
+ const {exec} = require("child_process"), exec refers to child_process.exec. Scopes
+ are not taken into account.
+ */
+function collectAliases(ast, disableScope) {
+    const aliases = new Map();
+    traverse(ast, {
+        noScope: disableScope,
+        ImportDeclaration: function(path) {
+            const source = moduleName(path.node.source.value);
+            for (const specifier of path.node.specifiers) {
+                if (specifier.type === "ImportSpecifier") {
+                    const imported = specifier.imported;
+                    const name = (imported.type === "Identifier") ? imported.name : imported.value;
+                    aliases.set(specifier.local.name, (name === "default") ? source : source + "." + name);
+                } else {
+                    aliases.set(specifier.local.name, source);
+                }
+            }
+        },
+        VariableDeclarator: function(path) {
+            const {id, init} = path.node;
+            if (!init || !(init.type === "Identifier" || init.type === "MemberExpression" || isRequireCall(init))) {
+                return;
+            }
+            const target = calleePath(init, aliases);
+            if (target.includes("?")) {
+                return;
+            }
+            if (id.type === "Identifier" && id.name !== target) {
+                aliases.set(id.name, target);
+            } else if (id.type === "ObjectPattern") {
+                for (const property of id.properties) {
+                    if (property.type === "ObjectProperty" && !property.computed &&
+                        property.key.type === "Identifier" && property.value.type === "Identifier") {
+                        aliases.set(property.value.name, target + "." + property.key.name);
+                    }
+                }
+            }
+        },
+    });
+    return aliases;
+}
+
 // Holds all parsing data for a single file
 class ParseData {
     constructor() {
@@ -103,6 +217,37 @@ class ParseData {
 
         this.logLiteral("StringTemplate", cookedStrings.join(sep), pos, inArray, extra);
     }
+
+    // logCall logs a call or new expression, with the kind of each of its arguments
+    logCall(callType, node, aliases) {
+        const extra = { arguments: node.arguments.map(argumentKind) };
+        this.tokens.push(ParseData.makeOutputDict("Call", callType, calleePath(node.callee, aliases), position(node), extra));
+    }
+
+    /*
+     logAccess logs a member expression which reads (or writes) one of accessedObjects
+     or its properties, as a call with no arguments. Only the outermost member
+     expression is logged, and member expressions which are called are skipped, as
+     they are logged by logCall.
+     */
+    logAccess(path, aliases) {
+        const parent = path.parentPath.node;
+        const isParentObject = (parent.type === "MemberExpression" || parent.type === "OptionalMemberExpression") &&
+            parent.object === path.node;
+        const isCallee = (parent.type === "CallExpression" || parent.type === "OptionalCallExpression" ||
+            parent.type === "NewExpression") && parent.callee === path.node;
+        if (isParentObject || isCallee) {
+            return;
+        }
+        const accessPath = calleePath(path.node, aliases);
+        for (const object of accessedObjects) {
+            if (accessPath === object || accessPath.startsWith(object + ".")) {
+                this.tokens.push(ParseData.makeOutputDict("Call", "MemberExpression", accessPath, position(path.node),
+                    { arguments: [] }));
+                return;
+            }
+        }
+    }
 }
 
 function visitIdentifierOrPrivateName(path, parseData) {
@@ -184,6 +329,26 @@ function visitIdentifierOrPrivateName(path, parseData) {
  when the AST was produced from parsing with errorRecovery: true.
  */
 function traverseAst(ast, parseData, disableScope) {
+    const aliases = collectAliases(ast, disableScope);
+
+    const callVisitors = {
+        CallExpression: function(path) {
+            this.parseData.logCall("CallExpression", path.node, this.aliases);
+        },
+        OptionalCallExpression: function(path) {
+            this.parseData.logCall("CallExpression", path.node, this.aliases);
+        },
+        NewExpression: function(path) {
+            this.parseData.logCall("NewExpression", path.node, this.aliases);
+        },
+        MemberExpression: function(path) {
+            this.parseData.logAccess(path, this.aliases);
+        },
+        OptionalMemberExpression: function(path) {
+            this.parseData.logAccess(path, this.aliases);
+        },
+    };
+
     /*
       TODO
        1. Consider adding state to allow distinction between elements from different arrays
@@ -210,7 +375,8 @@ function traverseAst(ast, parseData, disableScope) {
         TemplateLiteral: function(path) {
             const loc = position(path.node);
             this.parseData.logTemplate(path.node, loc, true);
-        }
+        },
+        ...callVisitors,
     };
 
     const astVisitor = {
@@ -243,16 +409,17 @@ function traverseAst(ast, parseData, disableScope) {
             this.parseData.logLiteral("Regexp", path.node.pattern, loc, false, path.node.extra);
         },
         ArrayExpression: function (path) {
-            path.traverse(arrayVisitor, { parseData });
+            path.traverse(arrayVisitor, { parseData, aliases });
             path.skip();
         },
         TemplateLiteral: function(path) {
             const loc = position(path.node);
             this.parseData.logTemplate(path.node, loc, false);
-        }
+        },
+        ...callVisitors,
     };
 
-    traverse(ast, astVisitor, null, { parseData });
+    traverse(ast, astVisitor, null, { parseData, aliases });
 }
 
 function parseFile(fileName, allowSyntaxErrors, includeAST) {
@@ -16,6 +16,21 @@ import (
 //go:embed babel-parser.js
 var babelParser []byte
 
+// pythonParser holds the content of the Python parser script.
+//
+//go:embed python-parser.py
+var pythonParser []byte
+
+// rubyParser holds the content of the Ruby parser script.
+//
+//go:embed ruby-parser.rb
+var rubyParser []byte
+
+// phpParser holds the content of the PHP parser script.
+//
+//go:embed php-parser.php
+var phpParser []byte
+
 // packageJSON holds the content of the NPM package.json file, with information
 // about the dependencies for the parser
 //
@@ -30,6 +45,9 @@ var packageLockJSON []byte
 
 const (
 	parserFileName          = "babel-parser.js"
+	pythonParserFileName    = "python-parser.py"
+	rubyParserFileName      = "ruby-parser.rb"
+	phpParserFileName       = "php-parser.php"
 	packageJSONFileName     = "package.json"
 	packageLockJSONFileName = "package-lock.json"
 )
@@ -39,9 +57,25 @@ const (
 // Docker build for the container this code will run in.
 const npmCacheDir = "/npm_cache"
 
+// rustParserCommand is the name of the Rust parser binary, which is built from the
+// rust-parser directory and installed on the PATH by the Docker build for the
+// container this code will run in.
+const rustParserCommand = "rust-parser"
+
 type ParserConfig struct {
 	InstallDir string
 	ParserPath string
+	// PythonParserPath is the path of the Python parser script, which is run with python3.
+	PythonParserPath string
+	// RubyParserPath is the path of the Ruby parser script, which is run with ruby.
+	// It is empty if ruby is not installed.
+	RubyParserPath string
+	// PHPParserPath is the path of the PHP parser script, which is run with php.
+	// It is empty if php is not installed.
+	PHPParserPath string
+	// RustParserPath is the path of the Rust parser binary. It is empty if the
+	// binary is not installed.
+	RustParserPath string
 }
 
 type parserFile struct {
@@ -52,6 +86,9 @@ type parserFile struct {
 
 var parserFiles = []parserFile{
 	{parserFileName, babelParser, false},
+	{pythonParserFileName, pythonParser, false},
+	{rubyParserFileName, rubyParser, false},
+	{phpParserFileName, phpParser, false},
 	{packageJSONFileName, packageJSON, false},
 	{packageLockJSONFileName, packageLockJSON, false},
 }
@@ -82,8 +119,23 @@ func InitParser(ctx context.Context, installDir string) (ParserConfig, error) {
 		return ParserConfig{}, fmt.Errorf("npm install error: %w", err)
 	}
 
-	return ParserConfig{
-		InstallDir: installDir,
-		ParserPath: filepath.Join(installDir, parserFileName),
-	}, nil
+	config := ParserConfig{
+		InstallDir:       installDir,
+		ParserPath:       filepath.Join(installDir, parserFileName),
+		PythonParserPath: filepath.Join(installDir, pythonParserFileName),
+	}
+
+	// The Ruby, PHP and Rust parsers are optional, so that static analysis can
+	// still be run outside the sandbox without them.
+	if _, err := exec.LookPath("ruby"); err == nil {
+		config.RubyParserPath = filepath.Join(installDir, rubyParserFileName)
+	}
+	if _, err := exec.LookPath("php"); err == nil {
+		config.PHPParserPath = filepath.Join(installDir, phpParserFileName)
+	}
+	if path, err := exec.LookPath(rustParserCommand); err == nil {
+		config.RustParserPath = path
+	}
+
+	return config, nil
 }
@@ -59,12 +59,16 @@ func (h parserArgsHandler) ReadStdinArg() []string {
 }
 
 /*
-runParser handles calling the parser program and provide the specified Javascript source to it,
-either by filename (jsFilePath) or piping jsSource to the program's stdin.
+runParser handles calling the parser program and provide the specified source to it,
+either by filename (jsFilePath) or piping jsSource to the program's stdin. The parser
+script at parserPath is run using the given interpreter (e.g. node), or run directly
+if interpreter is empty. argsHandler specifies how the input is passed to the parser.
 
 If sourcePath is empty, sourceString will be parsed as JS code.
 */
-func runParser(ctx context.Context, parserPath string, input externalcmd.Input, extraArgs ...string) (string, error) {
+func runParser(ctx context.Context, interpreter, parserPath string, argsHandler externalcmd.InputArgHandler,
+	input externalcmd.Input, extraArgs ...string,
+) (string, error) {
 	workingDir, err := os.MkdirTemp("", "package-analysis-run-parser-*")
 	if err != nil {
 		return "", fmt.Errorf("runParser failed to create temp working directory: %w", err)
@@ -77,14 +81,19 @@ func runParser(ctx context.Context, parserPath string, input externalcmd.Input,
 
 	outFilePath := filepath.Join(workingDir, "output.json")
 
-	nodeArgs := []string{parserPath, "--output", outFilePath}
+	parserArgs := []string{"--output", outFilePath}
 	if len(extraArgs) > 0 {
-		nodeArgs = append(nodeArgs, extraArgs...)
+		parserArgs = append(parserArgs, extraArgs...)
 	}
 
-	cmd := exec.CommandContext(ctx, "node", nodeArgs...)
+	var cmd *exec.Cmd
+	if interpreter == "" {
+		cmd = exec.CommandContext(ctx, parserPath, parserArgs...)
+	} else {
+		cmd = exec.CommandContext(ctx, interpreter, append([]string{parserPath}, parserArgs...)...)
+	}
 
-	if err := input.SendTo(cmd, parserArgsHandler{}, workingDir); err != nil {
+	if err := input.SendTo(cmd, argsHandler, workingDir); err != nil {
 		return "", fmt.Errorf("runParser failed to prepare parsing input: %w", err)
 	}
 
@@ -150,8 +159,19 @@ func (pd parseDataJSON) process(ctx context.Context) singleParseData {
 				Data: t.Data.(string),
 				Pos:  t.Pos,
 			})
+		case call:
+			callee, _ := t.Data.(string)
+			c := parsedCall{Type: t.TokenSubType, Callee: callee, Pos: t.Pos}
+			if args, ok := t.Extra["arguments"].([]any); ok {
+				for _, arg := range args {
+					if kind, ok := arg.(string); ok {
+						c.Arguments = append(c.Arguments, token.ArgumentKind(kind))
+					}
+				}
+			}
+			processed.Calls = append(processed.Calls, c)
 		default:
-			slog.WarnContext(ctx, fmt.Sprintf("parseJS: unrecognised token type %s", t.TokenType))
+			slog.WarnContext(ctx, fmt.Sprintf("parser: unrecognised token type %s", t.TokenType))
 		}
 	}
 	// process parser status (info/errors)
@@ -187,7 +207,15 @@ Otherwise, the first return value points to the parsing result object while the
 contains the raw JSON output from the parser.
 */
 func parseJS(ctx context.Context, parserConfig ParserConfig, input externalcmd.Input) (map[string]singleParseData, string, error) {
-	rawOutput, err := runParser(ctx, parserConfig.ParserPath, input)
+	return runParserAndProcess(ctx, "node", parserConfig.ParserPath, parserArgsHandler{}, input)
+}
+
+// runParserAndProcess runs the given parser (see runParser) and processes its output,
+// which must be in the format produced by the JS parser.
+func runParserAndProcess(ctx context.Context, interpreter, parserPath string, argsHandler externalcmd.InputArgHandler,
+	input externalcmd.Input,
+) (map[string]singleParseData, string, error) {
+	rawOutput, err := runParser(ctx, interpreter, parserPath, argsHandler, input)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			rawOutput = string(exitErr.Stderr)
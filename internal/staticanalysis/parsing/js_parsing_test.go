@@ -459,3 +459,40 @@ func TestParseJS(t *testing.T) {
 		})
 	}
 }
+
+func TestParseJSCalls(t *testing.T) {
+	jsParserConfig, err := InitParser(context.Background(), t.TempDir())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	input := `const cp = require("node:child_process");
+import("./plugins/" + name);
+cp.exec(` + "`ls`" + `, callback);
+new Function("return 1")();
+globalThis["eval"](code);
+const token = process.env.NPM_TOKEN;
+send(process.env);
+process.env[name].trim();
+`
+	want := []parsedCall{
+		{"CallExpression", "require", []token.ArgumentKind{token.LiteralArgument}, token.Position{1, 11}},
+		{"CallExpression", "import", []token.ArgumentKind{token.DynamicArgument}, token.Position{2, 0}},
+		{"CallExpression", "child_process.exec", []token.ArgumentKind{token.LiteralArgument, token.DynamicArgument}, token.Position{3, 0}},
+		{"CallExpression", "?", nil, token.Position{4, 0}},
+		{"NewExpression", "Function", []token.ArgumentKind{token.LiteralArgument}, token.Position{4, 0}},
+		{"CallExpression", "eval", []token.ArgumentKind{token.DynamicArgument}, token.Position{5, 0}},
+		{"MemberExpression", "process.env.NPM_TOKEN", nil, token.Position{6, 14}},
+		{"CallExpression", "send", []token.ArgumentKind{token.DynamicArgument}, token.Position{7, 0}},
+		{"MemberExpression", "process.env", nil, token.Position{7, 5}},
+		{"CallExpression", "process.env.?.trim", nil, token.Position{8, 0}},
+	}
+
+	result, rawOutput, err := parseJS(context.Background(), jsParserConfig, externalcmd.StringInput(input))
+	if err != nil {
+		t.Fatalf("parseJS() error = %v\nParser output:\n%s", err, rawOutput)
+	}
+	if got := result["stdin"].Calls; !reflect.DeepEqual(got, want) {
+		t.Errorf("Calls mismatch:\ngot  %v\nwant %v", got, want)
+	}
+}
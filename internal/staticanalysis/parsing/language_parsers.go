@@ -0,0 +1,153 @@
+package parsing
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/internal/staticanalysis/externalcmd"
+)
+
+// languageParser describes how to run the parser for a language other than
+// JavaScript. Each parser produces output in the same format as the JS parser.
+//
+// When input holds multiple files, a parser only parses those which look like
+// source code in its language (e.g. by file extension, or a shebang line), and the
+// others are absent from its output. Input from a string is always parsed.
+type languageParser struct {
+	language Language
+	// interpreter is the command used to run the parser script, or empty if the
+	// parser is an executable.
+	interpreter string
+	// parserPath returns the path of the parser, or an empty string if the parser
+	// is not available.
+	parserPath func(config ParserConfig) string
+	// argsHandler specifies how the input is passed to the parser.
+	argsHandler externalcmd.InputArgHandler
+	// extensions lists the file extensions used for source code in the language.
+	extensions []string
+	// filenames lists other names of files which hold source code in the language.
+	filenames []string
+	// shebang is found in the shebang line of scripts in the language, if they have one.
+	shebang string
+	// undetectedFallback is true if valid data for the language is used for files
+	// which are not detected as the language (see chooseParseData). It is false for
+	// languages in which most text is valid code, e.g. PHP, where text outside of
+	// <?php tags is output as-is, and Ruby, where words separated by spaces are
+	// method calls.
+	undetectedFallback bool
+}
+
+// hasSourceFilename returns true if the name of the file indicates that it
+// holds source code in the parser's language.
+func (p languageParser) hasSourceFilename(filename string) bool {
+	return slices.Contains(p.extensions, filepath.Ext(filename)) || slices.Contains(p.filenames, filepath.Base(filename))
+}
+
+// readShebang returns the first line of the file at path if it is a shebang
+// line, or an empty string otherwise.
+func readShebang(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	line, _ := bufio.NewReader(io.LimitReader(f, 256)).ReadString('\n')
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	return line
+}
+
+// languageParsers holds the parser for each supported language other than
+// JavaScript, in the order of allLanguages.
+var languageParsers = []languageParser{
+	{
+		// uses the ast module of the Python standard library
+		language:    Python,
+		interpreter: "python3",
+		parserPath:  func(c ParserConfig) string { return c.PythonParserPath },
+		argsHandler: parserArgsHandler{},
+		extensions:  []string{".py", ".pyw", ".pyi"},
+		shebang:     "python",
+
+		undetectedFallback: true,
+	},
+	{
+		// uses Ripper, from the Ruby standard library
+		language:    Ruby,
+		interpreter: "ruby",
+		parserPath:  func(c ParserConfig) string { return c.RubyParserPath },
+		argsHandler: parserArgsHandler{},
+		extensions:  []string{".rb", ".rake", ".gemspec", ".ru"},
+		filenames:   []string{"Rakefile", "Gemfile", "Guardfile"},
+		shebang:     "ruby",
+	},
+	{
+		// uses token_get_all(), from the PHP tokenizer extension
+		language:    PHP,
+		interpreter: "php",
+		parserPath:  func(c ParserConfig) string { return c.PHPParserPath },
+		argsHandler: parserArgsHandler{},
+		extensions:  []string{".php", ".phtml", ".php3", ".php4", ".php5", ".php7", ".phps", ".inc"},
+		shebang:     "php",
+	},
+	{
+		// a helper binary using the syn crate, built into the sandbox image
+		language:    Rust,
+		parserPath:  func(c ParserConfig) string { return c.RustParserPath },
+		argsHandler: parserArgsHandler{},
+		extensions:  []string{".rs"},
+	},
+}
+
+// getLanguageParser returns the parser for the given language, which must not be JavaScript.
+func getLanguageParser(language Language) (languageParser, bool) {
+	i := slices.IndexFunc(languageParsers, func(p languageParser) bool { return p.language == language })
+	if i < 0 {
+		return languageParser{}, false
+	}
+	return languageParsers[i], true
+}
+
+// detectLanguage returns the language indicated by the name of a file, or by its
+// shebang line, or NoLanguage if it is not one of the languages in languageParsers.
+func detectLanguage(filename string) Language {
+	for _, p := range languageParsers {
+		if p.hasSourceFilename(filename) {
+			return p.language
+		}
+	}
+	if shebang := readShebang(filename); shebang != "" {
+		for _, p := range languageParsers {
+			if p.shebang != "" && strings.Contains(shebang, p.shebang) {
+				return p.language
+			}
+		}
+	}
+	return NoLanguage
+}
+
+/*
+parseLanguage extracts source code identifiers, literals and comments from code in
+the given language, using the parser configured for it in parserConfig. The output
+has the same form as for parseJS.
+*/
+func parseLanguage(ctx context.Context, parserConfig ParserConfig, language Language, input externalcmd.Input) (map[string]singleParseData, string, error) {
+	p, ok := getLanguageParser(language)
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported language: %s", language)
+	}
+	path := p.parserPath(parserConfig)
+	if path == "" {
+		return nil, "", fmt.Errorf("%s parser is not available", language)
+	}
+	return runParserAndProcess(ctx, p.interpreter, path, p.argsHandler, input)
+}
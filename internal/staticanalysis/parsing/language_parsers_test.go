@@ -0,0 +1,251 @@
+package parsing
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ossf/package-analysis/internal/staticanalysis/externalcmd"
+	"github.com/ossf/package-analysis/internal/utils"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis/token"
+)
+
+// languageParserConfig returns a config for the parser for the given language,
+// skipping the test if its interpreter or binary is not installed.
+func languageParserConfig(t *testing.T, language Language) ParserConfig {
+	t.Helper()
+	writeScript := func(name string, contents []byte, interpreter string) string {
+		if _, err := exec.LookPath(interpreter); err != nil {
+			t.Skipf("%s is not installed", interpreter)
+		}
+		path := filepath.Join(t.TempDir(), name)
+		if err := utils.WriteFile(path, contents, false); err != nil {
+			t.Fatalf("failed to write %s parser: %v", language, err)
+		}
+		return path
+	}
+
+	switch language {
+	case Ruby:
+		return ParserConfig{RubyParserPath: writeScript(rubyParserFileName, rubyParser, "ruby")}
+	case PHP:
+		return ParserConfig{PHPParserPath: writeScript(phpParserFileName, phpParser, "php")}
+	case Rust:
+		path, err := exec.LookPath(rustParserCommand)
+		if err != nil {
+			t.Skipf("%s is not installed", rustParserCommand)
+		}
+		return ParserConfig{RustParserPath: path}
+	default:
+		t.Fatalf("no parser config for %s", language)
+		return ParserConfig{}
+	}
+}
+
+type languageTestCase struct {
+	language Language
+	input    string
+	want     singleParseData
+}
+
+var languageTestCases = []languageTestCase{
+	{
+		language: Ruby,
+		input: `# a comment
+class Greeter < Base
+  def greet(name, loud: false)
+    @count = 0x10
+    message = "hello #{name}!"
+    codes = [1, 2.5, 'it\'s']
+    message
+  end
+end
+`,
+		want: singleParseData{
+			ValidInput: true,
+			Identifiers: []parsedIdentifier{
+				{token.Class, "Greeter", token.Position{2, 6}},
+				{token.Function, "greet", token.Position{3, 6}},
+				{token.Parameter, "name", token.Position{3, 12}},
+				{token.Parameter, "loud", token.Position{3, 18}},
+				{token.Property, "count", token.Position{4, 4}},
+				{token.Variable, "message", token.Position{5, 4}},
+				{token.Variable, "codes", token.Position{6, 4}},
+			},
+			Literals: []parsedLiteral[any]{
+				{"Numeric", "float64", 16.0, "0x10", false, token.Position{4, 13}},
+				{"StringTemplate", "string", "hello #{}!", `"hello #{name}!"`, false, token.Position{5, 14}},
+				{"Numeric", "float64", 1.0, "1", true, token.Position{6, 13}},
+				{"Numeric", "float64", 2.5, "2.5", true, token.Position{6, 16}},
+				{"String", "string", "it's", `'it\'s'`, true, token.Position{6, 21}},
+			},
+			Comments: []parsedComment{
+				{"CommentLine", " a comment", token.Position{1, 0}},
+			},
+		},
+	},
+	{
+		language: PHP,
+		input: `<?php
+// a comment
+class Greeter {
+    private $count = 0x10;
+    function greet($name, $loud = false) {
+        $message = "hello $name!";
+        $codes = [1, 2.5, 'it\'s'];
+        return $message;
+    }
+}
+`,
+		want: singleParseData{
+			ValidInput: true,
+			Identifiers: []parsedIdentifier{
+				{token.Class, "Greeter", token.Position{3, 6}},
+				{token.Property, "count", token.Position{4, 12}},
+				{token.Function, "greet", token.Position{5, 13}},
+				{token.Parameter, "name", token.Position{5, 19}},
+				{token.Parameter, "loud", token.Position{5, 26}},
+				{token.Variable, "message", token.Position{6, 8}},
+				{token.Variable, "codes", token.Position{7, 8}},
+			},
+			Literals: []parsedLiteral[any]{
+				{"Numeric", "float64", 16.0, "0x10", false, token.Position{4, 21}},
+				{"StringTemplate", "string", "hello {}!", `"hello $name!"`, false, token.Position{6, 19}},
+				{"Numeric", "float64", 1.0, "1", true, token.Position{7, 18}},
+				{"Numeric", "float64", 2.5, "2.5", true, token.Position{7, 21}},
+				{"String", "string", "it's", `'it\'s'`, true, token.Position{7, 26}},
+			},
+			Comments: []parsedComment{
+				{"CommentLine", " a comment", token.Position{2, 0}},
+			},
+		},
+	},
+	{
+		language: Rust,
+		input: `//! crate docs
+struct Greeter {
+    count: u32,
+}
+
+fn greet(name: &str, loud: bool) -> String {
+    let message = format!("hello {}!", name);
+    let codes = [1, 0x10];
+    message
+}
+`,
+		want: singleParseData{
+			ValidInput: true,
+			Identifiers: []parsedIdentifier{
+				{token.Class, "Greeter", token.Position{2, 7}},
+				{token.Property, "count", token.Position{3, 4}},
+				{token.Function, "greet", token.Position{6, 3}},
+				{token.Parameter, "name", token.Position{6, 9}},
+				{token.Parameter, "loud", token.Position{6, 21}},
+				{token.Variable, "message", token.Position{7, 8}},
+				{token.Variable, "codes", token.Position{8, 8}},
+			},
+			Literals: []parsedLiteral[any]{
+				{"String", "string", "hello {}!", `"hello {}!"`, false, token.Position{7, 26}},
+				{"Numeric", "float64", 1.0, "1", true, token.Position{8, 17}},
+				{"Numeric", "float64", 16.0, "0x10", true, token.Position{8, 20}},
+			},
+			Comments: []parsedComment{
+				{"CommentLine", " crate docs", token.Position{1, 0}},
+			},
+		},
+	},
+	{language: Ruby, input: "def broken(\n", want: singleParseData{ValidInput: false}},
+	{language: PHP, input: "<?php function broken( {\n", want: singleParseData{ValidInput: false}},
+	{language: Rust, input: "fn broken( {\n", want: singleParseData{ValidInput: false}},
+}
+
+func TestParseLanguages(t *testing.T) {
+	for _, tt := range languageTestCases {
+		name := string(tt.language)
+		if !tt.want.ValidInput {
+			name += " syntax error"
+		}
+		t.Run(name, func(t *testing.T) {
+			config := languageParserConfig(t, tt.language)
+			result, rawOutput, err := parseLanguage(context.Background(), config, tt.language, externalcmd.StringInput(tt.input))
+			if err != nil {
+				t.Fatalf("parseLanguage() error = %v\nParser output:\n%s", err, rawOutput)
+			}
+			got := result["stdin"]
+
+			if got.ValidInput != tt.want.ValidInput {
+				t.Errorf("ValidInput = %v; want %v", got.ValidInput, tt.want.ValidInput)
+			}
+			if !reflect.DeepEqual(got.Identifiers, tt.want.Identifiers) {
+				t.Errorf("Identifiers mismatch:\ngot  %v\nwant %v", got.Identifiers, tt.want.Identifiers)
+			}
+			if !reflect.DeepEqual(got.Literals, tt.want.Literals) {
+				t.Errorf("Literals mismatch:\ngot  %v\nwant %v", got.Literals, tt.want.Literals)
+			}
+			if !reflect.DeepEqual(got.Comments, tt.want.Comments) {
+				t.Errorf("Comments mismatch:\ngot  %v\nwant %v", got.Comments, tt.want.Comments)
+			}
+		})
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "tool")
+	if err := os.WriteFile(script, []byte("#!/usr/bin/env ruby\nputs 'hi'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filename string
+		want     Language
+	}{
+		{"setup.py", Python},
+		{"lib/gem.rb", Ruby},
+		{"Rakefile", Ruby},
+		{"index.php", PHP},
+		{"src/main.rs", Rust},
+		{"index.js", NoLanguage},
+		{"stdin", NoLanguage},
+		{script, Ruby},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.filename); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %v; want %v", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestChooseParseData(t *testing.T) {
+	valid := singleParseData{ValidInput: true}
+	invalid := singleParseData{ValidInput: false}
+
+	tests := []struct {
+		name     string
+		filename string
+		data     map[Language]*singleParseData
+		want     Language
+	}{
+		{name: "python extension", filename: "setup.py", data: map[Language]*singleParseData{JavaScript: &valid, Python: &valid}, want: Python},
+		{name: "python extension invalid", filename: "setup.py", data: map[Language]*singleParseData{JavaScript: &valid, Python: &invalid}, want: JavaScript},
+		{name: "stdin valid in both", filename: "stdin", data: map[Language]*singleParseData{JavaScript: &valid, Python: &valid}, want: JavaScript},
+		{name: "stdin valid python", filename: "stdin", data: map[Language]*singleParseData{JavaScript: &invalid, Python: &valid}, want: Python},
+		{name: "not python", filename: "index.js", data: map[Language]*singleParseData{JavaScript: &invalid}, want: JavaScript},
+		{name: "python parser failed", filename: "setup.py", data: map[Language]*singleParseData{Python: &invalid}, want: Python},
+		{name: "ruby extension", filename: "lib/gem.rb", data: map[Language]*singleParseData{JavaScript: &valid, Ruby: &valid}, want: Ruby},
+		{name: "stdin valid ruby", filename: "stdin", data: map[Language]*singleParseData{JavaScript: &invalid, Python: &invalid, Ruby: &valid, PHP: &valid}, want: JavaScript},
+		{name: "php extension invalid", filename: "index.php", data: map[Language]*singleParseData{JavaScript: &invalid, PHP: &invalid}, want: JavaScript},
+		{name: "rust parser only", filename: "src/main.rs", data: map[Language]*singleParseData{Rust: &invalid}, want: Rust},
+		{name: "no data", filename: "README", data: map[Language]*singleParseData{}, want: NoLanguage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := chooseParseData(tt.filename, tt.data); got != tt.want {
+				t.Errorf("chooseParseData() language = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
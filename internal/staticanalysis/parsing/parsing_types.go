@@ -14,9 +14,13 @@ type Language string
 const (
 	NoLanguage Language = ""
 	JavaScript Language = "JavaScript"
+	Python     Language = "Python"
+	Ruby       Language = "Ruby"
+	PHP        Language = "PHP"
+	Rust       Language = "Rust"
 )
 
-var allLanguages = []Language{JavaScript}
+var allLanguages = []Language{JavaScript, Python, Ruby, PHP, Rust}
 
 func SupportedLanguages() []Language {
 	return allLanguages[:]
@@ -38,6 +42,9 @@ const (
 	// comment means any comment in the source code
 	comment tokenType = "Comment"
 
+	// call means a function call or constructor call, e.g. eval(code), new Function(code)
+	call tokenType = "Call"
+
 	// parseInfo means any metadata about the parsing, e.g. number of bytes read by parser.
 	parseInfo statusType = "Info"
 
@@ -82,6 +89,17 @@ func (c parsedComment) String() string {
 	return fmt.Sprintf("%s %s pos %d:%d", c.Type, c.Data, c.Pos.Row(), c.Pos.Col())
 }
 
+type parsedCall struct {
+	Type      string
+	Callee    string
+	Arguments []token.ArgumentKind
+	Pos       token.Position
+}
+
+func (c parsedCall) String() string {
+	return fmt.Sprintf("%s %s %v pos %d:%d", c.Type, c.Callee, c.Arguments, c.Pos.Row(), c.Pos.Col())
+}
+
 type parserStatus struct {
 	Type    statusType
 	Name    string
@@ -99,6 +117,7 @@ type singleParseData struct {
 	Identifiers []parsedIdentifier
 	Literals    []parsedLiteral[any]
 	Comments    []parsedComment
+	Calls       []parsedCall
 	Info        []parserStatus
 	Errors      []parserStatus
 }
@@ -107,6 +126,7 @@ func (d singleParseData) String() string {
 	identifiers := utils.Transform(d.Identifiers, func(pi parsedIdentifier) string { return pi.String() })
 	literals := utils.Transform(d.Literals, func(pl parsedLiteral[any]) string { return pl.String() })
 	comments := utils.Transform(d.Comments, func(c parsedComment) string { return c.String() })
+	calls := utils.Transform(d.Calls, func(c parsedCall) string { return c.String() })
 	info := utils.Transform(d.Info, func(i parserStatus) string { return i.String() })
 	errors := utils.Transform(d.Errors, func(e parserStatus) string { return e.String() })
 
@@ -117,6 +137,8 @@ func (d singleParseData) String() string {
 		strings.Join(literals, "\n"),
 		"== Comments ==",
 		strings.Join(comments, "\n"),
+		"== Calls ==",
+		strings.Join(calls, "\n"),
 		"== Info ==",
 		strings.Join(info, "\n"),
 		"== Errors ==",
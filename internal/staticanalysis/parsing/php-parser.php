@@ -0,0 +1,499 @@
+<?php
+/*
+ * Extracts identifiers, literals and comments from PHP source code, using the
+ * tokens returned by token_get_all() from the tokenizer extension.
+ *
+ * The output has the same format as babel-parser.js: a JSON object mapping each
+ * input filename to its tokens and parser status messages.
+ *
+ * When a list of files is given with --batch, only files which look like PHP
+ * source (by extension or shebang line) are parsed, and the others are omitted
+ * from the output. Source read from stdin is always parsed.
+ */
+
+// used to signal to parent process that parsing could not complete due to syntax errors
+const FATAL_SYNTAX_ERROR_MARKER = 'FATAL SYNTAX ERROR';
+
+const PHP_EXTENSIONS = ['php', 'phtml', 'php3', 'php4', 'php5', 'php7', 'phps', 'inc'];
+
+// JSON numbers are decoded as float64, so larger integers are output as strings
+const MAX_SAFE_INTEGER = 2 ** 53;
+
+// placeholder for interpolated expressions in string values
+const INTERPOLATION_PLACEHOLDER = '{}';
+
+const IGNORED_TOKENS = [T_WHITESPACE, T_OPEN_TAG, T_CLOSE_TAG, T_INLINE_HTML];
+
+// Holds all parsing data for a single file
+class ParseData
+{
+    // holds token information (function, variable names)
+    public array $tokens = [];
+    // holds status information (info, errors)
+    public array $status = [];
+
+    public static function makeOutputDict(string $type, string $subtype, $data, array $pos, ?array $extra = null): array
+    {
+        return ['type' => $type, 'subtype' => $subtype, 'data' => $data, 'pos' => $pos, 'extra' => $extra ?? new stdClass()];
+    }
+
+    public function logError(string $errorType, string $message, array $pos): void
+    {
+        $this->status[] = self::makeOutputDict('Error', $errorType, $message, $pos);
+    }
+
+    public function logInfo(string $infoType, string $message): void
+    {
+        $this->status[] = self::makeOutputDict('Info', $infoType, $message, []);
+    }
+
+    public function logComment(string $commentType, string $comment, array $pos): void
+    {
+        $this->tokens[] = self::makeOutputDict('Comment', $commentType, $comment, $pos);
+    }
+
+    public function logIdentifier(string $identifierType, string $name, array $pos): void
+    {
+        $this->tokens[] = self::makeOutputDict('Identifier', $identifierType, $name, $pos);
+    }
+
+    public function logLiteral(string $literalType, $value, string $raw, array $pos, bool $inArray): void
+    {
+        $this->tokens[] = self::makeOutputDict('Literal', $literalType, $value, $pos, ['array' => $inArray, 'raw' => $raw]);
+    }
+
+    public function toArray(): array
+    {
+        return ['tokens' => $this->tokens, 'status' => $this->status];
+    }
+}
+
+// unescapeDoubleQuoted processes the escape sequences of a double quoted string or heredoc.
+function unescapeDoubleQuoted(string $s): string
+{
+    return preg_replace_callback('/\\\\(x[0-9A-Fa-f]{1,2}|u\{[0-9A-Fa-f]+\}|[0-7]{1,3}|[nrtvef\\\\$"])/', function ($m) {
+        $esc = $m[1];
+        switch ($esc[0]) {
+            case 'x':
+                return chr(hexdec(substr($esc, 1)));
+            case 'u':
+                return mb_chr(hexdec(substr($esc, 2, -1)), 'UTF-8');
+            case 'n':
+                return "\n";
+            case 'r':
+                return "\r";
+            case 't':
+                return "\t";
+            case 'v':
+                return "\v";
+            case 'e':
+                return "\e";
+            case 'f':
+                return "\f";
+            default:
+                return preg_match('/^[0-7]+$/', $esc) ? chr(octdec($esc) & 0xff) : $esc;
+        }
+    }, $s) ?? $s;
+}
+
+// unescapeSingleQuoted processes the escape sequences of a single quoted string.
+function unescapeSingleQuoted(string $s): string
+{
+    return preg_replace('/\\\\([\\\\\'])/', '$1', $s) ?? $s;
+}
+
+// stringValue returns the value of a T_CONSTANT_ENCAPSED_STRING token, e.g. 'abc' or "abc".
+function stringValue(string $raw): string
+{
+    // strings may have a b prefix, e.g. b'abc'
+    $raw = ltrim($raw, 'bB');
+    $contents = substr($raw, 1, -1);
+    return $raw[0] === "'" ? unescapeSingleQuoted($contents) : unescapeDoubleQuoted($contents);
+}
+
+// numericValue returns the value of a T_LNUMBER or T_DNUMBER token.
+function numericValue(int $id, string $raw)
+{
+    $digits = strtolower(str_replace('_', '', $raw));
+    if (str_starts_with($digits, '0o')) {
+        $digits = '0' . substr($digits, 2);
+    }
+    if ($id === T_LNUMBER) {
+        $value = intval($digits, 0);
+        return abs($value) >= MAX_SAFE_INTEGER ? (string)$value : $value;
+    }
+    // integers too large for an int are tokenized as T_DNUMBER
+    if (str_starts_with($digits, '0x')) {
+        return (float)hexdec(substr($digits, 2));
+    }
+    if (str_starts_with($digits, '0b')) {
+        return (float)bindec(substr($digits, 2));
+    }
+    if (preg_match('/^0[0-7]+$/', $digits)) {
+        return (float)octdec($digits);
+    }
+    return (float)$digits;
+}
+
+// commentText strips the comment markers from a comment token.
+function commentText(string $text): array
+{
+    if (str_starts_with($text, '/*')) {
+        return ['CommentBlock', substr($text, 2, -2)];
+    }
+    $text = str_starts_with($text, '//') ? substr($text, 2) : substr($text, 1);
+    return ['CommentLine', rtrim($text, "\r\n")];
+}
+
+// Walks the tokens of a source file, logging identifiers, literals and comments.
+class TokenWalker
+{
+    private ParseData $data;
+    private array $tokens = [];
+    // one entry for each open bracket, which is true if it opens an array literal
+    private array $brackets = [];
+    // state of the interpolated string being read, if any
+    private ?array $template = null;
+    // depth of parentheses in the parameter list being read, or -1 if none
+    private int $paramDepth = -1;
+    // true after array, until its opening parenthesis
+    private bool $arrayCall = false;
+    // true while reading a const declaration, which may declare several constants
+    private bool $constDeclaration = false;
+    // true while reading a property declaration, e.g. private ?string $name = null;
+    private bool $propertyDeclaration = false;
+    // true while reading the exception types and variable of a catch clause
+    private bool $catchClause = false;
+
+    public function __construct(string $source, ParseData $data)
+    {
+        $this->data = $data;
+
+        // token_get_all() only records the line of each token, so columns are
+        // computed from the text of the preceding tokens.
+        $line = 1;
+        $column = 0;
+        foreach (token_get_all($source) as $token) {
+            if (is_array($token)) {
+                [$id, $text] = $token;
+            } else {
+                [$id, $text] = [null, $token];
+            }
+            if (!in_array($id, IGNORED_TOKENS, true)) {
+                $this->tokens[] = [$id, $text, [$line, $column]];
+            }
+            $newlines = substr_count($text, "\n");
+            if ($newlines > 0) {
+                $line += $newlines;
+                $column = strlen($text) - strrpos($text, "\n") - 1;
+            } else {
+                $column += strlen($text);
+            }
+        }
+    }
+
+    private function inArray(): bool
+    {
+        return in_array(true, $this->brackets, true);
+    }
+
+    private function tokenIs(?array $token, $id): bool
+    {
+        return $token !== null && $token[0] === $id;
+    }
+
+    private function textIs(?array $token, string $text): bool
+    {
+        return $token !== null && $token[0] === null && $token[1] === $text;
+    }
+
+    public function walk(): void
+    {
+        $count = count($this->tokens);
+        for ($i = 0; $i < $count; $i++) {
+            [$id, $text, $pos] = $this->tokens[$i];
+            $prev = $i > 0 ? $this->tokens[$i - 1] : null;
+            $next = $i + 1 < $count ? $this->tokens[$i + 1] : null;
+
+            if ($this->template !== null) {
+                $this->visitTemplatePart($id, $text, $pos);
+                if ($this->template !== null && $this->template['depth'] > 0) {
+                    $this->visit($id, $text, $pos, $prev, $next);
+                }
+                continue;
+            }
+            $this->visit($id, $text, $pos, $prev, $next);
+        }
+    }
+
+    // visitTemplatePart collects the parts of a string with interpolated variables,
+    // e.g. "Hello $name" or a heredoc.
+    private function visitTemplatePart($id, string $text, array $pos): void
+    {
+        $t = &$this->template;
+        $t['raw'] .= $text;
+        if ($t['depth'] === 0 && ($text === '"' || $text === '`' || $id === T_END_HEREDOC)) {
+            $value = implode('', $t['values']);
+            $type = $t['interpolated'] ? 'StringTemplate' : 'String';
+            $this->data->logLiteral($type, $value, $t['raw'], $t['pos'], $t['inArray']);
+            $this->template = null;
+            return;
+        }
+
+        if ($id === T_CURLY_OPEN || $id === T_DOLLAR_OPEN_CURLY_BRACES) {
+            if ($t['depth'] === 0) {
+                $t['values'][] = INTERPOLATION_PLACEHOLDER;
+                $t['interpolated'] = true;
+            }
+            $t['depth']++;
+        } elseif ($text === '{' && $t['depth'] > 0) {
+            $t['depth']++;
+        } elseif ($text === '}' && $t['depth'] > 0) {
+            $t['depth']--;
+        } elseif ($t['depth'] === 0) {
+            if ($id === T_ENCAPSED_AND_WHITESPACE) {
+                $t['values'][] = $t['nowdoc'] ? $text : unescapeDoubleQuoted($text);
+            } elseif ($id === T_VARIABLE) {
+                // a simple interpolation, e.g. $name, $name[0] or $name->prop
+                $t['values'][] = INTERPOLATION_PLACEHOLDER;
+                $t['interpolated'] = true;
+            }
+        }
+    }
+
+    private function visit($id, string $text, array $pos, ?array $prev, ?array $next): void
+    {
+        if ($this->paramDepth >= 0) {
+            $this->visitParameter($id, $text, $pos);
+        }
+
+        switch ($id) {
+            case T_COMMENT:
+            case T_DOC_COMMENT:
+                [$commentType, $comment] = commentText($text);
+                $this->data->logComment($commentType, $comment, $pos);
+                return;
+            case T_FUNCTION:
+            case T_FN:
+                $this->propertyDeclaration = false;
+                // use function Foo\bar;
+                if ($this->tokenIs($prev, T_USE)) {
+                    return;
+                }
+                if ($this->tokenIs($next, T_STRING)) {
+                    $this->data->logIdentifier('Function', $next[1], $next[2]);
+                }
+                // the parameter list starts at the next opening parenthesis
+                $this->paramDepth = 0;
+                return;
+            case T_CLASS:
+            case T_INTERFACE:
+            case T_TRAIT:
+            case T_ENUM:
+                // ignore Foo::class
+                if ($this->tokenIs($next, T_STRING) && !$this->tokenIs($prev, T_DOUBLE_COLON)) {
+                    $this->data->logIdentifier('Class', $next[1], $next[2]);
+                }
+                return;
+            case T_VARIABLE:
+                $this->visitVariable($text, $pos, $prev, $next);
+                return;
+            case T_STRING:
+                if ($this->tokenIs($prev, T_OBJECT_OPERATOR) || $this->tokenIs($prev, T_NULLSAFE_OBJECT_OPERATOR)) {
+                    $this->data->logIdentifier($this->textIs($next, '=') ? 'Property' : 'Member', $text, $pos);
+                } elseif ($this->constDeclaration && $this->textIs($next, '=')) {
+                    $this->data->logIdentifier('Variable', $text, $pos);
+                }
+                return;
+            case T_CONST:
+                $this->constDeclaration = true;
+                $this->propertyDeclaration = false;
+                return;
+            case T_PUBLIC:
+            case T_PROTECTED:
+            case T_PRIVATE:
+            case T_VAR:
+            case T_READONLY:
+                $this->propertyDeclaration = true;
+                return;
+            case T_CATCH:
+                $this->catchClause = true;
+                return;
+            case T_CONSTANT_ENCAPSED_STRING:
+                $this->data->logLiteral('String', stringValue($text), $text, $pos, $this->inArray());
+                return;
+            case T_START_HEREDOC:
+                $this->startTemplate($text, $pos, str_contains($text, "'"));
+                return;
+            case T_LNUMBER:
+            case T_DNUMBER:
+                $this->data->logLiteral('Numeric', numericValue($id, $text), $text, $pos, $this->inArray());
+                return;
+            case T_ARRAY:
+                if ($this->textIs($next, '(')) {
+                    $this->brackets[] = true;
+                    $this->arrayCall = true;
+                }
+                return;
+        }
+
+        switch ($text) {
+            case '"':
+            case '`':
+                $this->startTemplate($text, $pos, false);
+                break;
+            case '[':
+                // a bracket after an operand is an index, e.g. $a[0]
+                $this->brackets[] = $prev === null || !($this->tokenIs($prev, T_VARIABLE) || $this->tokenIs($prev, T_STRING)
+                        || $this->textIs($prev, ']') || $this->textIs($prev, ')') || $this->textIs($prev, '}'));
+                break;
+            case ']':
+                array_pop($this->brackets);
+                break;
+            case '(':
+                // array( is recorded by T_ARRAY
+                if ($this->arrayCall) {
+                    $this->arrayCall = false;
+                } else {
+                    $this->brackets[] = false;
+                }
+                break;
+            case ')':
+                array_pop($this->brackets);
+                $this->catchClause = false;
+                break;
+            case ';':
+            case '{':
+                $this->constDeclaration = false;
+                $this->propertyDeclaration = false;
+                break;
+        }
+    }
+
+    private function startTemplate(string $text, array $pos, bool $nowdoc): void
+    {
+        $this->template = [
+            'raw' => $text,
+            'pos' => $pos,
+            'values' => [],
+            'interpolated' => false,
+            'depth' => 0,
+            'nowdoc' => $nowdoc,
+            'inArray' => $this->inArray(),
+        ];
+    }
+
+    private function visitVariable(string $text, array $pos, ?array $prev, ?array $next): void
+    {
+        $name = substr($text, 1);
+        if ($this->paramDepth > 0) {
+            // logged by visitParameter
+            return;
+        }
+        if ($this->propertyDeclaration) {
+            $this->data->logIdentifier('Property', $name, $pos);
+        } elseif ($this->catchClause) {
+            $this->data->logIdentifier('Parameter', $name, $pos);
+        } elseif ($this->tokenIs($prev, T_AS) || $this->textIs($next, '=')) {
+            // a foreach value, or an assignment
+            $this->data->logIdentifier('Variable', $name, $pos);
+        }
+    }
+
+    // visitParameter logs the parameters of a function declaration, and ends the
+    // parameter list at its closing parenthesis.
+    private function visitParameter($id, string $text, array $pos): void
+    {
+        if ($text === '(') {
+            $this->paramDepth++;
+        } elseif ($text === ')') {
+            $this->paramDepth--;
+            if ($this->paramDepth === 0) {
+                $this->paramDepth = -1;
+            }
+        } elseif ($id === T_VARIABLE && $this->paramDepth === 1) {
+            $this->data->logIdentifier('Parameter', substr($text, 1), $pos);
+        }
+    }
+}
+
+function parseSource(string $source): ParseData
+{
+    $data = new ParseData();
+    $data->logInfo('InputLength', (string)mb_strlen($source, 'UTF-8'));
+
+    try {
+        // TOKEN_PARSE checks the syntax of the whole file
+        token_get_all($source, TOKEN_PARSE);
+    } catch (ParseError $e) {
+        $pos = [$e->getLine(), 0];
+        $data->logError('SyntaxError', $e->getMessage(), $pos);
+        $data->logError('SyntaxError', FATAL_SYNTAX_ERROR_MARKER . ' (unable to parse remainder of file)', $pos);
+        return $data;
+    }
+
+    (new TokenWalker($source, $data))->walk();
+    return $data;
+}
+
+function isPHPFile(string $path): bool
+{
+    if (in_array(strtolower(pathinfo($path, PATHINFO_EXTENSION)), PHP_EXTENSIONS, true)) {
+        return true;
+    }
+    $f = @fopen($path, 'rb');
+    if ($f === false) {
+        return false;
+    }
+    $firstLine = fgets($f, 256);
+    fclose($f);
+    return $firstLine !== false && str_starts_with($firstLine, '#!') && str_contains($firstLine, 'php');
+}
+
+function parseFile(string $path): ParseData
+{
+    $source = @file_get_contents($path);
+    if ($source === false) {
+        $data = new ParseData();
+        $data->logError('IOError', error_get_last()['message'] ?? "could not read $path", []);
+        return $data;
+    }
+    return parseSource($source);
+}
+
+function main(array $argv): void
+{
+    $options = getopt('f:b:o:', ['file:', 'batch:', 'output:']);
+    $file = $options['file'] ?? $options['f'] ?? '';
+    $batch = $options['batch'] ?? $options['b'] ?? '';
+    $output = $options['output'] ?? $options['o'] ?? '';
+
+    if ($file !== '' && $batch !== '') {
+        fwrite(STDERR, "error: --file (parse single file) cannot be used with --batch (parse multiple files)\n");
+        exit(1);
+    }
+
+    $result = [];
+    if ($batch !== '') {
+        $list = $batch === '-' ? stream_get_contents(STDIN) : file_get_contents($batch);
+        foreach (explode("\n", $list) as $fileName) {
+            if (trim($fileName) !== '' && isPHPFile($fileName)) {
+                $result[$fileName] = parseFile($fileName)->toArray();
+            }
+        }
+    } elseif ($file === '' || $file === '-') {
+        $result['stdin'] = parseSource(stream_get_contents(STDIN))->toArray();
+    } else {
+        $result[$file] = parseFile($file)->toArray();
+    }
+
+    $flags = JSON_PRETTY_PRINT | JSON_UNESCAPED_SLASHES | JSON_INVALID_UTF8_SUBSTITUTE | JSON_PRESERVE_ZERO_FRACTION;
+    $outputString = json_encode($result, $flags);
+    if ($output === '') {
+        echo $outputString, "\n";
+    } else {
+        file_put_contents($output, $outputString);
+    }
+}
+
+main($argv);
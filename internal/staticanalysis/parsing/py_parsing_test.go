@@ -0,0 +1,136 @@
+package parsing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ossf/package-analysis/internal/staticanalysis/externalcmd"
+	"github.com/ossf/package-analysis/internal/utils"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis/token"
+)
+
+// pythonParserConfig writes the Python parser to a temporary directory, without
+// installing the JS parser's dependencies.
+func pythonParserConfig(t *testing.T) ParserConfig {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), pythonParserFileName)
+	if err := utils.WriteFile(path, pythonParser, false); err != nil {
+		t.Fatalf("failed to write Python parser: %v", err)
+	}
+	return ParserConfig{PythonParserPath: path}
+}
+
+type pythonTestCase struct {
+	name        string
+	inputPython string
+	want        singleParseData
+}
+
+var pythonTestCases = []pythonTestCase{
+	{
+		name: "test declarations and literals",
+		inputPython: `import os as o
+# a comment
+class Greeter:
+    def greet(self, name, *args, loud=False, **kwargs):
+        self.count = 0x10
+        message = f"hello {name}!"
+        codes = [1, 2.5, b"\x41"]
+        return message
+`,
+		want: singleParseData{
+			ValidInput: true,
+			Identifiers: []parsedIdentifier{
+				{token.Variable, "o", token.Position{1, 7}},
+				{token.Class, "Greeter", token.Position{3, 0}},
+				{token.Function, "greet", token.Position{4, 4}},
+				{token.Parameter, "self", token.Position{4, 14}},
+				{token.Parameter, "name", token.Position{4, 20}},
+				{token.Parameter, "args", token.Position{4, 27}},
+				{token.Parameter, "loud", token.Position{4, 33}},
+				{token.Parameter, "kwargs", token.Position{4, 47}},
+				{token.Property, "count", token.Position{5, 8}},
+				{token.Variable, "message", token.Position{6, 8}},
+				{token.Variable, "codes", token.Position{7, 8}},
+			},
+			Literals: []parsedLiteral[any]{
+				{"Numeric", "float64", 16.0, "0x10", false, token.Position{5, 21}},
+				{"StringTemplate", "string", "hello {}!", `f"hello {name}!"`, false, token.Position{6, 18}},
+				{"Numeric", "float64", 1.0, "1", true, token.Position{7, 17}},
+				{"Numeric", "float64", 2.5, "2.5", true, token.Position{7, 20}},
+				{"String", "string", "A", `b"\x41"`, true, token.Position{7, 25}},
+			},
+			Comments: []parsedComment{
+				{"CommentLine", " a comment", token.Position{2, 0}},
+			},
+		},
+	},
+	{
+		name:        "test syntax error",
+		inputPython: "def broken(:\n",
+		want:        singleParseData{ValidInput: false},
+	},
+}
+
+func TestParsePython(t *testing.T) {
+	config := pythonParserConfig(t)
+
+	for _, tt := range pythonTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			result, rawOutput, err := parseLanguage(context.Background(), config, Python, externalcmd.StringInput(tt.inputPython))
+			if err != nil {
+				t.Fatalf("parseLanguage() error = %v\nParser output:\n%s", err, rawOutput)
+			}
+			got := result["stdin"]
+
+			if got.ValidInput != tt.want.ValidInput {
+				t.Errorf("ValidInput = %v; want %v", got.ValidInput, tt.want.ValidInput)
+			}
+			if !reflect.DeepEqual(got.Identifiers, tt.want.Identifiers) {
+				t.Errorf("Identifiers mismatch:\ngot  %v\nwant %v", got.Identifiers, tt.want.Identifiers)
+			}
+			if !reflect.DeepEqual(got.Literals, tt.want.Literals) {
+				t.Errorf("Literals mismatch:\ngot  %v\nwant %v", got.Literals, tt.want.Literals)
+			}
+			if !reflect.DeepEqual(got.Comments, tt.want.Comments) {
+				t.Errorf("Comments mismatch:\ngot  %v\nwant %v", got.Comments, tt.want.Comments)
+			}
+		})
+	}
+}
+
+func TestParsePythonSelectsFiles(t *testing.T) {
+	config := pythonParserConfig(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"setup.py":   "from setuptools import setup\nsetup()\n",
+		"index.js":   "console.log('hi')\n",
+		"bin/script": "#!/usr/bin/env python3\nprint('hi')\n",
+	}
+	var paths []string
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	result, rawOutput, err := parseLanguage(context.Background(), config, Python, externalcmd.MultipleFileInput(paths))
+	if err != nil {
+		t.Fatalf("parseLanguage() error = %v\nParser output:\n%s", err, rawOutput)
+	}
+	for name := range files {
+		_, parsed := result[filepath.Join(dir, name)]
+		if want := name != "index.js"; parsed != want {
+			t.Errorf("%s parsed = %v; want %v", name, parsed, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+#!/usr/bin/env python3
+"""Extracts identifiers, literals and comments from Python source code.
+
+The output has the same format as babel-parser.js: a JSON object mapping each
+input filename to its tokens and parser status messages.
+
+When a list of files is given with --batch, only files which look like Python
+source (by extension or shebang line) are parsed, and the others are omitted
+from the output. Source read from stdin is always parsed.
+"""
+
+import argparse
+import ast
+import io
+import json
+import sys
+import tokenize
+
+# used to signal to parent process that parsing could not complete due to syntax errors
+FATAL_SYNTAX_ERROR_MARKER = "FATAL SYNTAX ERROR"
+
+PYTHON_EXTENSIONS = (".py", ".pyw", ".pyi")
+
+# JSON numbers are decoded as float64, so larger integers are output as strings
+MAX_SAFE_INTEGER = 2**53
+
+
+def make_output_dict(type_, subtype, data, pos, extra=None):
+    return {"type": type_, "subtype": subtype, "data": data, "pos": pos, "extra": extra or {}}
+
+
+class ParseData:
+    """Holds all parsing data for a single file."""
+
+    def __init__(self):
+        # holds token information (function, variable names)
+        self.tokens = []
+        # holds status information (info, errors)
+        self.status = []
+
+    def log_error(self, error_type, message, pos):
+        self.status.append(make_output_dict("Error", error_type, message, pos))
+
+    def log_info(self, info_type, message):
+        self.status.append(make_output_dict("Info", info_type, message, []))
+
+    def log_comment(self, comment, pos):
+        self.tokens.append(make_output_dict("Comment", "CommentLine", comment, pos))
+
+    def log_identifier(self, identifier_type, name, pos):
+        self.tokens.append(make_output_dict("Identifier", identifier_type, name, pos))
+
+    def log_literal(self, literal_type, value, raw, pos, in_array):
+        extra = {"array": in_array}
+        if raw is not None:
+            extra["raw"] = raw
+        self.tokens.append(make_output_dict("Literal", literal_type, value, pos, extra))
+
+    def to_json(self):
+        return {"tokens": self.tokens, "status": self.status}
+
+
+def position(node):
+    return [node.lineno, node.col_offset]
+
+
+class Visitor(ast.NodeVisitor):
+    """Logs the identifiers and literals in an AST."""
+
+    def __init__(self, source, parse_data):
+        self.source = source
+        self.parse_data = parse_data
+        # depth of nested list, tuple and set displays
+        self.array_depth = 0
+
+    def raw(self, node):
+        return ast.get_source_segment(self.source, node)
+
+    def log_arguments(self, args):
+        all_args = args.posonlyargs + args.args + args.kwonlyargs
+        all_args += [arg for arg in (args.vararg, args.kwarg) if arg is not None]
+        for arg in sorted(all_args, key=position):
+            self.parse_data.log_identifier("Parameter", arg.arg, position(arg))
+
+    def visit_FunctionDef(self, node):
+        # the position of the name is not recorded, so the def statement is used
+        self.parse_data.log_identifier("Function", node.name, position(node))
+        self.log_arguments(node.args)
+        self.generic_visit(node)
+
+    visit_AsyncFunctionDef = visit_FunctionDef
+
+    def visit_Lambda(self, node):
+        self.log_arguments(node.args)
+        self.generic_visit(node)
+
+    def visit_ClassDef(self, node):
+        self.parse_data.log_identifier("Class", node.name, position(node))
+        self.generic_visit(node)
+
+    def visit_Name(self, node):
+        if isinstance(node.ctx, ast.Store):
+            self.parse_data.log_identifier("Variable", node.id, position(node))
+
+    def visit_Attribute(self, node):
+        if isinstance(node.ctx, ast.Store):
+            self.parse_data.log_identifier("Property", node.attr, position(node))
+        else:
+            self.parse_data.log_identifier("Member", node.attr, position(node))
+        self.generic_visit(node)
+
+    def visit_ExceptHandler(self, node):
+        if node.name is not None:
+            self.parse_data.log_identifier("Parameter", node.name, position(node))
+        self.generic_visit(node)
+
+    def visit_alias(self, node):
+        if node.asname is not None:
+            self.parse_data.log_identifier("Variable", node.asname, position(node))
+
+    def visit_Global(self, node):
+        pass
+
+    def visit_array(self, node):
+        self.array_depth += 1
+        self.generic_visit(node)
+        self.array_depth -= 1
+
+    visit_List = visit_array
+    visit_Tuple = visit_array
+    visit_Set = visit_array
+
+    def visit_Constant(self, node):
+        value = node.value
+        in_array = self.array_depth > 0
+        if isinstance(value, bytes):
+            value = value.decode("utf-8", errors="backslashreplace")
+        if isinstance(value, str):
+            self.parse_data.log_literal("String", value, self.raw(node), position(node), in_array)
+        elif isinstance(value, bool) or value is None or value is Ellipsis:
+            pass
+        elif isinstance(value, int):
+            if abs(value) >= MAX_SAFE_INTEGER:
+                value = str(value)
+            self.parse_data.log_literal("Numeric", value, self.raw(node), position(node), in_array)
+        elif isinstance(value, float):
+            self.parse_data.log_literal("Numeric", value, self.raw(node), position(node), in_array)
+
+    def visit_JoinedStr(self, node):
+        # As for template literals in JavaScript, only the string parts of an f-string
+        # are logged, joined by a placeholder. Expressions are logged separately.
+        parts = []
+        for value in node.values:
+            if isinstance(value, ast.Constant):
+                parts.append(value.value)
+            else:
+                parts.append("{}")
+                self.visit(value)
+        self.parse_data.log_literal("StringTemplate", "".join(parts), self.raw(node), position(node),
+                                    self.array_depth > 0)
+
+
+def log_comments(source, parse_data):
+    try:
+        for tok in tokenize.generate_tokens(io.StringIO(source).readline):
+            if tok.type == tokenize.COMMENT:
+                parse_data.log_comment(tok.string[1:], list(tok.start))
+    except (tokenize.TokenError, SyntaxError) as e:
+        parse_data.log_error(type(e).__name__, str(e), [])
+
+
+def parse_source(source):
+    parse_data = ParseData()
+    parse_data.log_info("InputLength", str(len(source)))
+
+    try:
+        tree = ast.parse(source)
+    except (SyntaxError, ValueError) as e:
+        # SyntaxError offsets start at 1, but columns are numbered from 0
+        pos = [getattr(e, "lineno", 0) or 0, max((getattr(e, "offset", 0) or 0) - 1, 0)]
+        parse_data.log_error(type(e).__name__, str(e), pos)
+        parse_data.log_error(type(e).__name__, FATAL_SYNTAX_ERROR_MARKER + " (unable to parse remainder of file)", pos)
+        return parse_data
+
+    log_comments(source, parse_data)
+    Visitor(source, parse_data).visit(tree)
+    return parse_data
+
+
+def is_python_file(path):
+    if path.endswith(PYTHON_EXTENSIONS):
+        return True
+    try:
+        with open(path, "rb") as f:
+            first_line = f.readline(256)
+    except OSError:
+        return False
+    return first_line.startswith(b"#!") and b"python" in first_line
+
+
+def read_source(f):
+    data = f.read()
+    return data.decode("utf-8", errors="replace") if isinstance(data, bytes) else data
+
+
+def parse_file(path):
+    try:
+        with open(path, "rb") as f:
+            return parse_source(read_source(f))
+    except (OSError, RecursionError, MemoryError) as e:
+        parse_data = ParseData()
+        parse_data.log_error(type(e).__name__, str(e), [])
+        return parse_data
+
+
+def main():
+    parser = argparse.ArgumentParser(description="Default behaviour is to parse stdin and output to stdout")
+    parser.add_argument("--file", "-f", default="", help="parse a single file (- for stdin)")
+    parser.add_argument("--batch", "-b", default="", help="parse each file listed in the given file (- for stdin)")
+    parser.add_argument("--output", "-o", default="", help="write output to the given file")
+    args = parser.parse_args()
+
+    if args.file and args.batch:
+        parser.error("--file (parse single file) cannot be used with --batch (parse multiple files)")
+
+    output = {}
+    if args.batch:
+        if args.batch == "-":
+            file_names = sys.stdin.read().split("\n")
+        else:
+            with open(args.batch, encoding="utf-8") as f:
+                file_names = f.read().split("\n")
+        for file_name in file_names:
+            if file_name.strip() and is_python_file(file_name):
+                output[file_name] = parse_file(file_name).to_json()
+    elif args.file in ("", "-"):
+        output["stdin"] = parse_source(read_source(sys.stdin.buffer)).to_json()
+    else:
+        output[args.file] = parse_file(args.file).to_json()
+
+    output_string = json.dumps(output, indent=2)
+    if args.output:
+        with open(args.output, "w", encoding="utf-8") as f:
+            f.write(output_string)
+    else:
+        print(output_string)
+
+
+if __name__ == "__main__":
+    sys.setrecursionlimit(10000)
+    main()
@@ -16,7 +16,8 @@ type SingleResult struct {
 	IntLiterals    []token.Int        `json:"int_literals"`
 	FloatLiterals  []token.Float      `json:"float_literals"`
 	Comments       []token.Comment    `json:"comments"`
-	// future: external function calls / references (e.g. eval)
+	// Calls holds function calls made in the code. It is only collected for JavaScript.
+	Calls []token.Call `json:"calls"`
 }
 
 func (r SingleResult) String() string {
@@ -27,6 +28,7 @@ func (r SingleResult) String() string {
 		fmt.Sprintf("integer literals\n%v", r.IntLiterals),
 		fmt.Sprintf("float literals\n%v", r.FloatLiterals),
 		fmt.Sprintf("comments\n%v", r.Comments),
+		fmt.Sprintf("calls\n%v", r.Calls),
 	}
 	return strings.Join(parts, "\n")
 }
@@ -0,0 +1,411 @@
+#!/usr/bin/env ruby
+# frozen_string_literal: true
+
+# Extracts identifiers, literals and comments from Ruby source code, using the
+# tokens produced by Ripper, the parser in the Ruby standard library.
+#
+# The output has the same format as babel-parser.js: a JSON object mapping each
+# input filename to its tokens and parser status messages.
+#
+# When a list of files is given with --batch, only files which look like Ruby
+# source (by extension, name or shebang line) are parsed, and the others are
+# omitted from the output. Source read from stdin is always parsed.
+
+require 'json'
+require 'optparse'
+require 'ripper'
+
+# used to signal to parent process that parsing could not complete due to syntax errors
+FATAL_SYNTAX_ERROR_MARKER = 'FATAL SYNTAX ERROR'
+
+RUBY_EXTENSIONS = %w[.rb .rake .gemspec .ru].freeze
+RUBY_FILENAMES = %w[Rakefile Gemfile Guardfile].freeze
+
+# JSON numbers are decoded as float64, so larger integers are output as strings
+MAX_SAFE_INTEGER = 2**53
+
+# placeholder for interpolated expressions in string values
+INTERPOLATION_PLACEHOLDER = '#{}'
+
+ESCAPES = {
+  'n' => "\n", 't' => "\t", 'r' => "\r", 's' => ' ', '0' => "\0", 'e' => "\e",
+  'a' => "\a", 'b' => "\b", 'f' => "\f", 'v' => "\v"
+}.freeze
+
+# Holds all parsing data for a single file
+class ParseData
+  def initialize
+    # holds token information (function, variable names)
+    @tokens = []
+    # holds status information (info, errors)
+    @status = []
+  end
+
+  def self.make_output_dict(type, subtype, data, pos, extra = nil)
+    { 'type' => type, 'subtype' => subtype, 'data' => data, 'pos' => pos, 'extra' => extra || {} }
+  end
+
+  def log_error(error_type, message, pos)
+    @status << ParseData.make_output_dict('Error', error_type, message, pos)
+  end
+
+  def log_info(info_type, message)
+    @status << ParseData.make_output_dict('Info', info_type, message, [])
+  end
+
+  def log_comment(comment_type, comment, pos)
+    @tokens << ParseData.make_output_dict('Comment', comment_type, comment, pos)
+  end
+
+  def log_identifier(identifier_type, name, pos)
+    @tokens << ParseData.make_output_dict('Identifier', identifier_type, name, pos)
+  end
+
+  def log_literal(literal_type, value, raw, pos, in_array)
+    @tokens << ParseData.make_output_dict('Literal', literal_type, value, pos, { 'array' => in_array, 'raw' => raw })
+  end
+
+  def to_h
+    { 'tokens' => @tokens, 'status' => @status }
+  end
+end
+
+# Records the first syntax error found while parsing
+class SyntaxChecker < Ripper
+  attr_reader :error
+
+  def on_parse_error(message)
+    @error ||= [message, [lineno, column]]
+  end
+
+  def compile_error(message)
+    on_parse_error(message)
+  end
+end
+
+def unescape_double_quoted(s)
+  s.gsub(/\\(x\h{1,2}|u\h{4}|u\{[\h ]+\}|[0-7]{1,3}|.)/m) do
+    esc = Regexp.last_match(1)
+    case esc
+    when /\Ax(\h+)\z/ then Regexp.last_match(1).hex.chr.force_encoding(Encoding::UTF_8)
+    when /\Au\{([\h ]+)\}\z/ then Regexp.last_match(1).split.map { |c| c.hex.chr(Encoding::UTF_8) }.join
+    when /\Au(\h{4})\z/ then Regexp.last_match(1).hex.chr(Encoding::UTF_8)
+    when /\A[0-7]+\z/ then esc.oct.chr.force_encoding(Encoding::UTF_8)
+    when "\n" then ''
+    else ESCAPES.fetch(esc, esc)
+    end
+  end.scrub
+end
+
+def unescape_single_quoted(s)
+  s.gsub(/\\([\\'])/, '\1')
+end
+
+# single_quoted? returns true if escapes and interpolation are not processed in a
+# string starting with the given token, e.g. 'abc', %q(abc) or <<~'EOS'.
+def single_quoted?(beg)
+  beg.start_with?("'", '%q', '%w', '%i') || beg.match?(/\A<<[~-]?'/)
+end
+
+# A string, heredoc, backtick command or regexp literal which is being read
+StringFrame = Struct.new(:event, :beg, :pos, :values, :template, :expr_depth, :in_array)
+
+# Walks the tokens of a source file, logging identifiers, literals and comments.
+class TokenWalker
+  SKIPPED_EVENTS = %i[on_sp on_ignored_nl on_ignored_sp on_words_sep].freeze
+  OPERAND_EVENTS = %i[on_ident on_const on_ivar on_cvar on_gvar on_rparen on_rbracket on_rbrace on_tstring_end
+                      on_int on_float on_kw on_backtick].freeze
+  PARAM_PREFIX_OPS = ['*', '**', '&', '|'].freeze
+
+  def initialize(source, tokens, data)
+    @source = source
+    @tokens = tokens.reject { |t| SKIPPED_EVENTS.include?(t[1]) }
+    @data = data
+    # one entry for each open bracket, which is true if it opens an array literal
+    @brackets = []
+    # depth of %w[] and similar word lists
+    @word_lists = 0
+    @strings = []
+    # state of the parameter list being read, if any
+    @params = nil
+    @rescue_line = nil
+  end
+
+  def in_array?
+    @word_lists.positive? || @brackets.any? || @strings.any?(&:in_array)
+  end
+
+  def walk
+    @tokens.each_with_index do |(pos, event, text), i|
+      prev = i.positive? ? @tokens[i - 1] : nil
+      nxt = @tokens[i + 1]
+      track_params(event, text, pos, prev)
+      visit(event, text, pos, prev, nxt)
+    end
+  end
+
+  private
+
+  def token_is?(token, event, text = nil)
+    !token.nil? && token[1] == event && (text.nil? || token[2] == text)
+  end
+
+  def start_params(closer, opener_pos)
+    @params = { closer: closer, depth: 0, opener_pos: opener_pos }
+  end
+
+  # track_params logs parameters and ends the parameter list when its closing token is reached.
+  def track_params(event, text, pos, prev)
+    return if @params.nil? || pos == @params[:opener_pos]
+
+    case event
+    when :on_lparen, :on_lbracket, :on_lbrace
+      @params[:depth] += 1
+      return
+    when :on_rparen, :on_rbracket, :on_rbrace
+      @params[:depth] -= 1
+    end
+
+    closer = @params[:closer]
+    at_top = @params[:depth].zero?
+    if (closer == :on_rparen && event == :on_rparen && @params[:depth].negative?) ||
+       (closer == :on_nl && %i[on_nl on_semicolon].include?(event) && at_top) ||
+       (closer == :on_op && event == :on_op && text == '|' && at_top)
+      @params = nil
+      return
+    end
+    return unless at_top
+
+    if event == :on_ident && (prev[1] == :on_comma || prev[0] == @params[:opener_pos] ||
+                              (prev[1] == :on_op && PARAM_PREFIX_OPS.include?(prev[2])))
+      @data.log_identifier('Parameter', text, pos)
+    elsif event == :on_label
+      @data.log_identifier('Parameter', text.chomp(':'), pos)
+    end
+  end
+
+  def visit(event, text, pos, prev, nxt)
+    frame = @strings.last
+    if frame && frame.expr_depth.zero? && %i[on_tstring_content on_embvar].include?(event)
+      frame.values << (event == :on_embvar ? INTERPOLATION_PLACEHOLDER : text)
+      frame.template ||= event == :on_embvar
+      return
+    end
+
+    case event
+    when :on_comment
+      @data.log_comment('CommentLine', text.delete_prefix('#').chomp, pos)
+    when :on_embdoc_beg
+      @embdoc = { pos: pos, lines: [] }
+    when :on_embdoc
+      @embdoc[:lines] << text if @embdoc
+    when :on_embdoc_end
+      @data.log_comment('CommentBlock', @embdoc[:lines].join.chomp, @embdoc[:pos]) if @embdoc
+      @embdoc = nil
+    when :on_kw
+      visit_keyword(text, pos, nxt)
+    when :on_ident, :on_const
+      visit_identifier(text, pos, prev, nxt)
+    when :on_ivar, :on_cvar
+      # names are logged without their sigil, e.g. @name is logged as name
+      @data.log_identifier('Property', text.sub(/\A@@?/, ''), pos) if token_is?(nxt, :on_op, '=')
+    when :on_gvar
+      @data.log_identifier('Variable', text.delete_prefix('$'), pos) if token_is?(nxt, :on_op, '=')
+    when :on_op
+      if @pending_def
+        # an operator method, e.g. def ==(other)
+        @pending_def = false
+        @data.log_identifier('Function', text, pos)
+        start_params(token_is?(nxt, :on_lparen) ? :on_rparen : :on_nl, token_is?(nxt, :on_lparen) ? nxt[0] : pos)
+      elsif text == '|' && (token_is?(prev, :on_lbrace) || token_is?(prev, :on_kw, 'do'))
+        start_params(:on_op, pos)
+      end
+    when :on_tlambda
+      start_params(:on_rparen, nxt[0]) if token_is?(nxt, :on_lparen)
+    when :on_lbracket
+      @brackets << (prev.nil? || !OPERAND_EVENTS.include?(prev[1]))
+    when :on_rbracket
+      @brackets.pop
+    when :on_qwords_beg, :on_words_beg, :on_qsymbols_beg, :on_symbols_beg
+      @word_lists += 1
+    when :on_tstring_beg, :on_heredoc_beg, :on_backtick, :on_regexp_beg
+      @strings << StringFrame.new(event, text, pos, [], false, 0, in_array?)
+    when :on_embexpr_beg
+      if frame
+        frame.values << INTERPOLATION_PLACEHOLDER if frame.expr_depth.zero?
+        frame.template = true
+        frame.expr_depth += 1
+      end
+    when :on_embexpr_end
+      frame.expr_depth -= 1 if frame
+    when :on_tstring_end, :on_heredoc_end, :on_regexp_end
+      if frame && frame.expr_depth.zero?
+        end_string(frame, text, pos)
+      elsif @word_lists.positive?
+        @word_lists -= 1
+      end
+    when :on_tstring_content
+      # a word in a word list, or a quoted symbol
+      value = @word_lists.positive? ? text : unescape_double_quoted(text)
+      @data.log_literal('String', value, text, pos, in_array?)
+    when :on_int
+      value = Integer(text)
+      value = value.to_s if value.abs >= MAX_SAFE_INTEGER
+      @data.log_literal('Numeric', value, text, pos, in_array?)
+    when :on_float
+      @data.log_literal('Numeric', Float(text), text, pos, in_array?)
+    end
+  end
+
+  def visit_keyword(text, pos, nxt)
+    case text
+    when 'def'
+      @pending_def = true
+    when 'class', 'module'
+      @pending_class = true unless token_is?(nxt, :on_op, '<<')
+    when 'rescue'
+      @rescue_line = pos[0]
+    end
+  end
+
+  def visit_identifier(text, pos, prev, nxt)
+    if @pending_class
+      # the last name in Outer::Inner is the class being defined
+      return if token_is?(nxt, :on_op, '::')
+
+      @pending_class = false
+      @data.log_identifier('Class', text, pos)
+    elsif @pending_def
+      # def self.name or def Const.name
+      return if token_is?(nxt, :on_period)
+
+      @pending_def = false
+      @data.log_identifier('Function', text, pos)
+      if token_is?(nxt, :on_lparen)
+        start_params(:on_rparen, nxt[0])
+      else
+        start_params(:on_nl, pos)
+      end
+    elsif token_is?(prev, :on_period) || token_is?(prev, :on_op, '&.')
+      @data.log_identifier(token_is?(nxt, :on_op, '=') ? 'Property' : 'Member', text, pos)
+    elsif token_is?(prev, :on_op, '=>') && @rescue_line == pos[0]
+      @data.log_identifier('Parameter', text, pos)
+    elsif token_is?(nxt, :on_op, '=') && @params.nil?
+      @data.log_identifier('Variable', text, pos)
+    end
+  end
+
+  def end_string(frame, text, pos)
+    @strings.pop
+    contents = frame.values.join
+    value = if frame.event == :on_regexp_beg
+              # escapes in a regexp are part of its pattern
+              contents
+            elsif single_quoted?(frame.beg)
+              unescape_single_quoted(contents)
+            else
+              unescape_double_quoted(contents)
+            end
+
+    raw = if frame.event == :on_heredoc_beg
+            frame.beg + "\n" + contents + text
+          else
+            source_between(frame.pos, pos, text)
+          end
+
+    type = if frame.event == :on_regexp_beg
+             'Regexp'
+           elsif frame.template
+             'StringTemplate'
+           else
+             'String'
+           end
+    @data.log_literal(type, value, raw, frame.pos, frame.in_array)
+  end
+
+  # source_between returns the source from start_pos up to and including the
+  # token end_text at end_pos.
+  def source_between(start_pos, end_pos, end_text)
+    @line_offsets ||= begin
+      offsets = [0, 0]
+      @source.each_line { |line| offsets << offsets.last + line.bytesize }
+      offsets
+    end
+    start = @line_offsets[start_pos[0]] + start_pos[1]
+    finish = @line_offsets[end_pos[0]] + end_pos[1] + end_text.bytesize
+    @source.byteslice(start, finish - start).scrub
+  end
+end
+
+def parse_source(source)
+  source = source.dup.force_encoding(Encoding::UTF_8).scrub
+  data = ParseData.new
+  data.log_info('InputLength', source.length.to_s)
+
+  checker = SyntaxChecker.new(source)
+  checker.parse
+  if checker.error
+    message, pos = checker.error
+    data.log_error('SyntaxError', message, pos)
+    data.log_error('SyntaxError', "#{FATAL_SYNTAX_ERROR_MARKER} (unable to parse remainder of file)", pos)
+    return data
+  end
+
+  TokenWalker.new(source, Ripper.lex(source), data).walk
+  data
+end
+
+def ruby_file?(path)
+  return true if path.end_with?(*RUBY_EXTENSIONS) || RUBY_FILENAMES.include?(File.basename(path))
+
+  first_line = File.open(path, 'rb') { |f| f.gets(256) } || ''
+  first_line.start_with?('#!') && first_line.include?('ruby')
+rescue SystemCallError
+  false
+end
+
+def parse_file(path)
+  parse_source(File.binread(path))
+rescue StandardError, SystemStackError => e
+  data = ParseData.new
+  data.log_error(e.class.name, e.message, [])
+  data
+end
+
+def main
+  options = { file: '', batch: '', output: '' }
+  OptionParser.new do |opts|
+    opts.banner = 'usage: ruby-parser.rb [--file <input.rb> | --batch <paths.txt>] [--output <out.json>]'
+    opts.on('-f', '--file FILE', 'parse a single file (- for stdin)') { |v| options[:file] = v }
+    opts.on('-b', '--batch FILE', 'parse each file listed in the given file (- for stdin)') { |v| options[:batch] = v }
+    opts.on('-o', '--output FILE', 'write output to the given file') { |v| options[:output] = v }
+  end.parse!
+
+  if !options[:file].empty? && !options[:batch].empty?
+    warn 'error: --file (parse single file) cannot be used with --batch (parse multiple files)'
+    exit 1
+  end
+
+  output = {}
+  if !options[:batch].empty?
+    list = options[:batch] == '-' ? $stdin.read : File.read(options[:batch])
+    list.split("\n").each do |file_name|
+      next if file_name.strip.empty? || !ruby_file?(file_name)
+
+      output[file_name] = parse_file(file_name).to_h
+    end
+  elsif ['', '-'].include?(options[:file])
+    output['stdin'] = parse_source($stdin.binmode.read).to_h
+  else
+    output[options[:file]] = parse_file(options[:file]).to_h
+  end
+
+  output_string = JSON.pretty_generate(output)
+  if options[:output].empty?
+    puts output_string
+  else
+    File.write(options[:output], output_string)
+  end
+end
+
+main
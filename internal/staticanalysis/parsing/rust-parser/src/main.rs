@@ -0,0 +1,417 @@
+//! Extracts identifiers, literals and comments from Rust source code, using syn.
+//!
+//! The output has the same format as babel-parser.js: a JSON object mapping each
+//! input filename to its tokens and parser status messages.
+//!
+//! When a list of files is given with --batch, only files with a .rs extension are
+//! parsed, and the others are omitted from the output. Source read from stdin is
+//! always parsed.
+//!
+//! syn does not keep ordinary comments, so only doc comments are logged. The tokens
+//! passed to a macro are logged if they can be parsed as a list of expressions;
+//! otherwise only the literals they contain are logged.
+
+use std::io::Read;
+use std::process::exit;
+use std::thread;
+
+use proc_macro2::{Span, TokenStream, TokenTree};
+use serde_json::{json, Map, Value};
+use syn::punctuated::Punctuated;
+use syn::visit::{self, Visit};
+use syn::{Expr, Lit, Token};
+
+/// Used to signal to parent process that parsing could not complete due to syntax errors.
+const FATAL_SYNTAX_ERROR_MARKER: &str = "FATAL SYNTAX ERROR";
+
+/// JSON numbers are decoded as float64, so larger integers are output as strings.
+const MAX_SAFE_INTEGER: u128 = 1 << 53;
+
+/// Deeply nested source can overflow the default stack, so parsing runs in a
+/// thread with a larger one.
+const PARSER_STACK_SIZE: usize = 256 * 1024 * 1024;
+
+const USAGE: &str =
+    "usage: rust-parser [--file <input.rs> | --batch <paths.txt>] [--output <out.json>]";
+
+fn make_output_dict(type_: &str, subtype: &str, data: Value, pos: Value, extra: Value) -> Value {
+    json!({"type": type_, "subtype": subtype, "data": data, "pos": pos, "extra": extra})
+}
+
+fn position(span: Span) -> Value {
+    let start = span.start();
+    json!([start.line, start.column])
+}
+
+/// Holds all parsing data for a single file.
+#[derive(Default)]
+struct ParseData {
+    /// holds token information (function, variable names)
+    tokens: Vec<Value>,
+    /// holds status information (info, errors)
+    status: Vec<Value>,
+}
+
+impl ParseData {
+    fn log_error(&mut self, error_type: &str, message: &str, pos: Value) {
+        let dict = make_output_dict("Error", error_type, json!(message), pos, json!({}));
+        self.status.push(dict);
+    }
+
+    fn log_info(&mut self, info_type: &str, message: &str) {
+        let dict = make_output_dict("Info", info_type, json!(message), json!([]), json!({}));
+        self.status.push(dict);
+    }
+
+    fn log_comment(&mut self, comment: &str, span: Span) {
+        let dict = make_output_dict("Comment", "CommentLine", json!(comment), position(span), json!({}));
+        self.tokens.push(dict);
+    }
+
+    fn log_identifier(&mut self, identifier_type: &str, ident: &syn::Ident) {
+        let name = ident.to_string();
+        // raw identifiers, e.g. r#type
+        let name = name.strip_prefix("r#").unwrap_or(&name);
+        let dict = make_output_dict("Identifier", identifier_type, json!(name), position(ident.span()), json!({}));
+        self.tokens.push(dict);
+    }
+
+    fn log_literal(&mut self, literal_type: &str, value: Value, raw: String, span: Span, in_array: bool) {
+        let extra = json!({"array": in_array, "raw": raw});
+        self.tokens.push(make_output_dict("Literal", literal_type, value, position(span), extra));
+    }
+
+    fn to_json(&self) -> Value {
+        json!({"tokens": self.tokens, "status": self.status})
+    }
+}
+
+/// Logs the identifiers, literals and doc comments in a syntax tree.
+struct Visitor<'a> {
+    data: &'a mut ParseData,
+    /// depth of nested array expressions
+    array_depth: usize,
+    /// the type of identifier bound by the pattern being visited, if any
+    binding: Option<&'static str>,
+}
+
+impl Visitor<'_> {
+    fn in_array(&self) -> bool {
+        self.array_depth > 0
+    }
+
+    fn visit_binding(&mut self, kind: &'static str, pat: &syn::Pat) {
+        let saved = self.binding.replace(kind);
+        self.visit_pat(pat);
+        self.binding = saved;
+    }
+
+    fn visit_array<F: FnOnce(&mut Self)>(&mut self, f: F) {
+        self.array_depth += 1;
+        f(self);
+        self.array_depth -= 1;
+    }
+
+    /// Logs the literals in the tokens of a macro that can't be parsed as expressions.
+    fn visit_macro_tokens(&mut self, tokens: TokenStream) {
+        for tt in tokens {
+            match tt {
+                TokenTree::Group(g) => self.visit_macro_tokens(g.stream()),
+                TokenTree::Literal(l) => self.visit_lit(&Lit::new(l)),
+                _ => {}
+            }
+        }
+    }
+}
+
+impl<'ast> Visit<'ast> for Visitor<'_> {
+    fn visit_attribute(&mut self, i: &'ast syn::Attribute) {
+        if let syn::Meta::NameValue(nv) = &i.meta {
+            if nv.path.is_ident("doc") {
+                if let Expr::Lit(syn::ExprLit { lit: Lit::Str(s), .. }) = &nv.value {
+                    self.data.log_comment(&s.value(), s.span());
+                    return;
+                }
+            }
+        }
+        visit::visit_attribute(self, i);
+    }
+
+    fn visit_signature(&mut self, i: &'ast syn::Signature) {
+        self.data.log_identifier("Function", &i.ident);
+        visit::visit_signature(self, i);
+    }
+
+    fn visit_fn_arg(&mut self, i: &'ast syn::FnArg) {
+        match i {
+            syn::FnArg::Typed(t) => {
+                for attr in &t.attrs {
+                    self.visit_attribute(attr);
+                }
+                self.visit_binding("Parameter", &t.pat);
+                self.visit_type(&t.ty);
+            }
+            syn::FnArg::Receiver(_) => visit::visit_fn_arg(self, i),
+        }
+    }
+
+    fn visit_expr_closure(&mut self, i: &'ast syn::ExprClosure) {
+        for attr in &i.attrs {
+            self.visit_attribute(attr);
+        }
+        for input in &i.inputs {
+            self.visit_binding("Parameter", input);
+        }
+        self.visit_return_type(&i.output);
+        self.visit_expr(&i.body);
+    }
+
+    fn visit_item_struct(&mut self, i: &'ast syn::ItemStruct) {
+        self.data.log_identifier("Class", &i.ident);
+        visit::visit_item_struct(self, i);
+    }
+
+    fn visit_item_enum(&mut self, i: &'ast syn::ItemEnum) {
+        self.data.log_identifier("Class", &i.ident);
+        visit::visit_item_enum(self, i);
+    }
+
+    fn visit_item_union(&mut self, i: &'ast syn::ItemUnion) {
+        self.data.log_identifier("Class", &i.ident);
+        visit::visit_item_union(self, i);
+    }
+
+    fn visit_item_trait(&mut self, i: &'ast syn::ItemTrait) {
+        self.data.log_identifier("Class", &i.ident);
+        visit::visit_item_trait(self, i);
+    }
+
+    fn visit_field(&mut self, i: &'ast syn::Field) {
+        if let Some(ident) = &i.ident {
+            self.data.log_identifier("Property", ident);
+        }
+        visit::visit_field(self, i);
+    }
+
+    fn visit_variant(&mut self, i: &'ast syn::Variant) {
+        self.data.log_identifier("Property", &i.ident);
+        visit::visit_variant(self, i);
+    }
+
+    fn visit_item_const(&mut self, i: &'ast syn::ItemConst) {
+        self.data.log_identifier("Variable", &i.ident);
+        visit::visit_item_const(self, i);
+    }
+
+    fn visit_item_static(&mut self, i: &'ast syn::ItemStatic) {
+        self.data.log_identifier("Variable", &i.ident);
+        visit::visit_item_static(self, i);
+    }
+
+    fn visit_local(&mut self, i: &'ast syn::Local) {
+        for attr in &i.attrs {
+            self.visit_attribute(attr);
+        }
+        self.visit_binding("Variable", &i.pat);
+        if let Some(init) = &i.init {
+            self.visit_local_init(init);
+        }
+    }
+
+    fn visit_expr_for_loop(&mut self, i: &'ast syn::ExprForLoop) {
+        for attr in &i.attrs {
+            self.visit_attribute(attr);
+        }
+        self.visit_binding("Variable", &i.pat);
+        self.visit_expr(&i.expr);
+        self.visit_block(&i.body);
+    }
+
+    fn visit_pat_ident(&mut self, i: &'ast syn::PatIdent) {
+        if let Some(kind) = self.binding {
+            self.data.log_identifier(kind, &i.ident);
+        }
+        visit::visit_pat_ident(self, i);
+    }
+
+    fn visit_expr_assign(&mut self, i: &'ast syn::ExprAssign) {
+        if let Expr::Field(f) = &*i.left {
+            if let syn::Member::Named(ident) = &f.member {
+                self.data.log_identifier("Property", ident);
+                self.visit_expr(&f.base);
+                self.visit_expr(&i.right);
+                return;
+            }
+        }
+        visit::visit_expr_assign(self, i);
+    }
+
+    fn visit_expr_field(&mut self, i: &'ast syn::ExprField) {
+        if let syn::Member::Named(ident) = &i.member {
+            self.data.log_identifier("Member", ident);
+        }
+        visit::visit_expr_field(self, i);
+    }
+
+    fn visit_expr_method_call(&mut self, i: &'ast syn::ExprMethodCall) {
+        self.data.log_identifier("Member", &i.method);
+        visit::visit_expr_method_call(self, i);
+    }
+
+    fn visit_expr_array(&mut self, i: &'ast syn::ExprArray) {
+        self.visit_array(|v| visit::visit_expr_array(v, i));
+    }
+
+    fn visit_macro(&mut self, i: &'ast syn::Macro) {
+        let is_vec = i.path.is_ident("vec");
+        let visit_body = |v: &mut Self| {
+            match i.parse_body_with(Punctuated::<Expr, Token![,]>::parse_terminated) {
+                Ok(exprs) => exprs.iter().for_each(|e| v.visit_expr(e)),
+                Err(_) => v.visit_macro_tokens(i.tokens.clone()),
+            }
+        };
+        if is_vec {
+            self.visit_array(visit_body);
+        } else {
+            visit_body(self);
+        }
+    }
+
+    fn visit_lit_str(&mut self, i: &'ast syn::LitStr) {
+        let in_array = self.in_array();
+        self.data.log_literal("String", json!(i.value()), i.token().to_string(), i.span(), in_array);
+    }
+
+    fn visit_lit_byte_str(&mut self, i: &'ast syn::LitByteStr) {
+        let value = String::from_utf8_lossy(&i.value()).into_owned();
+        let in_array = self.in_array();
+        self.data.log_literal("String", json!(value), i.token().to_string(), i.span(), in_array);
+    }
+
+    fn visit_lit_int(&mut self, i: &'ast syn::LitInt) {
+        let value = match i.base10_parse::<u128>() {
+            Ok(n) if n < MAX_SAFE_INTEGER => json!(n as u64),
+            _ => json!(i.base10_digits()),
+        };
+        let in_array = self.in_array();
+        self.data.log_literal("Numeric", value, i.token().to_string(), i.span(), in_array);
+    }
+
+    fn visit_lit_float(&mut self, i: &'ast syn::LitFloat) {
+        let value = match i.base10_parse::<f64>() {
+            Ok(f) if f.is_finite() => json!(f),
+            _ => json!(i.base10_digits()),
+        };
+        let in_array = self.in_array();
+        self.data.log_literal("Numeric", value, i.token().to_string(), i.span(), in_array);
+    }
+}
+
+fn parse_source(source: &str) -> ParseData {
+    let mut data = ParseData::default();
+    data.log_info("InputLength", &source.chars().count().to_string());
+
+    match syn::parse_file(source) {
+        Ok(file) => {
+            let mut visitor = Visitor { data: &mut data, array_depth: 0, binding: None };
+            visitor.visit_file(&file);
+        }
+        Err(e) => {
+            let pos = position(e.span());
+            data.log_error("SyntaxError", &e.to_string(), pos.clone());
+            let message = format!("{FATAL_SYNTAX_ERROR_MARKER} (unable to parse remainder of file)");
+            data.log_error("SyntaxError", &message, pos);
+        }
+    }
+    data
+}
+
+fn parse_file(path: &str) -> ParseData {
+    match std::fs::read(path) {
+        Ok(bytes) => parse_source(&String::from_utf8_lossy(&bytes)),
+        Err(e) => {
+            let mut data = ParseData::default();
+            data.log_error("IOError", &e.to_string(), json!([]));
+            data
+        }
+    }
+}
+
+fn read_stdin() -> String {
+    let mut bytes = Vec::new();
+    if let Err(e) = std::io::stdin().read_to_end(&mut bytes) {
+        eprintln!("error reading stdin: {e}");
+        exit(1);
+    }
+    String::from_utf8_lossy(&bytes).into_owned()
+}
+
+fn run(file: String, batch: String) -> Map<String, Value> {
+    let mut output = Map::new();
+    if !batch.is_empty() {
+        let list = if batch == "-" {
+            read_stdin()
+        } else {
+            std::fs::read_to_string(&batch).unwrap_or_else(|e| {
+                eprintln!("error reading {batch}: {e}");
+                exit(1);
+            })
+        };
+        for file_name in list.split('\n') {
+            if !file_name.trim().is_empty() && file_name.ends_with(".rs") {
+                output.insert(file_name.to_string(), parse_file(file_name).to_json());
+            }
+        }
+    } else if file.is_empty() || file == "-" {
+        output.insert("stdin".to_string(), parse_source(&read_stdin()).to_json());
+    } else {
+        let data = parse_file(&file).to_json();
+        output.insert(file, data);
+    }
+    output
+}
+
+fn main() {
+    let (mut file, mut batch, mut output) = (String::new(), String::new(), String::new());
+    let mut args = std::env::args().skip(1);
+    while let Some(arg) = args.next() {
+        let target = match arg.as_str() {
+            "--file" | "-f" => &mut file,
+            "--batch" | "-b" => &mut batch,
+            "--output" | "-o" => &mut output,
+            _ => {
+                eprintln!("unknown argument: {arg}\n{USAGE}");
+                exit(2);
+            }
+        };
+        match args.next() {
+            Some(value) => *target = value,
+            None => {
+                eprintln!("missing value for {arg}\n{USAGE}");
+                exit(2);
+            }
+        }
+    }
+
+    if !file.is_empty() && !batch.is_empty() {
+        eprintln!("error: --file (parse single file) cannot be used with --batch (parse multiple files)");
+        exit(1);
+    }
+
+    let parser = thread::Builder::new().stack_size(PARSER_STACK_SIZE).spawn(move || run(file, batch));
+    let result = match parser.map(|handle| handle.join()) {
+        Ok(Ok(result)) => result,
+        _ => {
+            eprintln!("error: parser thread failed");
+            exit(1);
+        }
+    };
+
+    let output_string = serde_json::to_string_pretty(&Value::Object(result)).expect("JSON serialization failed");
+    if output.is_empty() {
+        println!("{output_string}");
+    } else if let Err(e) = std::fs::write(&output, output_string) {
+        eprintln!("error writing {output}: {e}");
+        exit(1);
+    }
+}
@@ -13,11 +13,23 @@ import (
 // Result (staticanalysis.Result) is the top-level internal data structure
 // that stores all data produced by static analysis performed on a package artifact.
 type Result struct {
-	Archive ArchiveResult
-	Files   []SingleResult
+	// Archives records information about each package archive that was analyzed.
+	Archives []ArchiveResult
+	Files    []SingleResult
+
+	// Manifests records information from each package manifest, if the
+	// Manifest task was performed.
+	Manifests []staticanalysis.ManifestResult
 }
 
 type ArchiveResult struct {
+	// Filename is the name of the archive file.
+	Filename string
+
+	// Type records the kind of archive (e.g. sdist or wheel), if the
+	// ecosystem publishes more than one kind of archive.
+	Type string
+
 	// DetectedType records the output of the `file` command run on the archive.
 	DetectedType string
 
@@ -26,6 +38,10 @@ type ArchiveResult struct {
 
 	// SHA256 records the SHA256 hashsum of the archive.
 	SHA256 string
+
+	// Checksum records the verification of the archive against the checksum
+	// published by the registry, if there is one.
+	Checksum *staticanalysis.ArchiveChecksum
 }
 
 /*
@@ -57,7 +73,18 @@ func (r SingleResult) String() string {
 // ToAPIResults converts the data in this Result object into the
 // public staticanalysis.Results format defined in pkg/api/staticanalysis.
 func (r *Result) ToAPIResults() *staticanalysis.Results {
-	results := &staticanalysis.Results{}
+	results := &staticanalysis.Results{Manifests: r.Manifests}
+
+	for _, a := range r.Archives {
+		results.Archives = append(results.Archives, staticanalysis.ArchiveResult{
+			Filename:     a.Filename,
+			Type:         a.Type,
+			DetectedType: a.DetectedType,
+			Size:         a.Size,
+			SHA256:       a.SHA256,
+			Checksum:     a.Checksum,
+		})
+	}
 
 	for _, f := range r.Files {
 		fr := staticanalysis.FileResult{
@@ -72,14 +99,26 @@ func (r *Result) ToAPIResults() *staticanalysis.Results {
 				fr.LineLengths = &f.Basic.LineLengths
 			}
 		}
-		if f.Parsing != nil && f.Parsing.Language == parsing.JavaScript {
-			fr.Js = &staticanalysis.JsData{
+		if f.Parsing != nil {
+			data := &staticanalysis.ParseData{
 				Identifiers:    f.Parsing.Identifiers,
 				StringLiterals: f.Parsing.StringLiterals,
 				IntLiterals:    f.Parsing.IntLiterals,
 				FloatLiterals:  f.Parsing.FloatLiterals,
 				Comments:       f.Parsing.Comments,
 			}
+			switch f.Parsing.Language {
+			case parsing.JavaScript:
+				fr.Js = data
+			case parsing.Python:
+				fr.Python = data
+			case parsing.Ruby:
+				fr.Ruby = data
+			case parsing.PHP:
+				fr.PHP = data
+			case parsing.Rust:
+				fr.Rust = data
+			}
 		}
 		if f.Signals != nil {
 			// only populate value counts if nonempty
@@ -95,10 +134,16 @@ func (r *Result) ToAPIResults() *staticanalysis.Results {
 			fr.URLs = f.Signals.URLs
 			fr.EscapedStrings = f.Signals.EscapedStrings
 			fr.SuspiciousIdentifiers = f.Signals.SuspiciousIdentifiers
+			fr.SuspiciousCalls = f.Signals.SuspiciousCalls
+			fr.DecodedStrings = f.Signals.DecodedStrings
 		}
 
 		results.Files = append(results.Files, fr)
 	}
 
+	if len(r.Files) > 0 {
+		results.Package = summarisePackage(r.Files)
+	}
+
 	return results
 }
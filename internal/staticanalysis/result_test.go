@@ -161,7 +161,7 @@ func TestResult_ToAPIResults(t *testing.T) {
 					Size:         100,
 					SHA256:       "abc123def456",
 					LineLengths:  ptr(valuecounts.Count([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})),
-					Js: &staticanalysis.JsData{
+					Js: &staticanalysis.ParseData{
 						Identifiers: []token.Identifier{
 							{
 								Name:    "myvar",
@@ -236,11 +236,71 @@ func TestResult_ToAPIResults(t *testing.T) {
 				},
 			}},
 		},
+		{
+			name: "archives",
+			result: Result{
+				Archives: []ArchiveResult{
+					{
+						Filename:     "pkg-1.0.tar.gz",
+						Type:         "sdist",
+						DetectedType: "gzip compressed data",
+						Size:         1234,
+						SHA256:       "abc123",
+					},
+					{
+						Filename: "pkg-1.0-py3-none-any.whl",
+						Type:     "wheel",
+						Checksum: &staticanalysis.ArchiveChecksum{
+							Algorithm: "sha256",
+							Expected:  "abc123",
+							Actual:    "abc123",
+							Verified:  true,
+						},
+					},
+				},
+				Files: []SingleResult{
+					{
+						Filename: "pkg-1.0.tar.gz/setup.py",
+					},
+				},
+			},
+			want: &staticanalysis.Results{
+				Archives: []staticanalysis.ArchiveResult{
+					{
+						Filename:     "pkg-1.0.tar.gz",
+						Type:         "sdist",
+						DetectedType: "gzip compressed data",
+						Size:         1234,
+						SHA256:       "abc123",
+					},
+					{
+						Filename: "pkg-1.0-py3-none-any.whl",
+						Type:     "wheel",
+						Checksum: &staticanalysis.ArchiveChecksum{
+							Algorithm: "sha256",
+							Expected:  "abc123",
+							Actual:    "abc123",
+							Verified:  true,
+						},
+					},
+				},
+				Files: []staticanalysis.FileResult{
+					{
+						Filename: "pkg-1.0.tar.gz/setup.py",
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			r := tt.result
 			got := r.ToAPIResults()
+			if (got.Package != nil) != (len(r.Files) > 0) {
+				t.Errorf("ToAPIResults() Package = %v, want summary only if there are files", got.Package)
+			}
+			// the contents of the package summary are checked by TestSummarisePackage
+			got.Package = nil
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("ToAPIResults() mismatch\ngot\n%v\nwant\n%v", got, tt.want)
 			}
@@ -41,22 +41,26 @@ func AnalyzeSingle(parseData parsing.SingleResult) FileSignals {
 		SuspiciousIdentifiers: []staticanalysis.SuspiciousIdentifier{},
 		URLs:                  []string{},
 		IPAddresses:           []string{},
+		DecodedStrings:        []staticanalysis.DecodedString{},
+		SuspiciousCalls:       []staticanalysis.SuspiciousCall{},
 	}
 
 	for _, name := range identifierNames {
 		for rule, pattern := range detections.SuspiciousIdentifierPatterns {
 			if pattern.MatchString(name) {
-				signals.SuspiciousIdentifiers = append(signals.SuspiciousIdentifiers, staticanalysis.SuspiciousIdentifier{name, rule})
+				signals.SuspiciousIdentifiers = append(signals.SuspiciousIdentifiers, staticanalysis.SuspiciousIdentifier{Name: name, Rule: rule})
 				break // don't bother searching for multiple matching rules
 			}
 		}
 	}
 
+	decodeBudget := detections.NewDecodeBudget()
 	for _, sl := range parseData.StringLiterals {
 		signals.Base64Strings = append(signals.Base64Strings, detections.FindBase64Substrings(sl.Value)...)
 		signals.HexStrings = append(signals.HexStrings, detections.FindHexSubstrings(sl.Value)...)
 		signals.URLs = append(signals.URLs, detections.FindURLs(sl.Value)...)
 		signals.IPAddresses = append(signals.IPAddresses, detections.FindIPAddresses(sl.Value)...)
+		signals.DecodedStrings = append(signals.DecodedStrings, detections.DecodeStrings(sl.Value, decodeBudget)...)
 		if detections.IsHighlyEscaped(sl, 8, 0.25) {
 			escapedString := staticanalysis.EscapedString{
 				Value:           sl.Value,
@@ -67,5 +71,17 @@ func AnalyzeSingle(parseData parsing.SingleResult) FileSignals {
 		}
 	}
 
+	for _, c := range parseData.Calls {
+		if category, ok := detections.ClassifyCall(c); ok {
+			suspiciousCall := staticanalysis.SuspiciousCall{
+				Callee:    c.Callee,
+				Category:  category,
+				Arguments: c.Arguments,
+				Pos:       c.Pos,
+			}
+			signals.SuspiciousCalls = append(signals.SuspiciousCalls, suspiciousCall)
+		}
+	}
+
 	return signals
 }
@@ -0,0 +1,385 @@
+package detections
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+const (
+	// maxDecodeDepth is the maximum number of encodings that are removed from a string.
+	maxDecodeDepth = 5
+
+	// maxDecodedBytesPerString limits the total size of the data decoded and
+	// decompressed from one string, in bytes, across all layers of encoding.
+	maxDecodedBytesPerString = 256 << 10
+
+	// maxDecodedBytesPerFile limits the total size of the data decoded and
+	// decompressed from the strings of one file, in bytes.
+	maxDecodedBytesPerFile = 4 << 20
+
+	// maxDecodedStringsPerFile limits the number of decoded strings found in one file.
+	maxDecodedStringsPerFile = 1000
+
+	// maxDecodedTextLength is the length, in bytes, that the decoded text of a
+	// DecodedString is truncated to.
+	maxDecodedTextLength = 4 << 10
+
+	// minPrintableFraction is the fraction of characters of decoded data which
+	// must be printable for it to be treated as text. Binary data is discarded,
+	// since most of it comes from strings which only look encoded, such as words
+	// matching the base64 pattern.
+	minPrintableFraction = 0.9
+)
+
+var (
+	// urlEncodedRegex matches runs of URL-safe characters that contain at least
+	// 3 percent-encoded bytes, e.g. "https%3A%2F%2Fexample.com".
+	urlEncodedRegex = regexp.MustCompile(`[\w.~*'()!+/:?&=-]*(?:%[[:xdigit:]]{2}[\w.~*'()!+/:?&=-]*){3,}`)
+
+	// charCodesRegex matches lists of at least 4 decimal or hexadecimal numbers,
+	// such as the arguments of String.fromCharCode() or chr() calls.
+	charCodesRegex = regexp.MustCompile(`\b(?:(?:0x[[:xdigit:]]{1,6}|\d{1,7})\s*,\s*){3,}(?:0x[[:xdigit:]]{1,6}|\d{1,7})\b`)
+
+	// escapesRegex matches runs of at least 4 escape sequences (see allEscapeSequences).
+	escapesRegex = regexp.MustCompile(`(?:\\(?:x[[:xdigit:]]{2}|u[[:xdigit:]]{4}|u\{[[:xdigit:]]+}|U[[:xdigit:]]{8}|[0-7]{1,3})){4,}`)
+
+	// escapeRegex matches a single escape sequence, capturing its digits.
+	escapeRegex = regexp.MustCompile(`\\(?:x([[:xdigit:]]{2})|u([[:xdigit:]]{4})|u\{([[:xdigit:]]+)}|U([[:xdigit:]]{8})|([0-7]{1,3}))`)
+)
+
+// decoder finds and decodes substrings of a string which use one encoding.
+type decoder struct {
+	encoding staticanalysis.Encoding
+	// find returns the substrings of s which may be encoded.
+	find func(s string) []string
+	// decode returns the decoded data, or false if the candidate is not validly encoded.
+	decode func(candidate string) ([]byte, bool)
+	// revealsOnly is true for encodings which any text can be decoded from, e.g.
+	// rot13. Decoded text is only kept if it has URLs or suspicious content that
+	// the encoded text does not.
+	revealsOnly bool
+}
+
+var decoders = []decoder{
+	{encoding: staticanalysis.Base64Encoding, find: FindBase64Substrings, decode: decodeBase64},
+	{encoding: staticanalysis.HexEncoding, find: FindHexSubstrings, decode: decodeHex},
+	{encoding: staticanalysis.URLEncoding, find: findAll(urlEncodedRegex), decode: decodeURL},
+	{encoding: staticanalysis.CharCodesEncoding, find: findAll(charCodesRegex), decode: decodeCharCodes},
+	{encoding: staticanalysis.EscapesEncoding, find: findAll(escapesRegex), decode: decodeEscapes},
+	{encoding: staticanalysis.ROT13Encoding, find: findLetters, decode: decodeROT13, revealsOnly: true},
+}
+
+func findAll(r *regexp.Regexp) func(s string) []string {
+	return func(s string) []string {
+		return r.FindAllString(s, -1)
+	}
+}
+
+// findLetters returns s if it has at least 8 letters, otherwise nothing.
+func findLetters(s string) []string {
+	letters := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < 8 {
+		return nil
+	}
+	return []string{s}
+}
+
+func decodeBase64(candidate string) ([]byte, bool) {
+	candidate = strings.TrimRight(candidate, "=")
+	for _, encoding := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(candidate); err == nil {
+			return decoded, true
+		}
+	}
+	return nil, false
+}
+
+func decodeHex(candidate string) ([]byte, bool) {
+	decoded, err := hex.DecodeString(candidate)
+	return decoded, err == nil
+}
+
+func decodeURL(candidate string) ([]byte, bool) {
+	decoded, err := url.PathUnescape(candidate)
+	return []byte(decoded), err == nil
+}
+
+func decodeCharCodes(candidate string) ([]byte, bool) {
+	var decoded []rune
+	for _, code := range strings.Split(candidate, ",") {
+		r, err := strconv.ParseInt(strings.TrimSpace(code), 0, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return nil, false
+		}
+		decoded = append(decoded, rune(r))
+	}
+	return []byte(string(decoded)), true
+}
+
+// decodeEscapes decodes escape sequences. Hex and octal escapes are decoded to
+// bytes, since they often encode UTF-8 byte by byte, and the others to characters.
+func decodeEscapes(candidate string) ([]byte, bool) {
+	var decoded []byte
+	for _, m := range escapeRegex.FindAllStringSubmatch(candidate, -1) {
+		switch {
+		case m[1] != "":
+			b, _ := strconv.ParseUint(m[1], 16, 8)
+			decoded = append(decoded, byte(b))
+		case m[5] != "":
+			b, err := strconv.ParseUint(m[5], 8, 8)
+			if err != nil {
+				return nil, false
+			}
+			decoded = append(decoded, byte(b))
+		default:
+			r, err := strconv.ParseUint(m[2]+m[3]+m[4], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return nil, false
+			}
+			decoded = utf8.AppendRune(decoded, rune(r))
+		}
+	}
+	return decoded, true
+}
+
+func decodeROT13(candidate string) ([]byte, bool) {
+	return []byte(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+		return r
+	}, candidate)), true
+}
+
+// decompress decompresses data that starts with a gzip or zlib header, and
+// returns false if data is not compressed. At most limit+1 bytes are decompressed,
+// so that callers can tell if data decompresses to more than limit bytes.
+func decompress(data []byte, limit int) ([]byte, staticanalysis.Encoding, bool) {
+	var reader io.ReadCloser
+	var encoding staticanalysis.Encoding
+	var err error
+	switch {
+	case len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b:
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+		encoding = staticanalysis.GzipEncoding
+	case len(data) > 2 && data[0]&0x0f == 8 && (uint(data[0])<<8|uint(data[1]))%31 == 0:
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+		encoding = staticanalysis.ZlibEncoding
+	default:
+		return nil, "", false
+	}
+	if err != nil {
+		return nil, "", false
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, "", false
+	}
+	return decompressed, encoding, true
+}
+
+// isText returns true if data is valid UTF-8 and mostly printable.
+func isText(data []byte) bool {
+	if len(data) == 0 || !utf8.Valid(data) {
+		return false
+	}
+	total, printable := 0, 0
+	for _, r := range string(data) {
+		total++
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			printable++
+		}
+	}
+	return float64(printable) >= minPrintableFraction*float64(total)
+}
+
+// hasFindings returns true if s contains URLs or suspicious content.
+func hasFindings(s string) bool {
+	return len(FindURLs(s)) > 0 || len(FindSuspiciousContent(s)) > 0
+}
+
+/*
+DecodeBudget limits the decoding done by DecodeStrings for the string literals
+of one file, so that strings which decode or decompress to large amounts of data
+(e.g. nested compression bombs) cannot make analysis slow or its results large.
+*/
+type DecodeBudget struct {
+	bytes   int
+	results int
+}
+
+// NewDecodeBudget returns a DecodeBudget for the strings of one file.
+func NewDecodeBudget() *DecodeBudget {
+	return &DecodeBudget{bytes: maxDecodedBytesPerFile, results: maxDecodedStringsPerFile}
+}
+
+// decodeState tracks the data decoded from one string. Once either the string's
+// or the file's budget is exhausted, decoding stops.
+type decodeState struct {
+	file      *DecodeBudget
+	bytes     int
+	exhausted bool
+}
+
+// limit returns the number of bytes that may still be decoded.
+func (st *decodeState) limit() int {
+	return min(st.bytes, st.file.bytes)
+}
+
+// spend deducts n decoded bytes from the budgets, and returns false if they are exhausted.
+func (st *decodeState) spend(n int) bool {
+	if st.exhausted || n > st.limit() {
+		st.exhausted = true
+		return false
+	}
+	st.bytes -= n
+	st.file.bytes -= n
+	return true
+}
+
+// addResult counts a decoded string against the file's budget, and returns false if it is exhausted.
+func (st *decodeState) addResult() bool {
+	if st.file.results <= 0 {
+		st.exhausted = true
+		return false
+	}
+	st.file.results--
+	return true
+}
+
+func (st *decodeState) tryDecode(d decoder, candidate string) ([]byte, bool) {
+	if st.exhausted {
+		return nil, false
+	}
+	decoded, ok := d.decode(candidate)
+	if !ok {
+		return nil, false
+	}
+	// Decoders like rot13 decode most strings, so only the results which are kept
+	// are charged to the budget.
+	if d.revealsOnly && (!hasFindings(string(decoded)) || hasFindings(candidate)) {
+		return nil, false
+	}
+	if !st.spend(len(decoded)) {
+		return nil, false
+	}
+	return decoded, true
+}
+
+/*
+decodeLayers removes further encodings from data, which was decoded from the
+substring encoded of a string literal by removing the encodings in chain, and
+returns the decoded strings found in it. If data is compressed it is decompressed.
+Otherwise, if it is text, the result for data is followed by the results for
+any encoded substrings of it, unless the whole text is encoded, in which case
+only the results for the decoded text are returned.
+*/
+func (st *decodeState) decodeLayers(encoded string, data []byte, chain []staticanalysis.Encoding) []staticanalysis.DecodedString {
+	if len(chain) < maxDecodeDepth {
+		if decompressed, encoding, ok := decompress(data, st.limit()); ok {
+			if !st.spend(len(decompressed)) {
+				return nil
+			}
+			return st.decodeLayers(encoded, decompressed, append(slices.Clip(chain), encoding))
+		}
+	}
+	if !isText(data) {
+		return nil
+	}
+
+	text := string(data)
+	var nested []staticanalysis.DecodedString
+	whollyEncoded := false
+	if len(chain) < maxDecodeDepth {
+		for _, d := range decoders {
+			// rot13 is its own inverse
+			if d.encoding == staticanalysis.ROT13Encoding && chain[len(chain)-1] == staticanalysis.ROT13Encoding {
+				continue
+			}
+			for _, candidate := range d.find(text) {
+				if decoded, ok := st.tryDecode(d, candidate); ok {
+					whollyEncoded = whollyEncoded || candidate == strings.TrimSpace(text)
+					nested = append(nested, st.decodeLayers(encoded, decoded, append(slices.Clip(chain), d.encoding))...)
+				}
+			}
+		}
+	}
+	if whollyEncoded || !st.addResult() {
+		return nested
+	}
+
+	result := staticanalysis.DecodedString{
+		Encoded:           encoded,
+		Decoded:           truncate(text, maxDecodedTextLength),
+		DecodedLength:     len(text),
+		Chain:             chain,
+		URLs:              FindURLs(text),
+		IPAddresses:       FindIPAddresses(text),
+		SuspiciousContent: FindSuspiciousContent(text),
+	}
+	return append([]staticanalysis.DecodedString{result}, nested...)
+}
+
+/*
+DecodeStrings finds substrings of s which are encoded, and decodes them. The
+encodings supported are base64, hex, URL encoding (percent-encoding), lists of
+character codes, escape sequences and rot13, as well as gzip and zlib compression
+of data decoded by one of the others. Decoding is recursive, so that e.g. base64
+encoded text inside hex encoded text is found, up to maxDecodeDepth encodings.
+
+Each decoded string records the encodings removed and the URLs, IP addresses
+and suspicious content (see FindSuspiciousContent) found in the decoded text.
+Decoded data that is not text is discarded, as are the intermediate results
+of decoding text which is entirely encoded.
+
+The data decoded from s, and from all strings decoded using the same budget, is
+limited in total size and in the number of decoded strings, after which no more
+strings are decoded. Decoded text is truncated to maxDecodedTextLength bytes.
+*/
+func DecodeStrings(s string, budget *DecodeBudget) []staticanalysis.DecodedString {
+	results := []staticanalysis.DecodedString{}
+	st := &decodeState{file: budget, bytes: maxDecodedBytesPerString}
+	for _, d := range decoders {
+		for _, candidate := range d.find(s) {
+			if decoded, ok := st.tryDecode(d, candidate); ok {
+				results = append(results, st.decodeLayers(candidate, decoded, []staticanalysis.Encoding{d.encoding})...)
+			}
+		}
+	}
+	return results
+}
+
+// truncate returns the first n bytes of s, without splitting a UTF-8 encoded character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
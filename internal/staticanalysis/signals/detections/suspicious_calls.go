@@ -0,0 +1,105 @@
+package detections
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis/token"
+)
+
+// callRule matches calls to a set of functions which can be used for the same
+// (potentially malicious) purpose.
+type callRule struct {
+	category staticanalysis.SuspiciousCallCategory
+	// callees lists the callee paths matched by the rule. A path ending in ".*"
+	// matches every function under the path before it, e.g. "fs.*" matches
+	// fs.readFile and fs.promises.readFile.
+	callees []string
+	// matchArgs, if not nil, is called with the argument kinds of a call to one of
+	// the callees, and must return true for the call to match the rule.
+	matchArgs func(args []token.ArgumentKind) bool
+}
+
+func (r callRule) matchCallee(callee string) bool {
+	return slices.ContainsFunc(r.callees, func(c string) bool {
+		if prefix, ok := strings.CutSuffix(c, "*"); ok {
+			return strings.HasPrefix(callee, prefix)
+		}
+		return callee == c
+	})
+}
+
+// firstArgIs returns a function that checks whether the first argument of a call has the given kind.
+func firstArgIs(kind token.ArgumentKind) func(args []token.ArgumentKind) bool {
+	return func(args []token.ArgumentKind) bool {
+		return len(args) > 0 && args[0] == kind
+	}
+}
+
+/*
+suspiciousCallRules lists the functions which are reported as suspicious calls,
+according to the Node.js and browser APIs. Calls to these functions are common
+in benign code too, but they are the means by which malicious code does harm, so
+their presence tells what a file is capable of doing. The first matching rule
+determines the category of a call. Reads of process.env are recorded by the parser
+as calls with no arguments, so they are matched by the rules in the same way.
+*/
+var suspiciousCallRules = []callRule{
+	{
+		category: staticanalysis.CodeExecution,
+		callees: []string{
+			"eval", "Function",
+			"vm.runInThisContext", "vm.runInNewContext", "vm.runInContext",
+			"vm.compileFunction", "vm.Script", "vm.SourceTextModule",
+		},
+	},
+	{
+		// these run code from a string when given one instead of a function
+		category:  staticanalysis.CodeExecution,
+		callees:   []string{"setTimeout", "setInterval"},
+		matchArgs: firstArgIs(token.LiteralArgument),
+	},
+	{
+		category: staticanalysis.ProcessSpawn,
+		callees: []string{
+			"child_process.exec", "child_process.execSync", "child_process.execFile",
+			"child_process.execFileSync", "child_process.spawn", "child_process.spawnSync",
+			"child_process.fork", "process.dlopen", "process.binding",
+		},
+	},
+	{
+		category: staticanalysis.Network,
+		callees: []string{
+			"http.*", "https.*", "http2.*", "net.*", "tls.*", "dgram.*", "dns.*",
+			"fetch", "XMLHttpRequest", "WebSocket", "navigator.sendBeacon",
+		},
+	},
+	{
+		category: staticanalysis.Filesystem,
+		callees:  []string{"fs.*", "fs/promises.*"},
+	},
+	{
+		category: staticanalysis.EnvAccess,
+		callees: []string{
+			"process.env", "process.env.*", "os.userInfo", "os.hostname", "os.homedir", "os.networkInterfaces",
+		},
+	},
+	{
+		category:  staticanalysis.DynamicRequire,
+		callees:   []string{"require", "import", "module.require"},
+		matchArgs: firstArgIs(token.DynamicArgument),
+	},
+}
+
+// ClassifyCall returns the category of a suspicious function call, and true, or
+// false if the call does not match any of the rules in suspiciousCallRules.
+func ClassifyCall(call token.Call) (staticanalysis.SuspiciousCallCategory, bool) {
+	for _, rule := range suspiciousCallRules {
+		if rule.matchCallee(call.Callee) && (rule.matchArgs == nil || rule.matchArgs(call.Arguments)) {
+			return rule.category, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,103 @@
+package detections
+
+import (
+	"testing"
+
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis"
+	"github.com/ossf/package-analysis/pkg/api/staticanalysis/token"
+)
+
+func TestClassifyCall(t *testing.T) {
+	literal := []token.ArgumentKind{token.LiteralArgument}
+	dynamic := []token.ArgumentKind{token.DynamicArgument}
+
+	tests := []struct {
+		name      string
+		call      token.Call
+		want      staticanalysis.SuspiciousCallCategory
+		wantMatch bool
+	}{
+		{
+			name:      "eval",
+			call:      token.Call{Callee: "eval", Arguments: dynamic},
+			want:      staticanalysis.CodeExecution,
+			wantMatch: true,
+		},
+		{
+			name:      "setTimeout with string",
+			call:      token.Call{Callee: "setTimeout", Arguments: literal},
+			want:      staticanalysis.CodeExecution,
+			wantMatch: true,
+		},
+		{
+			name:      "setTimeout with function",
+			call:      token.Call{Callee: "setTimeout", Arguments: dynamic},
+			wantMatch: false,
+		},
+		{
+			name:      "child_process",
+			call:      token.Call{Callee: "child_process.execSync", Arguments: literal},
+			want:      staticanalysis.ProcessSpawn,
+			wantMatch: true,
+		},
+		{
+			name:      "https",
+			call:      token.Call{Callee: "https.request", Arguments: dynamic},
+			want:      staticanalysis.Network,
+			wantMatch: true,
+		},
+		{
+			name:      "fs promises",
+			call:      token.Call{Callee: "fs.promises.writeFile", Arguments: dynamic},
+			want:      staticanalysis.Filesystem,
+			wantMatch: true,
+		},
+		{
+			name:      "fs prefix only",
+			call:      token.Call{Callee: "fsevents.watch", Arguments: dynamic},
+			wantMatch: false,
+		},
+		{
+			name:      "os",
+			call:      token.Call{Callee: "os.userInfo"},
+			want:      staticanalysis.EnvAccess,
+			wantMatch: true,
+		},
+		{
+			name:      "process.env property",
+			call:      token.Call{Callee: "process.env.NPM_TOKEN"},
+			want:      staticanalysis.EnvAccess,
+			wantMatch: true,
+		},
+		{
+			name:      "process.env",
+			call:      token.Call{Callee: "process.env"},
+			want:      staticanalysis.EnvAccess,
+			wantMatch: true,
+		},
+		{
+			name:      "dynamic require",
+			call:      token.Call{Callee: "require", Arguments: dynamic},
+			want:      staticanalysis.DynamicRequire,
+			wantMatch: true,
+		},
+		{
+			name:      "static require",
+			call:      token.Call{Callee: "require", Arguments: literal},
+			wantMatch: false,
+		},
+		{
+			name:      "unknown callee",
+			call:      token.Call{Callee: "?.exec", Arguments: literal},
+			wantMatch: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ClassifyCall(tt.call)
+			if ok != tt.wantMatch || got != tt.want {
+				t.Errorf("ClassifyCall() = (%v, %v); want (%v, %v)", got, ok, tt.want, tt.wantMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package detections
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// DownloadPattern matches commands and APIs used to download content, in shell
+	// commands and in each of the languages supported by static analysis.
+	DownloadPattern = regexp.MustCompile(`(?i)\b(curl|wget|Invoke-WebRequest|iwr|bitsadmin|fetch|urlopen|urlretrieve|urllib|` +
+		`requests\.(get|post)|https?\.(get|request)|URI\.open|Net::HTTP|reqwest|ureq)\b|` +
+		`certutil\s+-urlcache|open-uri|file_get_contents\(\s*['"]https?:`)
+
+	// ExecPattern matches commands and APIs used to start processes, or to run
+	// code which is given inline or decoded at runtime. Methods such as exec()
+	// are not matched when called on an object, since RegExp.exec() is common;
+	// calls to child_process methods are matched by the module name instead.
+	ExecPattern = regexp.MustCompile(`(?i)\b(sh|bash|zsh|cmd|powershell|pwsh)(\.exe)?\s+[-/](c|e|enc|encodedcommand)\b|` +
+		`\|\s*(sh|bash|zsh|python3?|node|perl|ruby|php)\b|\b(node|python3?|ruby|perl|php)\s+-(e|c|r)\b|` +
+		`(^|[^.\w$])(eval|exec|execSync|execFile|execFileSync|spawn|spawnSync|system|popen|shell_exec|passthru|proc_open)\s*\(|` +
+		`\bchild_process\b|\bsubprocess\b|\bos\.(system|popen)\b|Command::new|%x[({\[]|\bbase64\s+(-d|--decode)\b`)
+)
+
+// FindSuspiciousContent returns the substrings of s which match DownloadPattern
+// or ExecPattern, i.e. code or commands which download content or run processes.
+func FindSuspiciousContent(s string) []string {
+	var matches []string
+	for _, pattern := range []*regexp.Regexp{DownloadPattern, ExecPattern} {
+		for _, m := range pattern.FindAllString(s, -1) {
+			// ExecPattern also matches the character before a function name
+			if r, size := utf8.DecodeRuneInString(m); !unicode.IsLetter(r) && r != '|' && r != '%' {
+				m = m[size:]
+			}
+			matches = append(matches, strings.TrimSpace(m))
+		}
+	}
+	return matches
+}
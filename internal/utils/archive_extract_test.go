@@ -2,6 +2,7 @@ package utils
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"compress/gzip"
 	"fmt"
 	"os"
@@ -335,3 +336,301 @@ func TestExtractZipSlip3(t *testing.T) {
 		t.Errorf("Error should be about path escaping output dir, instead got %v", err)
 	}
 }
+
+func createZipFile(path string, names []string) (err error) {
+	zipFile, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create temp archive file: %w", err)
+	}
+
+	defer func() {
+		closeErr := zipFile.Close()
+		if closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close temp archive file: %w", closeErr)
+		}
+	}()
+
+	zipWriter := zip.NewWriter(zipFile)
+	for _, name := range names {
+		w, err := zipWriter.Create(name)
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(name, "/") {
+			if _, err := w.Write([]byte(name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+func TestExtractSimpleZipFile(t *testing.T) {
+	workDir, _, extractPath, _ := makePaths(t, "simple-zip")
+	archivePath := filepath.Join(workDir, "simple.zip")
+
+	if err := createZipFile(archivePath, []string{"lib/", "lib/a.dll", "tools/install.ps1"}); err != nil {
+		t.Fatalf("failed to create test zip file: %v", err)
+	}
+
+	if err := ExtractZipFile(archivePath, extractPath); err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+
+	for _, name := range []string{"lib/a.dll", "tools/install.ps1"} {
+		contents, err := os.ReadFile(filepath.Join(extractPath, name))
+		if err != nil {
+			t.Errorf("read extracted file: %v", err)
+		} else if string(contents) != name {
+			t.Errorf("extracted file %s has contents %q, want %q", name, contents, name)
+		}
+	}
+}
+
+func TestExtractZipSlipZipFile(t *testing.T) {
+	workDir, _, extractPath, _ := makePaths(t, "zipslip-zip")
+	archivePath := filepath.Join(workDir, "zipslip.zip")
+
+	if err := createZipFile(archivePath, []string{"test/../../bad.txt"}); err != nil {
+		t.Fatalf("failed to create test zip file: %v", err)
+	}
+
+	err := ExtractZipFile(archivePath, extractPath)
+	if err == nil || !strings.Contains(err.Error(), "archive path escapes output dir") {
+		t.Errorf("Error should be about path escaping output dir, instead got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "bad.txt")); err == nil {
+		t.Errorf("Found file in parent directory")
+	}
+}
+
+func TestExtractZipFileSymlinks(t *testing.T) {
+	workDir, _, extractPath, _ := makePaths(t, "symlink-zip")
+	archivePath := filepath.Join(workDir, "symlink.zip")
+
+	zipFile, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("failed to create temp archive file: %v", err)
+	}
+	zipWriter := zip.NewWriter(zipFile)
+	entries := []struct {
+		name, contents string
+		mode           os.FileMode
+	}{
+		// link listed before its target
+		{name: "repo/README", contents: "docs/README.md", mode: os.ModeSymlink | 0o777},
+		{name: "repo/docs/README.md", contents: "readme", mode: 0o644},
+		{name: "repo/escape", contents: "../../outside.txt", mode: os.ModeSymlink | 0o777},
+		{name: "repo/pipe", mode: os.ModeNamedPipe | 0o644},
+	}
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		header.SetMode(e.mode)
+		w, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zipFile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExtractZipFile(archivePath, extractPath); err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+
+	linkPath := filepath.Join(extractPath, "repo", "README")
+	if info, err := os.Lstat(linkPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected repo/README to be a symlink (lstat error: %v)", err)
+	}
+	if contents, err := os.ReadFile(linkPath); err != nil || string(contents) != "readme" {
+		t.Errorf("read through symlink = %q, %v; want %q", contents, err, "readme")
+	}
+	for _, name := range []string{"repo/escape", "repo/pipe"} {
+		if _, err := os.Lstat(filepath.Join(extractPath, name)); err == nil {
+			t.Errorf("entry %s should have been skipped", name)
+		}
+	}
+}
+
+// makeLinkHeader initialises a record for a hard link or symlink entry in a tar file.
+func makeLinkHeader(typeflag byte, name, target string) *tar.Header {
+	return &tar.Header{
+		Typeflag: typeflag,
+		Name:     name,
+		Linkname: target,
+		Mode:     0o777,
+		Uid:      os.Geteuid(),
+		Gid:      os.Getegid(),
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	testName := "links"
+
+	_, archivePath, extractPath, err := makePaths(t, testName)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	testHeaders := []*tar.Header{
+		// links listed before their target
+		makeLinkHeader(tar.TypeSymlink, "test/link.txt", "1.txt"),
+		makeLinkHeader(tar.TypeSymlink, "up.txt", "test/../test/1.txt"),
+		makeLinkHeader(tar.TypeLink, "test/hard.txt", "test/1.txt"),
+		makeFileHeader("test/1.txt", 10),
+		makeLinkHeader(tar.TypeSymlink, "test/sub/parent.txt", "../1.txt"),
+	}
+
+	err = doExtractionTest(archivePath, extractPath, testHeaders, func() error {
+		for _, name := range []string{"test/link.txt", "test/sub/parent.txt"} {
+			linkInfo, err := os.Lstat(filepath.Join(extractPath, name))
+			if err != nil {
+				return fmt.Errorf("lstat symlink %s: %w", name, err)
+			}
+			if linkInfo.Mode()&os.ModeSymlink == 0 {
+				return fmt.Errorf("expected %s to be a symlink", name)
+			}
+			fileInfo, err := os.Stat(filepath.Join(extractPath, name))
+			if err != nil {
+				return fmt.Errorf("stat symlink %s: %w", name, err)
+			}
+			if fileInfo.Size() != 10 {
+				return fmt.Errorf("expected symlink %s to point to file with size 10 but it has size %d", name, fileInfo.Size())
+			}
+		}
+
+		if _, err := os.Lstat(filepath.Join(extractPath, "up.txt")); err == nil {
+			return fmt.Errorf("symlink with .. after a name should have been skipped")
+		}
+
+		hardInfo, err := os.Lstat(filepath.Join(extractPath, "test", "hard.txt"))
+		if err != nil {
+			return fmt.Errorf("lstat hard link: %w", err)
+		}
+		if !hardInfo.Mode().IsRegular() || hardInfo.Size() != 10 {
+			return fmt.Errorf("expected hard link to be a regular file with size 10")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Error: %v", err)
+	}
+}
+
+func TestExtractEscapingLinks(t *testing.T) {
+	testName := "escaping-links"
+
+	workDir, archivePath, extractPath, err := makePaths(t, testName)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+
+	outsideFile := filepath.Join(workDir, "outside.txt")
+	if err := os.WriteFile(outsideFile, []byte("secret"), 0o600); err != nil {
+		t.Fatalf("failed to create file outside extract dir: %v", err)
+	}
+
+	testHeaders := []*tar.Header{
+		makeLinkHeader(tar.TypeSymlink, "abs.txt", outsideFile),
+		makeLinkHeader(tar.TypeSymlink, "rel.txt", "../outside.txt"),
+		makeLinkHeader(tar.TypeSymlink, "dir", ".."),
+		makeLinkHeader(tar.TypeSymlink, "test/shallow", "../test2"),
+		makeLinkHeader(tar.TypeSymlink, "test/shallow/deep.txt", "../../outside.txt"),
+		makeLinkHeader(tar.TypeLink, "hard.txt", "../outside.txt"),
+	}
+
+	err = doExtractionTest(archivePath, extractPath, testHeaders, func() error {
+		for _, name := range []string{"abs.txt", "rel.txt", "dir", "test/shallow/deep.txt", "test2/deep.txt", "hard.txt"} {
+			if _, err := os.Lstat(filepath.Join(extractPath, name)); err == nil {
+				return fmt.Errorf("link %s should have been skipped", name)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Error: %v", err)
+	}
+}
+
+// createGemFile creates a .gem file containing a data.tar.gz archive with the
+// given headers and a gzipped metadata file.
+func createGemFile(path string, dataHeaders []*tar.Header, metadata string) error {
+	dataPath := path + ".data.tar.gz"
+	if err := createTgzFile(dataPath, dataHeaders); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return err
+	}
+
+	var metadataGz strings.Builder
+	gzWriter := gzip.NewWriter(&metadataGz)
+	if _, err := gzWriter.Write([]byte(metadata)); err != nil {
+		return err
+	}
+	if err := gzWriter.Close(); err != nil {
+		return err
+	}
+
+	gemFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer gemFile.Close()
+
+	tarWriter := tar.NewWriter(gemFile)
+	for name, contents := range map[string]string{"metadata.gz": metadataGz.String(), "data.tar.gz": string(data)} {
+		if err := tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Size: int64(len(contents)), Mode: 0o444}); err != nil {
+			return err
+		}
+		if _, err := tarWriter.Write([]byte(contents)); err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+func TestExtractGemFile(t *testing.T) {
+	workDir, _, extractPath, _ := makePaths(t, "gem")
+	archivePath := filepath.Join(workDir, "test-1.0.gem")
+
+	testHeaders := []*tar.Header{
+		makeDirHeader("lib"),
+		makeFileHeader("lib/test.rb", 10),
+	}
+	if err := createGemFile(archivePath, testHeaders, "--- !ruby/object:Gem::Specification\nname: test\n"); err != nil {
+		t.Fatalf("failed to create test gem file: %v", err)
+	}
+
+	if err := ExtractGemFile(archivePath, extractPath); err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+
+	fileInfo, err := os.Stat(filepath.Join(extractPath, "data", "lib", "test.rb"))
+	if err != nil {
+		t.Errorf("stat extracted file: %v", err)
+	} else if fileInfo.Size() != 10 {
+		t.Errorf("expected to extract file with size 10 but it has size %d", fileInfo.Size())
+	}
+
+	metadata, err := os.ReadFile(filepath.Join(extractPath, "metadata"))
+	if err != nil {
+		t.Errorf("read extracted metadata: %v", err)
+	} else if !strings.Contains(string(metadata), "name: test") {
+		t.Errorf("extracted metadata has unexpected contents %q", metadata)
+	}
+}
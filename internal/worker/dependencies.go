@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/ossf/package-analysis/internal/sandbox"
+	"github.com/ossf/package-analysis/pkg/api/analysisrun"
+)
+
+// sandboxDependenciesPath is the absolute path of the file inside the sandbox which
+// lists the packages installed during the install phase, with their dependencies.
+const sandboxDependenciesPath = "/dependencies.json"
+
+// dependenciesJSON is the format of the file at sandboxDependenciesPath.
+// Field names are matched case-insensitively.
+type dependenciesJSON struct {
+	Packages []analysisrun.InstalledPackage `json:"packages"`
+}
+
+// retrieveDependencies copies the list of installed packages back from the sandbox to
+// the host. If the list is not present (e.g. the analysis command for the ecosystem
+// does not produce it), no packages are returned.
+func retrieveDependencies(ctx context.Context, sb sandbox.Sandbox) ([]analysisrun.InstalledPackage, error) {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(dir)
+	hostPath := filepath.Join(dir, "dependencies.json")
+
+	if err := sb.CopyBackToHost(ctx, hostPath, sandboxDependenciesPath); err != nil {
+		slog.WarnContext(ctx, "Could not retrieve dependencies from sandbox", "error", err)
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(hostPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var deps dependenciesJSON
+	if err := json.Unmarshal(data, &deps); err != nil {
+		return nil, fmt.Errorf("failed to parse dependencies: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Read dependencies", "package_count", len(deps.Packages))
+	return deps.Packages, nil
+}
@@ -0,0 +1,249 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/ossf/package-analysis/internal/analysis"
+	"github.com/ossf/package-analysis/internal/sandbox"
+)
+
+/*
+EnvironmentProfile is a persona for the environment that dynamic analysis runs in,
+such as a CI runner or a developer's laptop. Malicious packages often check for
+signs of a particular environment (e.g. the CI environment variable, the hostname
+or username, files in the home directory, or a cloud metadata server) before acting.
+
+Honeytokens (see Honeytokens), such as cloud credentials and SSH keys, are planted in
+the home directory in all profiles, so profiles only add credentials that are specific
+to the environment.
+*/
+type EnvironmentProfile struct {
+	// Name identifies the profile.
+	Name string
+	// Hostname is the hostname of the sandbox.
+	Hostname string
+	// User is the name of the user running the analysis. The user has the same uid as
+	// root, and its home directory /home/User is a link to /root.
+	User string
+	// Env holds environment variables to set in the sandbox.
+	Env map[string]string
+	// Files maps paths in the sandbox to the contents of files to create there. Paths
+	// starting with ~/ are relative to the home directory.
+	Files map[string]string
+	// Hosts maps hostnames to IP addresses, which are added to /etc/hosts.
+	Hosts map[string]string
+}
+
+// environmentProfiles holds the built-in profiles. Each profile is created when it is
+// used, so that credentials and other identifiers differ between analysis runs.
+var environmentProfiles = map[string]func() EnvironmentProfile{
+	"developer-laptop": developerLaptopProfile,
+	"gcp-vm":           gcpVMProfile,
+	"github-actions":   githubActionsProfile,
+}
+
+// EnvironmentProfileNames returns the names of the built-in environment profiles.
+func EnvironmentProfileNames() []string {
+	names := maps.Keys(environmentProfiles)
+	slices.Sort(names)
+	return names
+}
+
+// NewEnvironmentProfile returns the built-in environment profile with the given name.
+func NewEnvironmentProfile(name string) (EnvironmentProfile, error) {
+	newProfile, ok := environmentProfiles[name]
+	if !ok {
+		return EnvironmentProfile{}, fmt.Errorf("unknown environment profile %q (available: %s)", name, strings.Join(EnvironmentProfileNames(), ", "))
+	}
+	return newProfile(), nil
+}
+
+/*
+WithEnvironmentProfile runs dynamic analysis in the environment described by the
+profile. The environment is set up before the install phase, and applies to all
+phases.
+*/
+func WithEnvironmentProfile(profile EnvironmentProfile) DynamicAnalysisOption {
+	return dynamicAnalysisOption(func(c *dynamicAnalysisConfig) { c.profile = &profile })
+}
+
+func (p *EnvironmentProfile) homeDir() string {
+	if p.User == "" || p.User == "root" {
+		return "/root"
+	}
+	return "/home/" + p.User
+}
+
+// sandboxOptions returns the options for creating a sandbox with the profile's
+// hostname, hosts and environment variables.
+func (p *EnvironmentProfile) sandboxOptions() []sandbox.Option {
+	var opts []sandbox.Option
+	if p.Hostname != "" {
+		opts = append(opts, sandbox.Hostname(p.Hostname))
+	}
+	for host, ip := range p.Hosts {
+		opts = append(opts, sandbox.AddHost(host, ip))
+	}
+	if p.User != "" {
+		opts = append(opts,
+			sandbox.SetEnv("USER", p.User),
+			sandbox.SetEnv("LOGNAME", p.User),
+			sandbox.SetEnv("HOME", p.homeDir()))
+	}
+	for k, v := range p.Env {
+		opts = append(opts, sandbox.SetEnv(k, v))
+	}
+	return opts
+}
+
+// setUp creates the profile's user and files in the sandbox.
+func (p *EnvironmentProfile) setUp(ctx context.Context, sb sandbox.Sandbox) error {
+	if p.User != "" && p.User != "root" {
+		// Adding the user before root in /etc/passwd makes it the name of uid 0, so
+		// the package still has the permissions that the analysis commands rely on.
+		script := fmt.Sprintf("sed -i '1i %s:x:0:0::%s:/bin/bash' /etc/passwd && mkdir -p /home && ln -sfn /root %s",
+			p.User, p.homeDir(), p.homeDir())
+		r, err := sb.Run(ctx, "/bin/sh", "-c", script)
+		if err != nil {
+			return fmt.Errorf("failed to add user %s: %w", p.User, err)
+		}
+		if status := analysis.StatusForRunResult(r); status != analysis.StatusCompleted {
+			return fmt.Errorf("failed to add user %s: %s", p.User, status)
+		}
+	}
+
+	if len(p.Files) == 0 {
+		return nil
+	}
+
+	tempdir, err := os.MkdirTemp("", "profile_files")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	for path, contents := range p.Files {
+		// the home directory links to /root, which is where the files must be copied
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			path = "/root/" + rest
+		} else if rest, ok := strings.CutPrefix(path, p.homeDir()+"/"); ok {
+			path = "/root/" + rest
+		}
+		hostPath := filepath.Join(tempdir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(hostPath), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(hostPath, []byte(contents), 0o600); err != nil {
+			return err
+		}
+	}
+
+	return sb.CopyIntoSandbox(ctx, tempdir+"/.", "/")
+}
+
+// randomString returns a pseudorandom string of n characters from charSet.
+func randomString(charSet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = charSet[mathrand.Intn(len(charSet))]
+	}
+	return string(b)
+}
+
+const (
+	alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	hexDigits    = "0123456789abcdef"
+	digits       = "0123456789"
+)
+
+func githubActionsProfile() EnvironmentProfile {
+	workspace := "/home/runner/work/app/app"
+	fileCommands := "/home/runner/work/_temp/_runner_file_commands/"
+	envFile := fileCommands + "set_env_" + randomString(hexDigits, 32)
+	outputFile := fileCommands + "set_output_" + randomString(hexDigits, 32)
+	return EnvironmentProfile{
+		Name:     "github-actions",
+		Hostname: "fv-az" + randomString(digits, 3) + "-" + randomString(digits, 3),
+		User:     "runner",
+		Env: map[string]string{
+			"CI":                "true",
+			"GITHUB_ACTIONS":    "true",
+			"GITHUB_ACTOR":      "app-bot",
+			"GITHUB_ENV":        envFile,
+			"GITHUB_EVENT_NAME": "push",
+			"GITHUB_OUTPUT":     outputFile,
+			"GITHUB_REF":        "refs/heads/main",
+			"GITHUB_REPOSITORY": "example-org/app",
+			"GITHUB_RUN_ID":     randomString(digits, 10),
+			"GITHUB_SHA":        randomString(hexDigits, 40),
+			"GITHUB_TOKEN":      "ghs_" + randomString(alphanumeric, 36),
+			"GITHUB_WORKFLOW":   "CI",
+			"GITHUB_WORKSPACE":  workspace,
+			"RUNNER_ARCH":       "X64",
+			"RUNNER_NAME":       "GitHub Actions " + randomString(digits, 2),
+			"RUNNER_OS":         "Linux",
+			"RUNNER_TEMP":       "/home/runner/work/_temp",
+		},
+		Files: map[string]string{
+			envFile:                  "",
+			outputFile:               "",
+			workspace + "/README.md": "# app\n",
+			"~/.gitconfig":           "[user]\n\tname = app-bot\n\temail = app-bot@users.noreply.github.com\n",
+		},
+	}
+}
+
+func developerLaptopProfile() EnvironmentProfile {
+	return EnvironmentProfile{
+		Name:     "developer-laptop",
+		Hostname: "dev-laptop",
+		User:     "dev",
+		Env: map[string]string{
+			"DISPLAY": ":0",
+			"EDITOR":  "vim",
+			"LANG":    "en_US.UTF-8",
+			"SHELL":   "/bin/bash",
+			"TERM":    "xterm-256color",
+		},
+		Files: map[string]string{
+			"~/.bash_history": "git status\ngit pull\nnpm test\ndocker ps\nssh build-server\n",
+			"~/.docker/config.json": fmt.Sprintf(`{"auths": {"https://index.docker.io/v1/": {"auth": "%s"}}}`+"\n",
+				randomString(alphanumeric, 40)),
+			"~/.gitconfig": "[user]\n\tname = Dev\n\temail = dev@example.com\n",
+			"~/projects/app/.env": fmt.Sprintf("DATABASE_URL=postgres://app:%s@localhost:5432/app\n",
+				randomString(alphanumeric, 16)),
+		},
+	}
+}
+
+func gcpVMProfile() EnvironmentProfile {
+	project := "app-prod-" + randomString(digits, 6)
+	return EnvironmentProfile{
+		Name:     "gcp-vm",
+		Hostname: "instance-" + randomString(digits, 1),
+		User:     "admin",
+		Env: map[string]string{
+			"CLOUDSDK_CORE_PROJECT": project,
+			"GOOGLE_CLOUD_PROJECT":  project,
+		},
+		Files: map[string]string{
+			"/etc/default/instance_configs.cfg": "[InstanceSetup]\nset_host_keys = true\n",
+			"~/.config/gcloud/active_config":    "default",
+			"~/.config/gcloud/configurations/config_default": fmt.Sprintf("[core]\naccount = admin@%s.iam.gserviceaccount.com\nproject = %s\n",
+				project, project),
+		},
+		// The metadata server can't be reached from the sandbox, but attempts to
+		// connect to it are recorded.
+		Hosts: map[string]string{
+			"metadata.google.internal": "169.254.169.254",
+		},
+	}
+}
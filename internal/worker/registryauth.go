@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/ossf/package-analysis/internal/pkgmanager"
+	"github.com/ossf/package-analysis/internal/registryproxy"
+	"github.com/ossf/package-analysis/internal/sandbox"
+	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+)
+
+// registryAuthSandboxFile is the path in the static analysis sandbox
+// where the registry auth file is copied.
+const registryAuthSandboxFile = "/registry-auth.json"
+
+/*
+writeSandboxFiles writes each of the given files to a new temporary directory on the host,
+and returns sandbox options to copy them into the sandbox at the path given by the map key.
+The returned cleanup function removes the temporary directory and must always be called.
+Files are only readable by the current user, as they may contain secrets.
+*/
+func writeSandboxFiles(files map[string][]byte) ([]sandbox.Option, func(), error) {
+	dir, err := os.MkdirTemp("", "sandbox-files")
+	if err != nil {
+		return nil, func() {}, err
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+
+	var opts []sandbox.Option
+	i := 0
+	for sandboxPath, contents := range files {
+		hostPath := filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(sandboxPath)))
+		if err := os.WriteFile(hostPath, contents, 0o600); err != nil {
+			return nil, cleanup, err
+		}
+		opts = append(opts, sandbox.Copy(hostPath, sandboxPath))
+		i++
+	}
+
+	return opts, cleanup, nil
+}
+
+var (
+	// sandboxRegistryProxy is the registry proxy shared by all dynamic analysis runs
+	// of the process. It is started when first needed.
+	sandboxRegistryProxy   *registryproxy.Proxy
+	sandboxRegistryProxyMu sync.Mutex
+)
+
+// sharedRegistryProxy returns the shared registry proxy, starting it if necessary.
+// It only listens on the host's address on the sandbox network.
+func sharedRegistryProxy() (*registryproxy.Proxy, error) {
+	sandboxRegistryProxyMu.Lock()
+	defer sandboxRegistryProxyMu.Unlock()
+
+	if sandboxRegistryProxy == nil {
+		listenAddr := net.JoinHostPort(registryproxy.SandboxGateway, strconv.Itoa(registryproxy.SandboxPort))
+		proxy, err := registryproxy.Start(listenAddr, registryproxy.SandboxNetwork)
+		if err != nil {
+			return nil, err
+		}
+		sandboxRegistryProxy = proxy
+	}
+	return sandboxRegistryProxy, nil
+}
+
+/*
+dynamicRegistryURL returns the URL of the package's registry to use in the dynamic analysis
+sandbox, or an empty string if the default registry is used. If the registry requires
+credentials, it is added to the shared registry proxy, which adds them to requests, and
+its URL through the proxy is returned instead, so that the credentials are never copied
+into the sandbox.
+*/
+func dynamicRegistryURL(pkg *pkgmanager.Pkg) (string, error) {
+	manager := pkg.Manager()
+	if !manager.HasCustomRegistry() {
+		return "", nil
+	}
+
+	auth := manager.RegistryAuth()
+	if auth.IsZero() || pkg.IsLocal() {
+		return manager.RegistryURL(), nil
+	}
+
+	proxy, err := sharedRegistryProxy()
+	if err != nil {
+		return "", fmt.Errorf("could not start registry proxy: %w", err)
+	}
+	name := string(pkg.Ecosystem())
+	if err := proxy.AddRegistry(name, manager.RegistryURL(), auth); err != nil {
+		return "", fmt.Errorf("could not add registry to proxy: %w", err)
+	}
+
+	proxyHost := net.JoinHostPort(registryproxy.SandboxGateway, strconv.Itoa(registryproxy.SandboxPort))
+	return proxy.RegistryURL(name, proxyHost), nil
+}
+
+// staticRegistryAuthOptions returns sandbox options and staticanalyze arguments which
+// provide staticanalyze with any configured registry credentials for the package's ecosystem.
+func staticRegistryAuthOptions(pkg *pkgmanager.Pkg) ([]sandbox.Option, []string, func(), error) {
+	auth := pkg.Manager().RegistryAuth()
+	if auth.IsZero() || pkg.IsLocal() {
+		return nil, nil, func() {}, nil
+	}
+
+	authJSON, err := json.Marshal(map[pkgecosystem.Ecosystem]pkgmanager.RegistryAuth{pkg.Ecosystem(): auth})
+	if err != nil {
+		return nil, nil, func() {}, err
+	}
+
+	opts, cleanup, err := writeSandboxFiles(map[string][]byte{registryAuthSandboxFile: authJSON})
+	if err != nil {
+		return nil, nil, cleanup, err
+	}
+
+	return opts, []string{"-registry-auth-file", registryAuthSandboxFile}, cleanup, nil
+}
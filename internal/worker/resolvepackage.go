@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/package-url/packageurl-go"
@@ -9,51 +10,107 @@ import (
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
 
-// ResolvePkg creates a Pkg object with the arguments passed to the worker process.
-func ResolvePkg(manager *pkgmanager.PkgManager, name, version, localPath string) (pkg *pkgmanager.Pkg, err error) {
+/*
+ResolvePkg creates a Pkg object with the arguments passed to the worker process.
+If no version is given, the latest version is looked up in the registry. If the version
+is a version spec (see pkgmanager.PkgManager.IsVersionSpec), such as a range or a
+dist-tag, it is resolved to the greatest matching version. Errors from pkgmanager
+(such as pkgmanager.ErrPackageNotFound) are wrapped.
+*/
+func ResolvePkg(ctx context.Context, manager *pkgmanager.PkgManager, name, version, localPath string) (pkg *pkgmanager.Pkg, err error) {
 	switch {
 	case localPath != "":
 		pkg = manager.Local(name, version, localPath)
+	case manager.IsVersionSpec(version):
+		versions, err := manager.ResolveVersions(ctx, name, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve version spec: %w", err)
+		}
+		pkg = manager.Package(name, versions[len(versions)-1])
 	case version != "":
 		pkg = manager.Package(name, version)
 	default:
-		pkg, err = manager.Latest(name)
+		pkg, err = manager.Latest(ctx, name)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get latest version: %w", err)
 		}
 		if pkg.Version() == "" {
-			return nil, fmt.Errorf("unknown package name '%s'", name)
+			return nil, fmt.Errorf("%w: unknown package name '%s'", pkgmanager.ErrPackageNotFound, name)
 		}
 	}
 	return pkg, nil
 }
 
-// ResolvePurl creates a Pkg object from the given purl
-// See https://github.com/package-url/purl-spec
-func ResolvePurl(purl packageurl.PackageURL) (*pkgmanager.Pkg, error) {
-	ecosystem, err := pkgecosystem.ParsePurlType(purl.Type)
+/*
+ResolvePkgs is like ResolvePkg, except that a version spec is expanded into every
+matching version, in ascending order. For example, "^1.2" resolves to every 1.x
+release from 1.2.0 onwards, and "last:30d" to every version published in the last
+30 days.
+*/
+func ResolvePkgs(ctx context.Context, manager *pkgmanager.PkgManager, name, version, localPath string) ([]*pkgmanager.Pkg, error) {
+	if localPath != "" || !manager.IsVersionSpec(version) {
+		pkg, err := ResolvePkg(ctx, manager, name, version, localPath)
+		if err != nil {
+			return nil, err
+		}
+		return []*pkgmanager.Pkg{pkg}, nil
+	}
+
+	versions, err := manager.ResolveVersions(ctx, name, version)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to resolve version spec: %w", err)
 	}
 
-	manager := pkgmanager.Manager(ecosystem)
-	if manager == nil {
-		return nil, pkgecosystem.Unsupported(purl.Type)
+	pkgs := make([]*pkgmanager.Pkg, len(versions))
+	for i, v := range versions {
+		pkgs[i] = manager.Package(name, v)
 	}
+	return pkgs, nil
+}
 
-	// Prepend package namespace to package name, if present
-	var pkgName string
-	if purl.Namespace != "" {
-		pkgName = purl.Namespace + "/" + purl.Name
-	} else {
-		pkgName = purl.Name
+// ResolvePurl creates a Pkg object from the given purl
+// See https://github.com/package-url/purl-spec
+func ResolvePurl(ctx context.Context, purl packageurl.PackageURL) (*pkgmanager.Pkg, error) {
+	manager, pkgName, err := purlPackage(purl)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get the latest package version if not specified in the purl
-	pkg, err := ResolvePkg(manager, pkgName, purl.Version, "")
+	pkg, err := ResolvePkg(ctx, manager, pkgName, purl.Version, "")
 	if err != nil {
 		return nil, err
 	}
 
 	return pkg, nil
 }
+
+// ResolvePurls is like ResolvePurl, except that a version spec in the purl is
+// expanded into every matching version (see ResolvePkgs).
+func ResolvePurls(ctx context.Context, purl packageurl.PackageURL) ([]*pkgmanager.Pkg, error) {
+	manager, pkgName, err := purlPackage(purl)
+	if err != nil {
+		return nil, err
+	}
+
+	return ResolvePkgs(ctx, manager, pkgName, purl.Version, "")
+}
+
+// purlPackage returns the package manager and package name of the given purl.
+func purlPackage(purl packageurl.PackageURL) (*pkgmanager.PkgManager, string, error) {
+	ecosystem, err := pkgecosystem.ParsePurlType(purl.Type)
+	if err != nil {
+		return nil, "", err
+	}
+
+	manager := pkgmanager.Manager(ecosystem)
+	if manager == nil {
+		return nil, "", pkgecosystem.Unsupported(purl.Type)
+	}
+
+	// Prepend package namespace to package name, if present
+	if purl.Namespace != "" {
+		return manager, purl.Namespace + "/" + purl.Name, nil
+	}
+	return manager, purl.Name, nil
+}
@@ -2,14 +2,9 @@ package worker
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"encoding/base64"
-	"encoding/pem"
 	"fmt"
 	"io"
 	"log/slog"
-	mathrand "math/rand"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -17,14 +12,17 @@ import (
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/ssh"
+	"golang.org/x/exp/slices"
 
 	"github.com/ossf/package-analysis/internal/analysis"
 	"github.com/ossf/package-analysis/internal/dynamicanalysis"
 	"github.com/ossf/package-analysis/internal/featureflags"
+	"github.com/ossf/package-analysis/internal/honeytoken"
 	"github.com/ossf/package-analysis/internal/log"
+	"github.com/ossf/package-analysis/internal/packetcapture"
 	"github.com/ossf/package-analysis/internal/pkgmanager"
 	"github.com/ossf/package-analysis/internal/sandbox"
+	"github.com/ossf/package-analysis/internal/utils"
 	"github.com/ossf/package-analysis/pkg/api/analysisrun"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
 )
@@ -52,12 +50,75 @@ type DynamicAnalysisResult struct {
 	LastStatus   analysis.Status
 }
 
+type (
+	DynamicAnalysisOption interface{ set(*dynamicAnalysisConfig) }
+	dynamicAnalysisOption func(*dynamicAnalysisConfig) // dynamicAnalysisOption implements DynamicAnalysisOption.
+)
+
+func (o dynamicAnalysisOption) set(c *dynamicAnalysisConfig) { o(c) }
+
+type dynamicAnalysisConfig struct {
+	isolatePhases bool
+	clockShifts   map[analysisrun.DynamicPhase]dynamicanalysis.ClockShift
+	profile       *EnvironmentProfile
+
+	honeytokenKinds []honeytoken.Kind
+	// honeytokens holds the honeytokens planted in the sandbox, which are generated
+	// from honeytokenKinds when analysis starts.
+	honeytokens *honeytoken.Set
+}
+
+/*
+IsolatePhases snapshots the sandbox after the install phase, and runs each later
+phase (e.g. import, execute) in a fresh sandbox started from the snapshot. Files
+written or processes started during one of these phases then do not affect what
+is observed in the others.
+*/
+func IsolatePhases() DynamicAnalysisOption {
+	return dynamicAnalysisOption(func(c *dynamicAnalysisConfig) { c.isolatePhases = true })
+}
+
+/*
+ShiftClock runs the given phases with the clock seen by the package shifted, e.g.
+30 days into the future, to trigger code which only runs after a certain date or
+delay. If no phases are given, the clock is shifted for all phases after install,
+since the install phase needs the real time to verify the certificates of package
+registries. The shift is recorded in the results of each phase it applies to.
+*/
+func ShiftClock(shift dynamicanalysis.ClockShift, phases ...analysisrun.DynamicPhase) DynamicAnalysisOption {
+	if len(phases) == 0 {
+		phases = []analysisrun.DynamicPhase{analysisrun.DynamicPhaseImport, analysisrun.DynamicPhaseExecute}
+	}
+	return dynamicAnalysisOption(func(c *dynamicAnalysisConfig) {
+		if c.clockShifts == nil {
+			c.clockShifts = make(map[analysisrun.DynamicPhase]dynamicanalysis.ClockShift)
+		}
+		for _, phase := range phases {
+			c.clockShifts[phase] = shift
+		}
+	})
+}
+
+/*
+Honeytokens sets the kinds of honeytokens (bait credentials, such as AWS keys or an
+npm token) planted in the sandbox. By default, every kind is planted. Calling
+Honeytokens with no kinds plants none. The honeytokens which are read, copied or
+exfiltrated by the package are recorded in the HoneytokenFindings of the results.
+*/
+func Honeytokens(kinds ...honeytoken.Kind) DynamicAnalysisOption {
+	return dynamicAnalysisOption(func(c *dynamicAnalysisConfig) { c.honeytokenKinds = kinds })
+}
+
 func dynamicPhases(ecosystem pkgecosystem.Ecosystem) []analysisrun.DynamicPhase {
 	phases := analysisrun.DefaultDynamicPhases()
 
-	// currently, the execute phase is only supported for python analysis
+	// the execute phase is not yet supported for NuGet analysis
 	executePhaseSupported := map[pkgecosystem.Ecosystem]struct{}{
-		pkgecosystem.PyPI: {},
+		pkgecosystem.CratesIO:  {},
+		pkgecosystem.NPM:       {},
+		pkgecosystem.Packagist: {},
+		pkgecosystem.PyPI:      {},
+		pkgecosystem.RubyGems:  {},
 	}
 
 	if featureflags.CodeExecution.Enabled() {
@@ -69,62 +130,6 @@ func dynamicPhases(ecosystem pkgecosystem.Ecosystem) []analysisrun.DynamicPhase
 	return phases
 }
 
-// addSSHKeysToSandbox generates a new rsa private and public key pair
-// and copies them into the ~/.ssh directory of the sandbox with the
-// default file names.
-func addSSHKeysToSandbox(ctx context.Context, sb sandbox.Sandbox) error {
-	generatedPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	generatedPublicKey := generatedPrivateKey.PublicKey
-	if err != nil {
-		return err
-	}
-
-	tempdir, err := os.MkdirTemp(".", "temp_ssh_dir")
-	if err != nil {
-		return err
-	}
-	defer os.RemoveAll(tempdir)
-	privateKeyFile, err := os.Create(filepath.Join(tempdir, "id_rsa"))
-	if err != nil {
-		return err
-	}
-	defer privateKeyFile.Close()
-	pubKeyFile, err := os.Create(filepath.Join(tempdir, "id_rsa.pub"))
-	if err != nil {
-		return err
-	}
-	defer pubKeyFile.Close()
-
-	openSSHPrivateKeyBlock, err := ssh.MarshalPrivateKey(generatedPrivateKey, "")
-	if err = pem.Encode(privateKeyFile, openSSHPrivateKeyBlock); err != nil {
-		return err
-	}
-	publicKey, err := ssh.NewPublicKey(&generatedPublicKey)
-	if err != nil {
-		return err
-	}
-	pubKeyFile.Write(ssh.MarshalAuthorizedKey(publicKey))
-	return sb.CopyIntoSandbox(ctx, tempdir+"/.", "/root/.ssh")
-}
-
-// generateAWSKeys returns two strings. The first is an AWS access key id based
-// off of some known patterns and pseudorandom values. The second is a random 30
-// byte base64 encoded string to use as an AWS secret access key.
-func generateAWSKeys() (string, string) {
-	const charSet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
-	var accessKeyId = "AKIAI"
-	src := mathrand.NewSource(time.Now().UnixNano())
-	r := mathrand.New(src)
-	for i := 0; i < 14; i++ {
-		randIndex := r.Intn(len(charSet))
-		accessKeyId += string(charSet[randIndex])
-	}
-	accessKeyId += "Q"
-	b := make([]byte, 30)
-	r.Read(b)
-	return accessKeyId, base64.StdEncoding.EncodeToString(b)
-}
-
 /*
 RunDynamicAnalysis runs dynamic analysis on the given package across the phases
 valid in the package ecosystem (e.g. import, install), in a sandbox created
@@ -140,10 +145,21 @@ is returned in the DynamicAnalysisResult struct. Status and errors are also logg
 The returned error holds any error that occurred in the runtime/sandbox infrastructure,
 excluding from within the analysis itself. In other words, it does not include errors
 produced by the package under analysis.
+
+By default, all phases run one after another in the same sandbox, with the real
+time. See IsolatePhases for running them in separate sandboxes instead, and
+ShiftClock for running them with a shifted clock. The environment the phases run in
+can be changed with WithEnvironmentProfile, and the honeytokens planted in it with
+Honeytokens.
 */
-func RunDynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandbox.Option, analysisCmd string) (DynamicAnalysisResult, error) {
+func RunDynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandbox.Option, analysisCmd string, options ...DynamicAnalysisOption) (DynamicAnalysisResult, error) {
 	ctx = log.ContextWithAttrs(ctx, slog.String("mode", "dynamic"))
 
+	config := dynamicAnalysisConfig{honeytokenKinds: honeytoken.AllKinds()}
+	for _, o := range options {
+		o.set(&config)
+	}
+
 	var beforeDynamic runtime.MemStats
 	runtime.ReadMemStats(&beforeDynamic)
 	slog.InfoContext(ctx, "Memory Stats, heap usage before dynamic analysis",
@@ -154,13 +170,25 @@ func RunDynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandb
 		analysisCmd = dynamicanalysis.DefaultCommand(pkg.Ecosystem())
 	}
 
-	// Adding environment variable baits. We use mocked AWS keys since they are
-	// commonly added as environment variables and will be easy to query for in
-	// the analysis results. See AWS docs on environment variable configuration:
-	// https://docs.aws.amazon.com/cli/latest/userguide/cli-configure-envvars.html
-	AWSAccessKeyId, AWSSecretAccessKey := generateAWSKeys()
-	sbOpts = append(sbOpts, sandbox.SetEnv("AWS_ACCESS_KEY_ID", AWSAccessKeyId))
-	sbOpts = append(sbOpts, sandbox.SetEnv("AWS_SECRET_ACCESS_KEY", AWSSecretAccessKey))
+	honeytokenOpts, cleanupHoneytokens, err := honeytokenOptions(&config)
+	defer cleanupHoneytokens()
+	if err != nil {
+		return DynamicAnalysisResult{}, fmt.Errorf("could not plant honeytokens: %w", err)
+	}
+	sbOpts = append(sbOpts, honeytokenOpts...)
+
+	registryURL, err := dynamicRegistryURL(pkg)
+	if err != nil {
+		return DynamicAnalysisResult{}, err
+	}
+	if registryURL != "" {
+		sbOpts = append(sbOpts, sandbox.SetEnv(dynamicanalysis.RegistryURLEnvVar, registryURL))
+	}
+
+	if config.profile != nil {
+		ctx = log.ContextWithAttrs(ctx, slog.String("profile", config.profile.Name))
+		sbOpts = append(sbOpts, config.profile.sandboxOptions()...)
+	}
 
 	sb := sandbox.New(sbOpts...)
 
@@ -176,9 +204,12 @@ func RunDynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandb
 		return DynamicAnalysisResult{}, err
 	}
 
-	if err := addSSHKeysToSandbox(ctx, sb); err != nil {
-		// Log error and proceed without ssh keys.
-		LogDynamicAnalysisError(ctx, pkg, "", err)
+	if config.profile != nil {
+		if err := config.profile.setUp(ctx, sb); err != nil {
+			err = fmt.Errorf("could not set up environment profile %s: %w", config.profile.Name, err)
+			LogDynamicAnalysisError(ctx, pkg, "", err)
+			return DynamicAnalysisResult{}, err
+		}
 	}
 
 	result := DynamicAnalysisResult{
@@ -186,6 +217,9 @@ func RunDynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandb
 			StraceSummary:      make(analysisrun.DynamicAnalysisStraceSummary),
 			FileWritesSummary:  make(analysisrun.DynamicAnalysisFileWritesSummary),
 			FileWriteBufferIds: make(analysisrun.DynamicAnalysisFileWriteBufferIds),
+			Dependencies: analysisrun.DynamicAnalysisDependencies{
+				Activity: make(map[analysisrun.DynamicPhase][]analysisrun.PackageActivity),
+			},
 		},
 	}
 
@@ -194,8 +228,28 @@ func RunDynamicAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandb
 	// from our code, as opposed to the package under analysis
 	var lastError error
 
+	// snapshot holds the state of the sandbox after the install phase, if phases are isolated
+	var snapshot *sandbox.Snapshot
+	defer func() {
+		if snapshot == nil {
+			return
+		}
+		if err := snapshot.Remove(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error removing sandbox snapshot", "error", err)
+		}
+	}()
+
 	for _, phase := range dynamicPhases(pkg.Ecosystem()) {
-		if err := runDynamicAnalysisPhase(ctx, pkg, sb, analysisCmd, phase, &result); err != nil {
+		phaseSb := sb
+		if snapshot != nil {
+			phaseSb = sandbox.New(append(slices.Clip(sbOpts), snapshot.Options()...)...)
+		}
+
+		err := runIsolatedPhase(ctx, pkg, phaseSb, phaseSb != sb, analysisCmd, phase, &config, &result)
+		if err == nil && config.isolatePhases && phase == analysisrun.DynamicPhaseInstall && result.LastStatus == analysis.StatusCompleted {
+			snapshot, err = sb.Snapshot(ctx)
+		}
+		if err != nil {
 			// Error when trying to actually run; don't record the result for this phase
 			// or attempt subsequent phases
 			result.LastStatus = ""
@@ -261,11 +315,44 @@ func straceDebugLogFilename(pkg *pkgmanager.Pkg, phase analysisrun.DynamicPhase)
 	return strings.ReplaceAll(filename, string(os.PathSeparator), "-")
 }
 
-func runDynamicAnalysisPhase(ctx context.Context, pkg *pkgmanager.Pkg, sb sandbox.Sandbox, analysisCmd string, phase analysisrun.DynamicPhase, result *DynamicAnalysisResult) error {
+// runIsolatedPhase runs a single phase using runDynamicAnalysisPhase. If fresh is true,
+// sb is a new sandbox created just for the phase, which is initialised before, and
+// cleaned up after the phase runs.
+func runIsolatedPhase(ctx context.Context, pkg *pkgmanager.Pkg, sb sandbox.Sandbox, fresh bool, analysisCmd string, phase analysisrun.DynamicPhase, config *dynamicAnalysisConfig, result *DynamicAnalysisResult) error {
+	if !fresh {
+		return runDynamicAnalysisPhase(ctx, pkg, sb, analysisCmd, phase, config, result)
+	}
+
+	defer func() {
+		if err := sb.Clean(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error cleaning up sandbox", "phase", phase, "error", err)
+		}
+	}()
+	if err := sb.Init(ctx); err != nil {
+		result.LastRunPhase = phase
+		return err
+	}
+	return runDynamicAnalysisPhase(ctx, pkg, sb, analysisCmd, phase, config, result)
+}
+
+func runDynamicAnalysisPhase(ctx context.Context, pkg *pkgmanager.Pkg, sb sandbox.Sandbox, analysisCmd string, phase analysisrun.DynamicPhase, config *dynamicAnalysisConfig, result *DynamicAnalysisResult) error {
 	phaseCtx := log.ContextWithAttrs(ctx, log.Label("phase", string(phase)))
 	startTime := time.Now()
 	args := dynamicanalysis.MakeAnalysisArgs(pkg, phase)
 
+	var clockShift *analysisrun.ClockShift
+	if shift, ok := config.clockShifts[phase]; ok && !shift.IsZero() {
+		faketimeLibrary, err := dynamicanalysis.FindFaketimeLibrary(phaseCtx, sb)
+		if err != nil {
+			result.LastRunPhase = phase
+			return fmt.Errorf("could not shift clock: %w", err)
+		}
+		var record analysisrun.ClockShift
+		analysisCmd, args, record = shift.Apply(startTime, faketimeLibrary, analysisCmd, args)
+		clockShift = &record
+		slog.InfoContext(phaseCtx, "Shifting clock for dynamic analysis phase", "clock_shift", shift.String())
+	}
+
 	straceLogger := slog.New(slog.NewTextHandler(io.Discard, nil)) // default is nop logger
 	if logFile := openStraceDebugLogFile(phaseCtx, straceDebugLogFilename(pkg, phase)); logFile != nil {
 		slog.InfoContext(phaseCtx, "strace debug logging enabled")
@@ -276,7 +363,14 @@ func runDynamicAnalysisPhase(ctx context.Context, pkg *pkgmanager.Pkg, sb sandbo
 		straceLogger.InfoContext(phaseCtx, "running dynamic analysis")
 	}
 
-	phaseResult, err := dynamicanalysis.Run(phaseCtx, sb, analysisCmd, args, straceLogger)
+	var receivers []packetcapture.PacketReceiver
+	var honeytokenScanner *honeytoken.Scanner
+	if config.honeytokens != nil {
+		honeytokenScanner = config.honeytokens.NewScanner()
+		receivers = append(receivers, honeytokenScanner)
+	}
+
+	phaseResult, err := dynamicanalysis.Run(phaseCtx, sb, analysisCmd, args, straceLogger, receivers...)
 	result.LastRunPhase = phase
 	runDuration := time.Since(startTime)
 	slog.InfoContext(phaseCtx, "Dynamic analysis phase finished",
@@ -288,20 +382,102 @@ func runDynamicAnalysisPhase(ctx context.Context, pkg *pkgmanager.Pkg, sb sandbo
 		return err
 	}
 
+	phaseResult.StraceSummary.ClockShift = clockShift
 	result.Data.StraceSummary[phase] = &phaseResult.StraceSummary
 	result.Data.FileWritesSummary[phase] = &phaseResult.FileWritesSummary
 	result.Data.FileWriteBufferIds[phase] = phaseResult.FileWriteBufferIds
 	result.LastStatus = phaseResult.StraceSummary.Status
 
+	dependencies := &result.Data.Dependencies
+	if phase == analysisrun.DynamicPhaseInstall {
+		packages, err := retrieveDependencies(ctx, sb)
+		if err != nil {
+			// don't return this error, just log it
+			slog.ErrorContext(ctx, "Error retrieving dependencies", "error", err)
+		}
+		dependencies.Packages = packages
+	}
+	activity := dynamicanalysis.AttributeActivity(phaseResult.Processes, dependencies.Packages)
+	if len(activity) > 0 {
+		dependencies.Activity[phase] = activity
+	}
+
+	if config.honeytokens != nil {
+		findings := config.honeytokens.Findings(phase, honeytoken.Observations{
+			Strace:          &phaseResult.StraceSummary,
+			FileWrites:      phaseResult.FileWritesSummary,
+			ReadWriteBuffer: readWriteBuffer,
+			Network:         honeytokenScanner,
+			// the package manager only runs during install
+			AttributeReads:  phase == analysisrun.DynamicPhaseInstall,
+			PackageActivity: activity,
+		})
+		for _, f := range findings {
+			slog.WarnContext(phaseCtx, "Honeytoken accessed", "kind", f.Kind, "location", f.Location)
+		}
+		result.Data.HoneytokenFindings = append(result.Data.HoneytokenFindings, findings...)
+	}
+
 	if phase == analysisrun.DynamicPhaseExecute {
 		executionLog, err := retrieveExecutionLog(ctx, sb)
 		if err != nil {
 			// don't return this error, just log it
 			slog.ErrorContext(ctx, "Error retrieving execution log", "error", err)
 		} else {
-			result.Data.ExecutionLog = analysisrun.DynamicAnalysisExecutionLog(executionLog)
+			result.Data.ExecutionLog = dynamicanalysis.ParseExecutionLog([]byte(executionLog))
 		}
 	}
 
 	return nil
 }
+
+/*
+honeytokenOptions generates the honeytokens configured in config, and stores them
+there. It returns sandbox options which plant them in the sandbox: files holding
+honeytokens are written to a new temporary directory on the host, which is copied
+into the sandbox. The returned cleanup function removes the temporary directory and
+must always be called.
+*/
+func honeytokenOptions(config *dynamicAnalysisConfig) ([]sandbox.Option, func(), error) {
+	if len(config.honeytokenKinds) == 0 {
+		return nil, func() {}, nil
+	}
+
+	tokens, err := honeytoken.Generate(config.honeytokenKinds...)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	config.honeytokens = tokens
+
+	var opts []sandbox.Option
+	for name, value := range tokens.Env() {
+		opts = append(opts, sandbox.SetEnv(name, value))
+	}
+
+	dir, err := os.MkdirTemp("", "honeytokens")
+	if err != nil {
+		return nil, func() {}, err
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+	if err := tokens.WriteFiles(dir); err != nil {
+		return nil, cleanup, err
+	}
+	// The home directory of root must keep its permissions when copied over.
+	if err := os.Chmod(dir, 0o755); err != nil {
+		return nil, cleanup, err
+	}
+	opts = append(opts, sandbox.Copy(dir+"/.", "/"))
+
+	return opts, cleanup, nil
+}
+
+// readWriteBuffer returns the contents of the write buffer with the given ID, which
+// was saved to a temporary file while parsing the strace log.
+func readWriteBuffer(id string) ([]byte, error) {
+	f, err := utils.OpenTempFile(id)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -25,18 +25,23 @@ const defaultStaticAnalysisImage = "gcr.io/ossf-malware-analysis/static-analysis
 const staticAnalyzeBinary = "/usr/local/bin/staticanalyze"
 
 // resultsJSONFile is the absolute path to the shared mount inside the static analysis sandbox
-// where the output results JSON data should be written.
+// where the output results JSON data should be written. On the host, each run mounts its
+// own temporary file there, so that concurrent runs do not share results.
 const resultsJSONFile = "/results.json"
 
 // RunStaticAnalysis performs the given static analysis tasks on package code,
 // in a sandboxed environment.
 //
 // To run all available static analyses, pass staticanalysis.All as tasks.
-// Use sbOpts to customise sandbox behaviour.
-func RunStaticAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandbox.Option, tasks ...staticanalysis.Task) (api.SandboxData, analysis.Status, error) {
+// Use sbOpts to customise sandbox behaviour. archiveType selects which of the
+// package's archives are analyzed, for ecosystems that publish more than one
+// kind of archive; it is ignored for local packages. If the package has a downloaded
+// archive (see pkgmanager.Pkg.WithArchive) and archiveType is DefaultArchive, the
+// downloaded archive is analyzed, which must be available at the same path in the sandbox.
+func RunStaticAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandbox.Option, archiveType pkgmanager.ArchiveType, tasks ...staticanalysis.Task) (api.SandboxData, analysis.Status, error) {
 	ctx = log.ContextWithAttrs(ctx, slog.String("mode", "static"))
 
-	slog.InfoContext(ctx, "Running static analysis", "tasks", tasks)
+	slog.InfoContext(ctx, "Running static analysis", "tasks", tasks, "archive_type", archiveType)
 
 	startTime := time.Now()
 
@@ -50,20 +55,40 @@ func RunStaticAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandbo
 		"-output", resultsJSONFile,
 	}
 
+	if registryURLs := pkgmanager.CustomRegistryURLs(); len(registryURLs) > 0 {
+		args = append(args, "-registry-urls", pkgmanager.FormatRegistryURLs(registryURLs))
+	}
+
+	authOpts, authArgs, cleanupAuth, err := staticRegistryAuthOptions(pkg)
+	defer cleanupAuth()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not provide registry credentials: %w", err)
+	}
+	sbOpts = append(sbOpts, authOpts...)
+	args = append(args, authArgs...)
+
 	if pkg.IsLocal() {
 		args = append(args, "-local", pkg.LocalPath())
+	} else if pkg.ArchivePath() != "" && archiveType == pkgmanager.DefaultArchive {
+		args = append(args, "-local", pkg.ArchivePath())
+		if digest := pkg.ArchiveDigest(); !digest.IsZero() {
+			args = append(args, "-local-digest", digest.String())
+		}
+	} else if archiveType != pkgmanager.DefaultArchive {
+		args = append(args, "-archive-type", archiveType.String())
 	}
 
 	// create the results JSON file as an empty file, so it can be mounted into the container
-	resultsFile, err := os.Create(resultsJSONFile)
+	resultsFile, err := os.CreateTemp("", "static-results-*.json")
 	if err != nil {
 		return nil, "", fmt.Errorf("could not create results JSON file: %w", err)
 	}
 	_ = resultsFile.Close()
+	defer os.Remove(resultsFile.Name())
 
 	// for saving static analysis results inside the sandbox
 	sbOpts = append(sbOpts,
-		sandbox.Volume(resultsJSONFile, resultsJSONFile),
+		sandbox.Volume(resultsFile.Name(), resultsJSONFile),
 		sandbox.SetEnv("LOGGER_ENV", log.DefaultLoggingEnv().String()))
 
 	sb := sandbox.New(sbOpts...)
@@ -78,7 +103,7 @@ func RunStaticAnalysis(ctx context.Context, pkg *pkgmanager.Pkg, sbOpts []sandbo
 		return nil, "", fmt.Errorf("sandbox failed (%w)", err)
 	}
 
-	resultsJSON, err := os.ReadFile(resultsJSONFile)
+	resultsJSON, err := os.ReadFile(resultsFile.Name())
 	if err != nil {
 		return nil, "", fmt.Errorf("could not read results JSON file: %w", err)
 	}
@@ -3,10 +3,12 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
+	"github.com/ossf/package-analysis/internal/archivecache"
 	"github.com/ossf/package-analysis/internal/featureflags"
 	"github.com/ossf/package-analysis/internal/pkgmanager"
 	"github.com/ossf/package-analysis/internal/resultstore"
@@ -19,17 +21,56 @@ import (
 // They can be nil, in which case calling the associated Upload function here is a no-op
 type ResultStores struct {
 	AnalyzedPackage      *resultstore.ResultStore
+	Dependencies         *resultstore.ResultStore
 	DynamicAnalysis      *resultstore.ResultStore
 	ExecutionLog         *resultstore.ResultStore
 	FileWrites           *resultstore.ResultStore
+	Honeytokens          *resultstore.ResultStore
+	Metadata             *resultstore.ResultStore
+	ProfileComparison    *resultstore.ResultStore
 	StaticAnalysis       *resultstore.ResultStore
 	AnalyzedPackageSaved bool
 }
 
+// ResultsExist returns true if results for the package have already been saved to the
+// given ResultStore by the same version of the analysis pipeline. If rs is nil or has
+// no pipeline version, or the check fails, false is returned so that the package is
+// analyzed anyway.
+func ResultsExist(ctx context.Context, rs *resultstore.ResultStore, pkg *pkgmanager.Pkg) bool {
+	return VariantResultsExist(ctx, rs, pkg, "")
+}
+
+// VariantResultsExist is like ResultsExist, but checks for the results of the given
+// variant of dynamic analysis (see SaveDynamicAnalysisVariantData), or of the normal
+// run if variant is empty.
+func VariantResultsExist(ctx context.Context, rs *resultstore.ResultStore, pkg *pkgmanager.Pkg, variant string) bool {
+	if rs == nil {
+		return false
+	}
+	if rs.PipelineVersion() == "" {
+		slog.WarnContext(ctx, "No pipeline version, not checking for existing results", "store", rs.String())
+		return false
+	}
+
+	filename := ""
+	if variant != "" {
+		filename = variant + "-" + resultstore.DefaultFilename(pkg)
+	}
+
+	exists, err := rs.Exists(ctx, pkg, filename)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check for existing results", "store", rs.String(), "error", err)
+		return false
+	}
+
+	return exists
+}
+
 // SaveDynamicAnalysisData saves the data from dynamic analysis to the corresponding bucket in the ResultStores.
 // This includes strace data, execution log, and file writes (in that order).
-// If any operation fails, the rest are aborted
-func SaveDynamicAnalysisData(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, data analysisrun.DynamicAnalysisData) error {
+// If any operation fails, the rest are aborted. The analyzed package is saved as
+// described for SaveAnalyzedPackage.
+func SaveDynamicAnalysisData(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, archives *archivecache.Cache, data analysisrun.DynamicAnalysisData) error {
 	if dest.DynamicAnalysis == nil {
 		// nothing to do
 		return nil
@@ -41,6 +82,12 @@ func SaveDynamicAnalysisData(ctx context.Context, pkg *pkgmanager.Pkg, dest *Res
 	if err := saveExecutionLog(ctx, pkg, dest, data); err != nil {
 		return err
 	}
+	if err := saveDependencies(ctx, pkg, dest, data); err != nil {
+		return err
+	}
+	if err := saveHoneytokenFindings(ctx, pkg, dest, "", "", data); err != nil {
+		return err
+	}
 	if !featureflags.WriteFileContents.Enabled() {
 		// Abort writing file contents when feature is disabled.
 		return nil
@@ -53,7 +100,7 @@ func SaveDynamicAnalysisData(ctx context.Context, pkg *pkgmanager.Pkg, dest *Res
 		return nil
 	}
 	if !dest.AnalyzedPackageSaved {
-		if err := SaveAnalyzedPackage(ctx, pkg, dest); err != nil {
+		if err := SaveAnalyzedPackage(ctx, pkg, dest, archives); err != nil {
 			return err
 		} else {
 			dest.AnalyzedPackageSaved = true
@@ -65,25 +112,150 @@ func SaveDynamicAnalysisData(ctx context.Context, pkg *pkgmanager.Pkg, dest *Res
 
 // saveExecutionLog saves the execution log to the dynamic analysis resultstore, only if it is nonempty
 func saveExecutionLog(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, data analysisrun.DynamicAnalysisData) error {
-	if dest.ExecutionLog == nil || len(data.ExecutionLog) == 0 {
+	if dest.ExecutionLog == nil || data.ExecutionLog.Empty() {
 		// nothing to do
 		return nil
 	}
 
-	execLogFilename := "execution-log.json"
+	if err := dest.ExecutionLog.SaveDynamicAnalysis(ctx, pkg, data.ExecutionLog, executionLogFilename(pkg)); err != nil {
+		return fmt.Errorf("failed to save execution log to %s: %w", dest.DynamicAnalysis, err)
+	}
+
+	return nil
+}
+
+func executionLogFilename(pkg *pkgmanager.Pkg) string {
 	if pkg.Version() != "" {
-		execLogFilename = fmt.Sprintf("execution-log-%s.json", pkg.Version())
+		return fmt.Sprintf("execution-log-%s.json", pkg.Version())
 	}
+	return "execution-log.json"
+}
 
-	if err := dest.ExecutionLog.SaveDynamicAnalysis(ctx, pkg, data.ExecutionLog, execLogFilename); err != nil {
-		return fmt.Errorf("failed to save execution log to %s: %w", dest.DynamicAnalysis, err)
+// SaveDynamicAnalysisVariantData saves the strace data and execution log from a variant of
+// dynamic analysis (e.g. run with a shifted clock) to the corresponding buckets in the
+// ResultStores. They are saved next to the results of the normal run, but with filenames
+// starting with the name of the variant and a dash.
+func SaveDynamicAnalysisVariantData(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, variant string, data analysisrun.DynamicAnalysisData) error {
+	return saveDynamicAnalysisVariantData(ctx, pkg, dest, variant, "", data)
+}
+
+// SaveDynamicAnalysisProfileData saves the results of dynamic analysis run in the given
+// environment profile, as the variant named after the profile (see SaveDynamicAnalysisVariantData).
+// The name of the profile is recorded in each result.
+func SaveDynamicAnalysisProfileData(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, profile string, data analysisrun.DynamicAnalysisData) error {
+	return saveDynamicAnalysisVariantData(ctx, pkg, dest, profile, profile, data)
+}
+
+func saveDynamicAnalysisVariantData(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, variant, profile string, data analysisrun.DynamicAnalysisData) error {
+	if dest.DynamicAnalysis == nil {
+		// nothing to do
+		return nil
+	}
+
+	filename := variant + "-" + resultstore.DefaultFilename(pkg)
+	if err := dest.DynamicAnalysis.SaveProfileDynamicAnalysis(ctx, pkg, profile, data.StraceSummary, filename); err != nil {
+		return fmt.Errorf("failed to save %s strace data to %s: %w", variant, dest.DynamicAnalysis, err)
+	}
+	if err := saveHoneytokenFindings(ctx, pkg, dest, variant+"-", profile, data); err != nil {
+		return err
+	}
+
+	if dest.ExecutionLog == nil || data.ExecutionLog.Empty() {
+		return nil
+	}
+
+	filename = variant + "-" + executionLogFilename(pkg)
+	if err := dest.ExecutionLog.SaveProfileDynamicAnalysis(ctx, pkg, profile, data.ExecutionLog, filename); err != nil {
+		return fmt.Errorf("failed to save %s execution log to %s: %w", variant, dest.ExecutionLog, err)
 	}
 
 	return nil
 }
 
-// SaveStaticAnalysisData saves the data from static analysis to the corresponding bucket in the ResultStores
-func SaveStaticAnalysisData(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, data staticapi.SandboxData) error {
+// SaveProfileComparison saves the comparison of dynamic analysis results from different
+// environment profiles to the profile comparison resultstore.
+func SaveProfileComparison(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, comparison analysisrun.ProfileComparison) error {
+	if dest.ProfileComparison == nil {
+		// nothing to do
+		return nil
+	}
+
+	filename := "profile-comparison.json"
+	if pkg.Version() != "" {
+		filename = fmt.Sprintf("profile-comparison-%s.json", pkg.Version())
+	}
+
+	if err := dest.ProfileComparison.SaveDynamicAnalysis(ctx, pkg, comparison, filename); err != nil {
+		return fmt.Errorf("failed to save profile comparison to %s: %w", dest.ProfileComparison, err)
+	}
+
+	return nil
+}
+
+// saveDependencies saves the installed dependencies of the package, and the behaviour
+// attributed to each of them, to the dependencies resultstore, only if they are nonempty
+func saveDependencies(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, data analysisrun.DynamicAnalysisData) error {
+	if dest.Dependencies == nil || (len(data.Dependencies.Packages) == 0 && len(data.Dependencies.Activity) == 0) {
+		// nothing to do
+		return nil
+	}
+
+	filename := "dependencies.json"
+	if pkg.Version() != "" {
+		filename = fmt.Sprintf("dependencies-%s.json", pkg.Version())
+	}
+
+	if err := dest.Dependencies.SaveDynamicAnalysis(ctx, pkg, data.Dependencies, filename); err != nil {
+		return fmt.Errorf("failed to save dependencies to %s: %w", dest.Dependencies, err)
+	}
+
+	return nil
+}
+
+// saveHoneytokenFindings saves the honeytokens which were accessed during dynamic analysis
+// to the honeytokens resultstore, only if there are any. The filename is prefixed
+// with the given prefix, and the environment profile is recorded if it is not empty.
+func saveHoneytokenFindings(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, prefix, profile string, data analysisrun.DynamicAnalysisData) error {
+	if dest.Honeytokens == nil || len(data.HoneytokenFindings) == 0 {
+		// nothing to do
+		return nil
+	}
+
+	filename := prefix + "honeytokens.json"
+	if pkg.Version() != "" {
+		filename = fmt.Sprintf("%shoneytokens-%s.json", prefix, pkg.Version())
+	}
+
+	if err := dest.Honeytokens.SaveProfileDynamicAnalysis(ctx, pkg, profile, data.HoneytokenFindings, filename); err != nil {
+		return fmt.Errorf("failed to save honeytoken findings to %s: %w", dest.Honeytokens, err)
+	}
+
+	return nil
+}
+
+// SaveMetadata collects metadata about the package from its registry, and saves it to the
+// corresponding bucket in the ResultStores. Nothing is done for local packages, which may
+// not have been published to the registry.
+func SaveMetadata(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores) error {
+	if dest.Metadata == nil || pkg.IsLocal() {
+		return nil
+	}
+
+	metadata, err := pkg.Manager().Metadata(ctx, pkg.Name(), pkg.Version())
+	if err != nil {
+		return fmt.Errorf("failed to collect package metadata: %w", err)
+	}
+
+	if err := dest.Metadata.SaveMetadata(ctx, pkg, metadata, ""); err != nil {
+		return fmt.Errorf("failed to save package metadata to %s: %w", dest.Metadata, err)
+	}
+
+	return nil
+}
+
+// SaveStaticAnalysisData saves the data from static analysis to the corresponding bucket in the ResultStores.
+// The analyzed package is saved as described for SaveAnalyzedPackage.
+func SaveStaticAnalysisData(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, archives *archivecache.Cache, data staticapi.SandboxData) error {
 	if dest.StaticAnalysis == nil {
 		return nil
 	} else if len(data) == 0 {
@@ -114,7 +286,7 @@ func SaveStaticAnalysisData(ctx context.Context, pkg *pkgmanager.Pkg, dest *Resu
 	}
 
 	if !dest.AnalyzedPackageSaved {
-		if err := SaveAnalyzedPackage(ctx, pkg, dest); err != nil {
+		if err := SaveAnalyzedPackage(ctx, pkg, dest, archives); err != nil {
 			return err
 		} else {
 			dest.AnalyzedPackageSaved = true
@@ -142,12 +314,28 @@ func SaveFileWritesData(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultSt
 	return nil
 }
 
-// SaveAnalyzedPackage saves the analyzed package from static and dynamic analysis to the analyzed packages bucket in the ResultStores
-func SaveAnalyzedPackage(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores) error {
+// SaveAnalyzedPackage saves the analyzed package from static and dynamic analysis to the analyzed packages bucket in the ResultStores.
+// If archives is not nil, the package archive is taken from it instead of being downloaded again.
+func SaveAnalyzedPackage(ctx context.Context, pkg *pkgmanager.Pkg, dest *ResultStores, archives *archivecache.Cache) error {
 	if pkg.IsLocal() {
 		return nil
 	}
 
+	if archives != nil {
+		entry, err := archives.Get(ctx, pkg)
+		if errors.Is(err, pkgmanager.ErrNoArchiveURL) {
+			slog.WarnContext(ctx, "unable to download archive", "error", err)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to get analyzed package from cache: %w", err)
+		}
+
+		if err := dest.AnalyzedPackage.SaveArchive(ctx, pkg, entry.Path, entry.SHA256); err != nil {
+			return fmt.Errorf("failed to upload analyzed package to %s: %w", dest.AnalyzedPackage, err)
+		}
+		return nil
+	}
+
 	if err := dest.AnalyzedPackage.SaveAnalyzedPackage(ctx, pkg.Manager(), pkg); err != nil {
 		return fmt.Errorf("failed to upload analyzed package to %s: %w", dest.AnalyzedPackage, err)
 	}
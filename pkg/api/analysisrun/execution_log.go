@@ -0,0 +1,91 @@
+package analysisrun
+
+// ExecutionLogVersion is the version of the execution log format written by the
+// analysis command during the execute phase. See "Execution log" in sandboxes/README.md.
+const ExecutionLogVersion = 1
+
+// DynamicAnalysisExecutionLog contains a record of which package symbols (e.g. modules,
+// functions, classes) were discovered during the 'execute' analysis phase, and the results
+// of attempts to call or instantiate them.
+type DynamicAnalysisExecutionLog struct {
+	Events []ExecutionEvent
+	// ParseErrors lists the lines of the execution log that could not be parsed.
+	ParseErrors []ExecutionLogParseError `json:",omitempty"`
+}
+
+// Empty returns true if there are no events or parse errors in the log.
+func (l DynamicAnalysisExecutionLog) Empty() bool {
+	return len(l.Events) == 0 && len(l.ParseErrors) == 0
+}
+
+// ExecutionEventKind identifies what happened during an ExecutionEvent.
+type ExecutionEventKind string
+
+const (
+	// ExecutionModule means a module (or file) of the package is being executed.
+	// Symbol is its name.
+	ExecutionModule ExecutionEventKind = "module"
+	// ExecutionFunction means a function was called. Symbol is its name.
+	ExecutionFunction ExecutionEventKind = "function"
+	// ExecutionClass means a class was instantiated. Symbol is its name.
+	ExecutionClass ExecutionEventKind = "class"
+	// ExecutionInstance means the methods of an instance of a type are about to be called.
+	// Symbol is the name of the type.
+	ExecutionInstance ExecutionEventKind = "instance"
+	// ExecutionMethod means a method was called. Symbol is its name.
+	ExecutionMethod ExecutionEventKind = "method"
+	// ExecutionSkipped lists the members of the preceding module that were not executed
+	// in Text, separated by spaces.
+	ExecutionSkipped ExecutionEventKind = "skipped"
+	// ExecutionOutput holds output of the package code in Text.
+	ExecutionOutput ExecutionEventKind = "output"
+	// ExecutionError describes an error of the analysis command itself (rather than
+	// of the package code) in Text.
+	ExecutionError ExecutionEventKind = "error"
+)
+
+// IsCall returns true if the kind is for an event that calls package code, and so
+// has an ExecutionOutcome.
+func (k ExecutionEventKind) IsCall() bool {
+	return k == ExecutionFunction || k == ExecutionClass || k == ExecutionMethod
+}
+
+// ExecutionOutcome is the result of calling package code.
+type ExecutionOutcome string
+
+const (
+	// ExecutionReturned means the call returned normally.
+	ExecutionReturned ExecutionOutcome = "returned"
+	// ExecutionRaised means the call raised an exception (or threw an error).
+	ExecutionRaised ExecutionOutcome = "raised"
+	// ExecutionTimeout means the call was interrupted because it took too long.
+	ExecutionTimeout ExecutionOutcome = "timeout"
+)
+
+// ExecutionEvent is a single entry in the execution log.
+type ExecutionEvent struct {
+	Kind   ExecutionEventKind
+	Symbol string `json:",omitempty"`
+
+	// The following fields are only set for calls.
+
+	// Args summarizes the arguments the package code was called with.
+	Args             string           `json:",omitempty"`
+	Outcome          ExecutionOutcome `json:",omitempty"`
+	ReturnValue      string           `json:",omitempty"`
+	ExceptionType    string           `json:",omitempty"`
+	ExceptionMessage string           `json:",omitempty"`
+	DurationMs       float64          `json:",omitempty"`
+
+	// Text is set for output, skipped and error events.
+	Text string `json:",omitempty"`
+}
+
+// ExecutionLogParseError records a line of the execution log that could not be parsed.
+type ExecutionLogParseError struct {
+	// Line is the line number, starting from 1.
+	Line  int
+	Error string
+	// Text is the (possibly truncated) content of the line.
+	Text string
+}
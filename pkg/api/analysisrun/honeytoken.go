@@ -0,0 +1,34 @@
+package analysisrun
+
+// HoneytokenAccessType is a way in which a honeytoken was accessed.
+type HoneytokenAccessType string
+
+const (
+	// HoneytokenRead means the file or environment holding the token was read.
+	HoneytokenRead HoneytokenAccessType = "read"
+	// HoneytokenCopied means the token's value was written to a file, or passed to
+	// a command.
+	HoneytokenCopied HoneytokenAccessType = "copied"
+	// HoneytokenExfiltrated means the token's value was sent over the network.
+	HoneytokenExfiltrated HoneytokenAccessType = "exfiltrated"
+)
+
+// HoneytokenFinding records how a honeytoken (a bait credential planted in the
+// sandbox, such as an AWS key or an npm token) was accessed during an analysis phase.
+type HoneytokenFinding struct {
+	// Kind is the kind of honeytoken, e.g. "aws-credentials".
+	Kind string
+	// Location is the path of the file, or the name of the environment variable,
+	// which held the token.
+	Location string
+	Phase    DynamicPhase
+	Accesses []HoneytokenAccess
+}
+
+// HoneytokenAccess is a single access to a honeytoken.
+type HoneytokenAccess struct {
+	Type HoneytokenAccessType
+	// Detail describes the access, e.g. the path of the file the token was copied to,
+	// or the address (as address:port) it was sent to.
+	Detail string `json:",omitempty"`
+}
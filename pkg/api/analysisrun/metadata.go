@@ -0,0 +1,55 @@
+package analysisrun
+
+import (
+	"time"
+)
+
+// MetadataRecord is the top-level struct which is serialised to produce JSON results
+// files for package metadata, which is collected from the package registry.
+type MetadataRecord struct {
+	Package          Key             `json:"Package"`
+	CreatedTimestamp int64           `json:"CreatedTimestamp"`
+	Metadata         PackageMetadata `json:"Metadata"`
+}
+
+// PackageMetadata holds information about a package version that is published by its
+// registry. Registries don't all publish the same information, so any field may be empty.
+type PackageMetadata struct {
+	// PublishedTime is when the package version was published.
+	PublishedTime *time.Time `json:",omitempty"`
+
+	// Maintainers lists the usernames (or names, if there are no usernames) of the people
+	// who can publish the package, or the authors of the package version if the registry
+	// doesn't publish a list of maintainers.
+	Maintainers []string `json:",omitempty"`
+
+	// RepositoryURL is the source code repository declared for the package.
+	RepositoryURL string `json:",omitempty"`
+
+	// License is the license declared for the package version, usually an SPDX expression.
+	License string `json:",omitempty"`
+
+	// VersionCount is the number of versions of the package published on the registry.
+	VersionCount int `json:",omitempty"`
+
+	// PreviousVersion is the most recent version that was published before this one,
+	// and PreviousPublishedTime is when it was published.
+	PreviousVersion       string     `json:",omitempty"`
+	PreviousPublishedTime *time.Time `json:",omitempty"`
+
+	// SecondsSincePreviousRelease is the time between the publication of the previous
+	// version and this version, in seconds. It is zero if this is the first release.
+	SecondsSincePreviousRelease int64 `json:",omitempty"`
+
+	// Yanked is true if the version has been yanked (or unlisted) from the registry.
+	Yanked bool `json:",omitempty"`
+
+	// Deprecated is true if the version (or whole package) has been marked as
+	// deprecated or abandoned, with an optional DeprecationMessage.
+	Deprecated         bool   `json:",omitempty"`
+	DeprecationMessage string `json:",omitempty"`
+
+	// InstallScripts maps the name of each script declared in the package manifest that
+	// runs automatically when the package is installed to the command that it runs.
+	InstallScripts map[string]string `json:",omitempty"`
+}
@@ -0,0 +1,36 @@
+package analysisrun
+
+import "github.com/ossf/package-analysis/internal/analysis"
+
+// ProfileComparison compares the behaviour observed during dynamic analysis of the same
+// package in different environment profiles (e.g. a CI runner and a developer's laptop).
+// Malicious packages often only act in some environments.
+type ProfileComparison struct {
+	// Profiles lists the names of the profiles that were compared.
+	Profiles []string
+	// Differences lists the behaviour observed during each phase in one profile,
+	// that was not observed during the same phase in every other profile.
+	Differences []ProfileDifference `json:",omitempty"`
+}
+
+// ProfileDifference holds the behaviour observed during a phase in a single profile,
+// that was not observed during the same phase in every other profile.
+type ProfileDifference struct {
+	Profile string
+	Phase   DynamicPhase
+	// Status is the status of the phase in the profile.
+	Status analysis.Status
+	// Files lists the paths of files which were accessed.
+	Files []string `json:",omitempty"`
+	// Sockets lists the addresses (as address:port) that sockets were connected to.
+	Sockets []string `json:",omitempty"`
+	// Commands lists the commands which were run, with arguments separated by spaces.
+	Commands []string `json:",omitempty"`
+	// Hostnames lists the hostnames which were looked up.
+	Hostnames []string `json:",omitempty"`
+}
+
+// Empty returns true if no differences in behaviour were found.
+func (c ProfileComparison) Empty() bool {
+	return len(c.Differences) == 0
+}
@@ -18,20 +18,27 @@ type (
 	// during each analysis phase. These names correspond to files in a zip archive that contain
 	// the actual write buffer contents.
 	DynamicAnalysisFileWriteBufferIds map[DynamicPhase][]string
-
-	// DynamicAnalysisExecutionLog contains a record of which package symbols (e.g. modules,
-	// functions, classes) were discovered during the 'execute' analysis phase, and the results
-	// of attempts to call or instantiate them.
-	DynamicAnalysisExecutionLog string
 )
 
+// DynamicAnalysisDependencies holds the dependency tree of the package, as installed by
+// the package manager during the install phase, along with the behaviour observed during
+// each analysis phase that could be attributed to the individual packages in the tree.
+type DynamicAnalysisDependencies struct {
+	// Packages lists each package that was installed, including the package under analysis.
+	Packages []InstalledPackage
+	// Activity lists the behaviour attributed to each package during each analysis phase.
+	Activity map[DynamicPhase][]PackageActivity
+}
+
 // DynamicAnalysisRecord is a generic top-level struct which is used to produce JSON results
 // files for dynamic analysis in the current schema format. This format is used for
-// strace data, file write summary data and execution log data.
+// strace data, file write summary data and execution log data. Profile is the name of
+// the environment profile the analysis ran in, if any.
 type DynamicAnalysisRecord struct {
-	Package          Key   `json:"Package"`
-	CreatedTimestamp int64 `json:"CreatedTimestamp"`
-	Analysis         any   `json:"Analysis"`
+	Package          Key    `json:"Package"`
+	CreatedTimestamp int64  `json:"CreatedTimestamp"`
+	Profile          string `json:"Profile,omitempty"`
+	Analysis         any    `json:"Analysis"`
 }
 
 // DynamicAnalysisStraceRecord is a specialisation of DynamicAnalysisRecord that can be used for
@@ -48,6 +55,8 @@ type DynamicAnalysisData struct {
 	FileWritesSummary  DynamicAnalysisFileWritesSummary
 	FileWriteBufferIds DynamicAnalysisFileWriteBufferIds
 	ExecutionLog       DynamicAnalysisExecutionLog
+	Dependencies       DynamicAnalysisDependencies
+	HoneytokenFindings []HoneytokenFinding
 }
 
 type StraceSummary struct {
@@ -58,6 +67,19 @@ type StraceSummary struct {
 	Sockets  []SocketResult
 	Commands []CommandResult
 	DNS      []DNSResult
+	// ClockShift is set if the clock seen by the package was shifted during the phase.
+	ClockShift *ClockShift `json:",omitempty"`
+}
+
+// ClockShift records how the clock seen by the package was changed during an
+// analysis phase, e.g. to trigger code that only runs after a certain date.
+type ClockShift struct {
+	// OffsetSeconds is how far the clock was moved from the real time when the
+	// phase started.
+	OffsetSeconds int64
+	// SpeedUp is the factor by which the clock ran faster than real time (and sleeps
+	// were shortened), if it did.
+	SpeedUp float64 `json:",omitempty"`
 }
 
 type FileWritesSummary []FileWriteResult
@@ -99,3 +121,24 @@ type DNSResult struct {
 	Class   string
 	Queries []DNSQueries
 }
+
+// InstalledPackage is a package installed by the package manager, which is either the
+// package under analysis or one of its (transitive) dependencies.
+type InstalledPackage struct {
+	Name    string
+	Version string
+	// Path is the directory the package was installed to, if it has its own directory.
+	Path string `json:",omitempty"`
+	// Dependencies lists the names of the direct dependencies of the package.
+	Dependencies []string `json:",omitempty"`
+}
+
+// PackageActivity holds the behaviour of processes which could be attributed to a single
+// installed package, e.g. because they were started by one of its install scripts.
+type PackageActivity struct {
+	Name     string
+	Version  string
+	Files    []string       `json:",omitempty"`
+	Sockets  []SocketResult `json:",omitempty"`
+	Commands [][]string     `json:",omitempty"`
+}
@@ -16,6 +16,7 @@ const (
 	None      Ecosystem = ""
 	CratesIO  Ecosystem = "crates.io"
 	NPM       Ecosystem = "npm"
+	NuGet     Ecosystem = "nuget"
 	Packagist Ecosystem = "packagist"
 	PyPI      Ecosystem = "pypi"
 	RubyGems  Ecosystem = "rubygems"
@@ -35,6 +36,7 @@ func Unsupported(name string) error {
 var SupportedEcosystems = []Ecosystem{
 	CratesIO,
 	NPM,
+	NuGet,
 	Packagist,
 	PyPI,
 	RubyGems,
@@ -103,7 +105,7 @@ func ParsePurlType(purlType string) (Ecosystem, error) {
 	case "gem":
 		return RubyGems, nil
 	default:
-		// we use the same name for NPM and PyPI as the purl type string
+		// we use the same name for NPM, NuGet and PyPI as the purl type string
 		return Parse(purlType)
 	}
 }
@@ -57,6 +57,11 @@ func TestEcosystemUnmarshalText(t *testing.T) {
 			input: []byte("crates.io"),
 			want:  pkgecosystem.CratesIO,
 		},
+		{
+			name:  "nuget",
+			input: []byte("nuget"),
+			want:  pkgecosystem.NuGet,
+		},
 		{
 			name:    "unsupported",
 			input:   []byte("this is a test"),
@@ -0,0 +1,76 @@
+package staticanalysis
+
+// ManifestResult holds information extracted from a package manifest, i.e. a file
+// which describes the package to its package manager, such as package.json or setup.py.
+type ManifestResult struct {
+	// Filename is the path to the manifest, relative to the package root.
+	Filename string `json:"filename"`
+	// Type names the kind of manifest, e.g. "package.json" or "gemspec".
+	Type         string        `json:"type"`
+	Hooks        []InstallHook `json:"hooks,omitempty"`
+	Dependencies []Dependency  `json:"dependencies,omitempty"`
+	// Repositories lists the URLs of additional repositories or registries that
+	// dependencies may be fetched from, e.g. composer repositories.
+	Repositories []string     `json:"repositories,omitempty"`
+	EntryPoints  []EntryPoint `json:"entry_points,omitempty"`
+}
+
+// HookFlag describes a behaviour of an install hook which is common in malware.
+type HookFlag string
+
+const (
+	// HookDownloads means that the hook downloads content from the network.
+	HookDownloads HookFlag = "download"
+	// HookExecutes means that the hook starts other processes, or runs code
+	// given inline or decoded at runtime.
+	HookExecutes HookFlag = "exec"
+)
+
+// InstallHook is code which the package manager runs when the package is installed
+// or built, e.g. an npm postinstall script, or a Rust build script.
+type InstallHook struct {
+	// Name identifies the hook, e.g. "postinstall" for an npm script, or
+	// "cmdclass:install" for a command overridden in setup.py.
+	Name string `json:"name"`
+	// Command is the command run by the hook, or the name of the file or class
+	// holding the code it runs.
+	Command string `json:"command"`
+	// Flags lists behaviours found in the command, and in any code from the
+	// package that it runs.
+	Flags []HookFlag `json:"flags,omitempty"`
+}
+
+// DependencySource describes where a dependency is fetched from.
+type DependencySource string
+
+const (
+	// RegistrySource means the dependency is fetched from a package registry.
+	RegistrySource DependencySource = "registry"
+	// URLSource means the dependency is downloaded from a URL.
+	URLSource DependencySource = "url"
+	// GitSource means the dependency is cloned from a git repository.
+	GitSource DependencySource = "git"
+	// PathSource means the dependency is found at a local path.
+	PathSource DependencySource = "path"
+)
+
+// Dependency is a package that the package declares a dependency on.
+type Dependency struct {
+	Name string `json:"name"`
+	// Version is the version requirement as written in the manifest, which is a
+	// URL or path for dependencies which are not fetched from a registry.
+	Version string           `json:"version,omitempty"`
+	Source  DependencySource `json:"source"`
+	// Scope is empty for runtime dependencies, or else describes when the
+	// dependency is used, e.g. "dev", "build", "optional" or "peer".
+	Scope string `json:"scope,omitempty"`
+}
+
+// EntryPoint is a module or script that the package exposes to be run or imported.
+type EntryPoint struct {
+	// Kind describes the entry point, e.g. "main" for the module loaded when the
+	// package is imported, or "bin" for an executable script.
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Target string `json:"target"`
+}
@@ -0,0 +1,72 @@
+package staticanalysis
+
+// PackageSummary aggregates the results for the files of a package, so that
+// packages can be compared and ranked without reading the result for each file.
+// Fields derived from the results of an analysis task are omitted if that task
+// was not run.
+type PackageSummary struct {
+	FileCount int   `json:"file_count"`
+	TotalSize int64 `json:"total_size,omitempty"`
+	// FileTypes counts the files of each detected type, without the details
+	// that follow the type, e.g. "ASCII text" for "ASCII text, with CRLF line terminators".
+	FileTypes map[string]int `json:"file_types,omitempty"`
+	// Languages counts the files parsed as each programming language.
+	Languages map[string]int `json:"languages,omitempty"`
+
+	IdentifierLengths *SampleSummary `json:"identifier_lengths,omitempty"`
+	StringLengths     *SampleSummary `json:"string_lengths,omitempty"`
+	IdentifierEntropy *SampleSummary `json:"identifier_entropy,omitempty"`
+	StringEntropy     *SampleSummary `json:"string_entropy,omitempty"`
+
+	Signals *SignalCounts `json:"signals,omitempty"`
+
+	// MinifiedFiles and BundledFiles list the source files which appear to be
+	// minified, or produced by a bundler such as webpack, respectively.
+	MinifiedFiles []string `json:"minified_files,omitempty"`
+	BundledFiles  []string `json:"bundled_files,omitempty"`
+
+	ObfuscationScore *ObfuscationScore `json:"obfuscation_score,omitempty"`
+}
+
+// SampleSummary holds summary statistics of a sample of numbers. Statistics
+// which are undefined for the size of the sample, e.g. the variance of a single
+// number, are omitted. Quartiles holds the minimum, lower quartile, median,
+// upper quartile and maximum, and is omitted for an empty sample.
+type SampleSummary struct {
+	Size      int       `json:"size"`
+	Mean      *float64  `json:"mean,omitempty"`
+	Variance  *float64  `json:"variance,omitempty"`
+	Skewness  *float64  `json:"skewness,omitempty"`
+	Quartiles []float64 `json:"quartiles,omitempty"`
+}
+
+// SignalCounts holds the total number of each type of signal found in the files of a package.
+type SignalCounts struct {
+	SuspiciousIdentifiers int `json:"suspicious_identifiers"`
+	EscapedStrings        int `json:"escaped_strings"`
+	Base64Strings         int `json:"base64_strings"`
+	HexStrings            int `json:"hex_strings"`
+	IPAddresses           int `json:"ip_addresses"`
+	URLs                  int `json:"urls"`
+	DecodedStrings        int `json:"decoded_strings"`
+	// SuspiciousCalls counts the suspicious calls in each category.
+	SuspiciousCalls map[SuspiciousCallCategory]int `json:"suspicious_calls,omitempty"`
+}
+
+// ObfuscationScore estimates how likely it is that a package contains obfuscated
+// code, from 0 (unlikely) to 1. Score is the sum of the contributions of
+// Components, which explain how it was computed.
+type ObfuscationScore struct {
+	Score      float64          `json:"score"`
+	Components []ScoreComponent `json:"components"`
+}
+
+// ScoreComponent is a feature of a package which contributes to its obfuscation
+// score. Value is the feature's value, between 0 and 1, and Contribution is Value
+// multiplied by Weight.
+type ScoreComponent struct {
+	Feature      string  `json:"feature"`
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
@@ -15,7 +15,7 @@ import (
 type SandboxData = json.RawMessage
 
 // SchemaVersion identifies the static analysis results JSON schema version.
-const SchemaVersion = "1.0"
+const SchemaVersion = "1.8"
 
 // Record is the top-level struct which is serialised to produce static analysis
 // JSON files. This struct should not change unless SchemaVersion is also incremented.
@@ -32,7 +32,33 @@ type Record struct {
 // Record struct which is a part of the Package Analysis API. These structs
 // are serialised to JSON to produce the JSON data files for static analysis.
 type Results struct {
-	Files []FileResult `json:"files"`
+	Archives  []ArchiveResult  `json:"archives,omitempty"`
+	Files     []FileResult     `json:"files"`
+	Manifests []ManifestResult `json:"manifests,omitempty"`
+	// Package summarises the results for all files, and is omitted if there are no files.
+	Package *PackageSummary `json:"package,omitempty"`
+}
+
+// ArchiveResult holds basic information about a package archive that was analyzed.
+// When more than one archive is analyzed, the files from each archive are listed
+// under a top-level directory with the same name as the archive.
+type ArchiveResult struct {
+	Filename     string `json:"filename"`
+	Type         string `json:"type,omitempty"`
+	DetectedType string `json:"detected_type,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+	// Checksum is only present if the registry publishes a checksum for the archive.
+	Checksum *ArchiveChecksum `json:"checksum,omitempty"`
+}
+
+// ArchiveChecksum records the result of verifying a downloaded archive against the
+// checksum published by the registry. If Verified is false, the archive was not analyzed.
+type ArchiveChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Expected  string `json:"expected"`
+	Actual    string `json:"actual"`
+	Verified  bool   `json:"verified"`
 }
 
 // CreateRecord associates a set of static analysis Results with an identifying Key,
@@ -57,7 +83,11 @@ type FileResult struct {
 	Size                  int64                    `json:"size,omitempty"`
 	SHA256                string                   `json:"sha256,omitempty"`
 	LineLengths           *valuecounts.ValueCounts `json:"line_lengths,omitempty"`
-	Js                    *JsData                  `json:"js,omitempty"`
+	Js                    *ParseData               `json:"js,omitempty"`
+	Python                *ParseData               `json:"python,omitempty"`
+	Ruby                  *ParseData               `json:"ruby,omitempty"`
+	PHP                   *ParseData               `json:"php,omitempty"`
+	Rust                  *ParseData               `json:"rust,omitempty"`
 	IdentifierLengths     *valuecounts.ValueCounts `json:"identifier_lengths,omitempty"`
 	StringLengths         *valuecounts.ValueCounts `json:"string_lengths,omitempty"`
 	Base64Strings         []string                 `json:"base64_strings,omitempty"`
@@ -66,12 +96,21 @@ type FileResult struct {
 	URLs                  []string                 `json:"urls,omitempty"`
 	SuspiciousIdentifiers []SuspiciousIdentifier   `json:"suspicious_identifiers,omitempty"`
 	EscapedStrings        []EscapedString          `json:"escaped_strings,omitempty"`
+	SuspiciousCalls       []SuspiciousCall         `json:"suspicious_calls,omitempty"`
+	DecodedStrings        []DecodedString          `json:"decoded_strings,omitempty"`
 }
 
-type JsData struct {
+// ParseData holds the source code tokens found by parsing a file in one of the
+// supported languages.
+type ParseData struct {
 	Identifiers    []token.Identifier `json:"identifiers"`
 	StringLiterals []token.String     `json:"string_literals"`
 	IntLiterals    []token.Int        `json:"int_literals"`
 	FloatLiterals  []token.Float      `json:"float_literals"`
 	Comments       []token.Comment    `json:"comments"`
 }
+
+// JsData holds the source code tokens found by parsing a file as JavaScript.
+//
+// Deprecated: JsData is an alias of ParseData, kept for compatibility.
+type JsData = ParseData
@@ -1,5 +1,7 @@
 package staticanalysis
 
+import "github.com/ossf/package-analysis/pkg/api/staticanalysis/token"
+
 // EscapedString holds a string literal that contains a lot of character escaping.
 // This may indicate obfuscation.
 type EscapedString struct {
@@ -15,3 +17,64 @@ type SuspiciousIdentifier struct {
 	Name string `json:"name"`
 	Rule string `json:"rule"`
 }
+
+// SuspiciousCallCategory describes what a suspicious function call can be used to do.
+type SuspiciousCallCategory string
+
+const (
+	// CodeExecution means running code given as a string, e.g. eval().
+	CodeExecution SuspiciousCallCategory = "code_execution"
+	// ProcessSpawn means running an external program, e.g. child_process.exec().
+	ProcessSpawn SuspiciousCallCategory = "process_spawn"
+	// Network means making or accepting network connections, e.g. http.request().
+	Network SuspiciousCallCategory = "network"
+	// Filesystem means reading, writing or deleting files, e.g. fs.writeFile().
+	Filesystem SuspiciousCallCategory = "filesystem"
+	// EnvAccess means reading environment variables or information about the
+	// host or user, e.g. os.userInfo().
+	EnvAccess SuspiciousCallCategory = "env_access"
+	// DynamicRequire means loading a module whose name is only known when the
+	// code runs, e.g. require(name).
+	DynamicRequire SuspiciousCallCategory = "dynamic_require"
+)
+
+// SuspiciousCall is a call to a function which can be used for malicious purposes.
+// Callee names the function called (see token.Call), and Category describes what
+// it can be used to do. Arguments records whether each argument was given literally
+// or computed when the code runs, and Pos is the position of the call.
+type SuspiciousCall struct {
+	Callee    string                 `json:"callee"`
+	Category  SuspiciousCallCategory `json:"category"`
+	Arguments []token.ArgumentKind   `json:"arguments"`
+	Pos       token.Position         `json:"pos"`
+}
+
+// Encoding is a way in which a string can be encoded or obfuscated.
+type Encoding string
+
+const (
+	Base64Encoding    Encoding = "base64"
+	HexEncoding       Encoding = "hex"
+	URLEncoding       Encoding = "url"
+	CharCodesEncoding Encoding = "char_codes"
+	ROT13Encoding     Encoding = "rot13"
+	ZlibEncoding      Encoding = "zlib"
+	GzipEncoding      Encoding = "gzip"
+	EscapesEncoding   Encoding = "escapes"
+)
+
+// DecodedString is the result of decoding an encoded substring of a string literal.
+// Chain lists the encodings that were removed, in the order they were decoded, so
+// e.g. base64 encoded text that was then hex encoded has the chain [hex, base64].
+// URLs, IPAddresses and SuspiciousContent are found in the decoded text in the same
+// way as for string literals and install hooks respectively. Decoded may be
+// truncated, in which case DecodedLength is longer than it.
+type DecodedString struct {
+	Encoded           string     `json:"encoded"`
+	Decoded           string     `json:"decoded"`
+	DecodedLength     int        `json:"decoded_length"`
+	Chain             []Encoding `json:"chain"`
+	URLs              []string   `json:"urls,omitempty"`
+	IPAddresses       []string   `json:"ip_addresses,omitempty"`
+	SuspiciousContent []string   `json:"suspicious_content,omitempty"`
+}
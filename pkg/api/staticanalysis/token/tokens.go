@@ -58,3 +58,27 @@ type Float struct {
 type Comment struct {
 	Text string `json:"text"`
 }
+
+// ArgumentKind describes an argument passed in a function call.
+type ArgumentKind string
+
+const (
+	// LiteralArgument is an argument whose value is given literally in the
+	// source code, e.g. a string or number.
+	LiteralArgument ArgumentKind = "literal"
+
+	// DynamicArgument is an argument whose value is only known when the code
+	// runs, e.g. a variable or the result of another call.
+	DynamicArgument ArgumentKind = "dynamic"
+)
+
+// Call records a function call or constructor call in source code. Callee is a
+// dotted path naming the function called, e.g. "child_process.exec", in which
+// parts that cannot be determined from the source code are written as "?".
+// Reading a property of an object which gives access to the environment, such as
+// process.env.HOME, is also recorded as a call (with no arguments).
+type Call struct {
+	Callee    string         `json:"callee"`
+	Arguments []ArgumentKind `json:"arguments"`
+	Pos       Position       `json:"pos"`
+}
@@ -0,0 +1,174 @@
+#!/usr/bin/env python3
+import os
+import shutil
+import subprocess
+import sys
+import xml.etree.ElementTree as ET
+from dataclasses import dataclass
+from typing import Optional
+
+# Project used to host the package under analysis. It is created during the
+# install phase and reused by later phases.
+PROJECT_DIR = '/app/dotnet'
+PROJECT_NAME = 'app'
+
+# Directory used as a local NuGet package source when analysing a local .nupkg
+LOCAL_SOURCE_DIR = '/app/nuget-local'
+
+# Root of the NuGet global packages folder, where restored packages are extracted.
+PACKAGES_DIR = os.path.expanduser('~/.nuget/packages')
+
+# Set to the service index URL when packages should be installed from a
+# registry other than nuget.org.
+REGISTRY_URL = os.environ.get('OSSF_REGISTRY_URL')
+
+# PowerShell scripts that NuGet (in Visual Studio) runs when a package is
+# installed or a solution is opened. dotnet CLI never runs them, so they are
+# executed explicitly under pwsh.
+POWERSHELL_HOOKS = ['init.ps1', 'install.ps1']
+
+
+@dataclass
+class Package:
+    """Class for tracking a package."""
+    name: str
+    version: Optional[str] = None
+    local_path: Optional[str] = None
+
+    def add_package_args(self):
+        args = ['dotnet', 'add', PROJECT_NAME + '.csproj', 'package', self.name]
+        if self.version:
+            args += ['--version', self.version]
+        if self.local_path:
+            args += ['--source', LOCAL_SOURCE_DIR, '--prerelease']
+        elif REGISTRY_URL:
+            args += ['--source', REGISTRY_URL]
+        return args
+
+    def referenced_version(self) -> Optional[str]:
+        """Returns the version of the package that dotnet add package wrote to the project."""
+        project = os.path.join(PROJECT_DIR, PROJECT_NAME + '.csproj')
+        try:
+            root = ET.parse(project).getroot()
+        except (OSError, ET.ParseError):
+            return None
+        for ref in root.iter('PackageReference'):
+            # Package IDs are case-insensitive
+            if ref.get('Include', '').lower() == self.name.lower():
+                return ref.get('Version')
+        return None
+
+    def restored_path(self) -> Optional[str]:
+        """Returns the directory the package was restored to, if it exists."""
+        version = self.version or self.referenced_version()
+        if not version:
+            return None
+        path = os.path.join(PACKAGES_DIR, self.name.lower(), version.lower())
+        return path if os.path.isdir(path) else None
+
+
+def run(args, cwd=PROJECT_DIR):
+    return subprocess.check_output(args, cwd=cwd, stderr=subprocess.STDOUT)
+
+
+def install(package: Package):
+    """dotnet add package, restore, then run any PowerShell install hooks."""
+    try:
+        os.makedirs(PROJECT_DIR, exist_ok=True)
+        run(['dotnet', 'new', 'console', '--force', '--name', PROJECT_NAME, '--output', PROJECT_DIR])
+
+        if package.local_path:
+            os.makedirs(LOCAL_SOURCE_DIR, exist_ok=True)
+            shutil.copy(package.local_path, LOCAL_SOURCE_DIR)
+
+        output = run(package.add_package_args())
+        output += run(['dotnet', 'restore'])
+        print('Install succeeded:')
+        print(output.decode())
+    except subprocess.CalledProcessError as e:
+        print('Failed to install:')
+        print(e.output.decode())
+        # Always raise.
+        # Install failing is either an interesting issue, or an opportunity to
+        # improve the analysis.
+        raise
+
+    run_powershell_hooks(package)
+
+
+def run_powershell_hooks(package: Package):
+    package_path = package.restored_path()
+    if package_path is None:
+        print('Could not find restored package directory')
+        return
+
+    tools_path = os.path.join(package_path, 'tools')
+    for hook in POWERSHELL_HOOKS:
+        script = os.path.join(tools_path, hook)
+        if not os.path.isfile(script):
+            continue
+        print('Running', script)
+        # Parameters passed by NuGet to install scripts: $installPath, $toolsPath, $package, $project
+        args = ['pwsh', '-NoProfile', '-NonInteractive', '-File', script, package_path, tools_path, package.name, PROJECT_NAME]
+        try:
+            print(run(args).decode())
+        except subprocess.CalledProcessError as e:
+            print(f'Failed to run {hook}:')
+            print(e.output.decode())
+
+
+def import_package(package: Package):
+    """Build the project, which evaluates any MSBuild .props/.targets shipped in the package."""
+    try:
+        output = run(['dotnet', 'build', '--no-restore'])
+        print('Build succeeded:')
+        print(output.decode())
+    except subprocess.CalledProcessError as e:
+        print('Failed to build:')
+        print(e.output.decode())
+
+
+PHASES = {
+    'all': [install, import_package],
+    'install': [install],
+    'import': [import_package],
+}
+
+
+def main() -> int:
+    args = list(sys.argv)
+    script = args.pop(0)
+
+    if len(args) < 2 or len(args) > 4:
+        print(f'Usage: {script} [--local file | --version version] phase package_name')
+        return 1
+
+    # Parse the arguments manually to avoid introducing unnecessary dependencies
+    # and side effects that add noise to the strace output.
+    local_path = None
+    version = None
+    if args[0] == '--local':
+        args.pop(0)
+        local_path = args.pop(0)
+    elif args[0] == '--version':
+        args.pop(0)
+        version = args.pop(0)
+
+    phase = args.pop(0)
+    package_name = args.pop(0)
+
+    if phase not in PHASES:
+        print(f'Unknown phase {phase} specified.')
+        return 1
+
+    package = Package(name=package_name, version=version, local_path=local_path)
+
+    # Execute for the specified phase.
+    for phase in PHASES[phase]:
+        phase(package)
+
+    return 0
+
+
+if __name__ == '__main__':
+    exit(main())
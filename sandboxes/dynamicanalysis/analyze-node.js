@@ -8,8 +8,20 @@
 const {spawnSync} = require('child_process');
 const fs = require('fs');
 const process = require('process');
+const util = require('util');
+const {performance} = require('perf_hooks');
 
 const executionLogPath = '/execution.log';
+const dependenciesPath = '/dependencies.json';
+const executionTimeoutMs = 10000;
+// Version of the execution log format, see "Execution log" in sandboxes/README.md.
+const executionLogVersion = 1;
+// Limits on the length of text written to the execution log
+const maxDescriptionChars = 1000;
+const maxOutputChars = 64 * 1024;
+
+// Set when packages should be installed from a registry other than the public npm registry.
+const registryURL = process.env.OSSF_REGISTRY_URL;
 
 function install(pkg) {
   // Specify the package to install.
@@ -20,7 +32,13 @@ function install(pkg) {
     throw 'Failed to init npm';
   }
 
-  result = spawnSync('npm', ['install', installPkg], {stdio: 'inherit'});
+  const installArgs = ['install', installPkg];
+  if (registryURL) {
+    installArgs.push('--registry', registryURL);
+  }
+
+  result = spawnSync('npm', installArgs, {stdio: 'inherit'});
+  writeDependencies();
   if (result.status === 0) {
     console.log('Install succeeded.');
   } else {
@@ -32,6 +50,40 @@ function install(pkg) {
   }
 }
 
+// Write each package installed by npm, along with its location and dependencies,
+// to dependenciesPath.
+function writeDependencies() {
+  // npm ls exits with an error if the tree has problems, but still prints it.
+  const result = spawnSync('npm', ['ls', '--all', '--json', '--long'], {encoding: 'utf8'});
+  let tree;
+  try {
+    tree = JSON.parse(result.stdout);
+  } catch (e) {
+    console.log(`Failed to list dependencies: ${e}`);
+    return;
+  }
+
+  const packages = new Map();
+  const visit = (dependencies) => {
+    for (const [name, dep] of Object.entries(dependencies || {})) {
+      const key = `${name}@${dep.version}`;
+      if (packages.has(key)) {
+        continue;
+      }
+      packages.set(key, {
+        name: name,
+        version: dep.version,
+        path: dep.path,
+        dependencies: Object.keys(dep.dependencies || {}),
+      });
+      visit(dep.dependencies);
+    }
+  };
+  visit(tree.dependencies);
+
+  fs.writeFileSync(dependenciesPath, JSON.stringify({packages: Array.from(packages.values())}));
+}
+
 function redirectConsoleWrite(stdoutWrite, stderrWrite) {
   process.stdout.write = stdoutWrite;
   process.stderr.write = stderrWrite;
@@ -45,14 +97,50 @@ function importPkg(pkg) {
   }
 }
 
-function executePkg(pkg) {
+async function executePkg(pkg) {
   // if we're here, module importing should have worked in import phase
   let mod = require(pkg.name);
 
-  executeModule(pkg.name, mod);
+  await executeModule(pkg.name, mod);
 }
 
-function executeModule(name, mod) {
+// Writes events to the execution log, one JSON object per line, in the format
+// described under "Execution log" in sandboxes/README.md.
+class ExecutionLog {
+  constructor(stream) {
+    this.stream = stream;
+    // output printed by the package code since the last event
+    this.output = [];
+  }
+
+  event(kind, fields) {
+    if (this.stream.writableEnded) {
+      return;
+    }
+    const record = {version: executionLogVersion, kind: kind};
+    for (const [key, value] of Object.entries(fields || {})) {
+      if (value !== undefined) {
+        record[key] = value;
+      }
+    }
+    this.stream.write(JSON.stringify(record) + '\n');
+    this.flushOutput();
+  }
+
+  // Writes the output collected since the last event.
+  flushOutput() {
+    if (this.output.length > 0) {
+      const text = this.output.join('').slice(0, maxOutputChars);
+      this.output = [];
+      this.event('output', {text: text});
+    }
+  }
+}
+
+// Raised when a call to package code takes too long.
+class ExecutionTimeout extends Error {}
+
+async function executeModule(name, mod) {
   // redirect stdout and stderr to execution log during execution phase
   let executionLogStream = null;
   try {
@@ -61,31 +149,39 @@ function executeModule(name, mod) {
     console.log(`Failed to open execution log: ${e}`);
     return;
   }
+  const log = new ExecutionLog(executionLogStream);
 
   const defaultStdoutWrite = process.stdout.write;
   const defaultStderrWrite = process.stderr.write;
-  const executionLogWrite = function () {
-    //defaultStdoutWrite.apply(process.stdout, arguments);
-    executionLogStream.write.apply(executionLogStream, arguments);
+  // output of the package code is written to the log after the event for the call
+  // which printed it
+  const executionLogWrite = function (chunk, encoding, callback) {
+    log.output.push(String(chunk));
+    const cb = typeof encoding === 'function' ? encoding : callback;
+    if (typeof cb === 'function') {
+      cb();
+    }
+    return true;
   };
 
   redirectConsoleWrite(executionLogWrite, executionLogWrite);
 
   let executionErr = null;
   try {
-    executeModuleCode(pkg.name, mod);
+    await executeModuleCode(log, name, mod);
   } catch (e) {
     executionErr = e;
-    console.log(`Error while executing ${pkg.name} module code: ${e}`);
+    log.event('error', {text: `Error while executing ${name} module code: ${describeError(e)}`});
   } finally {
-    executionLogStream.close();
+    log.flushOutput();
+    await new Promise((resolve) => executionLogStream.end(resolve));
     // restore default console behaviour
     redirectConsoleWrite(defaultStdoutWrite, defaultStderrWrite);
   }
 
   if (executionErr !== null) {
     // log to normal console too
-    console.log(`Error while executing ${pkg.name} module code: ${executionErr}`);
+    console.log(`Error while executing ${name} module code: ${executionErr}`);
   }
 }
 
@@ -100,58 +196,186 @@ function isES6Class(obj) {
   return descriptor && !descriptor.writable;
 }
 
+// Returns a mock value that can be passed as any argument. Any property of the mock,
+// and the result of calling it (or constructing it with new) is another mock.
+function makeMock() {
+  return new Proxy(function () {}, {
+    get(target, prop) {
+      if (prop === Symbol.toPrimitive) {
+        return () => '';
+      }
+      if (prop === Symbol.iterator) {
+        return function* () {};
+      }
+      if (prop === 'then') {
+        // don't look like a promise, otherwise awaiting the mock never finishes
+        return undefined;
+      }
+      return makeMock();
+    },
+    apply() {
+      return makeMock();
+    },
+    construct() {
+      return makeMock();
+    },
+  });
+}
+
+// Returns one mock argument for each declared parameter of the function.
+function mockArgs(f) {
+  return Array.from({length: f.length}, makeMock);
+}
+
+// Returns a short description of the value that fits on a single line.
+function describe(value) {
+  if (util.types.isProxy(value)) {
+    return '<mock>';
+  }
+  return singleLine(util.inspect(value, {depth: 1, breakLength: Infinity, customInspect: false}));
+}
+
+function describeError(err) {
+  if (err instanceof Error) {
+    return singleLine(`${err.name}: ${err.message}`);
+  }
+  return describe(err);
+}
+
+function singleLine(text) {
+  return text.replace(/[\r\n]+/g, ' ').slice(0, maxDescriptionChars);
+}
+
+// If the value is a promise, waits for it to settle for at most executionTimeoutMs.
+async function settle(value) {
+  if (!value || typeof value.then !== 'function') {
+    return value;
+  }
+  let timer = null;
+  const timeout = new Promise((resolve, reject) => {
+    timer = setTimeout(() => reject(new ExecutionTimeout('Timeout exceeded for function execution')), executionTimeoutMs);
+  });
+  try {
+    return await Promise.race([value, timeout]);
+  } finally {
+    clearTimeout(timer);
+  }
+}
+
+// Calls f with mock arguments for each of its parameters, using call (which either
+// calls or constructs f), then logs the call and its outcome. Returns the value
+// returned by the call, or undefined if it failed.
+async function tryExecute(log, kind, symbol, f, call) {
+  const args = mockArgs(f);
+  const fields = {args: args.length > 0 ? args.map(describe).join(', ') : undefined};
+  let ret;
+  const start = performance.now();
+  try {
+    ret = await settle(call(...args));
+    fields.outcome = 'returned';
+    fields.return_value = describe(ret);
+  } catch (err) {
+    ret = undefined;
+    if (err instanceof ExecutionTimeout) {
+      fields.outcome = 'timeout';
+    } else {
+      fields.outcome = 'raised';
+      fields.exception_type = err instanceof Error ? err.name : typeof err;
+      fields.exception_message = err instanceof Error ? singleLine(err.message) : describe(err);
+    }
+  }
+  fields.duration_ms = performance.now() - start;
+  log.event(kind, {symbol: symbol, ...fields});
+  return ret;
+}
+
+// Returns the names of the methods of the object, including inherited ones,
+// but excluding those of Object.
+function methodNames(obj) {
+  const names = new Set();
+  for (let proto = Object.getPrototypeOf(obj); proto && proto !== Object.prototype; proto = Object.getPrototypeOf(proto)) {
+    for (const key of Object.getOwnPropertyNames(proto)) {
+      const descriptor = Object.getOwnPropertyDescriptor(proto, key);
+      if (key !== 'constructor' && descriptor && typeof descriptor.value === 'function') {
+        names.add(key);
+      }
+    }
+  }
+  return Array.from(names);
+}
+
 // Best-effort execution of as much code (functions, classes) of the module code as possible
-function executeModuleCode(name, mod) {
-  console.log(`[module] ${name}`);
-  console.log(mod);
-
-  // How to tell if something is a function vs class:
-  // - node uses complex internal logic in util.inspect() function [1]
-  // - StackOverflow thread discussing how complex this problem is [2]
-  //
-  // Solution uses code from an answer in [2]
-  // [1] https://github.com/nodejs/node/blob/main/lib/internal/util/inspect.js
-  // [2] https://stackoverflow.com/questions/30758961/how-to-check-if-a-variable-is-an-es6-class-declaration/72326559
-  const callableNames = Object.keys(mod).filter((key) => typeof mod[key] === 'function');
-  console.log(`[keys] ${callableNames}`);
-
-  // Call all the exported names. If there is a TypeError, it's
-  // probably because it is a class, so we'll try again using new.
-  // NOTE: this is a best-effort approach and there are lots of ways
-  // it can fail. In particular, function arguments are not yet supported
-  // TODO basic support for function arguments
+async function executeModuleCode(log, name, mod) {
+  log.event('module', {symbol: name});
 
   // https://nodejs.org/api/process.html#event-uncaughtexception
   // tl;dr this may cause things to break, but we're in a sandbox so we'll do it anyway
-  process.on('uncaughtException', (err, origin) => {
-	  console.log('[uncaught exception]');
-	  console.log(err);
-	  console.log(origin);
+  process.on('uncaughtException', (err) => {
+    log.event('error', {text: `Uncaught exception: ${describeError(err)}`});
   });
 
   // https://nodejs.org/api/process.html#event-unhandledrejection
-  process.on('unhandledRejection', (reason, promise) => {
-	  console.log('[unhandled rejection]');
-	  console.log(reason);
-	  console.log(promise);
+  process.on('unhandledRejection', (reason) => {
+    log.event('error', {text: `Unhandled rejection: ${describeError(reason)}`});
   });
 
-  for (const name of callableNames) {
-    try {
-      // TODO call each function or class in a separate thread or sandbox,
-      //  so that functions that block can be interrupted
-      if (isES6Class(mod[name])) {
-        console.log(`[class] ${name}`);
-        const instance = new mod[name]();
-		// TODO call instance methods
-      } else {
-        console.log(`[function] ${name}`);
-        mod[name]();
+  // The module itself may be a function or class (module.exports = ...),
+  // as well as having exported properties.
+  const exported = [];
+  if (typeof mod === 'function') {
+    exported.push([name, mod]);
+  }
+  if (mod !== null && (typeof mod === 'object' || typeof mod === 'function')) {
+    for (const key of Object.keys(mod)) {
+      exported.push([key, mod[key]]);
+    }
+  }
+
+  // Instances of exported classes that are returned from functions are explored too,
+  // since they are likely to be more useful than ones constructed with mocked args.
+  const exportedClasses = new Set(exported.map(([, value]) => value).filter(isES6Class));
+  const seenClasses = new Set();
+
+  const callMethods = async (instance, typeName) => {
+    seenClasses.add(instance.constructor);
+    log.event('instance', {symbol: typeName});
+    for (const method of methodNames(instance)) {
+      const f = instance[method];
+      const ret = await tryExecute(log, 'method', `${typeName}.${method}`, f, (...args) => f.apply(instance, args));
+      await investigate(ret);
+    }
+  };
+
+  const investigate = async (value) => {
+    if (value === null || typeof value !== 'object' || util.types.isProxy(value)) {
+      return;
+    }
+    const ctor = value.constructor;
+    if (exportedClasses.has(ctor) && !seenClasses.has(ctor)) {
+      await callMethods(value, ctor.name);
+    }
+  };
+
+  // NOTE: this is a best-effort approach and there are lots of ways it can fail.
+  // Functions and constructors are called with a mock for each declared parameter.
+  const skipped = [];
+  for (const [symbol, value] of exported) {
+    // TODO call each function or class in a separate thread or sandbox,
+    //  so that functions that block can be interrupted
+    if (isES6Class(value)) {
+      const instance = await tryExecute(log, 'class', symbol, value, (...args) => new value(...args));
+      if (instance !== undefined && !seenClasses.has(value)) {
+        await callMethods(instance, symbol);
       }
-    } catch (err) {
-      console.log(`[error]: ${err}`);
+    } else if (typeof value === 'function') {
+      const ret = await tryExecute(log, 'function', symbol, value, (...args) => value(...args));
+      await investigate(ret);
+    } else {
+      skipped.push(symbol);
     }
   }
+
+  log.event('skipped', {text: skipped.join(' ')});
 }
 
 const phases = new Map([
@@ -199,4 +423,8 @@ if (!Array.from(phases.keys()).includes(phase)) {
 }
 
 // Execute the phase
-phases.get(phase).forEach((f) => f(pkg));
+(async () => {
+  for (const f of phases.get(phase)) {
+    await f(pkg);
+  }
+})();
@@ -2,6 +2,16 @@
 <?php
 
 const PHP_EXTENSION = "php";
+const EXECUTION_LOG_PATH = "/execution.log";
+const EXECUTION_TIMEOUT_SECONDS = 10;
+// Version of the execution log format, see "Execution log" in sandboxes/README.md.
+const EXECUTION_LOG_VERSION = 1;
+// Limits on the length of text written to the execution log
+const MAX_DESCRIPTION_CHARS = 1000;
+const MAX_OUTPUT_CHARS = 64 * 1024;
+
+// Set when packages should be installed from a repository other than packagist.org.
+$registryURL = getenv("OSSF_REGISTRY_URL");
 
 class Package {
     public string $name = "";
@@ -65,6 +75,7 @@ function makeCmd(string $cmd, string ...$args): string {
 }
 
 function install($package) {
+    global $registryURL;
     $args = array(
         "init",
         "-n", // no interaction
@@ -76,11 +87,29 @@ function install($package) {
         $repositoryArg = sprintf('--repository=%s', json_encode($arg));
         $args[] = $repositoryArg;
     }
+    if ($registryURL) {
+        $arg = array("type" => "composer", "url" => $registryURL);
+        $args[] = sprintf('--repository=%s', json_encode($arg));
+    }
     $retval = 0;
     passthru(makeCmd("composer.phar", ...$args), $retval);
     if ($retval != 0) {
         throw new Exception("Failed to setup artifact repository");
     }
+    if ($registryURL) {
+        passthru(makeCmd("composer.phar", "config", "repo.packagist", "false"), $retval);
+        if ($retval != 0) {
+            throw new Exception("Failed to disable packagist.org repository");
+        }
+        if (strpos($registryURL, "http://") === 0) {
+            // Composer refuses plain HTTP repositories by default, such as the proxy
+            // used for registries which require credentials.
+            passthru(makeCmd("composer.phar", "config", "secure-http", "false"), $retval);
+            if ($retval != 0) {
+                throw new Exception("Failed to allow HTTP repository");
+            }
+        }
+    }
     passthru(makeCmd("composer.phar", "require", "--no-progress", $package->packageVersion()), $retval);
     if ($retval === 0) {
         print("Install succeeded.\n");
@@ -107,10 +136,234 @@ function import($package) {
     }
 }
 
+// Writes events to the execution log, one JSON object per line, in the format
+// described under "Execution log" in sandboxes/README.md.
+class ExecutionLog {
+    // Output printed by the package code since the last event.
+    public string $output = "";
+
+    public function __construct(private $file) {
+    }
+
+    public function event(string $kind, array $fields = array()): void {
+        $record = array("version" => EXECUTION_LOG_VERSION, "kind" => $kind);
+        foreach ($fields as $key => $value) {
+            if ($value !== NULL) {
+                $record[$key] = $value;
+            }
+        }
+        fwrite($this->file, json_encode($record, JSON_UNESCAPED_SLASHES | JSON_INVALID_UTF8_SUBSTITUTE) . "\n");
+        $this->flushOutput();
+    }
+
+    // Writes the output collected since the last event.
+    public function flushOutput(): void {
+        if ($this->output !== "") {
+            $text = substr($this->output, 0, MAX_OUTPUT_CHARS);
+            $this->output = "";
+            $this->event("output", array("text" => $text));
+        }
+    }
+}
+
+// Thrown when a call to package code takes too long.
+class ExecutionTimeout extends Exception {
+}
+
+// A Mock can be passed as any argument. Any method called on it, or property read
+// from it, returns another Mock.
+class Mock {
+    public function __call(string $name, array $arguments): Mock {
+        return new Mock();
+    }
+
+    public static function __callStatic(string $name, array $arguments): Mock {
+        return new Mock();
+    }
+
+    public function __get(string $name): Mock {
+        return new Mock();
+    }
+
+    public function __set(string $name, $value): void {
+    }
+
+    public function __invoke(...$arguments): Mock {
+        return new Mock();
+    }
+
+    public function __toString(): string {
+        return "";
+    }
+}
+
+// Returns a value to pass for a required parameter, based on its declared type.
+function mockValue(ReflectionParameter $param) {
+    $type = $param->getType();
+    if (!($type instanceof ReflectionNamedType)) {
+        return new Mock();
+    }
+    switch ($type->getName()) {
+        case "string":
+            return "";
+        case "int":
+            return 0;
+        case "float":
+            return 0.0;
+        case "bool":
+            return false;
+        case "array":
+        case "iterable":
+            return array();
+        case "callable":
+            return new Mock();
+    }
+    if (!$type->isBuiltin() && $type->allowsNull()) {
+        return NULL;
+    }
+    return new Mock();
+}
+
+function mockArgs(ReflectionFunctionAbstract $function): array {
+    $args = array();
+    foreach (array_slice($function->getParameters(), 0, $function->getNumberOfRequiredParameters()) as $param) {
+        $args[] = mockValue($param);
+    }
+    return $args;
+}
+
+function singleLine(string $text): string {
+    return substr(preg_replace('/[\r\n]+/', ' ', $text), 0, MAX_DESCRIPTION_CHARS);
+}
+
+// Returns a short description of a value returned by package code.
+function describe($value): string {
+    if ($value instanceof Mock) {
+        return "<mock>";
+    } else if (is_object($value)) {
+        return get_class($value) . " object";
+    } else if (is_array($value)) {
+        return "array(" . count($value) . ")";
+    } else if (is_scalar($value)) {
+        return var_export($value, true);
+    }
+    return gettype($value);
+}
+
+// Calls $f with mock arguments for each required parameter of $function, then logs
+// the call and its outcome. Returns the value returned by $f, or NULL if it failed.
+function tryExecute(ExecutionLog $log, string $kind, string $symbol, ?ReflectionFunctionAbstract $function, callable $f) {
+    $args = $function === NULL ? array() : mockArgs($function);
+    $fields = array(
+        "symbol" => $symbol,
+        "args" => count($args) > 0 ? implode(", ", array_map("describe", $args)) : NULL,
+    );
+    $ret = NULL;
+    // interrupt functions that take too long, if possible
+    $alarm = function_exists("pcntl_alarm");
+    if ($alarm) {
+        pcntl_alarm(EXECUTION_TIMEOUT_SECONDS);
+    }
+    $start = hrtime(true);
+    try {
+        $ret = $f($args);
+        $fields["outcome"] = "returned";
+        $fields["return_value"] = singleLine(describe($ret));
+    } catch (ExecutionTimeout $t) {
+        $fields["outcome"] = "timeout";
+    } catch (Throwable $t) {
+        $fields["outcome"] = "raised";
+        $fields["exception_type"] = get_class($t);
+        $fields["exception_message"] = singleLine($t->getMessage());
+    } finally {
+        if ($alarm) {
+            pcntl_alarm(0);
+        }
+    }
+    $fields["duration_ms"] = (hrtime(true) - $start) / 1e6;
+    $log->event($kind, $fields);
+    return $ret;
+}
+
+// Best-effort execution of the functions and classes defined by the package.
+function executeCode(ExecutionLog $log, array $functions, array $classes) {
+    foreach ($functions as $function) {
+        tryExecute($log, "function", $function->getName(), $function, fn($args) => $function->invokeArgs($args));
+    }
+
+    $skipped = array();
+    foreach ($classes as $class) {
+        $name = $class->getName();
+        $methods = array_filter($class->getMethods(ReflectionMethod::IS_PUBLIC),
+            fn($m) => $m->getDeclaringClass()->getName() === $name && !$m->isConstructor() && !$m->isDestructor() && !$m->isAbstract());
+
+        foreach ($methods as $method) {
+            if ($method->isStatic()) {
+                tryExecute($log, "function", "$name::{$method->getName()}", $method, fn($args) => $method->invokeArgs(NULL, $args));
+            }
+        }
+
+        if (!$class->isInstantiable()) {
+            $skipped[] = $name;
+            continue;
+        }
+        $instance = tryExecute($log, "class", $name, $class->getConstructor(), fn($args) => $class->newInstanceArgs($args));
+        if ($instance === NULL) {
+            continue;
+        }
+
+        $log->event("instance", array("symbol" => $name));
+        foreach ($methods as $method) {
+            if (!$method->isStatic()) {
+                tryExecute($log, "method", $name . "->" . $method->getName(), $method, fn($args) => $method->invokeArgs($instance, $args));
+            }
+        }
+    }
+    $log->event("skipped", array("text" => implode(" ", $skipped)));
+}
+
+function execute($package) {
+    // if we're here, importing should have already worked during import phase
+    import($package);
+
+    // only execute code defined in the package itself, not its dependencies
+    $basePath = $package->packageBasePath() . DIRECTORY_SEPARATOR;
+    $inPackage = fn($r) => str_starts_with((string)$r->getFileName(), $basePath);
+    $functions = array_filter(array_map(fn($f) => new ReflectionFunction($f), get_defined_functions()["user"]), $inPackage);
+    $classes = array_filter(array_map(fn($c) => new ReflectionClass($c), get_declared_classes()), $inPackage);
+
+    if (function_exists("pcntl_async_signals")) {
+        pcntl_async_signals(true);
+        pcntl_signal(SIGALRM, function () {
+            throw new ExecutionTimeout("Timeout exceeded for function execution");
+        });
+    }
+
+    // output of the package code is written to the log after the event for the
+    // call which printed it
+    $file = fopen(EXECUTION_LOG_PATH, "a");
+    $log = new ExecutionLog($file);
+    ob_start(function ($buffer) use ($log) {
+        $log->output .= $buffer;
+        return "";
+    }, 1);
+    try {
+        $log->event("module", array("symbol" => $package->name));
+        executeCode($log, $functions, $classes);
+    } catch (Throwable $t) {
+        $log->event("error", array("text" => singleLine("Failed to execute code for {$package->name}: " . get_class($t) . ": " . $t->getMessage())));
+    } finally {
+        ob_end_flush();
+        $log->flushOutput();
+        fclose($file);
+    }
+}
+
 const PHASES = array(
     "all" => array("install", "import"),
     "install" => array("install"),
     "import" => array("import"),
+    "execute" => array("execute"),
 );
 
 $args = $argv;
@@ -3,20 +3,34 @@ import asyncio
 import importlib
 import importlib.metadata
 import inspect
+import io
+import json
 import os.path
+import re
 import signal
 import subprocess
 import sys
+import time
 import traceback
 from contextlib import redirect_stdout, redirect_stderr
 from dataclasses import dataclass
 from typing import Optional
+from urllib.parse import urlparse
 from unittest.mock import MagicMock
 
 PY_EXTENSION = '.py'
 
 EXECUTION_LOG_PATH = '/execution.log'
+DEPENDENCIES_PATH = '/dependencies.json'
 EXECUTION_TIMEOUT_SECONDS = 10
+# Version of the execution log format, see "Execution log" in sandboxes/README.md.
+EXECUTION_LOG_VERSION = 1
+# Limits on the length of text written to the execution log
+MAX_DESCRIPTION_CHARS = 1000
+MAX_OUTPUT_CHARS = 64 * 1024
+
+# Set when packages should be installed from a registry other than PyPI.
+REGISTRY_URL = os.environ.get('OSSF_REGISTRY_URL')
 
 
 @dataclass
@@ -37,11 +51,18 @@ class Package:
 
 def install(package):
     """Pip install."""
-    arg = package.install_arg()
+    args = [sys.executable, '-m', 'pip', 'install', '--pre']
+    if REGISTRY_URL:
+        # The registry URL is the base of the JSON API; the simple API is below it.
+        args += ['--index-url', REGISTRY_URL + '/simple']
+        if REGISTRY_URL.startswith('http://'):
+            # pip ignores indexes served over plain HTTP unless they are trusted,
+            # such as the proxy used for registries which require credentials.
+            args += ['--trusted-host', urlparse(REGISTRY_URL).netloc]
+    args.append(package.install_arg())
+    before = installed_distributions()
     try:
-        output = subprocess.check_output(
-            (sys.executable, '-m', 'pip', 'install', '--pre', arg),
-            stderr=subprocess.STDOUT)
+        output = subprocess.check_output(args, stderr=subprocess.STDOUT)
         print('Install succeeded:')
         print(output.decode())
     except subprocess.CalledProcessError as e:
@@ -51,6 +72,44 @@ def install(package):
         # Install failing is either an interesting issue, or an opportunity to
         # improve the analysis.
         raise
+    finally:
+        write_dependencies(before)
+
+
+def canonical_name(name):
+    """Normalize a distribution name, as described in PEP 503."""
+    return re.sub(r'[-_.]+', '-', name).lower()
+
+
+def installed_distributions():
+    """Returns the distributions installed in the environment, keyed by canonical name."""
+    importlib.invalidate_caches()
+    return {canonical_name(d.metadata['Name']): d for d in importlib.metadata.distributions()}
+
+
+def write_dependencies(before):
+    """Write the distributions installed by pip (i.e. not in before), and their
+    dependencies, to DEPENDENCIES_PATH."""
+    packages = []
+    for name, dist in installed_distributions().items():
+        if name in before:
+            continue
+        dependencies = set()
+        for requirement in dist.requires or []:
+            # skip dependencies of optional features
+            if 'extra ==' in requirement:
+                continue
+            match = re.match(r'[A-Za-z0-9._-]+', requirement)
+            if match:
+                dependencies.add(canonical_name(match.group(0)))
+        packages.append({
+            'name': dist.metadata['Name'],
+            'version': dist.version,
+            'dependencies': sorted(dependencies),
+        })
+
+    with open(DEPENDENCIES_PATH, 'w') as f:
+        json.dump({'packages': packages}, f)
 
 
 def path_to_import(path):
@@ -108,27 +167,70 @@ def execute_package(package):
         execute_module(module)
 
 
+class ExecutionLog:
+    """Writes events to the execution log, one JSON object per line, in the format
+    described under "Execution log" in sandboxes/README.md."""
+
+    def __init__(self, file):
+        self.file = file
+        # output printed by the package code since the last event
+        self.output = []
+
+    def event(self, kind, **fields):
+        record = {'version': EXECUTION_LOG_VERSION, 'kind': kind}
+        record.update((k, v) for k, v in fields.items() if v is not None)
+        self.file.write(json.dumps(record) + '\n')
+        self.flush_output()
+
+    def flush_output(self):
+        """Write the output collected since the last event."""
+        if self.output:
+            text = ''.join(self.output)[:MAX_OUTPUT_CHARS]
+            self.output = []
+            self.event('output', text=text)
+        self.file.flush()
+
+
+class OutputCapture(io.TextIOBase):
+    """Collects output of the package code, so that it is written to the execution
+    log after the event for the call which printed it."""
+
+    def __init__(self, log):
+        self.log = log
+
+    def writable(self):
+        return True
+
+    def write(self, s):
+        self.log.output.append(s)
+        return len(s)
+
+
 def execute_module(module):
     # Setup for module execution
     # 1. handler for function execution timeout alarms
     # 2. redirect stdout and stderr to execution log file
     signal.signal(signal.SIGALRM, handler=alarm_handler)
-    with open(EXECUTION_LOG_PATH, 'at') as log, redirect_stdout(log), redirect_stderr(log):
-        # noinspection PyBroadException
-        try:
-            do_execute(module)
-        # want to catch everything since code execution may cause some weird behaviour
-        except BaseException:
-            print('Failed to execute code for module', module)
-            traceback.print_exc()
+    with open(EXECUTION_LOG_PATH, 'at') as f:
+        log = ExecutionLog(f)
+        output = OutputCapture(log)
+        with redirect_stdout(output), redirect_stderr(output):
+            # noinspection PyBroadException
+            try:
+                do_execute(module, log)
+            # want to catch everything since code execution may cause some weird behaviour
+            except BaseException:
+                log.event('error', text=f'Failed to execute code for module {module.__name__}\n'
+                                        f'{traceback.format_exc()}')
+        log.flush_output()
 
     # restore default signal handler for SIGALRM
     signal.signal(signal.SIGALRM, signal.SIG_DFL)
 
 
-def do_execute(module):
+def do_execute(module, log):
     """Best-effort execution of code in a module"""
-    print('[module]', module)
+    log.event('module', symbol=module.__name__)
 
     # Keep track of all types belonging to the module we've seen so far in return values,
     # so that we can recursively explore each one's methods without going in infinite loops.
@@ -147,34 +249,36 @@ def do_execute(module):
     skipped_names = []
     for (name, member) in inspect.getmembers(module):
         if inspect.isfunction(member):
-            return_value = try_invoke_function(member, name)
+            return_value = try_call(log, 'function', name, member)
             return_type = return_value.__class__
             # TODO should it be DFS or BFS?
             if should_investigate(return_type):
-                print('[investigate type]', return_type)
                 mark_seen(return_type)
-                try_call_methods(return_value, return_type, should_investigate, mark_seen)
+                try_call_methods(log, return_value, return_type.__qualname__, should_investigate, mark_seen)
         elif inspect.isclass(member):
-            instance = try_instantiate_class(member, name)
-            assert instance.__class__ == member
+            instance = try_call(log, 'class', name, member)
             if instance is not None and member not in instantiated_types:
                 instantiated_types.add(member)
-                try_call_methods(instance, name, should_investigate, mark_seen)
+                try_call_methods(log, instance, name, should_investigate, mark_seen)
         else:
             skipped_names.append(name)
 
-    print('[skipped members]', ' '.join(skipped_names))
+    log.event('skipped', text=' '.join(skipped_names))
+
+
+class ExecutionTimeout(BaseException):
+    """Raised when a call to package code takes too long. This is not a subclass of
+    Exception, so that package code is unlikely to catch it."""
 
 
 def alarm_handler(sig_num, frame):
-    raise TimeoutError('Timeout exceeded for function execution')
+    raise ExecutionTimeout('Timeout exceeded for function execution')
 
 
-# Call a function with mock arguments based on its declared signature.
+# Returns arguments to call a function with, based on its declared signature.
 # The arguments are of type MagicMock, whose instances will return
 # dummy values for any method called on them.
-# Exceptions must be handled by the caller.
-def invoke_function(obj):
+def mock_args(obj):
     signature = inspect.signature(obj)
     args = []
     kwargs = {}
@@ -194,84 +298,94 @@ def invoke_function(obj):
             case param.VAR_KEYWORD:  # when **args appears in signature
                 pass  # ignore
 
-    # bind args and invoke the function
-    # any exceptions will be propagated to the caller
-    bound = signature.bind(*args, **kwargs)
+    return signature.bind(*args, **kwargs)
 
+
+# Call a function with the given arguments, awaiting or exhausting the result if necessary.
+# Exceptions must be handled by the caller.
+def invoke_function(obj, bound):
     # set timeout to prevent hangs
     signal.alarm(EXECUTION_TIMEOUT_SECONDS)
+    try:
+        # run function and await the result if necessary
+        # ret_obj is the object returned by the function, which may need
+        # further evaluation / awaiting to produce the return value
+        ret_obj = obj(*bound.args, **bound.kwargs)
+        if inspect.isasyncgen(ret_obj):
+            # async generator - await in a loop
+            async def execute():
+                return [x async for x in ret_obj]
+            return asyncio.run(execute())
+        elif inspect.isgenerator(ret_obj):
+            # normal generator - execute in a loop
+            return [x for x in ret_obj]
+        elif inspect.iscoroutine(ret_obj):
+            # async function - await run
+            return asyncio.run(ret_obj)
+        else:
+            # normal function - just run
+            return ret_obj
+    finally:
+        signal.alarm(0)
 
-    # run function and await the result if necessary
-    # ret_obj is the object returned by the function, which may need
-    # further evaluation / awaiting to produce the return value
-    ret_obj = obj(*bound.args, **bound.kwargs)
-    if inspect.isasyncgen(ret_obj):
-        # async generator - await in a loop
-        async def execute():
-            return [x async for x in ret_obj]
-        ret_val = asyncio.run(execute())
-    elif inspect.isgenerator(ret_obj):
-        # normal generator - execute in a loop
-        ret_val = [x for x in ret_obj]
-    elif inspect.iscoroutine(ret_obj):
-        # async function - await run
-        ret_val = asyncio.run(ret_obj)
-    else:
-        # normal function - just run
-        ret_val = ret_obj
 
-    signal.alarm(0)
-    return ret_val
+# Returns a short description of a value, which fits on a single line
+def describe(value):
+    if isinstance(value, MagicMock):
+        return '<mock>'
+    # noinspection PyBroadException
+    try:
+        text = repr(value)
+    except BaseException:
+        text = f'<{type(value).__name__}>'
+    return text.replace('\r', ' ').replace('\n', ' ')[:MAX_DESCRIPTION_CHARS]
 
 
-# Execute a callable and catch any exception, logging to stdout
-def run_and_catch_all(c: callable):
+# Calls a function (or class) with mock arguments, logging the call and its outcome.
+# Returns the value returned by the call, or None if it failed.
+def try_call(log, kind, name, f):
+    fields = {}
+    ret = None
+    start = time.monotonic()
     try:
-        return c()
+        bound = mock_args(f)
+        fields['args'] = ', '.join(f'{k}={describe(v)}' for k, v in bound.arguments.items()) or None
+        ret = invoke_function(f, bound)
+        fields.update(outcome='returned', return_value=describe(ret))
+    except ExecutionTimeout:
+        fields['outcome'] = 'timeout'
     except BaseException as e:
         # catch ALL exceptions, including KeyboardInterrupt and system exit
-        print(type(e), e, sep=': ')
-
-
-def try_invoke_function(f, name, is_method=False):
-    print('[method]' if is_method else '[function]', name)
+        fields.update(outcome='raised', exception_type=type(e).__name__, exception_message=describe_exception(e))
+    fields['duration_ms'] = (time.monotonic() - start) * 1000
+    log.event(kind, symbol=name, **fields)
+    return ret
 
-    def invoke():
-        return invoke_function(f)
 
-    ret = run_and_catch_all(invoke)
-
-    if ret is not None:
-        print('[return value]', repr(ret))
-        return ret
-
-
-def try_instantiate_class(c, name):
-    print('[class]', name)
-
-    def instantiate():
-        return invoke_function(c)
-
-    return run_and_catch_all(instantiate)
+def describe_exception(e):
+    # noinspection PyBroadException
+    try:
+        return str(e).replace('\r', ' ').replace('\n', ' ')[:MAX_DESCRIPTION_CHARS]
+    except BaseException:
+        return ''
 
 
 # tries to call the methods of the given object instance
 # should_investigate and mark_seen are mutable input/output variables
 # that track which types have been traversed
-def try_call_methods(instance, class_name, should_investigate, mark_seen):
-    print('[instance methods]', class_name)
+def try_call_methods(log, instance, class_name, should_investigate, mark_seen):
+    log.event('instance', symbol=class_name)
 
     def is_non_init_method(m):
         return inspect.ismethod(m) and m.__name__ != '__init__'
 
     for method_name, method in inspect.getmembers(instance, is_non_init_method):
-        return_value = try_invoke_function(method, method_name, is_method=True)
+        return_value = try_call(log, 'method', f'{class_name}.{method_name}', method)
         return_type = return_value.__class__
         # TODO should it be DFS or BFS?
         if should_investigate(return_type):
-            print('[investigate type]', return_type)
             mark_seen(return_type)
-            try_call_methods(return_value, return_type, should_investigate, mark_seen)
+            try_call_methods(log, return_value, return_type.__qualname__, should_investigate, mark_seen)
 
 
 PHASES = {
@@ -1,8 +1,22 @@
 #!/usr/bin/env ruby
 #
 require 'find'
+require 'json'
 require 'open3'
 require 'pathname'
+require 'stringio'
+require 'timeout'
+
+EXECUTION_LOG_PATH = "/execution.log"
+EXECUTION_TIMEOUT_SECONDS = 10
+# Version of the execution log format, see "Execution log" in sandboxes/README.md.
+EXECUTION_LOG_VERSION = 1
+# Limits on the length of text written to the execution log
+MAX_DESCRIPTION_CHARS = 1000
+MAX_OUTPUT_CHARS = 64 * 1024
+
+# Set when packages should be installed from a registry other than rubygems.org.
+REGISTRY_URL = ENV["OSSF_REGISTRY_URL"]
 
 class Package
   attr_reader :name, :version, :local_file
@@ -22,6 +36,9 @@ def install(package)
       cmd << package.version
     end
     cmd << package.name
+    if REGISTRY_URL
+      cmd += ["--clear-sources", "--source", REGISTRY_URL]
+    end
   end
 
   output, status = Open3.capture2e(*cmd)
@@ -65,10 +82,185 @@ def importPkg(package)
   end
 end
 
+# Writes events to the execution log, one JSON object per line, in the format
+# described under "Execution log" in sandboxes/README.md.
+class ExecutionLog
+  # Output printed by the package code since the last event.
+  attr_reader :output
+
+  def initialize(file)
+    @file = file
+    @output = StringIO.new
+  end
+
+  def event(kind, **fields)
+    record = { version: EXECUTION_LOG_VERSION, kind: kind }
+    fields.each { |key, value| record[key] = value unless value.nil? }
+    @file.write(JSON.generate(record) + "\n")
+    flush_output
+  end
+
+  # Writes the output collected since the last event.
+  def flush_output
+    return if @output.string.empty?
+    text = @output.string[0, MAX_OUTPUT_CHARS]
+    @output.truncate(0)
+    @output.rewind
+    event("output", text: text.scrub)
+  end
+end
+
+# A Mock can be passed as any argument. Any method called on it returns another Mock.
+class Mock < BasicObject
+  def method_missing(name, *args, &block)
+    ::Mock.new
+  end
+
+  def respond_to_missing?(name, include_private = false)
+    true
+  end
+
+  def to_s
+    ""
+  end
+
+  def inspect
+    "<mock>"
+  end
+end
+
+# Returns the positional and keyword arguments to call the method with: a Mock for
+# each required parameter.
+def mock_args(method)
+  args = []
+  kwargs = {}
+  method.parameters.each do |type, name|
+    case type
+    when :req
+      args << Mock.new
+    when :keyreq
+      kwargs[name] = Mock.new
+    end
+  end
+  [args, kwargs]
+end
+
+# Returns a short description of the value that fits on a single line.
+def describe(value)
+  begin
+    text = value.inspect
+  rescue Exception => e
+    text = "<#{e.class} in inspect>"
+  end
+  single_line(text)
+end
+
+def single_line(text)
+  text.to_s.scrub.gsub(/[\r\n]+/, " ")[0, MAX_DESCRIPTION_CHARS]
+end
+
+# Returns a description of the arguments, or nil if there are none.
+def describe_args(args, kwargs)
+  descriptions = args.map { |arg| describe(arg) }
+  descriptions += kwargs.map { |name, arg| "#{name}: #{describe(arg)}" }
+  descriptions.empty? ? nil : descriptions.join(", ")
+end
+
+# Runs the block with the given arguments, then logs the call and its outcome.
+# Returns the value returned by the block, or nil if it failed.
+def try_execute(log, kind, symbol, args, kwargs)
+  fields = { symbol: symbol, args: describe_args(args, kwargs) }
+  ret = nil
+  start = Process.clock_gettime(Process::CLOCK_MONOTONIC)
+  begin
+    ret = Timeout.timeout(EXECUTION_TIMEOUT_SECONDS) { yield(*args, **kwargs) }
+    fields[:outcome] = "returned"
+    fields[:return_value] = describe(ret)
+  rescue Timeout::Error
+    fields[:outcome] = "timeout"
+  rescue Exception => e
+    # catch everything, including SystemExit and Interrupt
+    fields[:outcome] = "raised"
+    fields[:exception_type] = e.class.name || e.class.to_s
+    fields[:exception_message] = single_line(e.message)
+  end
+  fields[:duration_ms] = (Process.clock_gettime(Process::CLOCK_MONOTONIC) - start) * 1000
+  log.event(kind, **fields)
+  ret
+end
+
+def try_call(log, kind, symbol, method)
+  args, kwargs = mock_args(method)
+  try_execute(log, kind, symbol, args, kwargs) { |*a, **kw| method.call(*a, **kw) }
+end
+
+# Returns the named modules and classes that were first defined in the gem.
+def package_modules(spec)
+  gem_path = spec.full_gem_path + File::SEPARATOR
+  ObjectSpace.each_object(Module).select do |mod|
+    name = mod.name
+    begin
+      location = name && Object.const_source_location(name)
+    rescue Exception
+      location = nil
+    end
+    location && location[0] && location[0].start_with?(gem_path)
+  end.sort_by(&:name)
+end
+
+# Best-effort execution of the code in a module: calls its module methods, and if it
+# is a class, instantiates it and calls the instance methods.
+def execute_module(log, mod)
+  log.event("module", symbol: mod.name)
+
+  mod.singleton_methods(false).sort.each do |name|
+    try_call(log, "function", "#{mod.name}.#{name}", mod.method(name))
+  end
+
+  unless mod.is_a?(Class)
+    # instance methods of a module can only be called once it is included in a class
+    log.event("skipped", text: mod.instance_methods(false).sort.join(" "))
+    return
+  end
+
+  args, kwargs = mock_args(mod.instance_method(:initialize))
+  instance = try_execute(log, "class", mod.name, args, kwargs) { |*a, **kw| mod.new(*a, **kw) }
+  return if instance.nil?
+
+  log.event("instance", symbol: mod.name)
+  mod.public_instance_methods(false).sort.each do |name|
+    try_call(log, "method", "#{mod.name}##{name}", mod.instance_method(name).bind(instance))
+  end
+end
+
+def execute(package)
+  # if we're here, loading should have already worked during import phase
+  importPkg(package)
+  modules = package_modules(Gem::Specification.find_by_name(package.name))
+
+  # output of the package code is written to the log after the event for the
+  # call which printed it
+  File.open(EXECUTION_LOG_PATH, "a") do |file|
+    file.sync = true
+    log = ExecutionLog.new(file)
+    stdout, stderr = $stdout, $stderr
+    $stdout, $stderr = log.output, log.output
+    begin
+      modules.each { |mod| execute_module(log, mod) }
+    rescue Exception => e
+      log.event("error", text: single_line("Failed to execute code for #{package.name}: #{e.class}: #{e.message}"))
+    ensure
+      $stdout, $stderr = stdout, stderr
+      log.flush_output
+    end
+  end
+end
+
 phases = {
   "all" => [method(:install), method(:importPkg)],
   "install" => [method(:install)],
   "import" => [method(:importPkg)],
+  "execute" => [method(:execute)],
 }
 
 if ARGV.length < 2 || ARGV.length > 4
@@ -1,11 +1,28 @@
 #!/usr/bin/env python3
 from dataclasses import dataclass
+import json
 import os
+import re
 import sys
 import subprocess
 import traceback
 from typing import Optional
 
+# Set when packages should be installed from a registry other than crates.io.
+# The registry must serve a sparse index under /index/. If it requires credentials,
+# this is the URL of a proxy which adds them to requests.
+REGISTRY_URL = os.environ.get('OSSF_REGISTRY_URL')
+
+EXECUTION_LOG_PATH = '/execution.log'
+# Version of the execution log format, see "Execution log" in sandboxes/README.md.
+EXECUTION_LOG_VERSION = 1
+# Limit on the length of output written to the execution log in a single event.
+MAX_OUTPUT_CHARS = 64 * 1024
+
+# Matches the result of a single test in the output of cargo test, e.g.
+# test tests::it_works ... ok
+TEST_RESULT_PATTERN = re.compile(r'^test (\S+) \.\.\. (\w+)')
+
 @dataclass
 class Package:
     """Class for tracking a package."""
@@ -21,9 +38,22 @@ class Package:
       else:
         return f'{self.name} = "*"'
 
+def configure_registry():
+    """Replace crates.io with the configured registry."""
+    os.makedirs('.cargo', exist_ok=True)
+    with open(os.path.join('.cargo', 'config.toml'), 'a') as handle:
+      handle.write('[source.crates-io]\nreplace-with = "mirror"\n\n')
+      handle.write(f'[registries.mirror]\nindex = "sparse+{REGISTRY_URL}/index/"\n')
+      # cargo requires a token for registries which require authentication, but the
+      # proxy replaces it with the real credentials.
+      handle.write('token = "placeholder"\n')
+
 def install(package: Package):
     """Cargo build."""
     try:
+      if REGISTRY_URL:
+        configure_registry()
+
       with open("Cargo.toml", 'a') as handle:
         handle.write(package.get_dependency_line() + '\n')
         handle.flush()
@@ -55,10 +85,77 @@ def importPkg(package: Package):
       print(e.output.decode())
       traceback.print_exc()
 
+def crate_manifest_path(package: Package):
+    """Returns the path of Cargo.toml of the installed crate."""
+    output = subprocess.check_output(['cargo', 'metadata', '--format-version', '1'])
+    for p in json.loads(output)['packages']:
+      if p['name'] == package.name:
+        return p['manifest_path']
+    raise ValueError(f'Crate {package.name} is not installed')
+
+class ExecutionLog:
+    """Writes events to the execution log, one JSON object per line, in the format
+    described under "Execution log" in sandboxes/README.md."""
+
+    def __init__(self, file):
+      self.file = file
+      # output of cargo since the last event
+      self.output = []
+
+    def event(self, kind, **fields):
+      record = {'version': EXECUTION_LOG_VERSION, 'kind': kind}
+      record.update((k, v) for k, v in fields.items() if v is not None)
+      self.file.write(json.dumps(record) + '\n')
+      self.flush_output()
+
+    def flush_output(self):
+      """Writes the output collected since the last event."""
+      if self.output:
+        text = ''.join(self.output)[:MAX_OUTPUT_CHARS]
+        self.output = []
+        self.event('output', text=text)
+
+def execute(package: Package):
+    """Run the tests of the crate, which is the best way to call its code without
+    knowing the types of its functions.
+
+    The result of each test is written to the execution log as a function call,
+    and any other output of cargo is written as output.
+    """
+    with open(EXECUTION_LOG_PATH, 'at') as file:
+      log = ExecutionLog(file)
+      try:
+        manifest_path = crate_manifest_path(package)
+        log.event('module', symbol=package.name)
+        args = ['cargo', 'test', '--manifest-path', manifest_path,
+                '--target-dir', os.path.join(os.getcwd(), 'target'), '--no-fail-fast']
+        proc = subprocess.Popen(args, stdout=subprocess.PIPE, stderr=subprocess.STDOUT, text=True, errors='replace')
+        skipped = []
+        for line in proc.stdout:
+          match = TEST_RESULT_PATTERN.match(line)
+          if not match:
+            log.output.append(line)
+          elif match.group(2) == 'ignored':
+            skipped.append(match.group(1))
+          elif match.group(2) == 'ok':
+            log.event('function', symbol=match.group(1), outcome='returned')
+          else:
+            log.event('function', symbol=match.group(1), outcome='raised',
+                      exception_type='TestFailure', exception_message=f'test {match.group(2)}')
+        proc.wait()
+        log.event('skipped', text=' '.join(skipped))
+      # want to catch everything since code execution may cause some weird behaviour
+      except BaseException as e:
+        log.event('error', text=f'Failed to execute code for crate {package.name}: {type(e).__name__}: {e}')
+        traceback.print_exc()
+      finally:
+        log.flush_output()
+
 PHASES = {
     "all": [install, importPkg],
     "install": [install],
     "import": [importPkg],
+    "execute": [execute],
 }
 
 def main():
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -11,6 +12,8 @@ import (
 	"path/filepath"
 	"time"
 
+	"golang.org/x/exp/slices"
+
 	"github.com/ossf/package-analysis/internal/log"
 	"github.com/ossf/package-analysis/internal/pkgmanager"
 	"github.com/ossf/package-analysis/internal/staticanalysis"
@@ -20,16 +23,21 @@ import (
 	"github.com/ossf/package-analysis/internal/utils"
 	"github.com/ossf/package-analysis/internal/worker"
 	"github.com/ossf/package-analysis/pkg/api/pkgecosystem"
+	api "github.com/ossf/package-analysis/pkg/api/staticanalysis"
 )
 
 var (
-	ecosystem   pkgecosystem.Ecosystem
-	packageName = flag.String("package", "", "package name (required)")
-	version     = flag.String("version", "", "package version (ignored if local file is specified)")
-	localFile   = flag.String("local", "", "local package archive containing package to be analysed. Name must match -package argument")
-	output      = flag.String("output", "", "where to write output JSON results (default stdout)")
-	help        = flag.Bool("help", false, "prints this help and list of available analyses")
-	analyses    = utils.CommaSeparatedFlags("analyses", []string{"all"}, "comma-separated list of static analysis tasks to perform")
+	ecosystem        pkgecosystem.Ecosystem
+	archiveType      pkgmanager.ArchiveType
+	packageName      = flag.String("package", "", "package name (required)")
+	version          = flag.String("version", "", "package version (ignored if local file is specified)")
+	localFile        = flag.String("local", "", "local package archive containing package to be analysed. Name must match -package argument")
+	localDigest      = flag.String("local-digest", "", "expected digest of the local package archive, as algorithm:hex. If set, the archive is verified against it")
+	output           = flag.String("output", "", "where to write output JSON results (default stdout)")
+	registryURLs     = flag.String("registry-urls", os.Getenv(pkgmanager.RegistryURLsEnvVar), "comma-separated list of ecosystem=URL pairs, overriding the default registry URL for each ecosystem (default $"+pkgmanager.RegistryURLsEnvVar+")")
+	registryAuthFile = flag.String("registry-auth-file", os.Getenv(pkgmanager.RegistryAuthFileEnvVar), "JSON file containing registry credentials for each ecosystem (default $"+pkgmanager.RegistryAuthFileEnvVar+")")
+	help             = flag.Bool("help", false, "prints this help and list of available analyses")
+	analyses         = utils.CommaSeparatedFlags("analyses", []string{"all"}, "comma-separated list of static analysis tasks to perform")
 )
 
 type workDirs struct {
@@ -100,6 +108,123 @@ func makeWorkDirs() (workDirs, error) {
 	}, nil
 }
 
+// downloadedArchive is a package archive that has been saved to the local filesystem.
+type downloadedArchive struct {
+	pkgmanager.Archive
+	path string
+}
+
+// downloadArchives downloads each of the archives of the given type for the package.
+func downloadArchives(ctx context.Context, manager *pkgmanager.PkgManager, pkg *pkgmanager.Pkg, archiveType pkgmanager.ArchiveType, dir string) ([]downloadedArchive, error) {
+	archives, err := manager.Archives(ctx, pkg.Name(), pkg.Version(), archiveType)
+	if err != nil {
+		return nil, err
+	}
+
+	var downloaded []downloadedArchive
+	for _, a := range archives {
+		path, err := manager.Download(ctx, a, dir)
+		if err != nil {
+			return nil, err
+		}
+		downloaded = append(downloaded, downloadedArchive{Archive: a, path: path})
+	}
+
+	return downloaded, nil
+}
+
+// localArchive returns the local archive at the given path. If digest is not empty,
+// the archive is verified against it.
+func localArchive(path, digest string) ([]downloadedArchive, error) {
+	archive := pkgmanager.Archive{Filename: filepath.Base(path)}
+	if digest != "" {
+		d, err := pkgmanager.ParseDigest(digest)
+		if err != nil {
+			return nil, err
+		}
+		archive.Digest = d
+		if err := pkgmanager.VerifyDigest(archive, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return []downloadedArchive{{Archive: archive, path: path}}, nil
+}
+
+// analyzeArchive collects basic data about the archive file itself. Errors are logged
+// but otherwise ignored, in which case the result contains only the archive filename and type.
+func analyzeArchive(ctx context.Context, a downloadedArchive) staticanalysis.ArchiveResult {
+	result := staticanalysis.ArchiveResult{
+		Filename: a.Filename,
+		Type:     string(a.Type),
+	}
+	if !a.Digest.IsZero() {
+		// hash the file that is analysed, which may have been provided locally
+		// or from a cache rather than downloaded and verified here
+		if actual, err := pkgmanager.ComputeDigest(a.path, a.Digest.Algorithm); err != nil {
+			slog.WarnContext(ctx, "failed to compute archive digest", "filename", a.Filename, "error", err)
+		} else {
+			result.Checksum = checksumResult(a.Digest, actual)
+		}
+	}
+
+	archiveResult, err := basicdata.Analyze(ctx, []string{a.path},
+		basicdata.SkipLineLengths(),
+		basicdata.FormatPaths(func(absPath string) string { return "/" }),
+	)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to analyze archive file", "filename", a.Filename, "error", err)
+	} else if len(archiveResult) != 1 {
+		slog.WarnContext(ctx, "archive file analysis: unexpected number of results", "filename", a.Filename, "len", len(archiveResult))
+	} else {
+		archiveInfo := archiveResult[0]
+		result.DetectedType = archiveInfo.DetectedType
+		result.Size = archiveInfo.Size
+		result.SHA256 = archiveInfo.SHA256
+	}
+
+	return result
+}
+
+// checksumResult records the verification of an archive against the expected digest.
+func checksumResult(expected, actual pkgmanager.Digest) *api.ArchiveChecksum {
+	return &api.ArchiveChecksum{
+		Algorithm: string(expected.Algorithm),
+		Expected:  expected.Value,
+		Actual:    actual.Value,
+		Verified:  expected == actual,
+	}
+}
+
+// writeResults serialises the results as JSON to the output file, or stdout if none was specified.
+func writeResults(ctx context.Context, results staticanalysis.Result) error {
+	jsonResult, err := json.Marshal(results)
+	if err != nil {
+		slog.WarnContext(ctx, fmt.Sprintf("unserialisable JSON: %v", results))
+		return fmt.Errorf("JSON marshal error: %w", err)
+	}
+
+	outputFile := os.Stdout
+	if *output != "" {
+		outputFile, err = os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("could not open/create output file %s: %w", *output, err)
+		}
+
+		defer func() {
+			if err := outputFile.Close(); err != nil {
+				slog.WarnContext(ctx, "could not close output file", "path", *output, "error", err)
+			}
+		}()
+	}
+
+	if _, writeErr := outputFile.Write(jsonResult); writeErr != nil {
+		return fmt.Errorf("could not write JSON results: %w", writeErr)
+	}
+
+	return nil
+}
+
 func run() (err error) {
 	startTime := time.Now()
 
@@ -109,6 +234,7 @@ func run() (err error) {
 	http.DefaultTransport = useragent.DefaultRoundTripper(http.DefaultTransport, userAgentExtra)
 
 	flag.TextVar(&ecosystem, "ecosystem", pkgecosystem.None, fmt.Sprintf("package ecosystem. Can be %s (required)", pkgecosystem.SupportedEcosystemsStrings))
+	flag.TextVar(&archiveType, "archive-type", pkgmanager.DefaultArchive, "type of package archive to analyze, for ecosystems with more than one. Can be default, sdist, wheel or all (ignored if local file is specified)")
 	analyses.InitFlag()
 	flag.Parse()
 
@@ -124,12 +250,16 @@ func run() (err error) {
 		return fmt.Errorf("ecosystem and package are required arguments")
 	}
 
+	if err := pkgmanager.ConfigureRegistries(*registryURLs, *registryAuthFile); err != nil {
+		return err
+	}
+
 	manager := pkgmanager.Manager(ecosystem)
 	if manager == nil {
 		return fmt.Errorf("unsupported pkg manager for ecosystem %s", ecosystem)
 	}
 
-	pkg, err := worker.ResolvePkg(manager, *packageName, *version, *localFile)
+	pkg, err := worker.ResolvePkg(context.Background(), manager, *packageName, *version, *localFile)
 	if err != nil {
 		return fmt.Errorf("package error: %w", err)
 	}
@@ -150,6 +280,9 @@ func run() (err error) {
 		"local_path", *localFile,
 		"output_file", *output,
 		"analyses", analysisTasks,
+		"archive_type", archiveType,
+		"registry_url", manager.RegistryURL(),
+		"registry_auth", manager.RegistryAuth(),
 		"user_agent_extra", userAgentExtra)
 
 	workDirs, err := makeWorkDirs()
@@ -160,44 +293,51 @@ func run() (err error) {
 
 	startDownloadTime := time.Now()
 
-	var archivePath string
+	results := staticanalysis.Result{}
+
+	var archives []downloadedArchive
 	if *localFile != "" {
-		archivePath = *localFile
+		archives, err = localArchive(*localFile, *localDigest)
 	} else {
-		archivePath, err = manager.DownloadArchive(pkg.Name(), pkg.Version(), workDirs.archiveDir)
-		if err != nil {
-			return fmt.Errorf("error downloading archive: %w", err)
+		archives, err = downloadArchives(ctx, manager, pkg, archiveType, workDirs.archiveDir)
+	}
+	if err != nil {
+		var mismatch *pkgmanager.ChecksumMismatchError
+		if errors.As(err, &mismatch) {
+			// the archive is not analyzed, but the mismatch is reported in the results
+			results.Archives = append(results.Archives, staticanalysis.ArchiveResult{
+				Filename: mismatch.Archive.Filename,
+				Type:     string(mismatch.Archive.Type),
+				Checksum: checksumResult(mismatch.Archive.Digest, mismatch.Actual),
+			})
+			if writeErr := writeResults(ctx, results); writeErr != nil {
+				slog.ErrorContext(ctx, "failed to write results", "error", writeErr)
+			}
 		}
+		return fmt.Errorf("error downloading archive: %w", err)
 	}
 
 	downloadTime := time.Since(startDownloadTime)
 
-	results := staticanalysis.Result{}
-
 	startArchiveAnalysisTime := time.Now()
-	archiveResult, err := basicdata.Analyze(ctx, []string{archivePath},
-		basicdata.SkipLineLengths(),
-		basicdata.FormatPaths(func(absPath string) string { return "/" }),
-	)
-	if err != nil {
-		slog.WarnContext(ctx, "failed to analyze archive file", "error", err)
-	} else if len(archiveResult) != 1 {
-		slog.WarnContext(ctx, "archive file analysis: unexpected number of results", "len", len(archiveResult))
-	} else {
-		archiveInfo := archiveResult[0]
-		results.Archive = staticanalysis.ArchiveResult{
-			DetectedType: archiveInfo.DetectedType,
-			Size:         archiveInfo.Size,
-			SHA256:       archiveInfo.SHA256,
-		}
+	for _, a := range archives {
+		results.Archives = append(results.Archives, analyzeArchive(ctx, a))
 	}
 
 	archiveAnalysisTime := time.Since(startArchiveAnalysisTime)
 
 	startExtractionTime := time.Now()
 
-	if err := manager.ExtractArchive(archivePath, workDirs.extractDir); err != nil {
-		return fmt.Errorf("archive extraction failed: %w", err)
+	for _, a := range archives {
+		// When there are multiple archives, each is extracted into its own
+		// subdirectory so that the files from different archives don't collide.
+		extractDir := workDirs.extractDir
+		if len(archives) > 1 {
+			extractDir = filepath.Join(workDirs.extractDir, a.Filename)
+		}
+		if err := manager.ExtractArchive(a.path, extractDir); err != nil {
+			return fmt.Errorf("archive extraction failed (%s): %w", a.Filename, err)
+		}
 	}
 
 	extractionTime := time.Since(startExtractionTime)
@@ -214,31 +354,19 @@ func run() (err error) {
 	}
 	results.Files = fileResults
 
-	analysisTime := time.Since(startAnalysisTime)
-	startWritingResultsTime := time.Now()
-
-	jsonResult, err := json.Marshal(results)
-	if err != nil {
-		slog.WarnContext(ctx, fmt.Sprintf("unserialisable JSON: %v", results))
-		return fmt.Errorf("JSON marshal error: %w", err)
-	}
-
-	outputFile := os.Stdout
-	if *output != "" {
-		outputFile, err = os.Create(*output)
+	if slices.Contains(analysisTasks, staticanalysis.Manifest) {
+		manifests, err := staticanalysis.AnalyzeManifests(ctx, workDirs.extractDir)
 		if err != nil {
-			return fmt.Errorf("could not open/create output file %s: %w", *output, err)
+			return fmt.Errorf("static analysis error: %w", err)
 		}
-
-		defer func() {
-			if err := outputFile.Close(); err != nil {
-				slog.WarnContext(ctx, "could not close output file", "path", *output, "error", err)
-			}
-		}()
+		results.Manifests = manifests
 	}
 
-	if _, writeErr := outputFile.Write(jsonResult); writeErr != nil {
-		return fmt.Errorf("could not write JSON results: %w", writeErr)
+	analysisTime := time.Since(startAnalysisTime)
+	startWritingResultsTime := time.Now()
+
+	if err := writeResults(ctx, results); err != nil {
+		return err
 	}
 
 	writingResultsTime := time.Since(startWritingResultsTime)
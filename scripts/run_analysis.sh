@@ -4,6 +4,7 @@ RESULTS_DIR=${RESULTS_DIR:-"/tmp/results"}
 STATIC_RESULTS_DIR=${STATIC_RESULTS_DIR:-"/tmp/staticResults"}
 FILE_WRITE_RESULTS_DIR=${FILE_WRITE_RESULTS_DIR:-"/tmp/writeResults"}
 ANALYZED_PACKAGES_DIR=${ANALYZED_PACKAGES_DIR:-"/tmp/analyzedPackages"}
+METADATA_RESULTS_DIR=${METADATA_RESULTS_DIR:-"/tmp/metadataResults"}
 LOGS_DIR=${LOGS_DIR:-"/tmp/dockertmp"}
 STRACE_LOGS_DIR=${STRACE_LOGS_DIR:-"/tmp/straceLogs"}
 
@@ -45,6 +46,7 @@ function print_results_dirs {
 	echo "Static analysis results:  $STATIC_RESULTS_DIR"
 	echo "File write results:       $FILE_WRITE_RESULTS_DIR"
 	echo "Analyzed package saved:   $ANALYZED_PACKAGES_DIR"
+	echo "Package metadata:         $METADATA_RESULTS_DIR"
 	echo "Debug logs:               $LOGS_DIR"
 	echo "Strace logs:              $STRACE_LOGS_DIR"
 }
@@ -63,6 +65,8 @@ PACKAGE=""
 VERSION=""
 PKG_PATH=""
 MOUNTED_PKG_PATH=""
+PURLS_PATH=""
+MOUNTED_PURLS_PATH=""
 
 i=0
 while [[ $i -lt $# ]]; do
@@ -98,6 +102,15 @@ while [[ $i -lt $# ]]; do
 			# which is stripped of host path info
 			args[$i]="$MOUNTED_PKG_PATH"
 			;;
+		"-purls")
+			# need to create a mount to pass the purl list to the docker image
+			i=$((i+1))
+			if [[ "${args[$i]}" != "-" ]]; then
+				PURLS_PATH=$(realpath -m "${args[$i]}")
+				MOUNTED_PURLS_PATH="/$(basename "$PURLS_PATH")"
+				args[$i]="$MOUNTED_PURLS_PATH"
+			fi
+			;;
 		"-ecosystem")
 			i=$((i+1))
 			ECOSYSTEM="${args[$i]}"
@@ -153,11 +166,11 @@ elif is_mount_type overlay /var/lib; then
 fi
 
 
-DOCKER_MOUNTS=("-v" "$CONTAINER_MOUNT_DIR:/var/lib/containers" "-v" "$RESULTS_DIR:/results" "-v" "$STATIC_RESULTS_DIR:/staticResults" "-v" "$FILE_WRITE_RESULTS_DIR:/writeResults" "-v" "$LOGS_DIR:/tmp" "-v" "$ANALYZED_PACKAGES_DIR:/analyzedPackages" "-v" "$STRACE_LOGS_DIR:/straceLogs")
+DOCKER_MOUNTS=("-v" "$CONTAINER_MOUNT_DIR:/var/lib/containers" "-v" "$RESULTS_DIR:/results" "-v" "$STATIC_RESULTS_DIR:/staticResults" "-v" "$FILE_WRITE_RESULTS_DIR:/writeResults" "-v" "$LOGS_DIR:/tmp" "-v" "$ANALYZED_PACKAGES_DIR:/analyzedPackages" "-v" "$METADATA_RESULTS_DIR:/metadataResults" "-v" "$STRACE_LOGS_DIR:/straceLogs")
 
 ANALYSIS_IMAGE=gcr.io/ossf-malware-analysis/analysis
 
-ANALYSIS_ARGS=("analyze" "-dynamic-bucket" "file:///results/" "-file-writes-bucket" "file:///writeResults/" "-static-bucket" "file:///staticResults/" "-analyzed-pkg-bucket" "file:///analyzedPackages/" "-execution-log-bucket" "file:///results")
+ANALYSIS_ARGS=("analyze" "-dynamic-bucket" "file:///results/" "-file-writes-bucket" "file:///writeResults/" "-static-bucket" "file:///staticResults/" "-analyzed-pkg-bucket" "file:///analyzedPackages/" "-execution-log-bucket" "file:///results" "-dependencies-bucket" "file:///results" "-profile-comparison-bucket" "file:///results" "-honeytokens-bucket" "file:///results" "-metadata-bucket" "file:///metadataResults/")
 
 # Add the remaining command line arguments
 ANALYSIS_ARGS=("${ANALYSIS_ARGS[@]}" "${args[@]}")
@@ -179,6 +192,10 @@ else
 	LOCATION="remote"
 fi
 
+if [[ -n "$PURLS_PATH" ]]; then
+	DOCKER_MOUNTS+=("-v" "$PURLS_PATH:$MOUNTED_PURLS_PATH")
+fi
+
 if [[ $DOCKER_OFFLINE -eq 1 ]]; then
 	DOCKER_OPTS+=("--network" "none")
 fi
@@ -218,12 +235,19 @@ if [[ $LOCAL -eq 1 ]] && [[ ! -f "$PKG_PATH" || ! -r "$PKG_PATH" ]]; then
 	exit 1
 fi
 
+if [[ -n "$PURLS_PATH" ]] && [[ ! -f "$PURLS_PATH" || ! -r "$PURLS_PATH" ]]; then
+	echo "Error: path $PURLS_PATH does not refer to a file or is not readable"
+	echo
+	exit 1
+fi
+
 sleep 1 # Allow time to read info above before executing
 
 mkdir -p "$RESULTS_DIR"
 mkdir -p "$STATIC_RESULTS_DIR"
 mkdir -p "$FILE_WRITE_RESULTS_DIR"
 mkdir -p "$ANALYZED_PACKAGES_DIR"
+mkdir -p "$METADATA_RESULTS_DIR"
 mkdir -p "$LOGS_DIR"
 mkdir -p "$STRACE_LOGS_DIR"
 
@@ -249,6 +273,7 @@ echo $LINE
 		rmdir --ignore-fail-on-non-empty "$STATIC_RESULTS_DIR"
 		rmdir --ignore-fail-on-non-empty "$FILE_WRITE_RESULTS_DIR"
 		rmdir --ignore-fail-on-non-empty "$ANALYZED_PACKAGES_DIR"
+		rmdir --ignore-fail-on-non-empty "$METADATA_RESULTS_DIR"
 		rmdir --ignore-fail-on-non-empty "$LOGS_DIR"
 		rmdir --ignore-fail-on-non-empty "$STRACE_LOGS_DIR"
 	fi